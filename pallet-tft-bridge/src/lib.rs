@@ -51,6 +51,15 @@ pub struct BurnTransaction<BlockNumber> {
     pub sequence_number: u64,
 }
 
+// BurnTransactionReset holds the validators that reported the signatures of a burn transaction
+// as stale for a sequence number, the signatures are reset once (number of validators / 2) + 1
+// validators reported them
+#[derive(PartialEq, Eq, PartialOrd, Ord, Clone, Encode, Decode, Debug, TypeInfo)]
+pub struct BurnTransactionReset<AccountId> {
+    pub sequence_number: u64,
+    pub validators: Vec<AccountId>,
+}
+
 #[derive(PartialEq, Eq, PartialOrd, Ord, Clone, Encode, Decode, Default, Debug, TypeInfo)]
 pub struct RefundTransaction<BlockNumber> {
     pub block: BlockNumber,
@@ -124,6 +133,11 @@ pub mod pallet {
     pub type ExecutedBurnTransactions<T: Config> =
         StorageMap<_, Blake2_128Concat, u64, BurnTransaction<T::BlockNumber>, ValueQuery>;
 
+    #[pallet::storage]
+    #[pallet::getter(fn burn_transaction_resets)]
+    pub type BurnTransactionResets<T: Config> =
+        StorageMap<_, Blake2_128Concat, u64, BurnTransactionReset<T::AccountId>, OptionQuery>;
+
     #[pallet::storage]
     #[pallet::getter(fn refund_transactions)]
     pub type RefundTransactions<T: Config> =
@@ -210,6 +224,7 @@ pub mod pallet {
         AmountIsLessThanDepositFee,
         WrongParametersProvided,
         InvalidStellarPublicKey,
+        BurnResetExists,
     }
 
     #[pallet::genesis_config]
@@ -432,6 +447,17 @@ pub mod pallet {
             let validator = ensure_signed(origin)?;
             Self::set_stellar_refund_transaction_executed(validator, tx_hash)
         }
+
+        #[pallet::call_index(11)]
+        #[pallet::weight(10_000)]
+        pub fn reset_burn_transaction(
+            origin: OriginFor<T>,
+            transaction_id: u64,
+            sequence_number: u64,
+        ) -> DispatchResultWithPostInfo {
+            let validator = ensure_signed(origin)?;
+            Self::reset_stellar_burn_transaction(validator, transaction_id, sequence_number)
+        }
     }
 }
 
@@ -751,6 +777,63 @@ impl<T: Config> Pallet<T> {
         Ok(().into())
     }
 
+    // votes to reset the signatures of a burn transaction that were collected for a stellar sequence
+    // number that can no longer be used. Once a majority of the validators voted for the same sequence
+    // number the transaction is expired so validators sign it again right away. Only signatures for
+    // sequence_number are reset so late votes for a stale sequence number do not reset the signatures
+    // collected after the reset.
+    pub fn reset_stellar_burn_transaction(
+        validator: T::AccountId,
+        tx_id: u64,
+        sequence_number: u64,
+    ) -> DispatchResultWithPostInfo {
+        Self::check_if_validator_exists(validator.clone())?;
+
+        ensure!(
+            !ExecutedBurnTransactions::<T>::contains_key(tx_id),
+            Error::<T>::BurnTransactionAlreadyExecuted
+        );
+        ensure!(
+            BurnTransactions::<T>::contains_key(tx_id),
+            Error::<T>::BurnTransactionNotExists
+        );
+
+        let mut tx = BurnTransactions::<T>::get(tx_id);
+        if tx.signatures.is_empty() || tx.sequence_number != sequence_number {
+            return Ok(().into());
+        }
+
+        // votes for another sequence number are for signatures that were reset or expired already
+        let mut reset = match BurnTransactionResets::<T>::get(tx_id) {
+            Some(reset) if reset.sequence_number == sequence_number => reset,
+            _ => BurnTransactionReset {
+                sequence_number,
+                validators: Vec::new(),
+            },
+        };
+        ensure!(
+            !reset.validators.contains(&validator),
+            Error::<T>::BurnResetExists
+        );
+        reset.validators.push(validator);
+
+        let validators = Validators::<T>::get();
+        if reset.validators.len() < (validators.len() / 2) + 1 {
+            BurnTransactionResets::<T>::insert(tx_id, &reset);
+            return Ok(().into());
+        }
+
+        BurnTransactionResets::<T>::remove(tx_id);
+        tx.signatures = Vec::new();
+        tx.sequence_number = 0;
+        tx.block = <frame_system::Pallet<T>>::block_number();
+        BurnTransactions::<T>::insert(tx_id, &tx);
+
+        Self::deposit_event(Event::BurnTransactionExpired(tx_id, tx.target, tx.amount));
+
+        Ok(().into())
+    }
+
     pub fn set_stellar_burn_transaction_executed(
         validator: T::AccountId,
         tx_id: u64,
@@ -769,6 +852,7 @@ impl<T: Config> Pallet<T> {
         let tx = BurnTransactions::<T>::get(tx_id);
 
         BurnTransactions::<T>::remove(tx_id);
+        BurnTransactionResets::<T>::remove(tx_id);
         ExecutedBurnTransactions::<T>::insert(tx_id, &tx);
 
         Self::deposit_event(Event::BurnTransactionProcessed(tx));
@@ -516,6 +516,112 @@ fn burn_flow_expired() {
     });
 }
 
+#[test]
+fn reset_stale_burn_transaction_works() {
+    new_test_ext().execute_with(|| {
+        prepare_validators();
+
+        assert_ok!(TFTBridgeModule::swap_to_stellar(
+            Origin::signed(bob()),
+            "GBIYYEQO73AYJEADTHMTF5M42WICTHU55IIT2CPEZBBLLDSJ322OGW7Z"
+                .as_bytes()
+                .to_vec(),
+            2000000000
+        ));
+
+        assert_ok!(TFTBridgeModule::propose_burn_transaction_or_add_sig(
+            Origin::signed(alice()),
+            1,
+            "GBIYYEQO73AYJEADTHMTF5M42WICTHU55IIT2CPEZBBLLDSJ322OGW7Z"
+                .as_bytes()
+                .to_vec(),
+            1500000000,
+            "alice_sig".as_bytes().to_vec(),
+            "alice_stellar_pubkey".as_bytes().to_vec(),
+            1
+        ));
+
+        // a reset for another sequence number leaves the signatures
+        assert_ok!(TFTBridgeModule::reset_burn_transaction(
+            Origin::signed(bob()),
+            1,
+            2
+        ));
+        let burn_tx = TFTBridgeModule::burn_transactions(1);
+        assert_eq!(burn_tx.signatures.len(), 1);
+        assert_eq!(burn_tx.sequence_number, 1);
+
+        // the signatures are only reset once a majority of the validators reported them stale
+        assert_ok!(TFTBridgeModule::reset_burn_transaction(
+            Origin::signed(bob()),
+            1,
+            1
+        ));
+        assert_noop!(
+            TFTBridgeModule::reset_burn_transaction(Origin::signed(bob()), 1, 1),
+            Error::<TestRuntime>::BurnResetExists
+        );
+        assert_ok!(TFTBridgeModule::reset_burn_transaction(
+            Origin::signed(eve()),
+            1,
+            1
+        ));
+        let burn_tx = TFTBridgeModule::burn_transactions(1);
+        assert_eq!(burn_tx.signatures.len(), 1);
+        assert_eq!(burn_tx.sequence_number, 1);
+
+        assert_ok!(TFTBridgeModule::reset_burn_transaction(
+            Origin::signed(ferdie()),
+            1,
+            1
+        ));
+        let burn_tx = TFTBridgeModule::burn_transactions(1);
+        assert_eq!(burn_tx.signatures.len(), 0);
+        assert_eq!(burn_tx.sequence_number, 0);
+        assert_eq!(TFTBridgeModule::burn_transaction_resets(1), None);
+
+        // signatures for the fresh sequence number are not reset by a late reset of the stale one
+        assert_ok!(TFTBridgeModule::propose_burn_transaction_or_add_sig(
+            Origin::signed(alice()),
+            1,
+            "GBIYYEQO73AYJEADTHMTF5M42WICTHU55IIT2CPEZBBLLDSJ322OGW7Z"
+                .as_bytes()
+                .to_vec(),
+            1500000000,
+            "alice_sig_2".as_bytes().to_vec(),
+            "alice_stellar_pubkey".as_bytes().to_vec(),
+            2
+        ));
+        assert_ok!(TFTBridgeModule::reset_burn_transaction(
+            Origin::signed(eve()),
+            1,
+            1
+        ));
+        let burn_tx = TFTBridgeModule::burn_transactions(1);
+        assert_eq!(burn_tx.signatures.len(), 1);
+        assert_eq!(burn_tx.sequence_number, 2);
+        assert_eq!(TFTBridgeModule::burn_transaction_resets(1), None);
+    });
+}
+
+#[test]
+fn reset_burn_transaction_without_being_validator_fails() {
+    new_test_ext().execute_with(|| {
+        prepare_validators();
+
+        assert_noop!(
+            TFTBridgeModule::reset_burn_transaction(Origin::signed(bob()), 1, 1),
+            Error::<TestRuntime>::BurnTransactionNotExists
+        );
+
+        TFTBridgeModule::remove_bridge_validator(RawOrigin::Root.into(), bob()).unwrap();
+        assert_noop!(
+            TFTBridgeModule::reset_burn_transaction(Origin::signed(bob()), 1, 1),
+            Error::<TestRuntime>::ValidatorNotExists
+        );
+    });
+}
+
 #[test]
 fn burn_fails_if_less_than_withdraw_fee_amount() {
     new_test_ext().execute_with(|| {
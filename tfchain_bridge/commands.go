@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+	"github.com/stellar/go/amount"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	"github.com/threefoldtech/tfchain_bridge/pkg/bridge"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+const usage = `commands:
+  retry-refund <stellar_tx_hash>  issue the refund of a stellar transaction again
+  trace <stellar_tx_hash>         show how the bridge handled a deposit on the bridge account
+  inspect-burn <withdraw_id>      verify the signatures collected for a withdraw
+  held list                       show the deposits and withdraws held for manual handling
+  held release <stellar_tx_hash>  mint a held deposit to its target, run it on every validator so the mint gets its votes
+  held refund <stellar_tx_hash>   refund a held deposit to its sender
+  doctor                          check the connectivity to tfchain and horizon with the current configuration
+  init-stellar [--submit] <threshold> <signer>...
+                                  configure the validator signers and thresholds of the bridge account
+  export --from <date> --to <date> [--format csv|json]
+                                  export the entries of the accounting ledger in a date range, --to is inclusive for a date
+                                  and exclusive for a time (dates are YYYY-MM-DD, times RFC3339)
+
+flags of the bridge go before the command, flags after the command are flags of the command`
+
+// runCommand runs a one-off operator command instead of the bridge daemon and returns the process exit code
+func runCommand(ctx context.Context, cfg pkg.BridgeConfig, args []string) int {
+	var err error
+	switch args[0] {
+	case "retry-refund":
+		err = retryRefund(ctx, cfg, args[1:])
+	case "trace":
+		err = trace(ctx, cfg, args[1:])
+	case "inspect-burn":
+		err = inspectBurn(ctx, cfg, args[1:])
+	case "held":
+		err = held(ctx, cfg, args[1:])
+	case "doctor":
+		err = doctor(ctx, cfg)
+	case "init-stellar":
+		err = initStellar(ctx, cfg, args[1:])
+	case "export":
+		err = export(cfg, args[1:])
+	default:
+		err = fmt.Errorf("unknown command %s\n%s", args[0], usage)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+func retryRefund(ctx context.Context, cfg pkg.BridgeConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: retry-refund <stellar_tx_hash>")
+	}
+
+	br, err := newBridge(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = br.RetryRefund(ctx, args[0])
+	if errors.Is(err, pkg.ErrTransactionAlreadyRefunded) {
+		fmt.Printf("transaction %s is already refunded\n", args[0])
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to retry refund")
+	}
+
+	fmt.Printf("refund of transaction %s submitted\n", args[0])
+	return nil
+}
+
+func trace(ctx context.Context, cfg pkg.BridgeConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: trace <stellar_tx_hash>")
+	}
+
+	br, err := newBridge(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	result, err := br.Trace(ctx, args[0])
+	if errors.Is(err, pkg.ErrNotFound) {
+		return fmt.Errorf("transaction %s has no deposit on the bridge account", args[0])
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to trace transaction")
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func inspectBurn(ctx context.Context, cfg pkg.BridgeConfig, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: inspect-burn <withdraw_id>")
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid withdraw id")
+	}
+
+	br, err := newBridge(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	inspection, err := br.InspectBurn(ctx, id)
+	if errors.Is(err, pkg.ErrNotFound) {
+		return fmt.Errorf("withdraw %d not found", id)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect burn")
+	}
+
+	out, err := json.MarshalIndent(inspection, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+// held lists and resolves the items held for manual handling, only deposits can be released or refunded
+func held(ctx context.Context, cfg pkg.BridgeConfig, args []string) error {
+	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
+		return fmt.Errorf("usage: held list | held release <stellar_tx_hash> | held refund <stellar_tx_hash>")
+	}
+
+	br, err := newBridge(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		items, err := br.HeldItems()
+		if err != nil {
+			return errors.Wrap(err, "failed to load held items")
+		}
+
+		out, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	case "release":
+		err = br.ReleaseHeldDeposit(ctx, args[1])
+	case "refund":
+		err = br.RefundHeldDeposit(ctx, args[1])
+	default:
+		return fmt.Errorf("unknown held command %s", args[0])
+	}
+
+	if errors.Is(err, pkg.ErrNotFound) {
+		return fmt.Errorf("no deposit of transaction %s is held", args[1])
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to %s held deposit", args[0])
+	}
+
+	fmt.Printf("held deposit %s resolved\n", args[1])
+	return nil
+}
+
+// doctorTfchain and doctorWallet are the clients the doctor command checks
+type doctorTfchain interface {
+	IsBridgeValidator() (bool, error)
+	GetDepositFee() (int64, error)
+}
+
+type doctorWallet interface {
+	GetBalance(ctx context.Context) (int64, error)
+	CheckBridgeTrustline(ctx context.Context) error
+}
+
+// doctor runs the checks a bridge needs to pass before it can start and reports each of them
+func doctor(ctx context.Context, cfg pkg.BridgeConfig) error {
+	connectTfchain := func() (doctorTfchain, error) {
+		signer, err := subpkg.NewSigner(&cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		// the client is created in dry run mode so it does not fail on the validator check itself
+		subClient, err := subpkg.NewSubstrateClient(cfg.TfchainURLs(), signer, subpkg.ExtrinsicOptions{DryRun: true})
+		if err != nil {
+			return nil, err
+		}
+		return subClient, nil
+	}
+	connectHorizon := func(ctx context.Context) (doctorWallet, error) {
+		// creating the wallet loads the bridge account from horizon
+		wallet, err := stellar.NewStellarWallet(ctx, &cfg.StellarConfig)
+		if err != nil {
+			return nil, err
+		}
+		return wallet, nil
+	}
+
+	return runDoctor(ctx, os.Stdout, cfg, connectTfchain, connectHorizon)
+}
+
+func runDoctor(ctx context.Context, out io.Writer, cfg pkg.BridgeConfig, connectTfchain func() (doctorTfchain, error), connectHorizon func(ctx context.Context) (doctorWallet, error)) error {
+	failed := 0
+	check := func(name string, fn func() (string, error)) {
+		detail, err := fn()
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "[FAIL] %s: %s\n", name, err)
+			return
+		}
+		fmt.Fprintf(out, "[ OK ] %s %s\n", name, detail)
+	}
+
+	subClient, err := connectTfchain()
+	check("connect to tfchain", func() (string, error) {
+		return cfg.TfchainURL, err
+	})
+	if err == nil {
+		check("account is a bridge validator", func() (string, error) {
+			isValidator, err := subClient.IsBridgeValidator()
+			if err == nil && !isValidator {
+				err = pkg.ErrNotValidator
+			}
+			return "", err
+		})
+		check("read deposit fee", func() (string, error) {
+			fee, err := subClient.GetDepositFee()
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprint(fee), bridge.ValidateDepositFee(fee, cfg.MaxDepositFee)
+		})
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second*15)
+	defer cancel()
+
+	wallet, err := connectHorizon(timeout)
+	check("load bridge account from horizon", func() (string, error) {
+		return cfg.StellarBridgeAccount, err
+	})
+	if err == nil {
+		check("read bridge account balance", func() (string, error) {
+			balance, err := wallet.GetBalance(ctx)
+			if err != nil {
+				return "", err
+			}
+			return amount.StringFromInt64(balance) + " XLM", nil
+		})
+		check("bridge account holds a trustline for the bridged asset", func() (string, error) {
+			return "", wallet.CheckBridgeTrustline(ctx)
+		})
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func initStellar(ctx context.Context, cfg pkg.BridgeConfig, args []string) error {
+	submit := len(args) > 0 && args[0] == "--submit"
+	if submit {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: init-stellar [--submit] <threshold> <signer>...")
+	}
+
+	threshold, err := strconv.ParseUint(args[0], 10, 8)
+	if err != nil {
+		return errors.Wrap(err, "invalid threshold")
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, time.Second*15)
+	defer cancel()
+
+	wallet, err := stellar.NewStellarWallet(timeout, &cfg.StellarConfig)
+	if err != nil {
+		return err
+	}
+
+	config, envelope, err := wallet.ConfigureSigners(ctx, args[1:], uint8(threshold), submit)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure the bridge account")
+	}
+
+	switch {
+	case envelope == "":
+		fmt.Println("bridge account is configured already")
+	case submit:
+		fmt.Println("bridge account configured")
+	default:
+		fmt.Printf("transaction to configure the bridge account, rerun with --submit to submit it:\n%s\n", envelope)
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
+
+func export(cfg pkg.BridgeConfig, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.String("from", "", "start of the range, a date (YYYY-MM-DD) or a time (RFC3339)")
+	to := fs.String("to", "", "end of the range, a date (YYYY-MM-DD, inclusive) or a time (RFC3339, exclusive)")
+	format := fs.String("format", accounting.FormatCSV, "output format, csv or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.AccountingLedger == "" {
+		return fmt.Errorf("no accounting ledger configured, set --accounting-ledger")
+	}
+	if *from == "" || *to == "" {
+		return fmt.Errorf("usage: export --from <date> --to <date> [--format csv|json]")
+	}
+
+	start, _, err := parseRangeBound(*from)
+	if err != nil {
+		return errors.Wrap(err, "invalid --from")
+	}
+	end, isDate, err := parseRangeBound(*to)
+	if err != nil {
+		return errors.Wrap(err, "invalid --to")
+	}
+	if isDate {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	file, err := os.Open(cfg.AccountingLedger)
+	if err != nil {
+		return errors.Wrap(err, "failed to open accounting ledger")
+	}
+	defer file.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	count, err := accounting.Export(file, out, *format, start, end)
+	if err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d entries\n", count)
+	return nil
+}
+
+// parseRangeBound parses a date (YYYY-MM-DD) or an RFC3339 time, dates are UTC days
+func parseRangeBound(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+func newBridge(ctx context.Context, cfg pkg.BridgeConfig) (*bridge.Bridge, error) {
+	timeout, cancel := context.WithTimeout(ctx, time.Second*15)
+	defer cancel()
+
+	return bridge.NewBridge(timeout, cfg)
+}
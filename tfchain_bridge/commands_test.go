@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+type doctorFakes struct {
+	tfchainErr error
+	validator  bool
+	fee        int64
+	feeErr     error
+	horizonErr error
+	balance    int64
+	balanceErr error
+	trustline  error
+}
+
+func (f *doctorFakes) IsBridgeValidator() (bool, error) { return f.validator, nil }
+
+func (f *doctorFakes) GetDepositFee() (int64, error) { return f.fee, f.feeErr }
+
+func (f *doctorFakes) GetBalance(ctx context.Context) (int64, error) { return f.balance, f.balanceErr }
+
+func (f *doctorFakes) CheckBridgeTrustline(ctx context.Context) error { return f.trustline }
+
+func TestDoctor(t *testing.T) {
+	healthy := doctorFakes{validator: true, fee: 10000000, balance: 1000000000}
+
+	tests := []struct {
+		name   string
+		fakes  func(f *doctorFakes)
+		report []string
+		failed int
+	}{
+		{
+			name: "healthy",
+			report: []string{
+				"[ OK ] connect to tfchain ws://tfchain",
+				"[ OK ] account is a bridge validator ",
+				"[ OK ] read deposit fee 10000000",
+				"[ OK ] load bridge account from horizon GBRIDGE",
+				"[ OK ] read bridge account balance 100.0000000 XLM",
+				"[ OK ] bridge account holds a trustline for the bridged asset ",
+			},
+		},
+		{
+			name:  "not a validator with a zero fee",
+			fakes: func(f *doctorFakes) { f.validator = false; f.fee = 0 },
+			report: []string{
+				"[ OK ] connect to tfchain ws://tfchain",
+				"[FAIL] account is a bridge validator: " + pkg.ErrNotValidator.Error(),
+				"[FAIL] read deposit fee: deposit fee 0 must be positive",
+				"[ OK ] load bridge account from horizon GBRIDGE",
+				"[ OK ] read bridge account balance 100.0000000 XLM",
+				"[ OK ] bridge account holds a trustline for the bridged asset ",
+			},
+			failed: 2,
+		},
+		{
+			name:  "tfchain unreachable",
+			fakes: func(f *doctorFakes) { f.tfchainErr = errors.New("connection refused") },
+			report: []string{
+				"[FAIL] connect to tfchain: connection refused",
+				"[ OK ] load bridge account from horizon GBRIDGE",
+				"[ OK ] read bridge account balance 100.0000000 XLM",
+				"[ OK ] bridge account holds a trustline for the bridged asset ",
+			},
+			failed: 1,
+		},
+		{
+			name:  "horizon unreachable",
+			fakes: func(f *doctorFakes) { f.horizonErr = errors.New("horizon is down") },
+			report: []string{
+				"[ OK ] connect to tfchain ws://tfchain",
+				"[ OK ] account is a bridge validator ",
+				"[ OK ] read deposit fee 10000000",
+				"[FAIL] load bridge account from horizon: horizon is down",
+			},
+			failed: 1,
+		},
+		{
+			name:  "balance unavailable",
+			fakes: func(f *doctorFakes) { f.balanceErr = errors.New("account not found") },
+			report: []string{
+				"[ OK ] connect to tfchain ws://tfchain",
+				"[ OK ] account is a bridge validator ",
+				"[ OK ] read deposit fee 10000000",
+				"[ OK ] load bridge account from horizon GBRIDGE",
+				"[FAIL] read bridge account balance: account not found",
+				"[ OK ] bridge account holds a trustline for the bridged asset ",
+			},
+			failed: 1,
+		},
+		{
+			name:  "no trustline",
+			fakes: func(f *doctorFakes) { f.trustline = stellar.ErrNoTrustline },
+			report: []string{
+				"[ OK ] connect to tfchain ws://tfchain",
+				"[ OK ] account is a bridge validator ",
+				"[ OK ] read deposit fee 10000000",
+				"[ OK ] load bridge account from horizon GBRIDGE",
+				"[ OK ] read bridge account balance 100.0000000 XLM",
+				"[FAIL] bridge account holds a trustline for the bridged asset: " + stellar.ErrNoTrustline.Error(),
+			},
+			failed: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakes := healthy
+			if test.fakes != nil {
+				test.fakes(&fakes)
+			}
+			cfg := pkg.BridgeConfig{TfchainURL: "ws://tfchain", StellarConfig: pkg.StellarConfig{StellarBridgeAccount: "GBRIDGE"}}
+
+			var out bytes.Buffer
+			err := runDoctor(context.Background(), &out, cfg,
+				func() (doctorTfchain, error) { return &fakes, fakes.tfchainErr },
+				func(ctx context.Context) (doctorWallet, error) { return &fakes, fakes.horizonErr },
+			)
+
+			if report := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(report, "\n") != strings.Join(test.report, "\n") {
+				t.Errorf("expected report\n%s\ngot\n%s", strings.Join(test.report, "\n"), out.String())
+			}
+			if test.failed == 0 && err != nil {
+				t.Errorf("expected all checks to pass, got %v", err)
+			}
+			if expected := fmt.Sprintf("%d checks failed", test.failed); test.failed > 0 && (err == nil || err.Error() != expected) {
+				t.Errorf("expected %d failed checks, got %v", test.failed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// process exit codes, orchestrators restart the bridge on exitFailure, exitSubscription and exitShutdownTimeout while
+// exitConfig and exitNotValidator need an operator to change the configuration or the validator set first
+const (
+	// exitOK is a clean shutdown after a signal
+	exitOK = 0
+	// exitFailure is an unexpected error, a restart can recover from it
+	exitFailure = 1
+	// exitConfig is an invalid configuration
+	exitConfig = 2
+	// exitNotValidator is returned when the tfchain account is not a bridge validator
+	exitNotValidator = 3
+	// exitSubscription is returned when the tfchain or stellar subscription failed, a restart resubscribes
+	exitSubscription = 4
+	// exitShutdownTimeout is returned when the bridge did not stop within the shutdown grace period after a signal
+	exitShutdownTimeout = 5
+)
+
+// exitCode maps the error the bridge stopped with to the exit code of the process
+func exitCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return exitOK
+	case errors.Is(err, pkg.ErrInvalidConfig):
+		return exitConfig
+	case errors.Is(err, pkg.ErrNotValidator):
+		return exitNotValidator
+	case errors.Is(err, pkg.ErrSubscriptionFailed):
+		return exitSubscription
+	default:
+		return exitFailure
+	}
+}
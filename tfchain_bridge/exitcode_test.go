@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "clean shutdown", code: exitOK},
+		{name: "signal", err: context.Canceled, code: exitOK},
+		{name: "invalid configuration", err: pkg.InvalidConfig(errors.New("unknown memo type")), code: exitConfig},
+		{name: "not a validator", err: errors.Wrap(pkg.ErrNotValidator, "failed to create substrate client"), code: exitNotValidator},
+		{name: "subscription failed", err: pkg.SubscriptionFailed(errors.Wrap(io.EOF, "failed to subscribe to tfchain")), code: exitSubscription},
+		{name: "unexpected error", err: errors.New("failed to save persistency"), code: exitFailure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := exitCode(test.err); code != test.code {
+				t.Errorf("expected exit code %d, got %d", test.code, code)
+			}
+		})
+	}
+}
+
+func TestExitCodesAreDistinct(t *testing.T) {
+	// orchestrators tell the reason the bridge stopped from its exit code only
+	codes := map[string]int{
+		"exitOK":              exitOK,
+		"exitFailure":         exitFailure,
+		"exitConfig":          exitConfig,
+		"exitNotValidator":    exitNotValidator,
+		"exitSubscription":    exitSubscription,
+		"exitShutdownTimeout": exitShutdownTimeout,
+	}
+	seen := make(map[int]string)
+	for name, code := range codes {
+		if other, ok := seen[code]; ok {
+			t.Errorf("%s and %s both exit with code %d", name, other, code)
+		}
+		seen[code] = name
+	}
+}
@@ -0,0 +1,220 @@
+//go:build integration
+
+// Package integration runs a deposit to mint and a burn to withdraw cycle against a tfchain dev node and the stellar
+// testnet, with the bridge validators of testing.md running. It is skipped unless the BRIDGE_IT_* variables are set:
+//
+//	go test -tags integration ./integration/
+package integration
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+const (
+	// transferAmount is the amount deposited and burned, in units of 0.0000001 TFT
+	transferAmount = 100_000_000
+	// settleTimeout is how long the validators get to mint a deposit or pay a withdraw
+	settleTimeout = 5 * time.Minute
+)
+
+// env holds the accounts of the user doing the transfers
+type env struct {
+	tfchainURL    string
+	mnemonic      string
+	twinID        uint32
+	stellarSecret string
+	bridgeAccount string
+	horizonURL    string
+}
+
+func loadEnv(t *testing.T) env {
+	e := env{
+		tfchainURL:    os.Getenv("BRIDGE_IT_TFCHAIN_URL"),
+		mnemonic:      os.Getenv("BRIDGE_IT_TFCHAIN_MNEMONIC"),
+		stellarSecret: os.Getenv("BRIDGE_IT_STELLAR_SECRET"),
+		bridgeAccount: os.Getenv("BRIDGE_IT_BRIDGE_ACCOUNT"),
+		horizonURL:    os.Getenv("BRIDGE_IT_HORIZON_URL"),
+	}
+	twin := os.Getenv("BRIDGE_IT_TWIN_ID")
+	if e.tfchainURL == "" || e.mnemonic == "" || twin == "" || e.stellarSecret == "" || e.bridgeAccount == "" {
+		t.Skip("BRIDGE_IT_TFCHAIN_URL, BRIDGE_IT_TFCHAIN_MNEMONIC, BRIDGE_IT_TWIN_ID, BRIDGE_IT_STELLAR_SECRET and BRIDGE_IT_BRIDGE_ACCOUNT must be set")
+	}
+	id, err := strconv.ParseUint(twin, 10, 32)
+	if err != nil {
+		t.Fatalf("invalid BRIDGE_IT_TWIN_ID %q: %s", twin, err)
+	}
+	e.twinID = uint32(id)
+	if e.horizonURL == "" {
+		e.horizonURL = horizonclient.DefaultTestNetClient.HorizonURL
+	}
+	return e
+}
+
+func TestDepositAndWithdraw(t *testing.T) {
+	e := loadEnv(t)
+
+	mgr := substrate.NewManager(e.tfchainURL)
+	sub, err := mgr.Substrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	identity, err := substrate.NewIdentityFromSr25519Phrase(e.mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	twin, err := sub.GetTwin(e.twinID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := keypair.ParseFull(e.stellarSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	horizon := &horizonclient.Client{HorizonURL: e.horizonURL}
+
+	t.Run("deposit to mint", func(t *testing.T) {
+		fee, err := sub.GetDepositFee()
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := tfchainBalance(t, sub, twin.Account)
+
+		deposit(t, horizon, user, e.bridgeAccount, fmt.Sprintf("twin_%d", e.twinID))
+
+		expected := new(big.Int).Add(before, big.NewInt(transferAmount-fee))
+		waitFor(t, "the deposit to be minted", func() bool {
+			return tfchainBalance(t, sub, twin.Account).Cmp(expected) >= 0
+		})
+	})
+
+	t.Run("burn to withdraw", func(t *testing.T) {
+		fee := withdrawFee(t, sub)
+		before := stellarBalance(t, horizon, user.Address())
+
+		swapToStellar(t, sub, identity, user.Address())
+
+		expected := before + transferAmount - int64(fee)
+		waitFor(t, "the withdraw to be paid", func() bool {
+			return stellarBalance(t, horizon, user.Address()) >= expected
+		})
+	})
+}
+
+// waitFor polls done until it returns true or the settle timeout expires
+func waitFor(t *testing.T, what string, done func() bool) {
+	deadline := time.Now().Add(settleTimeout)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(6 * time.Second)
+	}
+}
+
+func tfchainBalance(t *testing.T, sub *substrate.Substrate, account substrate.AccountID) *big.Int {
+	balance, err := sub.GetBalance(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance.Free.Int
+}
+
+// withdrawFee reads the fee the pallet deducts from a burn
+func withdrawFee(t *testing.T, sub *substrate.Substrate) uint64 {
+	cl, meta, err := sub.GetClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := types.CreateStorageKey(meta, "TFTBridgeModule", "WithdrawFee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fee types.U64
+	if _, err := cl.RPC.State.GetStorageLatest(key, &fee); err != nil {
+		t.Fatal(err)
+	}
+	return uint64(fee)
+}
+
+// swapToStellar burns the transfer amount from the identity on tfchain to the stellar target
+func swapToStellar(t *testing.T, sub *substrate.Substrate, identity substrate.Identity, target string) {
+	cl, meta, err := sub.GetClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, err := types.NewCall(meta, "TFTBridgeModule.swap_to_stellar", target, types.U64(transferAmount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Call(cl, meta, identity, call); err != nil {
+		t.Fatalf("failed to swap to stellar: %s", err)
+	}
+}
+
+// stellarBalance returns the testnet TFT balance of address in units of 0.0000001 TFT
+func stellarBalance(t *testing.T, horizon *horizonclient.Client, address string) int64 {
+	account, err := horizon.AccountDetail(horizonclient.AccountRequest{AccountID: address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tft := strings.Split(stellar.TFTTest, ":")
+	for _, balance := range account.Balances {
+		if balance.Code == tft[0] && balance.Issuer == tft[1] {
+			units, err := amount.ParseInt64(balance.Balance)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return units
+		}
+	}
+	t.Fatalf("account %s has no TFT trustline", address)
+	return 0
+}
+
+// deposit pays the transfer amount from user to the bridge account with memo
+func deposit(t *testing.T, horizon *horizonclient.Client, user *keypair.Full, bridgeAccount string, memo string) {
+	account, err := horizon.AccountDetail(horizonclient.AccountRequest{AccountID: user.Address()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tft := strings.Split(stellar.TFTTest, ":")
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &account,
+		IncrementSequenceNum: true,
+		Operations: []txnbuild.Operation{&txnbuild.Payment{
+			Destination: bridgeAccount,
+			Amount:      amount.StringFromInt64(transferAmount),
+			Asset:       txnbuild.CreditAsset{Code: tft[0], Issuer: tft[1]},
+		}},
+		Memo:       txnbuild.MemoText(memo),
+		BaseFee:    txnbuild.MinBaseFee,
+		Timebounds: txnbuild.NewTimeout(300),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err = tx.Sign(network.TestNetworkPassphrase, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := horizon.SubmitTransaction(tx); err != nil {
+		t.Fatalf("failed to submit the deposit: %s", err)
+	}
+}
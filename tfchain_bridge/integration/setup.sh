@@ -0,0 +1,28 @@
+#!/usr/bin/env bash
+# Starts a tfchain dev node and creates a funded stellar testnet account for the integration test.
+# The bridge account, its signers and the validators are set up as described in testing.md.
+set -euo pipefail
+cd "$(dirname "$0")"
+
+TFCHAIN_IMAGE=${TFCHAIN_IMAGE:-ghcr.io/threefoldtech/tfchain:latest}
+TFCHAIN_PORT=${TFCHAIN_PORT:-9944}
+
+if ! docker ps --format '{{.Names}}' | grep -q '^tfchain-dev$'; then
+	docker run -d --rm --name tfchain-dev -p "${TFCHAIN_PORT}:9944" "${TFCHAIN_IMAGE}" \
+		--dev --rpc-external --rpc-cors all --ws-external
+fi
+
+# the user account of the deposits and withdraws, friendbot funds it with testnet lumens
+user=$(go run ../tools/keygen)
+address=$(echo "$user" | awk '/Stellar Address/ {print $NF}')
+secret=$(echo "$user" | awk '/Stellar Secret/ {print $NF}')
+curl -sf "https://friendbot.stellar.org/?addr=${address}" > /dev/null
+
+cat <<ENV
+# add a TFT trustline to ${address} and get testnet TFT, create a twin for the tfchain user, then:
+export BRIDGE_IT_TFCHAIN_URL=ws://localhost:${TFCHAIN_PORT}
+export BRIDGE_IT_TFCHAIN_MNEMONIC="<mnemonic of the twin account>"
+export BRIDGE_IT_TWIN_ID=<twin id>
+export BRIDGE_IT_STELLAR_SECRET=${secret}
+export BRIDGE_IT_BRIDGE_ACCOUNT=<bridge account>
+ENV
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -12,54 +16,342 @@ import (
 	flag "github.com/spf13/pflag"
 	"github.com/threefoldtech/tfchain_bridge/pkg"
 	"github.com/threefoldtech/tfchain_bridge/pkg/bridge"
+	"github.com/threefoldtech/tfchain_bridge/pkg/server"
+	"github.com/threefoldtech/tfchain_bridge/pkg/version"
 )
 
 func main() {
-	var bridgeCfg pkg.BridgeConfig
+	bridgeCfg, opts, commands, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err)
+		os.Exit(exitConfig)
+	}
 
-	var debug bool
-	flag.StringVar(&bridgeCfg.TfchainURL, "tfchainurl", "", "Tfchain websocket url")
-	flag.StringVar(&bridgeCfg.TfchainSeed, "tfchainseed", "", "Tfchain secret seed")
-	flag.StringVar(&bridgeCfg.StellarBridgeAccount, "bridgewallet", "", "stellar bridge wallet")
-	flag.StringVar(&bridgeCfg.StellarSeed, "secret", "", "stellar secret")
-	flag.StringVar(&bridgeCfg.StellarNetwork, "network", "testnet", "stellar network url")
-	flag.StringVar(&bridgeCfg.PersistencyFile, "persistency", "./node.json", "file where last seen blockheight and stellar account cursor is stored")
-	flag.BoolVar(&bridgeCfg.RescanBridgeAccount, "rescan", false, "if true is provided, we rescan the bridge stellar account and mint all transactions again")
-	flag.StringVar(&bridgeCfg.StellarHorizonUrl, "horizon", "", "stellar horizon url endpoint")
-	flag.BoolVar(&debug, "debug", false, "sets debug level log output")
-
-	flag.Parse()
-
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
-	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	if debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	if opts.showVersion {
+		fmt.Println(version.String())
+		return
+	}
+
+	if err := configureLogger(logLevel(bridgeCfg.LogLevel, opts), bridgeCfg.LogFormat, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log configuration: %s\n", err)
+		os.Exit(exitConfig)
+	}
+	if opts.debug {
 		log.Debug().Msg("debug mode enabled")
 	}
+	if opts.traceExtrinsics {
+		log.Trace().Msg("trace mode enabled")
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if len(commands) > 0 {
+		code := runCommand(ctx, bridgeCfg, commands)
+		cancel()
+		os.Exit(code)
+	}
+
 	timeout, timeoutCancel := context.WithTimeout(ctx, time.Second*15)
 	defer timeoutCancel()
 
 	br, err := bridge.NewBridge(timeout, bridgeCfg)
 	if err != nil {
-		panic(err)
+		log.Error().Err(err).Msg("failed to start the bridge")
+		cancel()
+		os.Exit(exitCode(err))
+	}
+
+	if bridgeCfg.AdminAddress != "" {
+		srv := server.NewServer(bridgeCfg.AdminAddress, bridgeCfg.AdminToken, br)
+		go func() {
+			if err := srv.Serve(ctx); err != nil {
+				log.Err(err).Msg("admin server stopped")
+			}
+		}()
 	}
 
 	sigs := make(chan os.Signal, 1)
 
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGUSR1 pauses the processing of events and SIGUSR2 resumes it
+	pauses := make(chan os.Signal, 1)
+	signal.Notify(pauses, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	// SIGHUP reloads the configuration
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+
 	go func() {
 		log.Info().Msg("awaiting signal")
-		<-sigs
-		log.Info().Msg("shutting now")
-		cancel()
+		for {
+			select {
+			case sig := <-pauses:
+				if sig == syscall.SIGUSR1 {
+					br.Pause()
+				} else {
+					br.Resume()
+				}
+			case <-reloads:
+				reload(br, os.Args[1:])
+			case <-sigs:
+				log.Info().Msg("shutting now")
+				cancel()
+				forceExitAfter(bridgeCfg.ShutdownGracePeriod, os.Exit)
+				return
+			}
+		}
 	}()
 
-	if err = br.Start(ctx); err != nil && err != context.Canceled {
-		log.Fatal().Err(err).Msg("exited unexpectedly")
+	err = br.Run(ctx)
+	br.Close()
+	code := exitCode(err)
+	switch code {
+	case exitOK:
+		log.Info().Msg("bridge stopped")
+		return
+	case exitNotValidator:
+		log.Warn().Int("exit_code", code).Msg("stopping, the account is no longer a bridge validator")
+	default:
+		log.Error().Err(err).Int("exit_code", code).Msg("exited unexpectedly")
+	}
+	cancel()
+	os.Exit(code)
+}
+
+// cliOptions are the command line options that are not part of the bridge configuration
+type cliOptions struct {
+	debug             bool
+	traceExtrinsics   bool
+	showVersion       bool
+	configFile        string
+	alertDedupWindows map[string]string
+}
+
+// loadConfig parses args followed by the flags of the config file, the config file overrides the command line.
+// The positional args of the command line are returned as the command to run.
+func loadConfig(args []string) (pkg.BridgeConfig, cliOptions, []string, error) {
+	var bridgeCfg pkg.BridgeConfig
+	var opts cliOptions
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	// flags after the command are flags of the command
+	fs.SetInterspersed(false)
+	fs.StringVar(&bridgeCfg.TfchainURL, "tfchainurl", "", "Tfchain websocket url")
+	fs.StringSliceVar(&bridgeCfg.TfchainStandbyURLs, "tfchainurl-standby", nil, "comma separated tfchain websocket urls the bridge fails over to in order when the active endpoint fails")
+	fs.StringVar(&bridgeCfg.TfchainSeed, "tfchainseed", "", "Tfchain secret seed")
+	fs.StringVar(&bridgeCfg.TfchainSignerURL, "tfchain-signer-url", "", "url of a remote signing service holding the tfchain key, replaces the tfchainseed")
+	fs.StringVar(&bridgeCfg.TfchainSignerAddress, "tfchain-signer-address", "", "tfchain address of the key held by the remote signer")
+	fs.StringVar(&bridgeCfg.TfchainProxySeed, "tfchain-proxy-seed", "", "seed of a tfchain account added as proxy of the validator account, it submits the bridge extrinsics and pays their fees so the validator account needs no balance. Requires the proxy pallet in the runtime")
+	fs.DurationVar(&bridgeCfg.TfchainFeeRetryInterval, "tfchain-fee-retry-interval", 5*time.Minute, "how long extrinsic submissions wait before they are retried once the tfchain account can not pay the fees, operators are alerted when it happens")
+	fs.Uint64Var(&bridgeCfg.TfchainTip, "tfchain-tip", 0, "tip (in units of 0.0000001 TFT) paid for the bridge extrinsics to prioritize them during congestion")
+	fs.Uint64Var(&bridgeCfg.TfchainMortality, "tfchain-mortality", 0, "amount of blocks the bridge extrinsics are valid for, 0 submits immortal extrinsics")
+	fs.BoolVar(&bridgeCfg.TfchainLocalNonces, "tfchain-local-nonces", false, "track the tfchain account nonce locally so extrinsics submitted back to back get sequential nonces")
+	fs.StringVar(&bridgeCfg.StellarBridgeAccount, "bridgewallet", "", "stellar bridge wallet")
+	fs.StringVar(&bridgeCfg.StellarSeed, "secret", "", "stellar secret")
+	fs.StringVar(&bridgeCfg.StellarNetwork, "network", "testnet", "stellar network url")
+	fs.StringVar(&bridgeCfg.PersistencyFile, "persistency", "./node.json", "file where last seen blockheight and stellar account cursor is stored")
+	fs.StringVar(&bridgeCfg.AccountingLedger, "accounting-ledger", "", "file the mints, withdraws and refunds of the bridge are appended to as json lines for audits, no ledger is kept when empty")
+	fs.BoolVar(&bridgeCfg.AccountingHashChain, "accounting-hash-chain", false, "chain the entries of the accounting ledger with their hashes so changes to the ledger are detected")
+	fs.BoolVar(&bridgeCfg.RescanBridgeAccount, "rescan", false, "if true is provided, we rescan the bridge stellar account and mint all transactions again")
+	fs.IntVar(&bridgeCfg.RescanConcurrency, "rescan-concurrency", 1, "amount of stellar transactions fetched and checked for being minted at the same time during a rescan, deposits are still minted in order")
+	fs.StringVar(&bridgeCfg.StellarHorizonUrl, "horizon", "", "stellar horizon url endpoint")
+	fs.StringSliceVar(&bridgeCfg.StellarHorizonStandbyUrls, "horizon-standby", nil, "comma separated horizon urls the bridge fails over to in order when the active horizon keeps failing")
+	fs.StringVar(&bridgeCfg.StellarNetworkPassphrase, "network-passphrase", "", "stellar network passphrase, overrides the passphrase of --network")
+	fs.StringVar(&bridgeCfg.StellarAssetCode, "asset-code", "", "code of the bridged stellar asset, TFT of --network when empty")
+	fs.StringVar(&bridgeCfg.StellarAssetIssuer, "asset-issuer", "", "issuer of the bridged stellar asset")
+	fs.DurationVar(&bridgeCfg.HorizonTimeout, "horizon-timeout", 30*time.Second, "timeout of a single horizon request")
+	fs.IntVar(&bridgeCfg.HorizonMaxRetries, "horizon-max-retries", 3, "amount of retries of horizon requests failing with a server error or timeout")
+	fs.Int64Var(&bridgeCfg.StellarBaseFee, "stellar-base-fee", 100000, "base fee (in stroops) of the bridge payments, must be the same for all validators")
+	fs.Int64Var(&bridgeCfg.StellarMaxFee, "stellar-max-fee", 0, "highest base fee (in stroops) a payment rejected for an insufficient fee is fee bumped to, 0 disables fee bumps")
+	fs.IntVar(&bridgeCfg.StellarFeePercentile, "stellar-fee-percentile", 0, "percentile (10, 20, ..., 90, 95 or 99) of the recent network fees the first fee bump of a rejected payment pays, bounded by --stellar-max-fee. 0 doubles the base fee instead")
+	fs.BoolVar(&bridgeCfg.StellarClaimableBalances, "stellar-claimable-balances", false, "pay withdraws to stellar destinations that do not exist or have no trustline with a claimable balance the destination can claim later, instead of minting them back. All validators must use the same value")
+	fs.DurationVar(&bridgeCfg.StellarPaymentTimeout, "stellar-payment-timeout", 0, "window the time bounds of withdraw payments are aligned on, collected signatures stay valid for one to two windows. All validators must use the same value. 0 means withdraw payments do not expire")
+	fs.StringVar(&bridgeCfg.StellarSignerURL, "stellar-signer-url", "", "url of a remote signing service holding the stellar key, replaces the secret")
+	fs.StringVar(&bridgeCfg.StellarSignerAddress, "stellar-signer-address", "", "stellar address of the key held by the remote signer")
+	fs.DurationVar(&bridgeCfg.MemoCacheTTL, "memo-cache-ttl", 0, "how long the twin, farm, node and entity accounts resolved from deposit memos are cached, 0 disables the cache")
+	fs.Int64Var(&bridgeCfg.LowBalanceThreshold, "low-balance-threshold", 0, "XLM balance (in stroops) of the bridge account below which a warning and alert are raised, 0 disables the alert")
+	fs.DurationVar(&bridgeCfg.BalanceCheckInterval, "balance-check-interval", 5*time.Minute, "interval of the bridge account balance check")
+	fs.DurationVar(&bridgeCfg.CursorReconcileInterval, "cursor-reconcile-interval", time.Minute, "interval at which a stellar cursor that failed to save is saved again")
+	fs.IntVar(&bridgeCfg.CursorCheckpointCount, "cursor-checkpoint-count", 100, "amount of scanned stellar transactions without a deposit to process after which the cursor is saved, 0 disables the count")
+	fs.DurationVar(&bridgeCfg.CursorCheckpointInterval, "cursor-checkpoint-interval", time.Minute, "time after which the cursor of scanned stellar transactions without a deposit to process is saved, 0 disables the interval")
+	fs.DurationVar(&bridgeCfg.ShutdownGracePeriod, "shutdown-grace-period", 30*time.Second, "time the bridge gets to stop after a shutdown signal before the process is forced to exit, 0 waits forever")
+	fs.BoolVar(&bridgeCfg.ObserverMode, "observer", false, "only track bridge events and export metrics, nothing is submitted to tfchain or stellar. The tfchain account does not have to be a validator")
+	fs.DurationVar(&bridgeCfg.ValidatorCheckInterval, "validator-check-interval", 5*time.Minute, "interval at which the tfchain account is checked to still be a bridge validator")
+	fs.BoolVar(&bridgeCfg.ExitWhenNotValidator, "exit-when-not-validator", false, "stop the bridge instead of pausing extrinsic submissions when the account is no longer a bridge validator")
+	fs.DurationVar(&bridgeCfg.SignerCheckInterval, "signer-check-interval", time.Hour, "interval at which the bridge account signers are compared with the bridge validators on chain, 0 disables the check")
+	fs.StringSliceVar(&bridgeCfg.PeerAdminURLs, "peer-admin-urls", nil, "comma separated admin server urls of the other validators (e.g. http://validator2:8080), the network, asset and fees of the bridge are compared with theirs on startup")
+	fs.BoolVar(&bridgeCfg.PeerCheckHalt, "peer-check-halt", false, "refuse to start when the configuration diverges from the majority of the reachable peers, by default only an alert is raised")
+	fs.IntVar(&bridgeCfg.BreakerThreshold, "breaker-threshold", 0, "consecutive failures of an event type after which its processing is paused, 0 disables the circuit breakers")
+	fs.DurationVar(&bridgeCfg.BreakerCooldown, "breaker-cooldown", time.Minute, "how long the processing of an event type is paused once its circuit breaker opens")
+	fs.DurationVar(&bridgeCfg.EventTimeout, "event-timeout", 0, "time a single attempt to handle events can take before it is aborted and retried, 0 means no timeout")
+	fs.StringSliceVar(&bridgeCfg.WithdrawDestinationAllowlist, "withdraw-allowlist", nil, "comma separated stellar addresses withdraws can be paid to, withdraws to other destinations are minted back. Empty allows all destinations")
+	fs.Int64Var(&bridgeCfg.MaxDepositFee, "max-deposit-fee", 1_000_000_000, "highest deposit fee (in units of 0.0000001 TFT) read from chain the bridge starts with, 0 means no limit")
+	fs.IntVar(&bridgeCfg.MinMemoVersion, "min-memo-version", 1, "lowest memo version (v<version>_twin_<id>) deposits are minted for, older memos are refunded. Memos without a version are version 1")
+	fs.BoolVar(&bridgeCfg.MemoNotes, "memo-notes", false, "accept deposit memos with a free-form note after the routing part, separated by a '#' (twin_123#coffee). The whole memo is still limited to 28 bytes")
+	fs.BoolVar(&bridgeCfg.LenientMemos, "lenient-memos", false, "trim the whitespace around deposit memos and lowercase their prefix before parsing them (' Twin_123 ' mints to twin 123), the id is still parsed strictly")
+	fs.DurationVar(&bridgeCfg.IgnoreDepositsOlderThan, "ignore-deposits-older-than", 0, "skip deposits that closed longer ago unless --rescan is set, protects against minting old deposits again after a persistency loss. 0 disables the check")
+	fs.BoolVar(&bridgeCfg.HashMemos, "hash-memos", false, "mint deposits with a hash memo to the account whose 32 byte public key the hash carries, hash memos are refunded otherwise")
+	fs.StringSliceVar(&bridgeCfg.AllowedMemoTypes, "allowed-memo-types", pkg.MemoTypes, "comma separated grid object types (twin, farm, node, entity) deposits can be minted to, deposits to other types are refunded")
+	fs.StringVar(&bridgeCfg.NodeMintTarget, "node-mint-target", pkg.NodeMintTargetNode, "account deposits with a node memo are minted to: node (the twin of the node) or farm (the twin of the farm the node belongs to)")
+	fs.StringVar(&bridgeCfg.MintRole, "mint-role", pkg.MintRoleProposeAndVote, "role of the validator in mints: propose_and_vote or vote_only (wait for another validator to propose the mint and only vote on it)")
+	fs.DurationVar(&bridgeCfg.SignatureTimeout, "signature-timeout", 0, "time a withdraw can wait for validator signatures before an alert is raised, 0 disables the alert")
+	fs.Int64Var(&bridgeCfg.MaxRefundAmount, "max-refund-amount", 0, "highest amount (in stroops) the bridge refunds, larger refunds are refused and alerted. 0 means no limit")
+	fs.Int64Var(&bridgeCfg.DailyMintLimit, "daily-mint-limit", 0, "maximum amount (in stroops) minted to a single target per UTC day, deposits exceeding it are held for review. 0 disables the limit")
+	fs.DurationVar(&bridgeCfg.DepositBurstWindow, "deposit-burst-window", 0, "sliding window in which the deposits of a single stellar source are counted, deposits above --deposit-burst-count or --deposit-burst-amount within it are held for review. 0 disables the quarantine")
+	fs.IntVar(&bridgeCfg.DepositBurstCount, "deposit-burst-count", 0, "amount of deposits of a single source within --deposit-burst-window above which deposits are held for review, 0 means no limit")
+	fs.Int64Var(&bridgeCfg.DepositBurstAmount, "deposit-burst-amount", 0, "cumulative amount (in stroops) deposited by a single source within --deposit-burst-window above which deposits are held for review, 0 means no limit")
+	fs.StringVar(&bridgeCfg.RefundReservePolicy, "refund-reserve-policy", pkg.RefundReservePolicySubmit, "handling of refunds that would leave the bridge account below its minimum balance: submit (stellar rejects the refund if the account can not pay it) or hold (park and alert)")
+	fs.StringVar(&bridgeCfg.BelowFeePolicy, "below-fee-policy", pkg.BelowFeePolicyRefund, "handling of deposits that do not cover the deposit fee: refund, absorb (send to --fee-collection-account) or ignore (leave on the bridge account)")
+	fs.StringVar(&bridgeCfg.FeeCollectionAccount, "fee-collection-account", "", "stellar account that receives deposits below the deposit fee with --below-fee-policy absorb")
+	fs.StringVar(&bridgeCfg.UnsupportedAssetPolicy, "unsupported-asset-policy", pkg.UnsupportedAssetPolicyAlert, "handling of payments of other assets than the bridged asset: ignore, alert or hold (held for a manual refund as the sender has a trustline)")
+	fs.BoolVar(&bridgeCfg.PersistPendingMints, "persist-pending-mints", false, "persist fetched deposits until they are processed so they are handled first after a restart")
+	fs.IntVar(&bridgeCfg.StellarEventBuffer, "stellar-event-buffer", 100, "amount of fetched stellar transactions buffered until they are processed, fetching pauses while the buffer is full")
+	fs.StringVar(&bridgeCfg.MalformedEventPolicy, "malformed-event-policy", pkg.MalformedEventPolicySkip, "handling of malformed tfchain events: skip (record and alert) or fail")
+	fs.IntVar(&bridgeCfg.WithdrawConcurrency, "withdraw-concurrency", 1, "amount of withdraw created events of a block that are handled concurrently")
+	fs.DurationVar(&bridgeCfg.AlertDedupWindow, "alert-dedup-window", 0, "window in which identical alerts are grouped into a single alert with a count, 0 disables grouping")
+	fs.StringToStringVar(&opts.alertDedupWindows, "alert-dedup-windows", nil, "grouping window per alert kind (e.g. insufficient_reserve=1h,malformed_event=10m), overrides --alert-dedup-window")
+	fs.StringVar(&bridgeCfg.AlertWebhookURL, "alert-webhook", "", "url alerts are posted to as json, disabled when empty")
+	fs.StringVar(&bridgeCfg.AlertSlackWebhookURL, "alert-slack-webhook", "", "slack incoming webhook url alerts are posted to, disabled when empty")
+	fs.StringVar(&bridgeCfg.AlertPagerDutyRoutingKey, "alert-pagerduty-key", "", "routing key of the pagerduty integration alerts trigger events on, disabled when empty")
+	fs.StringVar(&bridgeCfg.AdminAddress, "admin-addr", "", "address of the admin http server (e.g. :8080), disabled when empty")
+	fs.StringVar(&bridgeCfg.AdminToken, "admin-token", "", "bearer token of the pending transactions api of the admin server, the api is disabled when empty")
+	fs.StringVar(&bridgeCfg.LogLevel, "log-level", "info", "log level (trace, debug, info, warn, error)")
+	fs.StringVar(&bridgeCfg.LogFormat, "log-format", pkg.LogFormatConsole, "log output format (console or json)")
+	fs.BoolVar(&opts.debug, "debug", false, "sets debug level log output")
+	fs.BoolVar(&opts.traceExtrinsics, "trace-extrinsics", false, "sets trace level log output, logging the content of every submitted extrinsic")
+	fs.BoolVar(&opts.showVersion, "version", false, "print the version and exit")
+
+	fs.StringVar(&opts.configFile, "config", "", "file with a flag per line (e.g. log-level=debug) overriding the command line, it is read again on SIGHUP and the reloadable fields are applied")
+
+	if err := fs.Parse(args); err != nil {
+		return bridgeCfg, opts, nil, err
+	}
+	commands := fs.Args()
+
+	if opts.configFile != "" {
+		fileArgs, err := readConfigFile(opts.configFile)
+		if err != nil {
+			return bridgeCfg, opts, nil, err
+		}
+		if err := fs.Parse(fileArgs); err != nil {
+			return bridgeCfg, opts, nil, fmt.Errorf("%s: %w", opts.configFile, err)
+		}
+	}
+
+	windows, err := parseDurations(opts.alertDedupWindows)
+	if err != nil {
+		return bridgeCfg, opts, nil, fmt.Errorf("invalid --alert-dedup-windows: %w", err)
+	}
+	bridgeCfg.AlertDedupWindows = windows
+
+	return bridgeCfg, opts, commands, nil
+}
+
+// readConfigFile reads the flags of a config file, one flag per line with or without the leading dashes.
+// Empty lines and lines starting with # are skipped.
+func readConfigFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args = append(args, "--"+strings.TrimLeft(line, "-"))
 	}
+	return args, nil
+}
+
+// reloader applies a configuration to a running bridge, it is implemented by *bridge.Bridge
+type reloader interface {
+	Reload(cfg pkg.BridgeConfig) error
+}
+
+// reload loads the configuration from args again and applies the fields that can change while the bridge runs,
+// a configuration changing other fields is rejected as a whole
+func reload(br reloader, args []string) {
+	cfg, opts, _, err := loadConfig(args)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to load configuration, keeping the running configuration")
+		return
+	}
+
+	level, err := zerolog.ParseLevel(logLevel(cfg.LogLevel, opts))
+	if err != nil {
+		log.Warn().Err(err).Msg("invalid log level, keeping the running configuration")
+		return
+	}
+
+	if err := br.Reload(cfg); err != nil {
+		log.Warn().Err(err).Msg("configuration reload rejected, restart the bridge to apply it")
+		return
+	}
+
+	zerolog.SetGlobalLevel(level)
+	log.Info().Str("log_level", level.String()).Msg("configuration reloaded")
+}
+
+// logLevel returns the log level of the configuration unless a more verbose level is forced on the command line
+func logLevel(level string, opts cliOptions) string {
+	switch {
+	case opts.traceExtrinsics:
+		return zerolog.LevelTraceValue
+	case opts.debug:
+		return zerolog.LevelDebugValue
+	}
+	return level
+}
+
+// forceExitAfter calls exit once grace has passed, a handler stuck in a call that does
+// not take a context must not block the restart of the bridge by its orchestrator
+func forceExitAfter(grace time.Duration, exit func(code int)) {
+	if grace <= 0 {
+		return
+	}
+	time.AfterFunc(grace, func() {
+		log.Error().Dur("grace_period", grace).Msg("bridge did not stop within the shutdown grace period, forcing exit")
+		exit(exitShutdownTimeout)
+	})
+}
+
+// parseDurations parses the values of a key=duration flag
+func parseDurations(values map[string]string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration, len(values))
+	for key, value := range values {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		durations[key] = d
+	}
+	return durations, nil
+}
+
+// configureLogger sets the global log level and the output format of the logger writing to out
+func configureLogger(level string, format string, out io.Writer) error {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case pkg.LogFormatConsole:
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: out})
+	case pkg.LogFormatJSON:
+		log.Logger = zerolog.New(out).With().Timestamp().Logger()
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	zerolog.SetGlobalLevel(lvl)
+	return nil
 }
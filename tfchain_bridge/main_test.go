@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestConfigureLogger(t *testing.T) {
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+
+	tests := []struct {
+		name   string
+		level  string
+		format string
+		err    bool
+		json   bool
+		// logged is whether an info message is written at the level
+		logged bool
+	}{
+		{name: "default", level: "info", format: pkg.LogFormatConsole, logged: true},
+		{name: "json", level: "info", format: pkg.LogFormatJSON, json: true, logged: true},
+		{name: "debug", level: "debug", format: pkg.LogFormatJSON, json: true, logged: true},
+		{name: "warn", level: "warn", format: pkg.LogFormatJSON, json: true},
+		{name: "unknown level", level: "loud", format: pkg.LogFormatJSON, err: true},
+		{name: "unknown format", level: "info", format: "xml", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := configureLogger(test.level, test.format, &out)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.err {
+				return
+			}
+
+			log.Info().Str("tx_id", "tx").Msg("mint processed")
+			if logged := out.Len() > 0; logged != test.logged {
+				t.Fatalf("expected logged %t at level %s, got %q", test.logged, test.level, out.String())
+			}
+			if !test.logged {
+				return
+			}
+
+			var entry map[string]interface{}
+			isJSON := json.Unmarshal(out.Bytes(), &entry) == nil
+			if isJSON != test.json {
+				t.Errorf("expected json %t, got %q", test.json, out.String())
+			}
+			if !strings.Contains(out.String(), "mint processed") {
+				t.Errorf("expected the message in the output, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestForceExitAfter(t *testing.T) {
+	t.Run("stuck handler", func(t *testing.T) {
+		exited := make(chan int, 1)
+		start := time.Now()
+
+		// the bridge never returns from its stuck handler, only the grace period ends the process
+		forceExitAfter(20*time.Millisecond, func(code int) { exited <- code })
+
+		select {
+		case code := <-exited:
+			if code != exitShutdownTimeout {
+				t.Errorf("expected exit code %d, got %d", exitShutdownTimeout, code)
+			}
+			if waited := time.Since(start); waited < 20*time.Millisecond {
+				t.Errorf("expected the exit after the grace period, exited after %s", waited)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the process was not forced to exit")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		exited := make(chan int, 1)
+		forceExitAfter(0, func(code int) { exited <- code })
+
+		select {
+		case <-exited:
+			t.Fatal("expected no forced exit without a grace period")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+// configReloader applies or rejects the reloaded configurations
+type configReloader struct {
+	reloaded []pkg.BridgeConfig
+	err      error
+}
+
+func (r *configReloader) Reload(cfg pkg.BridgeConfig) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.reloaded = append(r.reloaded, cfg)
+	return nil
+}
+
+func TestReloadLogLevel(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	t.Cleanup(func() { zerolog.SetGlobalLevel(level) })
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	config := filepath.Join(t.TempDir(), "bridge.conf")
+	if err := os.WriteFile(config, []byte("# raised while investigating\nlog-level=debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--log-level", "info", "--config", config}
+
+	// a rejected reload keeps the running log level
+	reload(&configReloader{err: errors.New("immutable fields changed")}, args)
+	if level := zerolog.GlobalLevel(); level != zerolog.InfoLevel {
+		t.Fatalf("expected a rejected reload to keep the info level, got %s", level)
+	}
+
+	br := &configReloader{}
+	reload(br, args)
+	if level := zerolog.GlobalLevel(); level != zerolog.DebugLevel {
+		t.Errorf("expected the reloaded debug level to take effect, got %s", level)
+	}
+	if len(br.reloaded) != 1 || br.reloaded[0].LogLevel != "debug" {
+		t.Errorf("expected the bridge to reload the debug level, got %+v", br.reloaded)
+	}
+}
@@ -0,0 +1,97 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// export formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// csvHeader is the header row of a csv export, the columns follow the fields of Entry
+var csvHeader = []string{"time", "kind", "amount", "source", "destination", "stellar_tx_hash", "tfchain_tx_id", "note", "hash"}
+
+// Export writes the entries of the ledger in r with a time in [from, to) to w in format, entries are written
+// as they are read so the ledger does not have to fit in memory. A json export is an array of entries.
+// It returns the amount of exported entries.
+func Export(r io.Reader, w io.Writer, format string, from, to time.Time) (int, error) {
+	var write func(entry Entry) error
+	var end func() error
+
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		write = func(entry Entry) error {
+			return cw.Write([]string{
+				entry.Time.UTC().Format(time.RFC3339Nano),
+				entry.Kind,
+				strconv.FormatUint(entry.Amount, 10),
+				entry.Source,
+				entry.Destination,
+				entry.StellarTxHash,
+				entry.TfchainTxID,
+				entry.Note,
+				entry.Hash,
+			})
+		}
+		end = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return 0, err
+		}
+		first := true
+		write = func(entry Entry) error {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			_, err = fmt.Fprintf(w, "\n  %s", data)
+			return err
+		}
+		end = func() error {
+			if first {
+				_, err := io.WriteString(w, "]\n")
+				return err
+			}
+			_, err := io.WriteString(w, "\n]\n")
+			return err
+		}
+	default:
+		return 0, fmt.Errorf("unknown export format %q, expected %s or %s", format, FormatCSV, FormatJSON)
+	}
+
+	count := 0
+	err := Read(r, func(entry Entry) error {
+		if entry.Time.Before(from) || !entry.Time.Before(to) {
+			return nil
+		}
+		count++
+		return write(entry)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, errors.Wrap(end(), "failed to write export")
+}
@@ -0,0 +1,97 @@
+package accounting
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportDay = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// exportLedger returns a ledger with a mint and a withdraw on exportDay and a mint on the day after
+func exportLedger(t *testing.T) *bytes.Reader {
+	var buf bytes.Buffer
+	for _, entry := range []Entry{
+		{Time: exportDay.Add(time.Hour), Kind: KindMint, Amount: 1000000000, Source: "GBMM", Destination: "5Grw", StellarTxHash: "a1", Note: "coffee"},
+		{Time: exportDay.Add(2 * time.Hour), Kind: KindWithdraw, Amount: 500000000, Destination: "GBMM", TfchainTxID: "7"},
+		{Time: exportDay.Add(25 * time.Hour), Kind: KindMint, Amount: 300000000, Source: "GBMM", Destination: "5Grw", StellarTxHash: "b2"},
+	} {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestExportCSV(t *testing.T) {
+	var out bytes.Buffer
+	count, err := Export(exportLedger(t), &out, FormatCSV, exportDay, exportDay.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 exported entries, got %d", count)
+	}
+
+	expected := "time,kind,amount,source,destination,stellar_tx_hash,tfchain_tx_id,note,hash\n" +
+		"2024-03-01T01:00:00Z,mint,1000000000,GBMM,5Grw,a1,,coffee,\n" +
+		"2024-03-01T02:00:00Z,withdraw,500000000,,GBMM,,7,,\n"
+	if out.String() != expected {
+		t.Errorf("expected csv\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	var out bytes.Buffer
+	count, err := Export(exportLedger(t), &out, FormatJSON, exportDay, exportDay.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 exported entries, got %d", count)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatalf("expected a json array, got %s: %s", out.String(), err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[1].Kind != KindWithdraw || entries[1].TfchainTxID != "7" || entries[2].StellarTxHash != "b2" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
+
+func TestExportEmptyRange(t *testing.T) {
+	from := exportDay.Add(-48 * time.Hour)
+	to := exportDay.Add(-24 * time.Hour)
+
+	for format, expected := range map[string]string{
+		FormatCSV:  "time,kind,amount,source,destination,stellar_tx_hash,tfchain_tx_id,note,hash\n",
+		FormatJSON: "[]\n",
+	} {
+		var out bytes.Buffer
+		count, err := Export(exportLedger(t), &out, format, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s: expected no exported entries, got %d", format, count)
+		}
+		if out.String() != expected {
+			t.Errorf("%s: expected %q, got %q", format, expected, out.String())
+		}
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	_, err := Export(strings.NewReader(""), &bytes.Buffer{}, "xml", exportDay, exportDay)
+	if err == nil {
+		t.Error("expected an unknown format to fail")
+	}
+}
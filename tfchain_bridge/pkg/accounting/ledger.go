@@ -0,0 +1,186 @@
+package accounting
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// entry kinds, the financial actions of the bridge
+const (
+	// KindMint is a deposit on stellar minted on tfchain
+	KindMint = "mint"
+	// KindWithdraw is a burn on tfchain paid on stellar
+	KindWithdraw = "withdraw"
+	// KindRefund is a deposit on stellar paid back on stellar
+	KindRefund = "refund"
+	// KindRemint is an invalid burn on tfchain minted back to its source
+	KindRemint = "remint"
+)
+
+// Entry is a single financial action of the bridge
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	Amount      uint64    `json:"amount"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination"`
+	// StellarTxHash is the hash of the stellar deposit or payment
+	StellarTxHash string `json:"stellar_tx_hash,omitempty"`
+	// TfchainTxID is the id of the burn or mint transaction on tfchain
+	TfchainTxID string `json:"tfchain_tx_id,omitempty"`
+	// Note is the free-form note of the deposit memo
+	Note string `json:"note,omitempty"`
+	// PrevHash and Hash chain the entries of a hash chained ledger
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// Ledger is an append only file of the financial actions of the bridge in json lines, every entry is synced
+// to disk before Record returns. In a hash chained ledger every entry carries the hash of the previous entry
+// and its own hash, so entries that are changed or removed afterwards are detected by Verify.
+// A nil Ledger records nothing.
+type Ledger struct {
+	mu      sync.Mutex
+	file    *os.File
+	chained bool
+	last    string
+}
+
+// Open opens the ledger at path for appending, a hash chained ledger continues the chain of the entries in the file
+func Open(path string, chained bool) (*Ledger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open accounting ledger")
+	}
+
+	l := &Ledger{file: file, chained: chained}
+	if chained {
+		if err := Read(file, func(entry Entry) error {
+			l.last = entry.Hash
+			return nil
+		}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+// Record appends entry to the ledger and syncs it to disk
+func (l *Ledger) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.PrevHash, entry.Hash = "", ""
+
+	if l.chained {
+		entry.PrevHash = l.last
+		hash, err := entryHash(entry)
+		if err != nil {
+			return err
+		}
+		entry.Hash = hash
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode accounting entry")
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "failed to write accounting entry")
+	}
+	if err := l.file.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync accounting ledger")
+	}
+
+	if l.chained {
+		l.last = entry.Hash
+	}
+	return nil
+}
+
+// Close closes the ledger file
+func (l *Ledger) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Read calls fn for every entry of the ledger in r in order
+func Read(r io.Reader, fn func(entry Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return errors.Wrapf(err, "invalid accounting entry on line %d", line)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(scanner.Err(), "failed to read accounting ledger")
+}
+
+// Verify checks the hash chain of the ledger in r, it returns an error for the first entry that does not
+// match its hash or does not follow the previous entry
+func Verify(r io.Reader) error {
+	var last string
+	line := 0
+	return Read(r, func(entry Entry) error {
+		line++
+		if entry.PrevHash != last {
+			return fmt.Errorf("entry %d does not follow the previous entry", line)
+		}
+
+		hash, err := entryHash(entry)
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("entry %d does not match its hash", line)
+		}
+
+		last = entry.Hash
+		return nil
+	})
+}
+
+// entryHash hashes the entry without its own hash, the previous hash is part of it so the entries form a chain
+func entryHash(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode accounting entry")
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
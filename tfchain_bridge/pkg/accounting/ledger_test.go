@@ -0,0 +1,53 @@
+package accounting
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLedgerHashChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounting.jsonl")
+
+	// the chain continues over a reopen of the ledger
+	for _, entry := range []Entry{
+		{Kind: KindMint, Amount: 1000000000, Source: "GBMM", Destination: "5Grw", StellarTxHash: "a1"},
+		{Kind: KindWithdraw, Amount: 500000000, Destination: "GBMM", TfchainTxID: "7"},
+	} {
+		ledger, err := Open(path, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ledger.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := ledger.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(bytes.NewReader(data)); err != nil {
+		t.Fatalf("expected a valid hash chain, got %s", err)
+	}
+
+	tampered := bytes.Replace(data, []byte(`"amount":500000000`), []byte(`"amount":900000000`), 1)
+	if err := Verify(bytes.NewReader(tampered)); err == nil {
+		t.Error("expected a changed entry to break the hash chain")
+	}
+	removed := data[bytes.IndexByte(data, '\n')+1:]
+	if err := Verify(bytes.NewReader(removed)); err == nil {
+		t.Error("expected a removed entry to break the hash chain")
+	}
+}
+
+func TestNilLedgerRecordsNothing(t *testing.T) {
+	var ledger *Ledger
+	if err := ledger.Record(Entry{Kind: KindMint}); err != nil {
+		t.Errorf("expected a nil ledger to record nothing, got %s", err)
+	}
+}
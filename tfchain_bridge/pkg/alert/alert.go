@@ -0,0 +1,72 @@
+package alert
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// KindDailyLimitExceeded is raised when a deposit would exceed the daily mint limit of its target
+	KindDailyLimitExceeded = "daily_limit_exceeded"
+	// KindInsufficientReserve is raised when the bridge account can not pay a refund without going below its minimum balance
+	KindInsufficientReserve = "insufficient_reserve"
+	// KindMalformedEvent is raised when a tfchain event is skipped because its content is invalid
+	KindMalformedEvent = "malformed_event"
+	// KindMemoRequired is raised when a withdraw is held because its destination requires a memo
+	KindMemoRequired = "memo_required"
+	// KindWithdrawNotAllowed is raised when a withdraw targets a destination that is not allowlisted
+	KindWithdrawNotAllowed = "withdraw_not_allowed"
+	// KindDeadLetter is raised when events failed permanently and were dropped
+	KindDeadLetter = "dead_letter"
+	// KindLowBalance is raised when the XLM balance of the bridge account drops below the configured threshold
+	KindLowBalance = "low_balance"
+	// KindSignatureTimeout is raised when a withdraw waits for validator signatures longer than the configured timeout
+	KindSignatureTimeout = "signature_timeout"
+	// KindRefundRefused is raised when a refund is above the deposited amount or the refund ceiling
+	KindRefundRefused = "refund_refused"
+	// KindSignerSetMismatch is raised when the signers of the bridge account diverge from the bridge validators on chain
+	KindSignerSetMismatch = "signer_set_mismatch"
+	// KindCircuitBreakerOpen is raised when the processing of an event type is paused after consecutive failures
+	KindCircuitBreakerOpen = "circuit_breaker_open"
+	// KindValidatorRemoved is raised when the bridge account is no longer a validator of the bridge
+	KindValidatorRemoved = "validator_removed"
+	// KindUnsupportedAsset is raised when an asset that is not bridged is paid to the bridge account
+	KindUnsupportedAsset = "unsupported_asset"
+	// KindDepositBurst is raised when a deposit is quarantined because its source deposits too often or too much
+	KindDepositBurst = "deposit_burst"
+	// KindNoTrustline is raised when the bridge account lost its trustline for the bridged asset
+	KindNoTrustline = "no_trustline"
+	// KindInsufficientFunds is raised when the tfchain account submitting the bridge extrinsics can not pay their fees
+	KindInsufficientFunds = "insufficient_funds"
+	// KindConfigDivergence is raised when the configuration of the bridge differs from the majority of its peers
+	KindConfigDivergence = "config_divergence"
+)
+
+// Alert describes a condition that requires the attention of an operator
+type Alert struct {
+	Kind    string
+	Message string
+	Fields  map[string]string
+}
+
+// Alerter delivers alerts to operators
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// LogAlerter writes alerts to the log, it is the default alerter of the bridge
+type LogAlerter struct{}
+
+func NewLogAlerter() *LogAlerter {
+	return &LogAlerter{}
+}
+
+func (a *LogAlerter) Alert(ctx context.Context, alert Alert) error {
+	event := log.Warn().Str("alert", alert.Kind)
+	for k, v := range alert.Fields {
+		event = event.Str(k, v)
+	}
+	event.Msg(alert.Message)
+	return nil
+}
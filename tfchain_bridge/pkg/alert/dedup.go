@@ -0,0 +1,97 @@
+package alert
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DedupAlerter suppresses identical alerts within a window per alert kind. The first alert of a group
+// is delivered right away, the alerts repeated within the window are delivered as a single grouped
+// alert with their count when the window ends.
+type DedupAlerter struct {
+	next          Alerter
+	windows       map[string]time.Duration
+	defaultWindow time.Duration
+
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+type group struct {
+	alert Alert
+	count int
+}
+
+// NewDedupAlerter wraps next, windows sets the suppression window of alert kinds and defaultWindow
+// the window of all other kinds. A window of 0 disables deduplication for the kind.
+func NewDedupAlerter(next Alerter, defaultWindow time.Duration, windows map[string]time.Duration) *DedupAlerter {
+	return &DedupAlerter{
+		next:          next,
+		windows:       windows,
+		defaultWindow: defaultWindow,
+		groups:        make(map[string]*group),
+	}
+}
+
+func (a *DedupAlerter) Alert(ctx context.Context, alert Alert) error {
+	window := a.window(alert.Kind)
+	if window <= 0 {
+		return a.next.Alert(ctx, alert)
+	}
+
+	key := alert.Kind + "/" + alert.Message
+
+	a.mu.Lock()
+	if g, ok := a.groups[key]; ok {
+		g.count++
+		a.mu.Unlock()
+		return nil
+	}
+	a.groups[key] = &group{alert: alert}
+	a.mu.Unlock()
+
+	time.AfterFunc(window, func() {
+		a.flush(key, window)
+	})
+
+	return a.next.Alert(ctx, alert)
+}
+
+func (a *DedupAlerter) window(kind string) time.Duration {
+	if window, ok := a.windows[kind]; ok {
+		return window
+	}
+	return a.defaultWindow
+}
+
+// flush ends the window of a group and sends a grouped alert if alerts were suppressed
+func (a *DedupAlerter) flush(key string, window time.Duration) {
+	a.mu.Lock()
+	g := a.groups[key]
+	delete(a.groups, key)
+	a.mu.Unlock()
+
+	if g == nil || g.count == 0 {
+		return
+	}
+
+	fields := make(map[string]string, len(g.alert.Fields)+2)
+	for k, v := range g.alert.Fields {
+		fields[k] = v
+	}
+	fields["count"] = strconv.Itoa(g.count)
+	fields["window"] = window.String()
+
+	grouped := Alert{
+		Kind:    g.alert.Kind,
+		Message: g.alert.Message + " (repeated)",
+		Fields:  fields,
+	}
+	if err := a.next.Alert(context.Background(), grouped); err != nil {
+		log.Err(err).Str("alert", g.alert.Kind).Msg("failed to send grouped alert")
+	}
+}
@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// channelAlerter sends the delivered alerts on a channel
+type channelAlerter chan Alert
+
+func (a channelAlerter) Alert(ctx context.Context, alert Alert) error {
+	a <- alert
+	return nil
+}
+
+// receive returns the next delivered alert, or false if none is delivered within timeout
+func (a channelAlerter) receive(timeout time.Duration) (Alert, bool) {
+	select {
+	case alert := <-a:
+		return alert, true
+	default:
+	}
+
+	select {
+	case alert := <-a:
+		return alert, true
+	case <-time.After(timeout):
+		return Alert{}, false
+	}
+}
+
+func TestDedupAlerterGroupsIdenticalAlerts(t *testing.T) {
+	const window = 50 * time.Millisecond
+	delivered := make(channelAlerter, 10)
+	alerter := NewDedupAlerter(delivered, window, nil)
+
+	reserve := Alert{Kind: KindInsufficientReserve, Message: "insufficient reserve", Fields: map[string]string{"tx_id": "a"}}
+	for i := 0; i < 3; i++ {
+		if err := alerter.Alert(context.Background(), reserve); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, ok := delivered.receive(0)
+	if !ok || first.Message != reserve.Message {
+		t.Fatalf("expected the first alert to be delivered right away, got %+v", first)
+	}
+	if alert, ok := delivered.receive(window / 2); ok {
+		t.Fatalf("expected the repeated alerts to be suppressed within the window, got %+v", alert)
+	}
+
+	grouped, ok := delivered.receive(time.Second)
+	if !ok {
+		t.Fatal("expected a grouped alert when the window ends")
+	}
+	if grouped.Kind != KindInsufficientReserve || grouped.Message != "insufficient reserve (repeated)" {
+		t.Errorf("unexpected grouped alert %+v", grouped)
+	}
+	if grouped.Fields["count"] != "2" || grouped.Fields["window"] != window.String() || grouped.Fields["tx_id"] != "a" {
+		t.Errorf("unexpected grouped alert fields %v", grouped.Fields)
+	}
+
+	// a new window starts after the grouped alert
+	if err := alerter.Alert(context.Background(), reserve); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := delivered.receive(0); !ok {
+		t.Error("expected the alert of a new window to be delivered right away")
+	}
+}
+
+func TestDedupAlerterWindows(t *testing.T) {
+	const window = 50 * time.Millisecond
+	delivered := make(channelAlerter, 10)
+	alerter := NewDedupAlerter(delivered, window, map[string]time.Duration{KindMalformedEvent: 0})
+
+	alerts := []Alert{
+		{Kind: KindDailyLimitExceeded, Message: "deposit a exceeds the limit"},
+		// another message is another group
+		{Kind: KindDailyLimitExceeded, Message: "deposit b exceeds the limit"},
+		// grouping is disabled for malformed events
+		{Kind: KindMalformedEvent, Message: "malformed"},
+		{Kind: KindMalformedEvent, Message: "malformed"},
+	}
+	for _, alert := range alerts {
+		if err := alerter.Alert(context.Background(), alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, expected := range alerts {
+		alert, ok := delivered.receive(0)
+		if !ok || alert.Message != expected.Message {
+			t.Fatalf("expected %q to be delivered, got %+v", expected.Message, alert)
+		}
+	}
+	// nothing was suppressed so the windows end without a grouped alert
+	if alert, ok := delivered.receive(2 * window); ok {
+		t.Errorf("expected no grouped alert, got %+v", alert)
+	}
+}
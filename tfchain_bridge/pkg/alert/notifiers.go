@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// notifierTimeout bounds a single delivery of an alert
+	notifierTimeout = 10 * time.Second
+	// PagerDutyEventsURL is the endpoint of the PagerDuty events api v2
+	PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	// pagerDutySource identifies the bridge as the source of its PagerDuty events
+	pagerDutySource = "tfchain_bridge"
+)
+
+// MultiAlerter delivers alerts to all of its alerters, a failing alerter does not hold back the others
+type MultiAlerter struct {
+	alerters []Alerter
+}
+
+func NewMultiAlerter(alerters ...Alerter) *MultiAlerter {
+	return &MultiAlerter{alerters: alerters}
+}
+
+// Alert returns the last delivery error, the other errors are logged
+func (a *MultiAlerter) Alert(ctx context.Context, alert Alert) error {
+	var last error
+	for _, alerter := range a.alerters {
+		if err := alerter.Alert(ctx, alert); err != nil {
+			if last != nil {
+				log.Err(last).Str("alert", alert.Kind).Msg("failed to send alert")
+			}
+			last = err
+		}
+	}
+	return last
+}
+
+// WebhookAlerter posts alerts as json to a generic webhook
+type WebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{url: url, client: &http.Client{Timeout: notifierTimeout}}
+}
+
+type webhookPayload struct {
+	Kind    string            `json:"kind"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+func (a *WebhookAlerter) Alert(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, a.client, a.url, webhookPayload{
+		Kind:    alert.Kind,
+		Message: alert.Message,
+		Fields:  alert.Fields,
+	})
+}
+
+// SlackAlerter posts alerts to a Slack incoming webhook
+type SlackAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func NewSlackAlerter(url string) *SlackAlerter {
+	return &SlackAlerter{url: url, client: &http.Client{Timeout: notifierTimeout}}
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+func (a *SlackAlerter) Alert(ctx context.Context, alert Alert) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "*[%s]* %s", alert.Kind, alert.Message)
+	for _, k := range sortedKeys(alert.Fields) {
+		fmt.Fprintf(&text, "\n• %s: `%s`", k, alert.Fields[k])
+	}
+	return postJSON(ctx, a.client, a.url, slackPayload{Text: text.String()})
+}
+
+// PagerDutyAlerter triggers PagerDuty incidents through the events api v2
+type PagerDutyAlerter struct {
+	url        string
+	routingKey string
+	client     *http.Client
+}
+
+// NewPagerDutyAlerter triggers events with the routing key of a PagerDuty integration on url, usually PagerDutyEventsURL
+func NewPagerDutyAlerter(url string, routingKey string) *PagerDutyAlerter {
+	return &PagerDutyAlerter{url: url, routingKey: routingKey, client: &http.Client{Timeout: notifierTimeout}}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Alert triggers an event deduplicated by kind and message, so repeated alerts update the open incident
+func (a *PagerDutyAlerter) Alert(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, a.client, a.url, pagerDutyEvent{
+		RoutingKey:  a.routingKey,
+		EventAction: "trigger",
+		DedupKey:    alert.Kind + "/" + alert.Message,
+		Payload: pagerDutyPayload{
+			Summary:       alert.Message,
+			Source:        pagerDutySource,
+			Severity:      "warning",
+			Component:     alert.Kind,
+			CustomDetails: alert.Fields,
+		},
+	})
+}
+
+// postJSON posts body as json to url, any status other than 2xx is an error
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func sortedKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
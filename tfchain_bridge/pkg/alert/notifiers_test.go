@@ -0,0 +1,114 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// receiver records the json bodies posted to it and responds with status
+func receiver(t *testing.T, status int) (*httptest.Server, <-chan map[string]interface{}) {
+	bodies := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected a json post, got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("invalid json body %q: %s", data, err)
+		}
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+var testAlert = Alert{
+	Kind:    KindLowBalance,
+	Message: "bridge account is running out of XLM",
+	Fields:  map[string]string{"balance": "5.0000000", "account": "GDCA"},
+}
+
+func TestNotifierPayloads(t *testing.T) {
+	tests := []struct {
+		name     string
+		alerter  func(url string) Alerter
+		expected map[string]interface{}
+	}{
+		{
+			name:    "webhook",
+			alerter: func(url string) Alerter { return NewWebhookAlerter(url) },
+			expected: map[string]interface{}{
+				"kind":    KindLowBalance,
+				"message": "bridge account is running out of XLM",
+				"fields":  map[string]interface{}{"balance": "5.0000000", "account": "GDCA"},
+			},
+		},
+		{
+			name:    "slack",
+			alerter: func(url string) Alerter { return NewSlackAlerter(url) },
+			expected: map[string]interface{}{
+				"text": "*[low_balance]* bridge account is running out of XLM\n• account: `GDCA`\n• balance: `5.0000000`",
+			},
+		},
+		{
+			name:    "pagerduty",
+			alerter: func(url string) Alerter { return NewPagerDutyAlerter(url, "routing-key") },
+			expected: map[string]interface{}{
+				"routing_key":  "routing-key",
+				"event_action": "trigger",
+				"dedup_key":    "low_balance/bridge account is running out of XLM",
+				"payload": map[string]interface{}{
+					"summary":        "bridge account is running out of XLM",
+					"source":         "tfchain_bridge",
+					"severity":       "warning",
+					"component":      KindLowBalance,
+					"custom_details": map[string]interface{}{"balance": "5.0000000", "account": "GDCA"},
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, bodies := receiver(t, http.StatusAccepted)
+			if err := test.alerter(server.URL).Alert(context.Background(), testAlert); err != nil {
+				t.Fatal(err)
+			}
+			if body := <-bodies; !reflect.DeepEqual(body, test.expected) {
+				t.Errorf("expected payload %v, got %v", test.expected, body)
+			}
+		})
+	}
+}
+
+func TestNotifierRejected(t *testing.T) {
+	server, _ := receiver(t, http.StatusInternalServerError)
+	if err := NewWebhookAlerter(server.URL).Alert(context.Background(), testAlert); err == nil {
+		t.Error("expected a rejected alert to fail")
+	}
+}
+
+// failingAlerter fails every delivery
+type failingAlerter struct{}
+
+func (failingAlerter) Alert(ctx context.Context, alert Alert) error { return io.ErrUnexpectedEOF }
+
+func TestMultiAlerterDeliversToAll(t *testing.T) {
+	delivered := make(channelAlerter, 1)
+	err := NewMultiAlerter(failingAlerter{}, delivered).Alert(context.Background(), testAlert)
+	if err == nil {
+		t.Error("expected the failed delivery to be returned")
+	}
+	if _, ok := delivered.receive(0); !ok {
+		t.Error("expected the alert to be delivered after a failing alerter")
+	}
+}
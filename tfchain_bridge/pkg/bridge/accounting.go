@@ -0,0 +1,18 @@
+package bridge
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+)
+
+// recordAccounting appends a financial action to the accounting ledger, nothing is recorded in observer mode
+// as no action is taken. The action is done already so failing to record it does not fail the handler.
+func (bridge *Bridge) recordAccounting(entry accounting.Entry) {
+	if bridge.config.ObserverMode {
+		return
+	}
+
+	if err := bridge.accounting.Record(entry); err != nil {
+		log.Err(err).Str("kind", entry.Kind).Str("stellar_tx_hash", entry.StellarTxHash).Str("tfchain_tx_id", entry.TfchainTxID).Uint64("amount", entry.Amount).Msg("failed to record accounting entry")
+	}
+}
@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+func TestAccountingEntries(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	tfchain.addTwin(t, 1, testTwinAddress)
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	path := filepath.Join(t.TempDir(), "accounting.jsonl")
+	ledger, err := accounting.Open(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge.accounting = ledger
+	t.Cleanup(func() { ledger.Close() })
+
+	ctx := testContext(t)
+	minted := testDeposit(1, testSender, 1000000000, "twin_1")
+	if err := wallet.deposit(ctx, bridge, minted); err != nil {
+		t.Fatal(err)
+	}
+	refunded := testDeposit(2, testSender, 50000000, "twin")
+	if err := wallet.deposit(ctx, bridge, refunded); err != nil {
+		t.Fatal(err)
+	}
+	err = bridge.dispatchTfchainEvents(ctx, bridge.events, subpkg.Events{
+		RefundReadyEvents: []subpkg.RefundTransactionReadyEvent{{Hash: refunded.Tx.Hash}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []accounting.Entry
+	if err := accounting.Read(file, func(entry accounting.Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected a mint and a refund entry, got %+v", entries)
+	}
+	mint, refund := entries[0], entries[1]
+	if mint.Kind != accounting.KindMint || mint.Amount != 1000000000 || mint.Source != testSender || mint.Destination != testTwinAddress || mint.StellarTxHash != minted.Tx.Hash {
+		t.Errorf("unexpected mint entry %+v", mint)
+	}
+	if refund.Kind != accounting.KindRefund || refund.Amount != uint64(tfchain.refunds[refunded.Tx.Hash].Amount) || refund.Destination != testSender || refund.StellarTxHash != refunded.Tx.Hash {
+		t.Errorf("unexpected refund entry %+v", refund)
+	}
+	if refund.PrevHash != mint.Hash {
+		t.Errorf("expected the refund entry to follow the mint entry in the hash chain")
+	}
+}
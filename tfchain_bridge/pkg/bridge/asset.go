@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// handleForeignPayments handles the payments of assets that are not bridged according to the unsupported
+// asset policy, they are never minted. The payments of the bridged asset in the same transaction are minted as usual.
+func (bridge *Bridge) handleForeignPayments(ctx context.Context, tx hProtocol.Transaction, payments []stellar.ForeignPayment) error {
+	for _, payment := range payments {
+		log.Warn().Str("tx_id", tx.Hash).Str("from", payment.From).Str("asset", payment.Asset).Int64("amount", payment.Amount).Str("policy", bridge.config.UnsupportedAssetPolicy).Msg("payment of an unsupported asset to the bridge account")
+
+		switch bridge.config.UnsupportedAssetPolicy {
+		case pkg.UnsupportedAssetPolicyIgnore:
+			continue
+		case pkg.UnsupportedAssetPolicyHold:
+			err := bridge.blockPersistency.HoldDeposit(pkg.HeldDeposit{
+				TxHash:      tx.Hash,
+				PagingToken: tx.PagingToken(),
+				Sender:      payment.From,
+				Target:      payment.From,
+				Amount:      payment.Amount,
+				Reason:      alert.KindUnsupportedAsset,
+				HeldAt:      time.Now(),
+				Asset:       payment.Asset,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := bridge.alerter.Alert(ctx, alert.Alert{
+			Kind:    alert.KindUnsupportedAsset,
+			Message: "payment of an asset that is not bridged received on the bridge account",
+			Fields: map[string]string{
+				"tx_id":  tx.Hash,
+				"from":   payment.From,
+				"asset":  payment.Asset,
+				"amount": fmt.Sprint(payment.Amount),
+				"policy": bridge.config.UnsupportedAssetPolicy,
+			},
+		})
+		if err != nil {
+			log.Err(err).Msg("failed to send alert")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+func TestUnsupportedAssetPolicy(t *testing.T) {
+	const usdc = "USDC:GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+
+	tests := []struct {
+		policy string
+		alerts []string
+		held   bool
+	}{
+		{policy: pkg.UnsupportedAssetPolicyIgnore},
+		{policy: pkg.UnsupportedAssetPolicyAlert, alerts: []string{alert.KindUnsupportedAsset}},
+		{policy: pkg.UnsupportedAssetPolicyHold, alerts: []string{alert.KindUnsupportedAsset}, held: true},
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			calls := &callLog{}
+			wallet := newFakeWallet(calls, 100)
+			bridge := newTestBridge(t, pkg.BridgeConfig{UnsupportedAssetPolicy: test.policy}, newFakeTfchain(calls), wallet, 10000000)
+
+			deposit := testDeposit(1, testSender, 0, "twin_1")
+			deposit.Senders = nil
+			deposit.ForeignPayments = []stellar.ForeignPayment{{From: testSender, Asset: usdc, Amount: 50000000}}
+			if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+				t.Fatal(err)
+			}
+
+			// a foreign payment is never minted nor refunded on chain
+			assertCalls(t, nil, calls.get())
+			if kinds := bridge.alerter.(*recordingAlerter).kinds(); !reflect.DeepEqual(kinds, test.alerts) {
+				t.Errorf("expected alerts %v, got %v", test.alerts, kinds)
+			}
+			height, err := bridge.blockPersistency.GetHeight()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if height.StellarCursor != deposit.Tx.PT {
+				t.Errorf("expected the cursor to be saved past the payment, got %q", height.StellarCursor)
+			}
+			if !test.held {
+				if len(height.HeldDeposits) != 0 {
+					t.Errorf("expected no held deposits, got %v", height.HeldDeposits)
+				}
+				return
+			}
+			if len(height.HeldDeposits) != 1 {
+				t.Fatalf("expected the payment to be held, got %v", height.HeldDeposits)
+			}
+			held := height.HeldDeposits[0]
+			if held.Target != testSender || held.Asset != usdc || held.Amount != 50000000 {
+				t.Errorf("expected 50000000 %s to be held for %s, got %+v", usdc, testSender, held)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/amount"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// monitorBalance periodically exposes the XLM balance of the bridge account and alerts when it drops
+// below the configured threshold, the account pays the network fee of every refund and withdraw.
+// The trustline of the bridge account is checked at the same interval.
+func (bridge *Bridge) monitorBalance(ctx context.Context) {
+	interval := bridge.config.BalanceCheckInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		bridge.checkBalance(ctx)
+		bridge.checkTrustline(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (bridge *Bridge) checkBalance(ctx context.Context) {
+	balance, err := bridge.wallet.GetBalance(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to get bridge account balance")
+		return
+	}
+
+	stellarBalance.Set(float64(balance) / float64(amount.One))
+
+	threshold := bridge.settings().LowBalanceThreshold
+	if threshold <= 0 || balance >= threshold {
+		return
+	}
+
+	log.Warn().Str("balance", amount.StringFromInt64(balance)).Str("threshold", amount.StringFromInt64(threshold)).Msg("bridge account is running out of XLM to pay network fees")
+	err = bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindLowBalance,
+		Message: "bridge account XLM balance is below the threshold",
+		Fields: map[string]string{
+			"balance":   fmt.Sprint(balance),
+			"threshold": fmt.Sprint(threshold),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+}
+
+// checkTrustline alerts when the bridge account no longer holds a trustline for the bridged asset,
+// withdraws and refunds fail on submission until it is restored
+func (bridge *Bridge) checkTrustline(ctx context.Context) {
+	err := bridge.wallet.CheckBridgeTrustline(ctx)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, stellar.ErrNoTrustline) {
+		log.Err(err).Msg("failed to check bridge account trustline")
+		return
+	}
+
+	log.Error().Str("account", bridge.config.StellarBridgeAccount).Msg("bridge account has no trustline for the bridged asset")
+	err = bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindNoTrustline,
+		Message: "bridge account has no trustline for the bridged asset, withdraws and refunds can not be paid",
+		Fields: map[string]string{
+			"account": bridge.config.StellarBridgeAccount,
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+}
@@ -0,0 +1,106 @@
+package bridge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// balanceWallet holds a fixed XLM balance
+type balanceWallet struct {
+	stellarWallet
+	balance int64
+	err     error
+}
+
+func (w *balanceWallet) GetBalance(ctx context.Context) (int64, error) {
+	return w.balance, w.err
+}
+
+// trustlineWallet fails the trustline check of the bridge account with err
+type trustlineWallet struct {
+	stellarWallet
+	err error
+}
+
+func (w *trustlineWallet) CheckBridgeTrustline(ctx context.Context) error {
+	return w.err
+}
+
+func TestCheckBalance(t *testing.T) {
+	tests := []struct {
+		name      string
+		balance   int64
+		err       error
+		threshold int64
+		gauge     float64
+		alerted   bool
+	}{
+		{name: "above threshold", balance: 200000000, threshold: 100000000, gauge: 20},
+		{name: "at threshold", balance: 100000000, threshold: 100000000, gauge: 10},
+		{name: "below threshold", balance: 50000000, threshold: 100000000, gauge: 5, alerted: true},
+		{name: "alert disabled", balance: 50000000, gauge: 5},
+		{name: "balance unavailable", err: errors.New("horizon is down"), threshold: 100000000, gauge: -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stellarBalance.Set(-1)
+			alerter := &recordingAlerter{}
+			bridge := &Bridge{
+				wallet:  &balanceWallet{balance: test.balance, err: test.err},
+				config:  &pkg.BridgeConfig{LowBalanceThreshold: test.threshold},
+				alerter: alerter,
+			}
+
+			bridge.checkBalance(context.Background())
+
+			if gauge := stellarBalance.Get(); gauge != test.gauge {
+				t.Errorf("expected the balance gauge to be %v, got %v", test.gauge, gauge)
+			}
+			var expected []string
+			if test.alerted {
+				expected = []string{alert.KindLowBalance}
+			}
+			if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, expected) {
+				t.Errorf("expected alerts %v, got %v", expected, kinds)
+			}
+		})
+	}
+}
+
+func TestCheckTrustline(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		alerted bool
+	}{
+		{name: "trustline"},
+		{name: "missing trustline", err: stellar.ErrNoTrustline, alerted: true},
+		{name: "horizon unavailable", err: errors.New("horizon is down")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			alerter := &recordingAlerter{}
+			bridge := &Bridge{
+				wallet:  &trustlineWallet{err: test.err},
+				config:  &pkg.BridgeConfig{},
+				alerter: alerter,
+			}
+
+			bridge.checkTrustline(context.Background())
+
+			var expected []string
+			if test.alerted {
+				expected = []string{alert.KindNoTrustline}
+			}
+			if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, expected) {
+				t.Errorf("expected alerts %v, got %v", expected, kinds)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// breaker counts the consecutive failures of a route and opens once they reach the threshold,
+// an open breaker pauses the route for the cooldown after which the next attempt is let through.
+// A success closes the breaker, a failure while it is at the threshold opens it again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// record counts the outcome of an attempt and returns the consecutive failures and whether the breaker opened
+func (b *breaker) record(err error) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return 0, false
+	}
+
+	b.failures++
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return b.failures, false
+	}
+
+	b.openUntil = time.Now().Add(b.cooldown)
+	return b.failures, true
+}
+
+func (b *breaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return time.Now().Before(b.openUntil)
+}
+
+// wait blocks while the breaker is open
+func (b *breaker) wait(ctx context.Context, name string) error {
+	b.mu.Lock()
+	remaining := time.Until(b.openUntil)
+	b.mu.Unlock()
+
+	if remaining <= 0 {
+		return nil
+	}
+
+	log.Info().Str("route", name).Dur("remaining", remaining).Msg("circuit breaker is open, waiting for the cooldown")
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBreaker(t *testing.T) {
+	failure := errors.New("failure")
+
+	tests := []struct {
+		name      string
+		threshold int
+		outcomes  []error
+		failures  int
+		open      bool
+	}{
+		{name: "closed below the threshold", threshold: 3, outcomes: []error{failure, failure}, failures: 2},
+		{name: "opens at the threshold", threshold: 3, outcomes: []error{failure, failure, failure}, failures: 3, open: true},
+		{name: "opens again after the threshold", threshold: 3, outcomes: []error{failure, failure, failure, failure}, failures: 4, open: true},
+		{name: "success closes it", threshold: 3, outcomes: []error{failure, failure, failure, nil}},
+		{name: "success resets the streak", threshold: 3, outcomes: []error{failure, failure, nil, failure, failure}, failures: 2},
+		{name: "never opens without threshold", outcomes: []error{failure, failure, failure, failure}, failures: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &breaker{threshold: test.threshold, cooldown: time.Minute}
+
+			var (
+				failures int
+				opened   bool
+			)
+			for _, outcome := range test.outcomes {
+				failures, opened = b.record(outcome)
+			}
+			if failures != test.failures {
+				t.Errorf("expected %d failures, got %d", test.failures, failures)
+			}
+			if opened != test.open {
+				t.Errorf("expected opened %t, got %t", test.open, opened)
+			}
+			if b.isOpen() != test.open {
+				t.Errorf("expected open %t, got %t", test.open, b.isOpen())
+			}
+		})
+	}
+}
+
+func TestBreakerWaitsForCooldown(t *testing.T) {
+	b := &breaker{threshold: 1, cooldown: 50 * time.Millisecond}
+	b.record(errors.New("failure"))
+
+	start := time.Now()
+	if err := b.wait(testContext(t), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 40*time.Millisecond {
+		t.Errorf("expected to wait for the cooldown, waited %s", waited)
+	}
+	if b.isOpen() {
+		t.Error("breaker is still open after the cooldown")
+	}
+
+	ctx, cancel := context.WithCancel(testContext(t))
+	b.record(errors.New("failure"))
+	cancel()
+	if err := b.wait(ctx, "test"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the wait to stop with the context, got %v", err)
+	}
+}
+
+func TestRouteBreakerOpensAndCloses(t *testing.T) {
+	ctx := testContext(t)
+	var opened []string
+	events := newDispatcher(2, 50*time.Millisecond, 0, ignoreDeadLetter, func(ctx context.Context, route string, failures int, err error) {
+		opened = append(opened, fmt.Sprintf("%s %d", route, failures))
+	})
+	events.start(ctx)
+
+	fail := func(ctx context.Context) error { return errors.New("tfchain is down") }
+	for i := 0; i < 2; i++ {
+		if err := events.mint.dispatch(ctx, fail); err == nil {
+			t.Fatal("expected the mint events to fail")
+		}
+	}
+	if breakers := events.breakers(); !breakers["mint events"] || breakers["withdraw ready"] {
+		t.Fatalf("expected only the mint breaker to be open, got %v", breakers)
+	}
+	if !reflect.DeepEqual(opened, []string{"mint events 2"}) {
+		t.Errorf("expected the opening of the mint breaker to be reported once, got %v", opened)
+	}
+
+	// the next mint events wait for the cooldown and close the breaker
+	start := time.Now()
+	if err := events.mint.dispatch(ctx, func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 40*time.Millisecond {
+		t.Errorf("expected the mint events to wait for the cooldown, waited %s", waited)
+	}
+	if breakers := events.breakers(); breakers["mint events"] {
+		t.Errorf("expected the mint breaker to be closed, got %v", breakers)
+	}
+}
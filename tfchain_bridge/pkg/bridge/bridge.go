@@ -2,12 +2,21 @@ package bridge
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/strkey"
 	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
 	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
 	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+	"github.com/threefoldtech/tfchain_bridge/pkg/version"
 )
 
 const (
@@ -17,15 +26,73 @@ const (
 // Bridge is a high lvl structure which listens on contract events and bridge-related
 // stellar transactions, and handles them
 type Bridge struct {
-	wallet           *stellar.StellarWallet
-	subClient        *subpkg.SubstrateClient
+	wallet           stellarWallet
+	subClient        tfchainClient
 	blockPersistency *pkg.ChainPersistency
-	config           *pkg.BridgeConfig
-	depositFee       int64
+	// mu guards the reloadable fields of config
+	mu           sync.RWMutex
+	config       *pkg.BridgeConfig
+	depositFee   int64
+	alerter      alert.Alerter
+	outstanding  *outstanding
+	signatures   *signatureTracker
+	addressCache *addressCache
+	bursts       *burstTracker
+	cursor       *cursorTracker
+	checkpoint   *cursorCheckpoint
+	fingerprint  pkg.ConfigFingerprint
+	events       *dispatcher
+	pause        *pauseState
+	accounting   *accounting.Ledger
 }
 
-func NewBridge(ctx context.Context, cfg pkg.BridgeConfig) (*Bridge, error) {
-	subClient, err := subpkg.NewSubstrateClient(cfg.TfchainURL, cfg.TfchainSeed)
+func NewBridge(ctx context.Context, cfg pkg.BridgeConfig, opts ...Option) (*Bridge, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if cfg.ObserverMode {
+		log.Warn().Msg("running in observer mode, no extrinsics or stellar payments are submitted")
+	}
+	log.Info().Str("version", version.Version).Str("commit", version.Commit).Str("build_date", version.BuildDate).Msg("starting bridge")
+	buildInfo.Set(1, version.Version, version.Commit, version.BuildDate)
+
+	if err := validateConfig(cfg); err != nil {
+		return nil, pkg.InvalidConfig(err)
+	}
+
+	signer := o.tfchainSigner
+	if signer == nil {
+		var err error
+		if signer, err = subpkg.NewSigner(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	alerter := o.alerter
+	if alerter == nil {
+		alerter = newNotifiers(cfg)
+	}
+	alerter = alert.NewDedupAlerter(alerter, cfg.AlertDedupWindow, cfg.AlertDedupWindows)
+
+	proxy, err := subpkg.NewProxySigner(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	subClient, err := subpkg.NewSubstrateClient(cfg.TfchainURLs(), signer, subpkg.ExtrinsicOptions{
+		Tip:         cfg.TfchainTip,
+		Mortality:   cfg.TfchainMortality,
+		DryRun:      cfg.ObserverMode,
+		LocalNonces: cfg.TfchainLocalNonces,
+		Proxy:       proxy,
+		// submissions wait for the account to be funded, the alert is sent once when it runs out of funds
+		FeeRetryInterval: cfg.TfchainFeeRetryInterval,
+		OnInsufficientFunds: func(account string) {
+			alertInsufficientFunds(ctx, alerter, account)
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -40,11 +107,16 @@ func NewBridge(ctx context.Context, cfg pkg.BridgeConfig) (*Bridge, error) {
 		return nil, err
 	}
 
+	// withdraws would be signed and fail on submission without a trustline, fail fast instead
+	if err := wallet.CheckBridgeTrustline(ctx); err != nil {
+		return nil, errors.Wrapf(err, "bridge account %s can not hold the bridged asset", cfg.StellarBridgeAccount)
+	}
+
 	if cfg.RescanBridgeAccount {
 		// saving the cursor to 0 will trigger the bridge stellar account
 		// to scan for every transaction ever made on the bridge account
 		// and mint accordingly
-		err = blockPersistency.SaveStellarCursor("0")
+		err = blockPersistency.ResetStellarCursor("0")
 		if err != nil {
 			return nil, err
 		}
@@ -59,6 +131,17 @@ func NewBridge(ctx context.Context, cfg pkg.BridgeConfig) (*Bridge, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := ValidateDepositFee(depositFee, cfg.MaxDepositFee); err != nil {
+		return nil, err
+	}
+
+	var ledger *accounting.Ledger
+	if cfg.AccountingLedger != "" {
+		ledger, err = accounting.Open(cfg.AccountingLedger, cfg.AccountingHashChain)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	bridge := &Bridge{
 		subClient:        subClient,
@@ -66,23 +149,172 @@ func NewBridge(ctx context.Context, cfg pkg.BridgeConfig) (*Bridge, error) {
 		wallet:           wallet,
 		config:           &cfg,
 		depositFee:       depositFee,
+		alerter:          alerter,
+		outstanding:      newOutstanding(),
+		signatures:       newSignatureTracker(),
+		addressCache:     newAddressCache(cfg.MemoCacheTTL),
+		bursts:           newBurstTracker(cfg.DepositBurstWindow, cfg.DepositBurstCount, cfg.DepositBurstAmount),
+		cursor:           &cursorTracker{},
+		checkpoint:       newCursorCheckpoint(cfg.CursorCheckpointCount, cfg.CursorCheckpointInterval),
+		pause:            newPauseState(),
+		accounting:       ledger,
+	}
+	bridge.events = newDispatcher(cfg.BreakerThreshold, cfg.BreakerCooldown, cfg.EventTimeout, bridge.recordDeadLetter, bridge.alertBreakerOpened)
+
+	assetCode, assetIssuer := wallet.Asset()
+	bridge.fingerprint = pkg.ConfigFingerprint{
+		Network:           cfg.StellarNetwork,
+		BridgeAccount:     cfg.StellarBridgeAccount,
+		AssetCode:         assetCode,
+		AssetIssuer:       assetIssuer,
+		DepositFee:        depositFee,
+		BaseFee:           cfg.StellarBaseFee,
+		PaymentTimeout:    cfg.StellarPaymentTimeout,
+		ClaimableBalances: cfg.StellarClaimableBalances,
 	}
+	setConfigMetrics(bridge.fingerprint)
 
 	return bridge, nil
 }
 
-func (bridge *Bridge) Start(ctx context.Context) error {
+// setConfigMetrics exposes the deposit fee and the configuration values that must be the same on all
+// validators, a validator that diverges from the others shows up as a different series
+func setConfigMetrics(fingerprint pkg.ConfigFingerprint) {
+	depositFeeGauge.Set(float64(fingerprint.DepositFee))
+	configInfo.Set(1,
+		fingerprint.Network,
+		fingerprint.BridgeAccount,
+		fingerprint.AssetCode,
+		fingerprint.AssetIssuer,
+		strconv.FormatInt(fingerprint.BaseFee, 10),
+		fingerprint.PaymentTimeout.String(),
+		strconv.FormatBool(fingerprint.ClaimableBalances),
+	)
+}
+
+// validateConfig checks the policies of the configuration before the bridge connects to anything
+func validateConfig(cfg pkg.BridgeConfig) error {
+	switch cfg.UnsupportedAssetPolicy {
+	case "", pkg.UnsupportedAssetPolicyIgnore, pkg.UnsupportedAssetPolicyAlert, pkg.UnsupportedAssetPolicyHold:
+	default:
+		return fmt.Errorf("unknown unsupported asset policy %q", cfg.UnsupportedAssetPolicy)
+	}
+
+	for _, kind := range cfg.AllowedMemoTypes {
+		if !isMemoType(kind) {
+			return fmt.Errorf("unknown memo type %q, expected one of %s", kind, strings.Join(pkg.MemoTypes, ", "))
+		}
+	}
+
+	switch cfg.NodeMintTarget {
+	case "", pkg.NodeMintTargetNode, pkg.NodeMintTargetFarm:
+	default:
+		return fmt.Errorf("unknown node mint target %q, expected %s or %s", cfg.NodeMintTarget, pkg.NodeMintTargetNode, pkg.NodeMintTargetFarm)
+	}
+
+	switch cfg.MintRole {
+	case "", pkg.MintRoleProposeAndVote, pkg.MintRoleVoteOnly:
+	default:
+		return fmt.Errorf("unknown mint role %q, expected %s or %s", cfg.MintRole, pkg.MintRoleProposeAndVote, pkg.MintRoleVoteOnly)
+	}
+
+	if cfg.BelowFeePolicy == pkg.BelowFeePolicyAbsorb && !strkey.IsValidEd25519PublicKey(cfg.FeeCollectionAccount) {
+		return fmt.Errorf("below fee policy absorb requires a valid fee collection account, got %q", cfg.FeeCollectionAccount)
+	}
+	return nil
+}
+
+// newNotifiers logs the alerts and delivers them to the configured notifiers
+func newNotifiers(cfg pkg.BridgeConfig) alert.Alerter {
+	alerters := []alert.Alerter{alert.NewLogAlerter()}
+	if cfg.AlertWebhookURL != "" {
+		alerters = append(alerters, alert.NewWebhookAlerter(cfg.AlertWebhookURL))
+	}
+	if cfg.AlertSlackWebhookURL != "" {
+		alerters = append(alerters, alert.NewSlackAlerter(cfg.AlertSlackWebhookURL))
+	}
+	if cfg.AlertPagerDutyRoutingKey != "" {
+		alerters = append(alerters, alert.NewPagerDutyAlerter(alert.PagerDutyEventsURL, cfg.AlertPagerDutyRoutingKey))
+	}
+	return alert.NewMultiAlerter(alerters...)
+}
+
+// ValidateDepositFee checks the deposit fee read from chain is positive and not above max, a max of 0 means no ceiling.
+// A fee of 0 would mint deposits of any size and a misconfigured huge fee would refund every deposit.
+func ValidateDepositFee(fee int64, max int64) error {
+	if fee <= 0 {
+		return fmt.Errorf("deposit fee %d must be positive", fee)
+	}
+	if max > 0 && fee > max {
+		return fmt.Errorf("deposit fee %d is above the maximum of %d", fee, max)
+	}
+	return nil
+}
+
+// Run processes the events of tfchain and the bridge account until ctx is cancelled or the bridge fails
+func (bridge *Bridge) Run(ctx context.Context) (err error) {
+	// stop cancels the bridge, Run returns the error it is called with
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopped := make(chan error, 1)
+	stop := func(err error) {
+		select {
+		case stopped <- err:
+		default:
+		}
+		cancel()
+	}
+	defer func() {
+		select {
+		case err = <-stopped:
+		default:
+		}
+	}()
+
+	if err := bridge.checkPeers(ctx); err != nil {
+		return err
+	}
+
 	height, err := bridge.blockPersistency.GetHeight()
 	if err != nil {
 		return errors.Wrap(err, "failed to get block height from persistency")
 	}
 
+	var streamOpts stellar.StreamOptions
+	if bridge.config.RescanBridgeAccount && bridge.config.RescanConcurrency > 1 {
+		streamOpts.Concurrency = bridge.config.RescanConcurrency
+		streamOpts.Minted = func(txHash string) (bool, error) {
+			return bridge.subClient.CheckMinted(ctx, txHash)
+		}
+	}
+	if bridge.config.PersistPendingMints {
+		streamOpts.Store = &pendingMintStore{persistency: bridge.blockPersistency}
+		if err := bridge.processPendingMints(ctx); err != nil {
+			return errors.Wrap(err, "failed to process pending mints")
+		}
+	}
+
+	go bridge.monitorBalance(ctx)
+	go bridge.reconcileCursors(ctx)
+	go bridge.pruneOutstanding(ctx)
+	go bridge.monitorSignatures(ctx)
+	go bridge.monitorSignerSet(ctx)
+
+	// an observer never submits extrinsics so it does not have to stay a validator
+	if !bridge.config.ObserverMode {
+		go bridge.monitorValidator(ctx, stop)
+	}
+
+	events := bridge.events
+	events.start(ctx)
+
 	log.Info().Msg("starting stellar subscription...")
-	stellarSub := make(chan stellar.MintEventSubscription)
+	// the cursor only moves past processed deposits, buffered events are fetched again after a restart
+	stellarSub := make(chan stellar.MintEventSubscription, bridge.config.StellarEventBuffer)
 	go func() {
 		defer close(stellarSub)
-		if err = bridge.wallet.StreamBridgeStellarTransactions(ctx, stellarSub, height.StellarCursor); err != nil {
-			log.Fatal().Msgf("failed to monitor bridge account %s", err.Error())
+		if err := bridge.wallet.StreamBridgeStellarTransactions(ctx, stellarSub, height.StellarCursor, streamOpts); err != nil && ctx.Err() == nil {
+			stop(pkg.SubscriptionFailed(errors.Wrap(err, "failed to monitor bridge account")))
 		}
 	}()
 
@@ -90,76 +322,253 @@ func (bridge *Bridge) Start(ctx context.Context) error {
 	tfchainSub := make(chan subpkg.EventSubscription)
 	go func() {
 		defer close(tfchainSub)
-		if err := bridge.subClient.SubscribeTfchainBridgeEvents(ctx, tfchainSub); err != nil {
-			log.Fatal().Msgf("failed to subscribe to tfchain %s", err.Error())
+		if err := bridge.subClient.SubscribeTfchainBridgeEvents(ctx, tfchainSub, bridge.lastHeight); err != nil && ctx.Err() == nil {
+			stop(pkg.SubscriptionFailed(errors.Wrap(err, "failed to subscribe to tfchain")))
 		}
 	}()
 
+	recent := newRecentEvents()
 	for {
+		// while paused the subscriptions are not read from so their events queue up
+		tfchainEvents, stellarEvents := tfchainSub, stellarSub
+		paused, pauseChanged := bridge.pause.get()
+		if paused {
+			tfchainEvents, stellarEvents = nil, nil
+		}
+
 		select {
-		case data := <-tfchainSub:
+		case <-pauseChanged:
+		case data := <-tfchainEvents:
 			if data.Err != nil {
-				return errors.Wrap(err, "failed to process events")
+				return errors.Wrap(data.Err, "failed to process events")
 			}
-			for _, withdrawCreatedEvent := range data.Events.WithdrawCreatedEvents {
-				err := bridge.handleWithdrawCreated(ctx, withdrawCreatedEvent)
-				if err != nil {
-					// If the TX is already withdrawn or refunded (minted on tfchain) skip
-					if errors.Is(err, pkg.ErrTransactionAlreadyBurned) || errors.Is(err, pkg.ErrTransactionAlreadyMinted) {
-						continue
-					}
-					return errors.Wrap(err, "failed to handle withdraw created")
-				}
+			data.Events = recent.dedup(data.Hash, data.Events)
+			data.Events.Sort()
+			bridge.outstanding.trackEvents(data.Events)
+			bridge.signatures.trackEvents(data.Events, time.Now())
+			if err := bridge.dispatchTfchainEvents(ctx, events, data.Events); err != nil {
+				return err
 			}
-			for _, withdrawExpiredEvent := range data.Events.WithdrawExpiredEvents {
-				err := bridge.handleWithdrawExpired(ctx, withdrawExpiredEvent)
-				if err != nil {
-					return errors.Wrap(err, "failed to handle withdraw expired")
-				}
-			}
-			for _, withdawReadyEvent := range data.Events.WithdrawReadyEvents {
-				err := bridge.handleWithdrawReady(ctx, withdawReadyEvent)
-				if err != nil {
-					if errors.Is(err, pkg.ErrTransactionAlreadyBurned) {
-						continue
-					}
-					return errors.Wrap(err, "failed to handle withdraw ready")
-				}
-				log.Info().Uint64("ID", withdawReadyEvent.ID).Msg("withdraw processed")
-			}
-			for _, refundExpiredEvent := range data.Events.RefundExpiredEvents {
-				err := bridge.handleRefundExpired(ctx, refundExpiredEvent)
-				if err != nil {
-					return errors.Wrap(err, "failed to handle refund expired")
-				}
-			}
-			for _, refundReadyEvent := range data.Events.RefundReadyEvents {
-				err := bridge.handleRefundReady(ctx, refundReadyEvent)
-				if err != nil {
-					if errors.Is(err, pkg.ErrTransactionAlreadyRefunded) {
-						continue
-					}
-					return errors.Wrap(err, "failed to handle refund ready")
-				}
-				log.Info().Str("hash", refundReadyEvent.Hash).Msg("refund processed")
+
+			// the height is saved for every processed block, after a reorg the subscription
+			// replays from the fork point so the saved height is rewound as well
+			if err := bridge.blockPersistency.SaveHeight(data.Height); err != nil {
+				return errors.Wrap(err, "failed to save block height")
 			}
-		case data := <-stellarSub:
+		case data := <-stellarEvents:
+			stellarEventsBuffered.Set(float64(len(stellarSub)))
 			if data.Err != nil {
-				return errors.Wrap(err, "failed to get mint events")
+				return errors.Wrap(data.Err, "failed to get mint events")
 			}
 
 			for _, mEvent := range data.Events {
-				err := bridge.mint(ctx, mEvent.Senders, mEvent.Tx)
+				mEvent := mEvent
+				err := events.mint.dispatch(ctx, func(ctx context.Context) error {
+					return bridge.handleMintEvent(ctx, mEvent)
+				})
 				if err != nil {
-					if errors.Is(err, pkg.ErrTransactionAlreadyMinted) {
-						continue
-					}
-					return errors.Wrap(err, "failed to handle mint")
+					return err
 				}
-				log.Info().Str("hash", mEvent.Tx.Hash).Msg("mint processed")
 			}
+			// the events of the transaction are handled, transactions that are skipped do not
+			// save the cursor themselves so it is checkpointed for them
+			bridge.checkpointStellarCursor(data.Cursor)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
+
+// lastHeight returns the height of the last tfchain block the bridge processed, the subscription replays the
+// blocks after it
+func (bridge *Bridge) lastHeight() (uint32, error) {
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		return 0, err
+	}
+	return height.LastHeight, nil
+}
+
+// dispatchTfchainEvents hands the events of a tfchain block to their routes, one event type after the other:
+// malformed events, withdraws created, expired and ready, then refunds expired and ready. Within a type the
+// events are handled in the order of Events.Sort so a block delivered again after a restart is processed the same way.
+func (bridge *Bridge) dispatchTfchainEvents(ctx context.Context, events *dispatcher, data subpkg.Events) error {
+	if err := events.malformed.dispatch(ctx, func(ctx context.Context) error {
+		return bridge.handleMalformedEvents(ctx, data.MalformedEvents)
+	}); err != nil {
+		return err
+	}
+	if err := events.withdrawCreated.dispatch(ctx, func(ctx context.Context) error {
+		return bridge.handleWithdrawCreatedEvents(ctx, data.WithdrawCreatedEvents)
+	}); err != nil {
+		return err
+	}
+	// the other events are dispatched one by one so a permanent failure only drops the failing event
+	for _, withdrawExpiredEvent := range data.WithdrawExpiredEvents {
+		withdrawExpiredEvent := withdrawExpiredEvent
+		if err := events.withdrawExpired.dispatch(ctx, func(ctx context.Context) error {
+			err := bridge.handleWithdrawExpired(ctx, withdrawExpiredEvent)
+			return errors.Wrapf(err, "failed to handle expired withdraw %d of %d to %s", withdrawExpiredEvent.ID, withdrawExpiredEvent.Amount, withdrawExpiredEvent.Target)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, withdawReadyEvent := range data.WithdrawReadyEvents {
+		withdawReadyEvent := withdawReadyEvent
+		if err := events.withdrawReady.dispatch(ctx, func(ctx context.Context) error {
+			err := bridge.handleWithdrawReady(ctx, withdawReadyEvent)
+			if errors.Is(err, pkg.ErrTransactionAlreadyBurned) {
+				return nil
+			}
+			if err == nil {
+				log.Info().Uint64("ID", withdawReadyEvent.ID).Msg("withdraw processed")
+			}
+			return errors.Wrapf(err, "failed to handle ready withdraw %d", withdawReadyEvent.ID)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, refundExpiredEvent := range data.RefundExpiredEvents {
+		refundExpiredEvent := refundExpiredEvent
+		if err := events.refundExpired.dispatch(ctx, func(ctx context.Context) error {
+			err := bridge.handleRefundExpired(ctx, refundExpiredEvent)
+			return errors.Wrapf(err, "failed to handle expired refund of %s of %d to %s", refundExpiredEvent.Hash, refundExpiredEvent.Amount, refundExpiredEvent.Target)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, refundReadyEvent := range data.RefundReadyEvents {
+		refundReadyEvent := refundReadyEvent
+		if err := events.refundReady.dispatch(ctx, func(ctx context.Context) error {
+			err := bridge.handleRefundReady(ctx, refundReadyEvent)
+			if errors.Is(err, pkg.ErrTransactionAlreadyRefunded) {
+				return nil
+			}
+			if err == nil {
+				log.Info().Str("hash", refundReadyEvent.Hash).Msg("refund processed")
+			}
+			return errors.Wrapf(err, "failed to handle ready refund of %s", refundReadyEvent.Hash)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Breakers returns for every event type whether its circuit breaker is open
+func (bridge *Bridge) Breakers() map[string]bool {
+	return bridge.events.breakers()
+}
+
+// recordDeadLetter keeps and alerts on events that failed permanently
+func (bridge *Bridge) recordDeadLetter(ctx context.Context, route string, failure error) {
+	err := bridge.blockPersistency.RecordDeadLetter(pkg.DeadLetter{
+		Route: route,
+		Error: failure.Error(),
+		At:    time.Now(),
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to record dead letter")
+	}
+
+	err = bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindDeadLetter,
+		Message: "events failed permanently and were dropped",
+		Fields: map[string]string{
+			"route": route,
+			"error": failure.Error(),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+}
+
+// alertBreakerOpened alerts that the processing of an event type is paused after a streak of failures
+func (bridge *Bridge) alertBreakerOpened(ctx context.Context, route string, failures int, failure error) {
+	err := bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindCircuitBreakerOpen,
+		Message: "processing of events is paused after consecutive failures",
+		Fields: map[string]string{
+			"route":    route,
+			"failures": fmt.Sprint(failures),
+			"error":    failure.Error(),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+}
+
+// alertInsufficientFunds alerts that the tfchain account submitting the bridge extrinsics can not pay their fees
+func alertInsufficientFunds(ctx context.Context, alerter alert.Alerter, account string) {
+	err := alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindInsufficientFunds,
+		Message: "tfchain account can not pay the fees of the bridge extrinsics, submissions are retried until it is funded",
+		Fields: map[string]string{
+			"account": account,
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+}
+
+// handleMalformedEvents records and alerts on malformed events, unless the policy is to fail on them
+func (bridge *Bridge) handleMalformedEvents(ctx context.Context, events []pkg.MalformedEvent) error {
+	for _, event := range events {
+		if bridge.config.MalformedEventPolicy == pkg.MalformedEventPolicyFail {
+			return fmt.Errorf("malformed %s event at height %d: %s", event.Type, event.Height, event.Reason)
+		}
+
+		log.Warn().Str("type", event.Type).Uint32("height", event.Height).Str("reason", event.Reason).Msg("skipping malformed event")
+		if err := bridge.blockPersistency.RecordMalformedEvent(event); err != nil {
+			log.Err(err).Msg("failed to record malformed event")
+		}
+
+		err := bridge.alerter.Alert(ctx, alert.Alert{
+			Kind:    alert.KindMalformedEvent,
+			Message: "skipped malformed tfchain event",
+			Fields: map[string]string{
+				"type":   event.Type,
+				"height": fmt.Sprint(event.Height),
+				"reason": event.Reason,
+				"event":  event.Event,
+			},
+		})
+		if err != nil {
+			log.Err(err).Msg("failed to send alert")
+		}
+	}
+
+	return nil
+}
+
+// handleMintEvent mints a deposit and drops it from the pending mints once it is processed
+func (bridge *Bridge) handleMintEvent(ctx context.Context, mEvent stellar.MintEvent) error {
+	if err := bridge.handleForeignPayments(ctx, mEvent.Tx, mEvent.ForeignPayments); err != nil {
+		return errors.Wrapf(err, "failed to handle payments of unsupported assets in %s", mEvent.Tx.Hash)
+	}
+
+	var err error
+	if mEvent.Minted {
+		log.Info().Str("tx_id", mEvent.Tx.Hash).Msg("transaction is already minted")
+		err = pkg.ErrTransactionAlreadyMinted
+	} else {
+		err = bridge.mint(ctx, mEvent.Senders, mEvent.Tx)
+	}
+	if err != nil && !errors.Is(err, pkg.ErrTransactionAlreadyMinted) {
+		return errors.Wrapf(err, "failed to handle mint of %s of %s", mEvent.Tx.Hash, depositAmount(mEvent.Senders))
+	}
+	if err == nil {
+		log.Info().Str("hash", mEvent.Tx.Hash).Msg("mint processed")
+	}
+
+	if bridge.config.PersistPendingMints {
+		if err := bridge.blockPersistency.RemovePendingMint(mEvent.Tx.Hash); err != nil {
+			log.Err(err).Str("hash", mEvent.Tx.Hash).Msg("failed to remove pending mint")
+		}
+	}
+
+	return nil
+}
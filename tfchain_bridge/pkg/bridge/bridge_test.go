@@ -0,0 +1,116 @@
+package bridge
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// recordingAlerter keeps the alerts raised by the bridge
+type recordingAlerter struct {
+	mu     sync.Mutex
+	alerts []alert.Alert
+}
+
+func (a *recordingAlerter) Alert(ctx context.Context, alert alert.Alert) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alerts = append(a.alerts, alert)
+	return nil
+}
+
+func (a *recordingAlerter) kinds() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var kinds []string
+	for _, alert := range a.alerts {
+		kinds = append(kinds, alert.Kind)
+	}
+	return kinds
+}
+
+func TestValidateDepositFee(t *testing.T) {
+	tests := []struct {
+		name string
+		fee  int64
+		max  int64
+		err  bool
+	}{
+		{name: "positive", fee: 10000000},
+		{name: "positive without maximum", fee: 10000000, max: 0},
+		{name: "at maximum", fee: 10000000, max: 10000000},
+		{name: "zero", fee: 0, err: true},
+		{name: "negative", fee: -10000000, err: true},
+		{name: "too large", fee: 1000000000000, max: 100000000, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateDepositFee(test.fee, test.max)
+			if (err != nil) != test.err {
+				t.Errorf("expected error %t, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorsIdentifyTheTransaction(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	tfchain.lookupErr = io.EOF
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	deposit := testDeposit(1, testSender, 1000000000, "twin_1")
+	err := bridge.handleMintEvent(testContext(t), deposit)
+	if err == nil {
+		t.Fatal("expected the mint to fail while tfchain is unreachable")
+	}
+	for _, expected := range []string{deposit.Tx.Hash, "1000000000", io.EOF.Error()} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain %q, got %q", expected, err)
+		}
+	}
+
+	// the withdraw has no burn transaction on chain, its ready event fails
+	err = bridge.dispatchTfchainEvents(testContext(t), bridge.events, subpkg.Events{
+		WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 42}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "withdraw 42") {
+		t.Errorf("expected the error to contain the withdraw id, got %v", err)
+	}
+}
+
+func TestSetConfigMetrics(t *testing.T) {
+	fingerprint := pkg.ConfigFingerprint{
+		Network:        "testnet",
+		BridgeAccount:  testSender,
+		AssetCode:      "TFT",
+		AssetIssuer:    "GA47YZA3PKFUZMPLQ3B5F2E3CJIB57TGGU7SPCQT2WAEYKN766PWIMB3",
+		DepositFee:     10000000,
+		BaseFee:        1000,
+		PaymentTimeout: 5 * time.Minute,
+	}
+
+	setConfigMetrics(fingerprint)
+
+	if fee := depositFeeGauge.Get(); fee != 10000000 {
+		t.Errorf("expected the deposit fee gauge to be 10000000, got %v", fee)
+	}
+	if info := configInfo.Get("testnet", testSender, "TFT", fingerprint.AssetIssuer, "1000", "5m0s", "false"); info != 1 {
+		t.Errorf("expected the configuration to be exposed, got %v", info)
+	}
+
+	// the fee read on chain changed since the last start
+	fingerprint.DepositFee = 20000000
+	setConfigMetrics(fingerprint)
+	if fee := depositFeeGauge.Get(); fee != 20000000 {
+		t.Errorf("expected the deposit fee gauge to be 20000000, got %v", fee)
+	}
+}
@@ -0,0 +1,89 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+)
+
+// burstTracker keeps the deposits of every source within a sliding window to detect bursts of deposits,
+// a source exceeding the amount of deposits or the cumulative amount within the window is quarantined.
+// The deposits are kept in memory, a restart starts from empty windows. A window of 0 disables the tracker.
+type burstTracker struct {
+	window    time.Duration
+	maxCount  int
+	maxAmount int64
+	mu        sync.Mutex
+	deposits  map[string][]burstDeposit
+}
+
+type burstDeposit struct {
+	at     time.Time
+	amount int64
+}
+
+func newBurstTracker(window time.Duration, maxCount int, maxAmount int64) *burstTracker {
+	return &burstTracker{
+		window:    window,
+		maxCount:  maxCount,
+		maxAmount: maxAmount,
+		deposits:  make(map[string][]burstDeposit),
+	}
+}
+
+func (t *burstTracker) enabled() bool {
+	return t.window > 0 && (t.maxCount > 0 || t.maxAmount > 0)
+}
+
+// exceeds checks if a deposit of amount from source at now would exceed the rate or cumulative amount of the window
+func (t *burstTracker) exceeds(source string, amount int64, now time.Time) bool {
+	if !t.enabled() {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deposits := t.prune(source, now)
+	if t.maxCount > 0 && len(deposits)+1 > t.maxCount {
+		return true
+	}
+	if t.maxAmount > 0 {
+		total := amount
+		for _, deposit := range deposits {
+			total += deposit.amount
+		}
+		if total > t.maxAmount {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a deposit of amount from source at now
+func (t *burstTracker) add(source string, amount int64, now time.Time) {
+	if !t.enabled() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.deposits[source] = append(t.prune(source, now), burstDeposit{at: now, amount: amount})
+}
+
+// prune drops the deposits of source that left the window, it must be called with mu held
+func (t *burstTracker) prune(source string, now time.Time) []burstDeposit {
+	deposits := t.deposits[source]
+	start := 0
+	for start < len(deposits) && now.Sub(deposits[start].at) >= t.window {
+		start++
+	}
+	deposits = deposits[start:]
+
+	if len(deposits) == 0 {
+		delete(t.deposits, source)
+		return nil
+	}
+	t.deposits[source] = deposits
+	return deposits
+}
@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+)
+
+func TestBurstTracker(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		maxCount  int
+		maxAmount int64
+		deposits  []int64
+		amount    int64
+		exceeds   bool
+	}{
+		{name: "below count", maxCount: 3, deposits: []int64{10, 10}, amount: 10},
+		{name: "above count", maxCount: 3, deposits: []int64{10, 10, 10}, amount: 10, exceeds: true},
+		{name: "at amount", maxAmount: 30, deposits: []int64{10, 10}, amount: 10},
+		{name: "above amount", maxAmount: 30, deposits: []int64{10, 10}, amount: 11, exceeds: true},
+		{name: "single large deposit", maxAmount: 30, amount: 31, exceeds: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracker := newBurstTracker(time.Hour, test.maxCount, test.maxAmount)
+			for i, amount := range test.deposits {
+				tracker.add(testSender, amount, now.Add(time.Duration(i)*time.Minute))
+			}
+			at := now.Add(10 * time.Minute)
+			if exceeds := tracker.exceeds(testSender, test.amount, at); exceeds != test.exceeds {
+				t.Errorf("expected exceeds to be %t, got %t", test.exceeds, exceeds)
+			}
+			// other sources have their own window
+			if tracker.exceeds("GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV", 1, at) {
+				t.Error("expected another source not to exceed the limits")
+			}
+			// the deposits leave the window
+			if tracker.exceeds(testSender, 1, now.Add(2*time.Hour)) {
+				t.Error("expected the deposits to leave the window")
+			}
+		})
+	}
+
+	if newBurstTracker(0, 1, 1).exceeds(testSender, 100, now) {
+		t.Error("expected a tracker without window to be disabled")
+	}
+}
+
+func TestDepositBurstQuarantine(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	tfchain.addTwin(t, 1, testTwinAddress)
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{DepositBurstWindow: time.Hour, DepositBurstCount: 2}, tfchain, wallet, 10000000)
+
+	for i := 1; i <= 3; i++ {
+		if err := wallet.deposit(testContext(t), bridge, testDeposit(i, testSender, 1000000000, "twin_1")); err != nil {
+			t.Fatalf("deposit %d failed: %s", i, err)
+		}
+	}
+
+	// the first two deposits are minted, the third one exceeds the burst limit and is held
+	assertCalls(t, []string{
+		"ProposeMintOrVote " + testDeposit(1, testSender, 0, "").Tx.Hash + " " + testTwinAddress + " 1000000000",
+		"ProposeMintOrVote " + testDeposit(2, testSender, 0, "").Tx.Hash + " " + testTwinAddress + " 1000000000",
+	}, calls.get())
+
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var held []pkg.HeldDeposit
+	for _, deposit := range height.HeldDeposits {
+		held = append(held, pkg.HeldDeposit{TxHash: deposit.TxHash, Sender: deposit.Sender, Target: deposit.Target, Amount: deposit.Amount, Reason: deposit.Reason})
+	}
+	expected := []pkg.HeldDeposit{{TxHash: testDeposit(3, testSender, 0, "").Tx.Hash, Sender: testSender, Target: testTwinAddress, Amount: 1000000000, Reason: alert.KindDepositBurst}}
+	if !reflect.DeepEqual(held, expected) {
+		t.Errorf("expected held deposits %+v, got %+v", expected, held)
+	}
+	if kinds := bridge.alerter.(*recordingAlerter).kinds(); !reflect.DeepEqual(kinds, []string{alert.KindDepositBurst}) {
+		t.Errorf("expected a deposit burst alert, got %v", kinds)
+	}
+}
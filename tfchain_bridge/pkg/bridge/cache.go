@@ -0,0 +1,56 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+)
+
+// addressCache caches the substrate addresses resolved from deposit memos, expired entries are dropped
+// on lookup and failed lookups are never cached. A ttl of 0 disables the cache.
+type addressCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedAddress
+}
+
+type cachedAddress struct {
+	address string
+	expires time.Time
+}
+
+func newAddressCache(ttl time.Duration) *addressCache {
+	return &addressCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedAddress),
+	}
+}
+
+func (c *addressCache) get(key string) (string, bool) {
+	if c.ttl <= 0 {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.address, true
+}
+
+func (c *addressCache) set(key string, address string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = cachedAddress{address: address, expires: time.Now().Add(c.ttl)}
+}
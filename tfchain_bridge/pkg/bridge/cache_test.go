@@ -0,0 +1,58 @@
+package bridge
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// lookupCountingTfchain counts the twin lookups of the memos
+type lookupCountingTfchain struct {
+	*fakeTfchain
+	lookups int
+}
+
+func (f *lookupCountingTfchain) GetTwin(id uint32) (*substrate.Twin, error) {
+	f.lookups++
+	return f.fakeTfchain.GetTwin(id)
+}
+
+func TestMemoLookupCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		lookups int
+	}{
+		{name: "second deposit hits the cache", ttl: time.Minute, lookups: 1},
+		{name: "disabled by default", lookups: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := &lookupCountingTfchain{fakeTfchain: newFakeTfchain(calls)}
+			tfchain.addTwin(t, 1, testTwinAddress)
+			wallet := newFakeWallet(calls, 100)
+			bridge := newTestBridge(t, pkg.BridgeConfig{MemoCacheTTL: test.ttl}, tfchain, wallet, 10000000)
+
+			first := testDeposit(1, testSender, 1000000000, "twin_1")
+			second := testDeposit(2, testSender, 1000000000, "twin_1")
+			for _, deposit := range []stellar.MintEvent{first, second} {
+				if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+					t.Fatalf("deposit %s failed: %s", deposit.Tx.Hash, err)
+				}
+			}
+
+			if tfchain.lookups != test.lookups {
+				t.Errorf("expected %d twin lookups, got %d", test.lookups, tfchain.lookups)
+			}
+			assertCalls(t, []string{
+				fmt.Sprintf("ProposeMintOrVote %s %s 1000000000", first.Tx.Hash, testTwinAddress),
+				fmt.Sprintf("ProposeMintOrVote %s %s 1000000000", second.Tx.Hash, testTwinAddress),
+			}, calls.get())
+		})
+	}
+}
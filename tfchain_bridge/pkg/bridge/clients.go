@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// tfchainClient is the part of the substrate client the bridge uses, it is implemented by *subpkg.SubstrateClient
+// and faked in the tests so the handlers run without a tfchain node
+type tfchainClient interface {
+	SubscribeTfchainBridgeEvents(ctx context.Context, eventChannel chan<- subpkg.EventSubscription, lastHeight func() (uint32, error)) error
+	IsBridgeValidator() (bool, error)
+	GetBridgeValidators() ([]substrate.AccountID, error)
+	PauseSubmissions()
+	ResumeSubmissions()
+	Close()
+
+	GetTwin(id uint32) (*substrate.Twin, error)
+	GetFarm(id uint32) (*substrate.Farm, error)
+	GetNode(id uint32) (*substrate.Node, error)
+	GetEntity(id uint32) (*substrate.Entity, error)
+
+	CheckMinted(ctx context.Context, txID string) (bool, error)
+	GetProposedMintTransaction(txHash string) (*subpkg.MintTransaction, error)
+	GetExecutedMintTransaction(txHash string) (*subpkg.MintTransaction, error)
+	RetryProposeMintOrVote(ctx context.Context, txID string, target substrate.AccountID, amount *big.Int) error
+
+	IsBurnedAlready(id types.U64) (bool, error)
+	GetBurnTransaction(id types.U64) (*substrate.BurnTransaction, error)
+	GetExecutedBurnTransaction(burnTransactionID uint64) (*substrate.BurnTransaction, error)
+	HasWithdrawSignature(burnTransactionID uint64, stellarAddress string) (bool, error)
+	RetryProposeWithdrawOrAddSig(ctx context.Context, txID uint64, target string, amount *big.Int, signature string, stellarAddress string, sequenceNumber uint64) error
+	RetrySetWithdrawExecuted(ctx context.Context, txID uint64) error
+	ResetWithdraw(txID uint64, sequenceNumber uint64) error
+
+	IsRefundedAlready(txHash string) (bool, error)
+	GetRefundTransaction(txHash string) (*substrate.RefundTransaction, error)
+	RetryCreateRefundTransactionOrAddSig(ctx context.Context, txHash string, target string, amount int64, signature string, stellarAddress string, sequenceNumber uint64) error
+	RetrySetRefundTransactionExecutedTx(ctx context.Context, txHash string) error
+}
+
+// stellarWallet is the part of the stellar wallet the bridge uses, it is implemented by *stellar.StellarWallet
+// and faked in the tests so the handlers run without horizon
+type stellarWallet interface {
+	GetAddress() string
+	GetAccountConfig() (stellar.AccountConfig, error)
+	GetSignatureCount() int
+	GetBalance(ctx context.Context) (int64, error)
+	CheckAccount(ctx context.Context, account string) error
+	CheckPaymentBalance(paymentAmount uint64) error
+	CheckBridgeTrustline(ctx context.Context) error
+	ResetAccountSequence() error
+
+	StreamBridgeStellarTransactions(ctx context.Context, mintChan chan<- stellar.MintEventSubscription, cursor string, opts stellar.StreamOptions) error
+	GetTransactionMintEvents(txHash string) ([]stellar.MintEvent, error)
+
+	CreatePaymentAndReturnSignature(ctx context.Context, target string, amount uint64, txID uint64, claimable bool) (string, uint64, error)
+	CreatePaymentWithSignaturesAndSubmit(ctx context.Context, target string, amount uint64, txHash string, signatures []substrate.StellarSignature, sequenceNumber int64) error
+	HasSignatureQuorum(signatures []substrate.StellarSignature) bool
+	PaymentTransactionHash(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (string, error)
+	SignsClaimableBalance(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (bool, error)
+	IsTransactionSubmitted(ctx context.Context, hash string) (bool, error)
+	CheckPaymentSequence(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) error
+	VerifyPaymentSignatures(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) ([]stellar.SignatureCheck, error)
+
+	CreateRefundAndReturnSignature(ctx context.Context, target string, amount uint64, message string) (string, uint64, error)
+	CreateRefundPaymentWithSignaturesAndSubmit(ctx context.Context, target string, amount uint64, txHash string, signatures []substrate.StellarSignature, sequenceNumber int64) error
+}
+
+var (
+	_ tfchainClient = (*subpkg.SubstrateClient)(nil)
+	_ stellarWallet = (*stellar.StellarWallet)(nil)
+)
@@ -0,0 +1,130 @@
+package bridge
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// cursorTracker keeps the cursor of the last processed stellar transaction, when saving
+// it fails the persisted cursor lags behind and is advanced by the reconciliation
+type cursorTracker struct {
+	mu        sync.Mutex
+	processed string
+}
+
+func (c *cursorTracker) set(cursor string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.processed = cursor
+}
+
+func (c *cursorTracker) get() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.processed
+}
+
+// cursorCheckpoint decides when the cursor of scanned transactions is saved. Only transactions that
+// are handled save the cursor, a long stretch of skipped transactions is replayed after a restart
+// unless it is checkpointed after count transactions or interval since the last save.
+type cursorCheckpoint struct {
+	count    int
+	interval time.Duration
+
+	mu      sync.Mutex
+	scanned int
+	saved   time.Time
+}
+
+func newCursorCheckpoint(count int, interval time.Duration) *cursorCheckpoint {
+	return &cursorCheckpoint{count: count, interval: interval, saved: time.Now()}
+}
+
+// scan records a scanned transaction and returns true if the cursor is due for a checkpoint
+func (c *cursorCheckpoint) scan(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanned++
+	if c.count > 0 && c.scanned >= c.count {
+		return true
+	}
+	return c.interval > 0 && now.Sub(c.saved) >= c.interval
+}
+
+// reset records that the cursor was saved
+func (c *cursorCheckpoint) reset(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanned = 0
+	c.saved = now
+}
+
+// saveStellarCursor saves the cursor of a processed transaction. The transaction is already
+// handled on chain so a failed save is not fatal, the cursor is saved again by the reconciliation
+// and until then a restart only replays transactions that are detected as minted or refunded already
+func (bridge *Bridge) saveStellarCursor(cursor string) {
+	bridge.cursor.set(cursor)
+	if err := bridge.blockPersistency.SaveStellarCursor(cursor); err != nil {
+		log.Err(err).Str("cursor", cursor).Msg("failed to save stellar cursor, it will be reconciled")
+		return
+	}
+	bridge.checkpoint.reset(time.Now())
+}
+
+// checkpointStellarCursor saves the cursor of a scanned transaction once the checkpoint is due. The
+// events of the transaction and all transactions before it are handled when it is called.
+func (bridge *Bridge) checkpointStellarCursor(cursor string) {
+	if cursor == "" || !bridge.checkpoint.scan(time.Now()) {
+		return
+	}
+	log.Debug().Str("cursor", cursor).Msg("checkpointing stellar cursor")
+	cursorCheckpoints.Inc()
+	bridge.saveStellarCursor(cursor)
+}
+
+// reconcileCursor advances the persisted cursor to the last processed one if saving it failed
+func (bridge *Bridge) reconcileCursor() error {
+	processed := bridge.cursor.get()
+	if processed == "" {
+		return nil
+	}
+
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		return err
+	}
+	if height.StellarCursor == processed {
+		return nil
+	}
+
+	// SaveStellarCursor ignores the processed cursor if the persisted one is already past it
+	log.Info().Str("persisted", height.StellarCursor).Str("processed", processed).Msg("reconciling stellar cursor")
+	return bridge.blockPersistency.SaveStellarCursor(processed)
+}
+
+// reconcileCursors runs the cursor reconciliation on startup and then periodically, the interval is
+// jittered so the bridges of the different validators do not all hit their disks at the same moment
+func (bridge *Bridge) reconcileCursors(ctx context.Context) {
+	interval := bridge.config.CursorReconcileInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	for {
+		if err := bridge.reconcileCursor(); err != nil {
+			log.Err(err).Msg("failed to reconcile stellar cursor")
+		}
+
+		timer := time.NewTimer(interval + time.Duration(rand.Int63n(int64(interval/2)+1)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
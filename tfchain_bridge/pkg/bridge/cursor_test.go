@@ -0,0 +1,113 @@
+package bridge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestReconcileCursorAfterSaveFailure(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "persistency.json")
+	persistency, err := pkg.InitPersist(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := &Bridge{blockPersistency: persistency, cursor: &cursorTracker{}, checkpoint: newCursorCheckpoint(0, 0)}
+
+	bridge.saveStellarCursor("185661728346116353")
+	saved, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the disk fails while the next transaction is processed, the cursor is not saved
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(file, 0755); err != nil {
+		t.Fatal(err)
+	}
+	bridge.saveStellarCursor("185661728346116354")
+	if err := bridge.reconcileCursor(); err == nil {
+		t.Fatal("expected the reconciliation to fail while the disk fails")
+	}
+
+	// the disk recovers with the cursor saved before the failure, the next run advances it
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, saved, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.reconcileCursor(); err != nil {
+		t.Fatal(err)
+	}
+
+	height, err := persistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height.StellarCursor != "185661728346116354" {
+		t.Errorf("expected the cursor to be reconciled to 185661728346116354, got %s", height.StellarCursor)
+	}
+
+	// a reconciled cursor is left as is
+	if err := bridge.reconcileCursor(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCursorCheckpoint(t *testing.T) {
+	persistency, err := pkg.InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := &Bridge{blockPersistency: persistency, cursor: &cursorTracker{}, checkpoint: newCursorCheckpoint(3, 0)}
+
+	saved := func() string {
+		t.Helper()
+		height, err := persistency.GetHeight()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return height.StellarCursor
+	}
+
+	// a stretch of skipped transactions is checkpointed every third transaction
+	for i, expected := range []string{"", "", "185661728346116355", "185661728346116355", "185661728346116355", "185661728346116358"} {
+		cursor := fmt.Sprint(185661728346116353 + i)
+		bridge.checkpointStellarCursor(cursor)
+		if cursor := saved(); cursor != expected {
+			t.Errorf("after transaction %d expected the saved cursor %q, got %q", i+1, expected, cursor)
+		}
+	}
+
+	// a handled transaction saves the cursor itself, the count starts over
+	bridge.saveStellarCursor("185661728346116359")
+	bridge.checkpointStellarCursor("185661728346116360")
+	bridge.checkpointStellarCursor("185661728346116361")
+	if cursor := saved(); cursor != "185661728346116359" {
+		t.Errorf("expected the cursor of the handled transaction to be saved, got %s", cursor)
+	}
+}
+
+func TestCursorCheckpointInterval(t *testing.T) {
+	start := time.Now()
+	checkpoint := newCursorCheckpoint(0, time.Minute)
+	checkpoint.reset(start)
+
+	if checkpoint.scan(start.Add(30 * time.Second)) {
+		t.Error("expected no checkpoint before the interval passed")
+	}
+	if !checkpoint.scan(start.Add(time.Minute)) {
+		t.Error("expected a checkpoint once the interval passed")
+	}
+	checkpoint.reset(start.Add(time.Minute))
+	if checkpoint.scan(start.Add(90 * time.Second)) {
+		t.Error("expected the interval to start over after the checkpoint")
+	}
+}
@@ -0,0 +1,85 @@
+package bridge
+
+import (
+	"fmt"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/rs/zerolog/log"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// recentEventsSize is the amount of recently dispatched events remembered to drop duplicates
+const recentEventsSize = 4096
+
+// recentEvents remembers the most recently dispatched tfchain events, an event is identified by its
+// type, its id and the block it is in. Expired events of the same withdraw or refund in later blocks
+// are new events, and a block replayed after a reorg has another hash so its events are handled again.
+type recentEvents struct {
+	seen  map[string]struct{}
+	order []string
+}
+
+func newRecentEvents() *recentEvents {
+	return &recentEvents{seen: make(map[string]struct{})}
+}
+
+// firstSeen returns true the first time key is seen and remembers it
+func (r *recentEvents) firstSeen(key string) bool {
+	if _, ok := r.seen[key]; ok {
+		return false
+	}
+
+	r.seen[key] = struct{}{}
+	r.order = append(r.order, key)
+	if len(r.order) > recentEventsSize {
+		delete(r.seen, r.order[0])
+		r.order = r.order[1:]
+	}
+	return true
+}
+
+// dedup drops the events of a block that were dispatched before, within the block or in an earlier
+// delivery of the same block when the subscription is reopened
+func (r *recentEvents) dedup(hash types.Hash, events subpkg.Events) subpkg.Events {
+	keep := func(kind string, id interface{}) bool {
+		if r.firstSeen(fmt.Sprintf("%s/%v/%s", kind, id, hash.Hex())) {
+			return true
+		}
+		log.Warn().Str("type", kind).Interface("id", id).Str("block", hash.Hex()).Msg("dropping duplicate event")
+		duplicateEvents.Inc(kind)
+		return false
+	}
+
+	deduped := subpkg.Events{MalformedEvents: events.MalformedEvents}
+	for _, e := range events.WithdrawCreatedEvents {
+		if keep("withdraw_created", e.ID) {
+			deduped.WithdrawCreatedEvents = append(deduped.WithdrawCreatedEvents, e)
+		}
+	}
+	for _, e := range events.WithdrawReadyEvents {
+		if keep("withdraw_ready", e.ID) {
+			deduped.WithdrawReadyEvents = append(deduped.WithdrawReadyEvents, e)
+		}
+	}
+	for _, e := range events.WithdrawExpiredEvents {
+		if keep("withdraw_expired", e.ID) {
+			deduped.WithdrawExpiredEvents = append(deduped.WithdrawExpiredEvents, e)
+		}
+	}
+	for _, e := range events.RefundCreatedEvents {
+		if keep("refund_created", e.Hash) {
+			deduped.RefundCreatedEvents = append(deduped.RefundCreatedEvents, e)
+		}
+	}
+	for _, e := range events.RefundReadyEvents {
+		if keep("refund_ready", e.Hash) {
+			deduped.RefundReadyEvents = append(deduped.RefundReadyEvents, e)
+		}
+	}
+	for _, e := range events.RefundExpiredEvents {
+		if keep("refund_expired", e.Hash) {
+			deduped.RefundExpiredEvents = append(deduped.RefundExpiredEvents, e)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,61 @@
+package bridge
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+func TestRecentEventsDropsDuplicates(t *testing.T) {
+	block := types.NewHash([]byte{1})
+	created := func(ids ...uint64) subpkg.Events {
+		var events subpkg.Events
+		for _, id := range ids {
+			events.WithdrawCreatedEvents = append(events.WithdrawCreatedEvents, subpkg.WithdrawCreatedEvent{ID: id})
+		}
+		return events
+	}
+	ids := func(events subpkg.Events) []uint64 {
+		var ids []uint64
+		for _, e := range events.WithdrawCreatedEvents {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	recent := newRecentEvents()
+
+	// a withdraw duplicated within a batch is dispatched once
+	if deduped := recent.dedup(block, created(1, 2, 1)); !reflect.DeepEqual(ids(deduped), []uint64{1, 2}) {
+		t.Errorf("expected withdraws 1 and 2, got %v", ids(deduped))
+	}
+	// the same block delivered again after a resubscribe is dropped
+	if deduped := recent.dedup(block, created(1, 2)); len(deduped.WithdrawCreatedEvents) != 0 {
+		t.Errorf("expected the redelivered withdraws to be dropped, got %v", ids(deduped))
+	}
+	// a block replayed after a reorg has another hash so its events are handled again
+	if deduped := recent.dedup(types.NewHash([]byte{2}), created(1)); !reflect.DeepEqual(ids(deduped), []uint64{1}) {
+		t.Errorf("expected withdraw 1 of another block, got %v", ids(deduped))
+	}
+	// other event types with the same id are not duplicates
+	ready := subpkg.Events{WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 1}}}
+	if deduped := recent.dedup(block, ready); len(deduped.WithdrawReadyEvents) != 1 {
+		t.Errorf("expected the ready event of withdraw 1 to be kept, got %v", deduped.WithdrawReadyEvents)
+	}
+}
+
+func TestRecentEventsForgetsOldest(t *testing.T) {
+	recent := newRecentEvents()
+	for i := 0; i <= recentEventsSize; i++ {
+		recent.firstSeen(fmt.Sprint(i))
+	}
+	if len(recent.seen) != recentEventsSize {
+		t.Errorf("expected %d remembered events, got %d", recentEventsSize, len(recent.seen))
+	}
+	if !recent.firstSeen("0") {
+		t.Error("expected the oldest event to be forgotten")
+	}
+}
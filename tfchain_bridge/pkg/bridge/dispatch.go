@@ -0,0 +1,225 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// errorPolicy decides what happens when an event handler fails
+type errorPolicy int
+
+const (
+	// errorPolicyFatal stops the bridge on the first error of the handler
+	errorPolicyFatal errorPolicy = iota
+	// errorPolicyRetry retries the failed events with backoff until they are handled or the bridge stops
+	errorPolicyRetry
+)
+
+// job handles the events of one type of a single tfchain block or stellar transaction
+type job struct {
+	handle func(ctx context.Context) error
+	done   chan error
+}
+
+// route runs the handler of one event type in its own goroutine with its own error policy
+type route struct {
+	name    string
+	policy  errorPolicy
+	jobs    chan job
+	breaker *breaker
+	// timeout bounds a single attempt of the handler, 0 means no timeout
+	timeout    time.Duration
+	deadLetter func(ctx context.Context, route string, err error)
+	// opened is called when the breaker of the route opens after a streak of failures
+	opened func(ctx context.Context, route string, failures int, err error)
+	// abandoned receives the outcome of an attempt that was left behind after the timeout, it is only used by the
+	// route goroutine
+	abandoned chan error
+}
+
+func newRoute(name string, policy errorPolicy, threshold int, cooldown time.Duration) *route {
+	return &route{
+		name:    name,
+		policy:  policy,
+		jobs:    make(chan job),
+		breaker: &breaker{threshold: threshold, cooldown: cooldown},
+	}
+}
+
+func (r *route) serve(ctx context.Context) {
+	for {
+		select {
+		case j := <-r.jobs:
+			j.done <- r.run(ctx, j.handle)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// run handles the events, transient failures are retried and permanent failures are dead lettered
+// whatever the policy of the route, other failures are retried or fatal according to the policy
+func (r *route) run(ctx context.Context, handle func(ctx context.Context) error) error {
+	err := backoff.RetryNotify(func() error {
+		err := r.attempt(ctx, handle)
+		switch {
+		case err == nil:
+			return nil
+		case ctx.Err() != nil, pkg.IsPermanent(err):
+			return backoff.Permanent(err)
+		case pkg.IsTransient(err), r.policy == errorPolicyRetry:
+			return err
+		default:
+			return backoff.Permanent(err)
+		}
+	}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx), func(err error, d time.Duration) {
+		log.Warn().Err(err).Str("route", r.name).Dur("retry_in", d).Msg("failed to handle events, retrying")
+	})
+	if pkg.IsPermanent(err) && ctx.Err() == nil {
+		log.Error().Err(err).Str("route", r.name).Msg("events failed permanently, dropping them")
+		r.deadLetter(ctx, r.name, err)
+		return nil
+	}
+	return errors.Wrapf(err, "failed to handle %s", r.name)
+}
+
+// attempt handles the events once the circuit breaker of the route lets them through
+func (r *route) attempt(ctx context.Context, handle func(ctx context.Context) error) error {
+	if err := r.breaker.wait(ctx, r.name); err != nil {
+		return err
+	}
+
+	err := r.handle(ctx, handle)
+	failures, open := r.breaker.record(err)
+	handlerFailures.Set(float64(failures), r.name)
+	if open {
+		log.Warn().Err(err).Str("route", r.name).Int("failures", failures).Dur("cooldown", r.breaker.cooldown).Msg("circuit breaker opened, pausing the route")
+		breakerOpen.Set(1, r.name)
+		// the breaker opens again on every failure after the cooldown, only the start of the streak is reported
+		if failures == r.breaker.threshold {
+			r.opened(ctx, r.name, failures, err)
+		}
+	} else {
+		breakerOpen.Set(0, r.name)
+	}
+	return err
+}
+
+// handle runs handle with the timeout of the route. The context of the handler is cancelled at the timeout, a
+// handler stuck in a call that does not take the context is left behind so the failure is reported and the
+// events are retried as a transient failure. The retry only starts once the abandoned attempt returned, so the
+// two never submit the same events at the same time.
+func (r *route) handle(ctx context.Context, handle func(ctx context.Context) error) error {
+	if r.abandoned != nil {
+		log.Warn().Str("route", r.name).Msg("waiting for the abandoned attempt to return before retrying")
+		select {
+		case <-r.abandoned:
+			r.abandoned = nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if r.timeout <= 0 {
+		return handle(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- handle(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return pkg.Transient(errors.Wrapf(err, "%s timed out after %s", r.name, r.timeout))
+		}
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Error().Str("route", r.name).Dur("timeout", r.timeout).Msg("handler did not return within the event timeout, abandoning it")
+			r.abandoned = done
+			return pkg.Transient(errors.Errorf("%s timed out after %s", r.name, r.timeout))
+		}
+		return ctx.Err()
+	}
+}
+
+// dispatch hands handle to the route goroutine and waits for the outcome, so events
+// of different types are still handled in the order they are dispatched
+func (r *route) dispatch(ctx context.Context, handle func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	select {
+	case r.jobs <- job{handle: handle, done: done}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatcher routes the events of the bridge subscriptions to the handler of their type
+type dispatcher struct {
+	malformed       *route
+	withdrawCreated *route
+	withdrawExpired *route
+	withdrawReady   *route
+	refundExpired   *route
+	refundReady     *route
+	mint            *route
+}
+
+// newDispatcher creates the routes of the bridge, every handler stops the bridge on error
+// as replaying the block or transaction on restart is the safe default. A route is paused for
+// cooldown after threshold consecutive failures, a threshold of 0 disables the circuit breakers.
+// An attempt that takes longer than timeout is aborted and retried.
+func newDispatcher(threshold int, cooldown time.Duration, timeout time.Duration, deadLetter func(ctx context.Context, route string, err error), opened func(ctx context.Context, route string, failures int, err error)) *dispatcher {
+	d := &dispatcher{
+		malformed:       newRoute("malformed events", errorPolicyFatal, threshold, cooldown),
+		withdrawCreated: newRoute("withdraw created", errorPolicyFatal, threshold, cooldown),
+		withdrawExpired: newRoute("withdraw expired", errorPolicyFatal, threshold, cooldown),
+		withdrawReady:   newRoute("withdraw ready", errorPolicyFatal, threshold, cooldown),
+		refundExpired:   newRoute("refund expired", errorPolicyFatal, threshold, cooldown),
+		refundReady:     newRoute("refund ready", errorPolicyFatal, threshold, cooldown),
+		mint:            newRoute("mint events", errorPolicyFatal, threshold, cooldown),
+	}
+	for _, r := range d.routes() {
+		r.timeout = timeout
+		r.deadLetter = deadLetter
+		r.opened = opened
+	}
+	return d
+}
+
+func (d *dispatcher) routes() []*route {
+	return []*route{d.malformed, d.withdrawCreated, d.withdrawExpired, d.withdrawReady, d.refundExpired, d.refundReady, d.mint}
+}
+
+// start runs the handler goroutines until the context is cancelled
+func (d *dispatcher) start(ctx context.Context) {
+	for _, r := range d.routes() {
+		go r.serve(ctx)
+	}
+}
+
+// breakers returns for every route whether its circuit breaker is open
+func (d *dispatcher) breakers() map[string]bool {
+	states := make(map[string]bool)
+	for _, r := range d.routes() {
+		states[r.name] = r.breaker.isOpen()
+	}
+	return states
+}
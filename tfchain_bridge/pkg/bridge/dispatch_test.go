@@ -0,0 +1,250 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// settledTfchain has every burn and refund executed already, it records the lookups in order
+type settledTfchain struct {
+	tfchainClient
+	calls []string
+	// failing is the lookup that fails
+	failing string
+}
+
+func (f *settledTfchain) lookup(call string) (bool, error) {
+	f.calls = append(f.calls, call)
+	if call == f.failing {
+		return false, errors.New("lookup failed")
+	}
+	return true, nil
+}
+
+func (f *settledTfchain) IsBurnedAlready(id types.U64) (bool, error) {
+	return f.lookup(fmt.Sprintf("burned %d", id))
+}
+
+func (f *settledTfchain) IsRefundedAlready(txHash string) (bool, error) {
+	return f.lookup("refunded " + txHash)
+}
+
+// ignoreDeadLetter drops permanently failed events for tests that do not look at them
+func ignoreDeadLetter(ctx context.Context, route string, err error) {}
+
+// ignoreBreakerOpened drops the opening of breakers for tests that do not look at them
+func ignoreBreakerOpened(ctx context.Context, route string, failures int, err error) {}
+
+func TestRouteErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      errorPolicy
+		failure     error
+		attempts    int
+		failed      bool
+		deadLetters int
+	}{
+		{name: "fatal", policy: errorPolicyFatal, failure: errors.New("failure"), attempts: 1, failed: true},
+		{name: "retry", policy: errorPolicyRetry, failure: errors.New("failure"), attempts: 2},
+		{name: "transient retried when fatal", policy: errorPolicyFatal, failure: pkg.Transient(errors.New("timeout")), attempts: 2},
+		{name: "permanent dead lettered when fatal", policy: errorPolicyFatal, failure: pkg.Permanent(errors.New("malformed memo")), attempts: 1, deadLetters: 1},
+		{name: "permanent dead lettered when retried", policy: errorPolicyRetry, failure: pkg.Permanent(errors.New("malformed memo")), attempts: 1, deadLetters: 1},
+		{name: "wrapped permanent", policy: errorPolicyRetry, failure: errors.Wrap(pkg.ErrTransactionAlreadyRefunded, "failed to refund"), attempts: 1, deadLetters: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r := newRoute("test", test.policy, 0, 0)
+			deadLetters := 0
+			r.deadLetter = func(ctx context.Context, route string, err error) {
+				if !errors.Is(err, test.failure) {
+					t.Errorf("expected the dead letter of %v, got %v", test.failure, err)
+				}
+				deadLetters++
+			}
+			go r.serve(ctx)
+
+			attempts := 0
+			err := r.dispatch(ctx, func(ctx context.Context) error {
+				attempts++
+				if attempts == 1 {
+					return test.failure
+				}
+				return nil
+			})
+			if (err != nil) != test.failed {
+				t.Errorf("expected failure %t, got %v", test.failed, err)
+			}
+			if attempts != test.attempts {
+				t.Errorf("expected %d attempts, got %d", test.attempts, attempts)
+			}
+			if deadLetters != test.deadLetters {
+				t.Errorf("expected %d dead letters, got %d", test.deadLetters, deadLetters)
+			}
+		})
+	}
+}
+
+func TestRouteDispatchStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// nothing serves the route, the dispatch must not block
+	r := newRoute("test", errorPolicyFatal, 0, 0)
+	if err := r.dispatch(ctx, func(ctx context.Context) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the dispatch to stop with the context, got %v", err)
+	}
+}
+
+func TestDispatchTfchainEvents(t *testing.T) {
+	events := subpkg.Events{
+		WithdrawCreatedEvents: []subpkg.WithdrawCreatedEvent{{ID: 1}},
+		WithdrawReadyEvents:   []subpkg.WithdrawReadyEvent{{ID: 2}},
+		RefundExpiredEvents:   []subpkg.RefundTransactionExpiredEvent{{Hash: "a"}},
+		RefundReadyEvents:     []subpkg.RefundTransactionReadyEvent{{Hash: "b"}},
+	}
+
+	tests := []struct {
+		name    string
+		failing string
+		calls   []string
+	}{
+		{name: "routed in event type order", calls: []string{"burned 1", "burned 2", "refunded a", "refunded b"}},
+		{name: "failure stops the block", failing: "burned 2", calls: []string{"burned 1", "burned 2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dispatcher := newDispatcher(0, 0, 0, ignoreDeadLetter, ignoreBreakerOpened)
+			dispatcher.start(ctx)
+
+			tfchain := &settledTfchain{failing: test.failing}
+			bridge := &Bridge{subClient: tfchain, config: &pkg.BridgeConfig{}}
+
+			err := bridge.dispatchTfchainEvents(ctx, dispatcher, events)
+			if (err != nil) != (test.failing != "") {
+				t.Errorf("expected failure %t, got %v", test.failing != "", err)
+			}
+			if !reflect.DeepEqual(tfchain.calls, test.calls) {
+				t.Errorf("expected calls %v, got %v", test.calls, tfchain.calls)
+			}
+		})
+	}
+}
+
+func TestRouteTimeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	tests := []struct {
+		name      string
+		handle    func(ctx context.Context) error
+		transient bool
+	}{
+		{name: "within the timeout", handle: func(ctx context.Context) error { return nil }},
+		{
+			name: "context deadline",
+			handle: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			transient: true,
+		},
+		{
+			name: "stuck in a call without context",
+			handle: func(ctx context.Context) error {
+				<-stuck
+				return nil
+			},
+			transient: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &route{name: "test", timeout: timeout}
+
+			start := time.Now()
+			err := r.handle(testContext(t), test.handle)
+			if waited := time.Since(start); waited > time.Second {
+				t.Errorf("expected the handler to be aborted after %s, waited %s", timeout, waited)
+			}
+			if test.transient != pkg.IsTransient(err) {
+				t.Errorf("expected a transient failure %t, got %v", test.transient, err)
+			}
+			if !test.transient && err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+		})
+	}
+}
+
+func newTestRoute(timeout time.Duration) *route {
+	r := newRoute("test", errorPolicyFatal, 0, 0)
+	r.timeout = timeout
+	r.deadLetter = func(ctx context.Context, route string, err error) {}
+	r.opened = func(ctx context.Context, route string, failures int, err error) {}
+	return r
+}
+
+func TestRouteRetriesOnlyAfterAbandonedAttemptReturned(t *testing.T) {
+	r := newTestRoute(20 * time.Millisecond)
+
+	var (
+		mu         sync.Mutex
+		attempts   int
+		running    int
+		overlapped bool
+		cancelled  bool
+	)
+	handle := func(ctx context.Context) error {
+		mu.Lock()
+		attempts++
+		attempt := attempts
+		running++
+		overlapped = overlapped || running > 1
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		if attempt == 1 {
+			// a call that does not take the context and outlives the timeout and the backoff before the retry
+			time.Sleep(time.Second)
+			mu.Lock()
+			cancelled = ctx.Err() != nil
+			mu.Unlock()
+		}
+		return nil
+	}
+
+	if err := r.run(testContext(t), handle); err != nil {
+		t.Fatalf("events were not handled: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if overlapped {
+		t.Error("the retry ran while the abandoned attempt was still running")
+	}
+	if !cancelled {
+		t.Error("the context of the abandoned attempt was not cancelled at the timeout")
+	}
+}
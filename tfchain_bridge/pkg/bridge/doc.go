@@ -0,0 +1,14 @@
+// Package bridge runs the TFT bridge between tfchain and stellar, it can be embedded in other services:
+//
+//	br, err := bridge.NewBridge(ctx, cfg, bridge.WithAlerter(myAlerter))
+//	if err != nil {
+//		return err
+//	}
+//	defer br.Close()
+//
+//	go http.ListenAndServe(":9090", br.MetricsHandler())
+//	return br.Run(ctx)
+//
+// Run blocks until the context is cancelled or the bridge fails, the state of the bridge is
+// available meanwhile through PendingWithdraws, PendingRefunds, Breakers, Paused and Height.
+package bridge
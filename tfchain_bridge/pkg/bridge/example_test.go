@@ -0,0 +1,65 @@
+package bridge_test
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/bridge"
+)
+
+// alertCounter counts the alerts of the embedded bridge by kind
+type alertCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *alertCounter) Alert(ctx context.Context, alert alert.Alert) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[alert.Kind]++
+	return nil
+}
+
+// Example shows a service running the bridge next to its own logic, with the alerts delivered to
+// the service and the bridge metrics served on the service mux
+func Example() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	cfg := pkg.BridgeConfig{
+		TfchainURL:      "wss://tfchain.grid.tf/ws",
+		TfchainSeed:     os.Getenv("TFCHAIN_SEED"),
+		PersistencyFile: "bridge.json",
+		StellarConfig: pkg.StellarConfig{
+			StellarNetwork:       "production",
+			StellarBridgeAccount: os.Getenv("BRIDGE_ACCOUNT"),
+			StellarSeed:          os.Getenv("STELLAR_SEED"),
+		},
+	}
+
+	alerts := &alertCounter{counts: make(map[string]int)}
+	br, err := bridge.NewBridge(ctx, cfg, bridge.WithAlerter(alerts))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer br.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/bridge/metrics", br.MetricsHandler())
+	go func() {
+		log.Println(http.ListenAndServe(":9090", mux))
+	}()
+
+	if err := br.Run(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("bridge stopped: %s", err)
+	}
+	if height, err := br.Height(); err == nil {
+		log.Printf("bridge stopped at tfchain height %d and stellar cursor %s", height.LastHeight, height.StellarCursor)
+	}
+}
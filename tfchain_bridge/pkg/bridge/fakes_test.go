@@ -0,0 +1,514 @@
+package bridge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// accounts the tests deposit from, withdraw to and mint on
+const (
+	testSender      = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+	testOtherSender = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+	testTwinAddress = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+)
+
+// callLog records the extrinsics and stellar submissions of the fakes in the order they are made
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, fmt.Sprintf(format, args...))
+}
+
+func (l *callLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+// fakeTfchain keeps the bridge pallet state the handlers read and changes it on the extrinsics they submit
+type fakeTfchain struct {
+	log *callLog
+	// delay is called before every extrinsic, tests use it to shuffle concurrent submissions
+	delay func()
+	// retractMints drops the mints after their submission, as if their block was retracted
+	retractMints bool
+	// lookupErr fails the twin lookups, as an unreachable chain does
+	lookupErr error
+
+	mu            sync.Mutex
+	twins         map[uint32]substrate.AccountID
+	proposedMints map[string]*subpkg.MintTransaction
+	executedMints map[string]*subpkg.MintTransaction
+	burns         map[uint64]*substrate.BurnTransaction
+	executedBurns map[uint64]bool
+	refunds       map[string]*substrate.RefundTransaction
+	refunded      map[string]bool
+}
+
+func newFakeTfchain(log *callLog) *fakeTfchain {
+	return &fakeTfchain{
+		log:           log,
+		twins:         make(map[uint32]substrate.AccountID),
+		proposedMints: make(map[string]*subpkg.MintTransaction),
+		executedMints: make(map[string]*subpkg.MintTransaction),
+		burns:         make(map[uint64]*substrate.BurnTransaction),
+		executedBurns: make(map[uint64]bool),
+		refunds:       make(map[string]*substrate.RefundTransaction),
+		refunded:      make(map[string]bool),
+	}
+}
+
+// addTwin registers a twin with the account of an ss58 address
+func (f *fakeTfchain) addTwin(t *testing.T, id uint32, address string) {
+	t.Helper()
+
+	account, err := substrate.FromAddress(address)
+	if err != nil {
+		t.Fatalf("invalid twin address %s: %s", address, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.twins[id] = account
+}
+
+func (f *fakeTfchain) extrinsic() {
+	if f.delay != nil {
+		f.delay()
+	}
+}
+
+func (f *fakeTfchain) SubscribeTfchainBridgeEvents(ctx context.Context, eventChannel chan<- subpkg.EventSubscription, lastHeight func() (uint32, error)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeTfchain) IsBridgeValidator() (bool, error) { return true, nil }
+
+func (f *fakeTfchain) GetBridgeValidators() ([]substrate.AccountID, error) { return nil, nil }
+
+func (f *fakeTfchain) PauseSubmissions() {}
+
+func (f *fakeTfchain) ResumeSubmissions() {}
+
+func (f *fakeTfchain) Close() {}
+
+func (f *fakeTfchain) GetTwin(id uint32) (*substrate.Twin, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.lookupErr != nil {
+		return nil, f.lookupErr
+	}
+	account, ok := f.twins[id]
+	if !ok {
+		return nil, substrate.ErrNotFound
+	}
+	return &substrate.Twin{ID: types.U32(id), Account: account}, nil
+}
+
+func (f *fakeTfchain) GetFarm(id uint32) (*substrate.Farm, error) { return nil, substrate.ErrNotFound }
+
+func (f *fakeTfchain) GetNode(id uint32) (*substrate.Node, error) { return nil, substrate.ErrNotFound }
+
+func (f *fakeTfchain) GetEntity(id uint32) (*substrate.Entity, error) {
+	return nil, substrate.ErrNotFound
+}
+
+func (f *fakeTfchain) CheckMinted(ctx context.Context, txID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.executedMints[txID]
+	return ok, nil
+}
+
+func (f *fakeTfchain) GetProposedMintTransaction(txHash string) (*subpkg.MintTransaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if mint, ok := f.proposedMints[txHash]; ok {
+		return mint, nil
+	}
+	return nil, subpkg.ErrNotFound
+}
+
+func (f *fakeTfchain) GetExecutedMintTransaction(txHash string) (*subpkg.MintTransaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if mint, ok := f.executedMints[txHash]; ok {
+		return mint, nil
+	}
+	return nil, subpkg.ErrNotFound
+}
+
+// RetryProposeMintOrVote executes the mint right away, the fake chain has a single validator
+func (f *fakeTfchain) RetryProposeMintOrVote(ctx context.Context, txID string, target substrate.AccountID, amount *big.Int) error {
+	f.extrinsic()
+	f.log.add("ProposeMintOrVote %s %s %s", txID, target.String(), amount)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.retractMints {
+		return nil
+	}
+	delete(f.proposedMints, txID)
+	f.executedMints[txID] = &subpkg.MintTransaction{Amount: types.U64(amount.Uint64()), Target: target, Votes: 1}
+	return nil
+}
+
+func (f *fakeTfchain) IsBurnedAlready(id types.U64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.executedBurns[uint64(id)], nil
+}
+
+func (f *fakeTfchain) GetBurnTransaction(id types.U64) (*substrate.BurnTransaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// executed burns are moved out of the pending burn transactions
+	if burn, ok := f.burns[uint64(id)]; ok && !f.executedBurns[uint64(id)] {
+		return burn, nil
+	}
+	return nil, substrate.ErrBurnTransactionNotFound
+}
+
+func (f *fakeTfchain) GetExecutedBurnTransaction(id uint64) (*substrate.BurnTransaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if burn, ok := f.burns[id]; ok && f.executedBurns[id] {
+		return burn, nil
+	}
+	return nil, substrate.ErrBurnTransactionNotFound
+}
+
+func (f *fakeTfchain) HasWithdrawSignature(id uint64, stellarAddress string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	burn, ok := f.burns[id]
+	if !ok || f.executedBurns[id] {
+		return false, nil
+	}
+	for _, sig := range burn.Signatures {
+		if string(sig.StellarAddress) == stellarAddress {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeTfchain) RetryProposeWithdrawOrAddSig(ctx context.Context, txID uint64, target string, amount *big.Int, signature string, stellarAddress string, sequenceNumber uint64) error {
+	f.extrinsic()
+	f.log.add("ProposeWithdrawOrAddSig %d %s %s seq=%d", txID, target, amount, sequenceNumber)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	burn, ok := f.burns[txID]
+	if !ok {
+		burn = &substrate.BurnTransaction{Target: target, Amount: types.U64(amount.Uint64()), SequenceNumber: types.U64(sequenceNumber)}
+		f.burns[txID] = burn
+	}
+	burn.Signatures = append(burn.Signatures, substrate.StellarSignature{Signature: []byte(signature), StellarAddress: []byte(stellarAddress)})
+	return nil
+}
+
+func (f *fakeTfchain) RetrySetWithdrawExecuted(ctx context.Context, txID uint64) error {
+	f.extrinsic()
+	f.log.add("SetWithdrawExecuted %d", txID)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.executedBurns[txID] = true
+	return nil
+}
+
+// ResetWithdraw drops the signatures of the burn transaction, it is signed again after its expiry
+func (f *fakeTfchain) ResetWithdraw(txID uint64, sequenceNumber uint64) error {
+	f.log.add("ResetWithdraw %d %d", txID, sequenceNumber)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if burn, ok := f.burns[txID]; ok {
+		burn.Signatures = nil
+	}
+	return nil
+}
+
+func (f *fakeTfchain) IsRefundedAlready(txHash string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.refunded[txHash], nil
+}
+
+func (f *fakeTfchain) GetRefundTransaction(txHash string) (*substrate.RefundTransaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if refund, ok := f.refunds[txHash]; ok {
+		return refund, nil
+	}
+	return nil, substrate.ErrBurnTransactionNotFound
+}
+
+func (f *fakeTfchain) RetryCreateRefundTransactionOrAddSig(ctx context.Context, txHash string, target string, amount int64, signature string, stellarAddress string, sequenceNumber uint64) error {
+	// like the substrate client a refund is not submitted once the bridge stops
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.extrinsic()
+	f.log.add("CreateRefundTransactionOrAddSig %s %s %d seq=%d", txHash, target, amount, sequenceNumber)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	refund, ok := f.refunds[txHash]
+	if !ok {
+		refund = &substrate.RefundTransaction{TxHash: txHash, Target: target, Amount: types.U64(amount), SequenceNumber: types.U64(sequenceNumber)}
+		f.refunds[txHash] = refund
+	}
+	refund.Signatures = append(refund.Signatures, substrate.StellarSignature{Signature: []byte(signature), StellarAddress: []byte(stellarAddress)})
+	return nil
+}
+
+func (f *fakeTfchain) RetrySetRefundTransactionExecutedTx(ctx context.Context, txHash string) error {
+	f.extrinsic()
+	f.log.add("SetRefundTransactionExecuted %s", txHash)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refunded[txHash] = true
+	return nil
+}
+
+// fakeWallet signs payments with a sequence number reserved like the stellar wallet does and records the
+// payments it submits, signatures and payment hashes are derived from the payment so they are deterministic
+type fakeWallet struct {
+	log     *callLog
+	keypair *keypair.Full
+	// delay is called while a payment is signed, tests use it to shuffle concurrent handlers
+	delay func()
+	// balanceErr is returned by the check of the balance for a payment
+	balanceErr error
+	// submitErr is returned by the submission of a withdraw payment
+	submitErr error
+	// signatureCount is the signature quorum of the bridge account, 1 if not set
+	signatureCount int
+	// accountErr is returned by the check of a withdraw destination
+	accountErr error
+
+	mu       sync.Mutex
+	sequence int64
+	// consumed is the last sequence number used by a payment the bridge did not make, signatures for it are stale
+	consumed  int64
+	deposits  map[string][]stellar.MintEvent
+	submitted map[string]bool
+}
+
+func newFakeWallet(log *callLog, sequence int64) *fakeWallet {
+	return &fakeWallet{
+		log:       log,
+		keypair:   keypair.MustRandom(),
+		sequence:  sequence,
+		deposits:  make(map[string][]stellar.MintEvent),
+		submitted: make(map[string]bool),
+	}
+}
+
+func (w *fakeWallet) reserve() int64 {
+	if w.delay != nil {
+		w.delay()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.sequence++
+	return w.sequence
+}
+
+func (w *fakeWallet) GetAddress() string { return w.keypair.Address() }
+
+func (w *fakeWallet) GetAccountConfig() (stellar.AccountConfig, error) {
+	return stellar.AccountConfig{Account: w.keypair.Address()}, nil
+}
+
+func (w *fakeWallet) GetSignatureCount() int {
+	if w.signatureCount == 0 {
+		return 1
+	}
+	return w.signatureCount
+}
+
+func (w *fakeWallet) GetBalance(ctx context.Context) (int64, error) { return 1 << 40, nil }
+
+func (w *fakeWallet) CheckAccount(ctx context.Context, account string) error { return w.accountErr }
+
+func (w *fakeWallet) CheckPaymentBalance(paymentAmount uint64) error { return w.balanceErr }
+
+func (w *fakeWallet) CheckBridgeTrustline(ctx context.Context) error { return nil }
+
+func (w *fakeWallet) ResetAccountSequence() error { return nil }
+
+func (w *fakeWallet) StreamBridgeStellarTransactions(ctx context.Context, mintChan chan<- stellar.MintEventSubscription, cursor string, opts stellar.StreamOptions) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (w *fakeWallet) GetTransactionMintEvents(txHash string) ([]stellar.MintEvent, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.deposits[txHash], nil
+}
+
+// CreatePaymentAndReturnSignature signs a payment, the signature of a claimable balance ends with the claimable suffix
+func (w *fakeWallet) CreatePaymentAndReturnSignature(ctx context.Context, target string, amount uint64, txID uint64, claimable bool) (string, uint64, error) {
+	sequence := w.reserve()
+	payment := fmt.Sprintf("%s:%s:%d:%d", w.keypair.Address(), target, amount, sequence)
+	if claimable {
+		payment += fakeClaimableSuffix
+	}
+	return base64.StdEncoding.EncodeToString([]byte(payment)), uint64(sequence), nil
+}
+
+func (w *fakeWallet) CreatePaymentWithSignaturesAndSubmit(ctx context.Context, target string, amount uint64, txHash string, signatures []substrate.StellarSignature, sequenceNumber int64) error {
+	w.log.add("SubmitPayment %s %d seq=%d", target, amount, sequenceNumber)
+	if w.submitErr != nil {
+		return w.submitErr
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.submitted[fakePaymentHash(target, amount, sequenceNumber)] = true
+	return nil
+}
+
+func (w *fakeWallet) HasSignatureQuorum(signatures []substrate.StellarSignature) bool {
+	return len(signatures) >= w.GetSignatureCount()
+}
+
+func (w *fakeWallet) PaymentTransactionHash(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (string, error) {
+	return fakePaymentHash(target, amount, sequenceNumber), nil
+}
+
+func (w *fakeWallet) SignsClaimableBalance(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (bool, error) {
+	if len(signatures) == 0 {
+		return false, stellar.ErrStaleSignatures
+	}
+	return isFakeClaimableSignature(string(signatures[0].Signature)), nil
+}
+
+func (w *fakeWallet) IsTransactionSubmitted(ctx context.Context, hash string) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.submitted[hash], nil
+}
+
+func (w *fakeWallet) CheckPaymentSequence(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sequenceNumber <= w.consumed {
+		return stellar.ErrStaleSignatures
+	}
+	return nil
+}
+
+// VerifyPaymentSignatures reports every signature as a valid signature of a signer
+func (w *fakeWallet) VerifyPaymentSignatures(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) ([]stellar.SignatureCheck, error) {
+	var checks []stellar.SignatureCheck
+	for _, signature := range signatures {
+		checks = append(checks, stellar.SignatureCheck{Signer: string(signature.StellarAddress), Weight: 1, Signed: true, Valid: true})
+	}
+	return checks, nil
+}
+
+func (w *fakeWallet) CreateRefundAndReturnSignature(ctx context.Context, target string, amount uint64, message string) (string, uint64, error) {
+	sequence := w.reserve()
+	signature := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s:%d:%s:%d", w.keypair.Address(), target, amount, message, sequence)))
+	return signature, uint64(sequence), nil
+}
+
+func (w *fakeWallet) CreateRefundPaymentWithSignaturesAndSubmit(ctx context.Context, target string, amount uint64, txHash string, signatures []substrate.StellarSignature, sequenceNumber int64) error {
+	w.log.add("SubmitRefund %s %s %d seq=%d", txHash, target, amount, sequenceNumber)
+	return nil
+}
+
+func fakePaymentHash(target string, amount uint64, sequenceNumber int64) string {
+	return fmt.Sprintf("payment-%s-%d-%d", target, amount, sequenceNumber)
+}
+
+// fakeClaimableSuffix ends the payments of fake signatures of a claimable balance
+const fakeClaimableSuffix = ":claimable"
+
+// isFakeClaimableSignature returns whether a base64 signature of a fake wallet signs a claimable balance
+func isFakeClaimableSignature(signature string) bool {
+	payment, err := base64.StdEncoding.DecodeString(signature)
+	return err == nil && strings.HasSuffix(string(payment), fakeClaimableSuffix)
+}
+
+// testDeposit is a deposit of amount from sender with a text memo, its hash and paging token are derived from n
+func testDeposit(n int, sender string, amount int64, memo string) stellar.MintEvent {
+	hash := fmt.Sprintf("%064x", n)
+	return stellar.MintEvent{
+		Senders: map[string]*big.Int{sender: big.NewInt(amount)},
+		Tx: hProtocol.Transaction{
+			ID:         hash,
+			Hash:       hash,
+			PT:         fmt.Sprint(185661728346116352 + n),
+			Successful: true,
+			MemoType:   "text",
+			Memo:       memo,
+		},
+	}
+}
+
+// deposit makes event known to the wallet and hands it to the mint handler
+func (w *fakeWallet) deposit(ctx context.Context, bridge *Bridge, event stellar.MintEvent) error {
+	w.mu.Lock()
+	w.deposits[event.Tx.Hash] = []stellar.MintEvent{event}
+	w.mu.Unlock()
+	return bridge.handleMintEvent(ctx, event)
+}
+
+// newTestBridge creates a bridge on the fakes with a persistency file in a temporary directory, the event routes
+// are served until the test ends
+func newTestBridge(t *testing.T, cfg pkg.BridgeConfig, tfchain tfchainClient, wallet stellarWallet, depositFee int64) *Bridge {
+	t.Helper()
+
+	persistency, err := pkg.InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bridge := &Bridge{
+		subClient:        tfchain,
+		wallet:           wallet,
+		blockPersistency: persistency,
+		config:           &cfg,
+		depositFee:       depositFee,
+		alerter:          &recordingAlerter{},
+		outstanding:      newOutstanding(),
+		addressCache:     newAddressCache(cfg.MemoCacheTTL),
+		bursts:           newBurstTracker(cfg.DepositBurstWindow, cfg.DepositBurstCount, cfg.DepositBurstAmount),
+		cursor:           &cursorTracker{},
+		checkpoint:       newCursorCheckpoint(cfg.CursorCheckpointCount, cfg.CursorCheckpointInterval),
+	}
+	bridge.events = newDispatcher(cfg.BreakerThreshold, cfg.BreakerCooldown, cfg.EventTimeout, bridge.recordDeadLetter, bridge.alertBreakerOpened)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	bridge.events.start(ctx)
+
+	return bridge
+}
+
+// testContext is cancelled when the test ends or after a deadline, a handler that blocks fails the test instead of hanging it
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
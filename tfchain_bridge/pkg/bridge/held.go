@@ -0,0 +1,132 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// HeldItems are the deposits and withdraws parked for manual handling
+type HeldItems struct {
+	Deposits  []pkg.HeldDeposit  `json:"deposits"`
+	Withdraws []pkg.HeldWithdraw `json:"withdraws"`
+}
+
+// HeldItems returns the deposits and withdraws parked for manual handling
+func (bridge *Bridge) HeldItems() (HeldItems, error) {
+	blockheight, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		return HeldItems{}, err
+	}
+
+	return HeldItems{
+		Deposits:  blockheight.HeldDeposits,
+		Withdraws: blockheight.HeldWithdraws,
+	}, nil
+}
+
+// ReleaseHeldDeposit mints a held deposit to its target after review. A deposit that is minted or refunded
+// already is not minted and is dropped from the held list, the mint is keyed on the deposit hash like any
+// other mint so the validators that release it vote for the same mint transaction.
+func (bridge *Bridge) ReleaseHeldDeposit(ctx context.Context, txHash string) error {
+	held, err := bridge.blockPersistency.GetHeldDeposit(txHash)
+	if err != nil {
+		return err
+	}
+
+	accountID, err := substrate.FromAddress(held.Target)
+	if err != nil {
+		return fmt.Errorf("held deposit %s has no tfchain target to mint on, it can only be refunded", txHash)
+	}
+
+	resolved, err := bridge.isDepositResolved(ctx, txHash)
+	if err != nil {
+		return err
+	}
+	if resolved {
+		return bridge.blockPersistency.RemoveHeldDeposit(txHash)
+	}
+
+	// a refund another validator started must not be raced by a mint
+	_, err = bridge.subClient.GetRefundTransaction(txHash)
+	if err == nil {
+		return fmt.Errorf("held deposit %s has a refund transaction on chain, it can only be refunded", txHash)
+	}
+	if !errors.Is(err, substrate.ErrBurnTransactionNotFound) {
+		return errors.Wrap(err, "failed to check refund transaction")
+	}
+
+	// a release is a decision of the operator, it proposes the mint whatever the mint role of the validator
+	log.Info().Str("tx_id", txHash).Str("target", held.Target).Int64("amount", held.Amount).Msg("releasing held deposit")
+	if err := bridge.subClient.RetryProposeMintOrVote(ctx, txHash, accountID, big.NewInt(held.Amount)); err != nil {
+		return err
+	}
+
+	bridge.recordAccounting(accounting.Entry{
+		Kind:          accounting.KindMint,
+		Amount:        uint64(held.Amount),
+		Source:        held.Sender,
+		Destination:   held.Target,
+		StellarTxHash: txHash,
+		TfchainTxID:   txHash,
+	})
+
+	return bridge.blockPersistency.RemoveHeldDeposit(txHash)
+}
+
+// RefundHeldDeposit refunds a held deposit to its sender after review. A deposit that is minted or refunded
+// already is not refunded and is dropped from the held list.
+func (bridge *Bridge) RefundHeldDeposit(ctx context.Context, txHash string) error {
+	held, err := bridge.blockPersistency.GetHeldDeposit(txHash)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := bridge.isDepositResolved(ctx, txHash)
+	if err != nil {
+		return err
+	}
+	if resolved {
+		return bridge.blockPersistency.RemoveHeldDeposit(txHash)
+	}
+
+	log.Info().Str("tx_id", txHash).Str("target", held.Sender).Int64("amount", held.Amount).Msg("refunding held deposit")
+	err = bridge.handleRefundExpired(ctx, subpkg.RefundTransactionExpiredEvent{
+		Hash:   txHash,
+		Target: held.Sender,
+		Amount: uint64(held.Amount),
+	})
+	if err != nil {
+		return err
+	}
+
+	return bridge.blockPersistency.RemoveHeldDeposit(txHash)
+}
+
+// isDepositResolved checks if a deposit is minted or refunded already, a resolved deposit must not be released nor refunded
+func (bridge *Bridge) isDepositResolved(ctx context.Context, txHash string) (bool, error) {
+	minted, err := bridge.subClient.CheckMinted(ctx, txHash)
+	if err != nil {
+		return false, err
+	}
+	if minted {
+		log.Info().Str("tx_id", txHash).Msg("held deposit is minted already, dropping it from the held list")
+		return true, nil
+	}
+
+	refunded, err := bridge.subClient.IsRefundedAlready(txHash)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check refund transaction")
+	}
+	if refunded {
+		log.Info().Str("tx_id", txHash).Msg("held deposit is refunded already, dropping it from the held list")
+	}
+	return refunded, nil
+}
@@ -0,0 +1,93 @@
+package bridge
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+func TestHeldDeposits(t *testing.T) {
+	held := func(n int) pkg.HeldDeposit {
+		return pkg.HeldDeposit{TxHash: fmt.Sprintf("%064x", n), Sender: testSender, Target: testTwinAddress, Amount: 1000000000, Reason: "review"}
+	}
+
+	tests := []struct {
+		name     string
+		resolve  func(bridge *Bridge) error
+		minted   bool
+		refunded bool
+		calls    []string
+		err      error
+		remains  bool
+	}{
+		{
+			name:    "release",
+			resolve: func(bridge *Bridge) error { return bridge.ReleaseHeldDeposit(testContext(t), held(1).TxHash) },
+			calls:   []string{fmt.Sprintf("ProposeMintOrVote %064x %s 1000000000", 1, testTwinAddress)},
+		},
+		{
+			name:    "refund",
+			resolve: func(bridge *Bridge) error { return bridge.RefundHeldDeposit(testContext(t), held(1).TxHash) },
+			calls:   []string{fmt.Sprintf("CreateRefundTransactionOrAddSig %064x %s 1000000000 seq=101", 1, testSender)},
+		},
+		{
+			name:    "release minted deposit",
+			resolve: func(bridge *Bridge) error { return bridge.ReleaseHeldDeposit(testContext(t), held(1).TxHash) },
+			minted:  true,
+		},
+		{
+			name:     "refund refunded deposit",
+			resolve:  func(bridge *Bridge) error { return bridge.RefundHeldDeposit(testContext(t), held(1).TxHash) },
+			refunded: true,
+		},
+		{
+			name:    "deposit not held",
+			resolve: func(bridge *Bridge) error { return bridge.ReleaseHeldDeposit(testContext(t), held(2).TxHash) },
+			err:     pkg.ErrNotFound,
+			remains: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := newFakeTfchain(calls)
+			wallet := newFakeWallet(calls, 100)
+			bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+			// the held deposit is known to horizon so it can be refunded
+			wallet.deposits[held(1).TxHash] = []stellar.MintEvent{testDeposit(1, testSender, 1000000000, "")}
+			if test.minted {
+				tfchain.executedMints[held(1).TxHash] = &subpkg.MintTransaction{}
+			}
+			if test.refunded {
+				tfchain.refunded[held(1).TxHash] = true
+			}
+			if err := bridge.blockPersistency.HoldDeposit(held(1)); err != nil {
+				t.Fatal(err)
+			}
+
+			items, err := bridge.HeldItems()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items.Deposits) != 1 || items.Deposits[0].TxHash != held(1).TxHash {
+				t.Fatalf("expected the deposit to be listed, got %+v", items.Deposits)
+			}
+
+			if err := test.resolve(bridge); err != test.err {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			assertCalls(t, test.calls, calls.get())
+
+			_, err = bridge.blockPersistency.GetHeldDeposit(held(1).TxHash)
+			if test.remains && err != nil {
+				t.Errorf("expected the deposit to stay held, got %v", err)
+			}
+			if !test.remains && err != pkg.ErrNotFound {
+				t.Errorf("expected the deposit to be dropped from the held list, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// BurnInspection lists the signatures of a burn transaction and whether they are valid for its payment
+type BurnInspection struct {
+	ID                 uint64                   `json:"id"`
+	Executed           bool                     `json:"executed"`
+	Target             string                   `json:"target"`
+	Amount             uint64                   `json:"amount"`
+	SequenceNumber     int64                    `json:"sequence_number"`
+	StellarTxHash      string                   `json:"stellar_tx_hash"`
+	RequiredSignatures int                      `json:"required_signatures"`
+	Signatures         []stellar.SignatureCheck `json:"signatures"`
+}
+
+// InspectBurn verifies the signatures stored on chain for the burn transaction with id
+// against the stellar payment the bridge would submit for it
+func (bridge *Bridge) InspectBurn(ctx context.Context, id uint64) (*BurnInspection, error) {
+	inspection := &BurnInspection{
+		ID:                 id,
+		RequiredSignatures: bridge.wallet.GetSignatureCount(),
+	}
+
+	burnTx, err := bridge.subClient.GetBurnTransaction(types.U64(id))
+	if err != nil {
+		log.Debug().Err(err).Uint64("ID", id).Msg("failed to get burn transaction, looking up executed burn transactions")
+		burnTx, err = bridge.subClient.GetExecutedBurnTransaction(id)
+		if err != nil {
+			return nil, pkg.ErrNotFound
+		}
+		inspection.Executed = true
+	}
+
+	inspection.Target = burnTx.Target
+	inspection.Amount = uint64(burnTx.Amount)
+	inspection.SequenceNumber = int64(burnTx.SequenceNumber)
+
+	inspection.StellarTxHash, err = bridge.wallet.PaymentTransactionHash(inspection.Target, inspection.Amount, inspection.SequenceNumber, burnTx.Signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	inspection.Signatures, err = bridge.wallet.VerifyPaymentSignatures(inspection.Target, inspection.Amount, inspection.SequenceNumber, burnTx.Signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	return inspection, nil
+}
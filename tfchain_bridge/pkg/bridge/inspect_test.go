@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestInspectBurn(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	signatures := []substrate.StellarSignature{{Signature: []byte("signature"), StellarAddress: []byte("validator")}}
+	tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: signatures}
+	tfchain.burns[8] = &substrate.BurnTransaction{Target: testSender, Amount: 300000000, SequenceNumber: 99, Signatures: signatures}
+	tfchain.executedBurns[8] = true
+
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, newFakeWallet(calls, 100), 10000000)
+	ctx := testContext(t)
+
+	tests := []struct {
+		name     string
+		id       uint64
+		executed bool
+		amount   uint64
+		err      error
+	}{
+		{name: "pending", id: 7, amount: 500000000},
+		{name: "executed", id: 8, executed: true, amount: 300000000},
+		{name: "unknown", id: 9, err: pkg.ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inspection, err := bridge.InspectBurn(ctx, test.id)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if test.err != nil {
+				return
+			}
+			if inspection.Executed != test.executed || inspection.Amount != test.amount || inspection.Target != testSender {
+				t.Errorf("unexpected inspection %+v", inspection)
+			}
+			if inspection.StellarTxHash == "" {
+				t.Error("expected the hash of the stellar payment")
+			}
+			if len(inspection.Signatures) != 1 || inspection.Signatures[0].Signer != "validator" || !inspection.Signatures[0].Valid {
+				t.Errorf("expected the signature of the validator, got %+v", inspection.Signatures)
+			}
+		})
+	}
+
+	assertCalls(t, nil, calls.get())
+}
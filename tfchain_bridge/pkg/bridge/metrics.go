@@ -0,0 +1,27 @@
+package bridge
+
+import (
+	"github.com/threefoldtech/tfchain_bridge/pkg/metrics"
+)
+
+var (
+	buildInfo = metrics.NewGauge("bridge_build_info", "Build information of the bridge, always 1", "version", "commit", "build_date")
+	// configInfo is labeled with the stellar configuration that is part of the payments the validators sign together
+	configInfo      = metrics.NewGauge("bridge_config_info", "Configuration of the bridge that must match on all validators, always 1", "network", "bridge_account", "asset_code", "asset_issuer", "base_fee", "payment_timeout", "claimable_balances")
+	depositFeeGauge = metrics.NewGauge("bridge_deposit_fee", "Deposit fee in stroops taken from the deposits minted by the bridge")
+	stellarBalance  = metrics.NewGauge("bridge_stellar_balance", "XLM balance of the bridge stellar account")
+	// handlerFailures and breakerOpen are labeled with the route of the failing event type
+	handlerFailures                  = metrics.NewGauge("bridge_handler_consecutive_failures", "Consecutive failures of the handler of an event type", "route")
+	breakerOpen                      = metrics.NewGauge("bridge_circuit_breaker_open", "1 if the circuit breaker of an event type is open", "route")
+	outstandingBurns                 = metrics.NewGauge("bridge_outstanding_burns", "Burn transactions seen in tfchain events that are not executed yet")
+	outstandingRefunds               = metrics.NewGauge("bridge_outstanding_refunds", "Refund transactions seen in tfchain events that are not executed yet")
+	duplicateEvents                  = metrics.NewCounter("bridge_duplicate_events_total", "Tfchain events that were delivered more than once and dropped", "type")
+	stellarEventsBuffered            = metrics.NewGauge("bridge_stellar_events_buffered", "Fetched stellar transactions waiting to be processed")
+	returnDeposits                   = metrics.NewCounter("bridge_return_memo_deposits_total", "Deposits with a return memo that were recorded instead of minted")
+	staleWithdraws                   = metrics.NewCounter("bridge_stale_withdraws_total", "Withdraws whose signatures were collected for a stellar sequence number that can no longer be used")
+	withdrawAwaitingSignatures       = metrics.NewGauge("bridge_withdraws_awaiting_signatures", "Withdraws seen created that are not ready to be paid yet")
+	withdrawOldestAwaitingSignatures = metrics.NewGauge("bridge_withdraw_oldest_awaiting_signatures_seconds", "Time the oldest withdraw has been waiting for signatures")
+	withdrawSignatureDuration        = metrics.NewHistogram("bridge_withdraw_signature_collection_seconds", "Time from the creation of a withdraw to the collection of its signatures", []float64{10, 30, 60, 120, 300, 600, 1800, 3600})
+	cursorCheckpoints                = metrics.NewCounter("bridge_stellar_cursor_checkpoints_total", "Stellar cursor saves of scanned transactions that did not save it themselves")
+	mintLatency                      = metrics.NewHistogram("bridge_mint_latency_seconds", "Time from the ledger close of a deposit to the submission of its mint", []float64{5, 10, 30, 60, 120, 300, 600, 1800, 3600})
+)
@@ -2,24 +2,60 @@ package bridge
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"math/big"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/threefoldtech/substrate-client"
 	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
 )
 
+// mintProposalPollInterval is the interval at which a vote only validator looks up the proposal of a mint
+var mintProposalPollInterval = 10 * time.Second
+
+// mintProposalTimeout is how long a vote only validator waits for the proposal of a mint before the
+// deposit is retried, so a mint nobody proposes does not hold back the deposits that follow forever
+const mintProposalTimeout = 5 * time.Minute
+
+// deposit actions
+const (
+	DepositActionMint   = "mint"
+	DepositActionRefund = "refund"
+	DepositActionHold   = "hold"
+	DepositActionSkip   = "skip"
+	DepositActionAbsorb = "absorb"
+)
+
+// DepositOutcome is what the bridge decides to do with a deposit on the bridge account
+type DepositOutcome struct {
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	// Sender is the stellar account the deposit came from, refunds are sent back to it
+	Sender string `json:"sender,omitempty"`
+	// Target is the substrate address minted on
+	Target string `json:"target,omitempty"`
+	Amount int64  `json:"amount"`
+	// NetAmount is the amount the target receives after the deposit fee
+	NetAmount int64 `json:"net_amount,omitempty"`
+	// Note is the free-form note of the memo, it is not used for routing
+	Note string `json:"note,omitempty"`
+}
+
 // mint handler for stellar
 func (bridge *Bridge) mint(ctx context.Context, senders map[string]*big.Int, tx hProtocol.Transaction) error {
-	minted, err := bridge.subClient.IsMintedAlready(tx.Hash)
+	minted, err := bridge.subClient.CheckMinted(ctx, tx.Hash)
 	if err != nil {
-		if !errors.Is(err, substrate.ErrMintTransactionNotFound) {
-			return err
-		}
+		return err
 	}
 
 	if minted {
@@ -28,116 +64,593 @@ func (bridge *Bridge) mint(ctx context.Context, senders map[string]*big.Int, tx
 	}
 
 	if len(senders) == 0 {
+		// transactions with only payments of unsupported assets have no senders, a malformed
+		// transaction must not stop the bridge either so it is skipped
+		log.Warn().Str("tx_id", tx.Hash).Msg("transaction has no senders of the bridged asset, skipping")
+		bridge.saveStellarCursor(tx.PagingToken())
 		return nil
 	}
 
-	if len(senders) > 1 {
-		log.Info().Msgf("cannot process mint transaction, multiple senders found, refunding now")
-		for sender, depositAmount := range senders {
-			return bridge.refund(context.Background(), sender, depositAmount.Int64(), tx)
+	if bridge.isTooOld(tx, time.Now()) {
+		// a lost persistency must not make the bridge mint deposits from long ago again
+		log.Warn().Str("tx_id", tx.Hash).Time("ledger_close_time", tx.LedgerCloseTime).Msg("deposit is older than the replay protection window, skipping")
+		bridge.saveStellarCursor(tx.PagingToken())
+		return nil
+	}
+
+	outcome, err := bridge.decideDeposit(senders, tx.Memo, tx.MemoType)
+	if err != nil {
+		return err
+	}
+
+	switch outcome.Action {
+	case DepositActionSkip:
+		log.Debug().Str("tx_id", tx.Hash).Str("reason", outcome.Reason).Msg("skipping this transaction")
+		if tx.MemoType == "return" {
+			bridge.recordReturnDeposit(tx, outcome.Amount)
 		}
+		// save cursor
+		cursor := tx.PagingToken()
+		bridge.saveStellarCursor(cursor)
+		log.Info().Msg("stellar cursor saved")
+		return nil
+	case DepositActionRefund:
+		log.Info().Str("tx_id", tx.Hash).Str("reason", outcome.Reason).Msg("refunding transaction")
+		return bridge.refund(ctx, outcome.Sender, outcome.Sender, outcome.Amount, tx)
+	case DepositActionAbsorb:
+		// the fee collection account is paid through the refund flow so the validators sign it like any refund
+		log.Info().Str("tx_id", tx.Hash).Str("target", outcome.Target).Msg("absorbing deposit below the deposit fee")
+		return bridge.refund(ctx, outcome.Sender, outcome.Target, outcome.Amount, tx)
+	case DepositActionHold:
+		if err := bridge.holdDeposit(ctx, outcome, tx); err != nil {
+			return err
+		}
+		// a quarantined source keeps its window going so a burst does not resume minting halfway
+		bridge.bursts.add(outcome.Sender, outcome.Amount, time.Now())
+		return nil
 	}
 
-	var receiver string
-	var depositedAmount *big.Int
-	for receiv, amount := range senders {
-		receiver = receiv
-		depositedAmount = amount
+	log.Info().Int64("amount", outcome.Amount).Str("tx_id", tx.Hash).Str("note", outcome.Note).Msgf("target substrate address to mint on: %s", outcome.Target)
+
+	accountID, err := substrate.FromAddress(outcome.Target)
+	if err != nil {
+		return pkg.Permanent(err)
 	}
 
-	if tx.Memo == "" {
-		log.Info().Str("tx_id", tx.Hash).Msg("transaction has empty memo, refunding now")
-		return bridge.refund(context.Background(), receiver, depositedAmount.Int64(), tx)
+	latency := depositLatency(tx, time.Now())
+	mintLatency.Observe(latency.Seconds())
+	log.Info().Str("tx_id", tx.Hash).Dur("latency", latency).Msg("submitting mint")
+
+	err = bridge.proposeOrVoteMint(ctx, tx.Hash, accountID, big.NewInt(outcome.Amount))
+	if err != nil {
+		return err
 	}
 
-	if tx.MemoType == "return" {
-		log.Debug().Str("tx_id", tx.Hash).Msg("transaction has a return memo hash, skipping this transaction")
-		// save cursor
-		cursor := tx.PagingToken()
-		err := bridge.blockPersistency.SaveStellarCursor(cursor)
+	// the cursor only moves past the deposit once its mint is confirmed, a failed confirmation
+	// retries the deposit and the minted check above keeps the retry from minting it twice
+	if err := bridge.confirmMintIncluded(tx.Hash); err != nil {
+		return err
+	}
+
+	bridge.recordAccounting(accounting.Entry{
+		Kind:          accounting.KindMint,
+		Amount:        uint64(outcome.Amount),
+		Source:        outcome.Sender,
+		Destination:   outcome.Target,
+		StellarTxHash: tx.Hash,
+		TfchainTxID:   tx.Hash,
+		Note:          outcome.Note,
+	})
+
+	bridge.bursts.add(outcome.Sender, outcome.Amount, time.Now())
+
+	if bridge.config.DailyMintLimit > 0 {
+		if err = bridge.blockPersistency.AddDailyMinted(outcome.Target, outcome.Amount, time.Now().UTC()); err != nil {
+			log.Err(err).Str("target", outcome.Target).Msg("error while saving daily minted amount")
+		}
+	}
+
+	// save cursor
+	cursor := tx.PagingToken()
+	bridge.saveStellarCursor(cursor)
+
+	return nil
+}
+
+// depositAmount is the amount deposited by all senders of a deposit
+func depositAmount(senders map[string]*big.Int) *big.Int {
+	total := big.NewInt(0)
+	for _, amount := range senders {
+		if amount != nil {
+			total.Add(total, amount)
+		}
+	}
+	return total
+}
+
+// proposeOrVoteMint proposes or votes for a mint following the mint role of the validator, a vote only
+// validator waits until another validator proposed the mint before it votes
+func (bridge *Bridge) proposeOrVoteMint(ctx context.Context, txID string, target substrate.AccountID, amount *big.Int) error {
+	if bridge.config.MintRole == pkg.MintRoleVoteOnly {
+		proposed, err := bridge.awaitMintProposal(ctx, txID, mintProposalTimeout)
 		if err != nil {
-			log.Err(err).Msg("error while saving cursor")
 			return err
 		}
-		log.Info().Msg("stellar cursor saved")
+		if !proposed {
+			log.Info().Str("tx_id", txID).Msg("mint transaction executed already, vote not needed")
+			return nil
+		}
+	}
+	return bridge.subClient.RetryProposeMintOrVote(ctx, txID, target, amount)
+}
+
+// awaitMintProposal polls until the mint is proposed by another validator and returns false if it
+// got executed without the vote of this validator, a transient error is returned if it is not proposed
+// within timeout
+func (bridge *Bridge) awaitMintProposal(ctx context.Context, txID string, timeout time.Duration) (bool, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		minted, err := bridge.subClient.CheckMinted(ctx, txID)
+		if err != nil {
+			return false, err
+		}
+		if minted {
+			return false, nil
+		}
+
+		_, err = bridge.subClient.GetProposedMintTransaction(txID)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, subpkg.ErrNotFound) {
+			return false, errors.Wrap(err, "failed to lookup mint proposal")
+		}
+
+		log.Debug().Str("tx_id", txID).Msg("vote only validator, waiting for the mint to be proposed")
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-deadline.C:
+			return false, pkg.Transient(errors.Errorf("mint %s is not proposed within %s", txID, timeout))
+		case <-time.After(mintProposalPollInterval):
+		}
+	}
+}
+
+// recordReturnDeposit keeps a deposit with a return memo so the refunds of the bridge can be reconciled,
+// failing to record it does not hold back the deposits that follow
+func (bridge *Bridge) recordReturnDeposit(tx hProtocol.Transaction, amount int64) {
+	returnDeposits.Inc()
+
+	refunded, err := base64.StdEncoding.DecodeString(tx.Memo)
+	if err != nil {
+		log.Warn().Err(err).Str("tx_id", tx.Hash).Msg("return memo is not base64 encoded")
+	}
+
+	err = bridge.blockPersistency.RecordReturnDeposit(pkg.ReturnDeposit{
+		TxHash:     tx.Hash,
+		Amount:     amount,
+		RefundedTx: hex.EncodeToString(refunded),
+		SeenAt:     time.Now(),
+	})
+	if err != nil {
+		log.Err(err).Str("tx_id", tx.Hash).Msg("error while recording return memo deposit")
+	}
+}
+
+// confirmMintIncluded checks the mint of a deposit is in the chain state, the block the mint extrinsic was
+// included in can still be retracted so inclusion alone does not guarantee the deposit is minted
+func (bridge *Bridge) confirmMintIncluded(txHash string) error {
+	if bridge.config.ObserverMode {
+		return nil
+	}
+
+	_, err := bridge.subClient.GetExecutedMintTransaction(txHash)
+	if err == nil {
 		return nil
 	}
+	if !errors.Is(err, subpkg.ErrNotFound) {
+		return pkg.Transient(err)
+	}
+
+	_, err = bridge.subClient.GetProposedMintTransaction(txHash)
+	if errors.Is(err, subpkg.ErrNotFound) {
+		return pkg.Transient(fmt.Errorf("mint of %s is not on chain after its inclusion", txHash))
+	}
+	return pkg.Transient(err)
+}
+
+// depositLatency is the time between the ledger close of a deposit and now
+func depositLatency(tx hProtocol.Transaction, now time.Time) time.Duration {
+	return now.Sub(tx.LedgerCloseTime)
+}
+
+// isTooOld checks if a deposit closed before the replay protection window, the window does not
+// apply when the bridge account is explicitly rescanned
+func (bridge *Bridge) isTooOld(tx hProtocol.Transaction, now time.Time) bool {
+	if bridge.config.IgnoreDepositsOlderThan <= 0 || bridge.config.RescanBridgeAccount {
+		return false
+	}
+	return depositLatency(tx, now) > bridge.config.IgnoreDepositsOlderThan
+}
+
+// SimulateDeposit returns what the bridge would do with a deposit of amount from sender with memo,
+// without submitting anything
+func (bridge *Bridge) SimulateDeposit(sender string, amount int64, memo string) (DepositOutcome, error) {
+	return bridge.decideDeposit(map[string]*big.Int{sender: big.NewInt(amount)}, memo, "text")
+}
+
+// decideDeposit decides whether a deposit is minted, refunded, held or skipped. It only reads state
+// so it is shared by the mint handler and the deposit simulation.
+func (bridge *Bridge) decideDeposit(senders map[string]*big.Int, memo string, memoType string) (DepositOutcome, error) {
+	if len(senders) > 1 {
+		for sender, depositAmount := range senders {
+			return DepositOutcome{Action: DepositActionRefund, Reason: "multiple senders", Sender: sender, Amount: depositAmount.Int64()}, nil
+		}
+	}
+
+	var outcome DepositOutcome
+	for sender, amount := range senders {
+		if amount == nil {
+			continue
+		}
+		outcome.Sender = sender
+		outcome.Amount = amount.Int64()
+	}
+
+	if outcome.Sender == "" {
+		outcome.Action = DepositActionSkip
+		outcome.Reason = "no deposited amount"
+		return outcome, nil
+	}
+
+	if memo == "" {
+		outcome.Action = DepositActionRefund
+		outcome.Reason = "empty memo"
+		return outcome, nil
+	}
+
+	if memoType == "return" {
+		outcome.Action = DepositActionSkip
+		outcome.Reason = "return memo"
+		return outcome, nil
+	}
+
+	if memoType == "text" {
+		// stellar does not accept longer memos, this only catches simulated deposits. An SS58 address does
+		// not fit a memo text, the length of a memo carrying one is not checked
+		if _, isAddress, _ := getSubstrateAddressFromAddressMemo(memo); !isAddress && len(memo) > maxMemoTextLength {
+			outcome.Action = DepositActionRefund
+			outcome.Reason = fmt.Sprintf("invalid memo: memo text is longer than %d bytes", maxMemoTextLength)
+			return outcome, nil
+		}
+		if bridge.config.MemoNotes {
+			memo, outcome.Note = splitMemoNote(memo)
+		}
+	}
 
-	// if the deposited amount is lower than the depositfee, trigger a refund
-	if depositedAmount.Cmp(big.NewInt(bridge.depositFee)) <= 0 {
-		return bridge.refund(context.Background(), receiver, depositedAmount.Int64(), tx)
+	// if the deposited amount is lower than the depositfee, handle it according to the below fee policy
+	if outcome.Amount <= bridge.depositFee {
+		outcome.Reason = "amount below deposit fee"
+		switch bridge.config.BelowFeePolicy {
+		case pkg.BelowFeePolicyAbsorb:
+			outcome.Action = DepositActionAbsorb
+			outcome.Target = bridge.config.FeeCollectionAccount
+		case pkg.BelowFeePolicyIgnore:
+			outcome.Action = DepositActionSkip
+		default:
+			outcome.Action = DepositActionRefund
+		}
+		return outcome, nil
 	}
 
-	destinationSubstrateAddress, err := bridge.getSubstrateAddressFromMemo(tx.Memo)
+	destinationSubstrateAddress, err := bridge.getSubstrateAddress(memo, memoType)
+	if pkg.IsTransient(err) {
+		// the target could not be looked up, refunding would bounce a deposit with a valid memo
+		return DepositOutcome{}, err
+	}
 	if err != nil {
 		log.Info().Msgf("error while decoding tx memo: %s", err.Error())
 		// memo is not formatted correctly, issue a refund
-		return bridge.refund(context.Background(), receiver, depositedAmount.Int64(), tx)
+		outcome.Action = DepositActionRefund
+		outcome.Reason = fmt.Sprintf("invalid memo: %s", err.Error())
+		return outcome, nil
+	}
+	outcome.Target = destinationSubstrateAddress
+	outcome.NetAmount = outcome.Amount - bridge.depositFee
+
+	exceeded, err := bridge.exceedsDailyMintLimit(destinationSubstrateAddress, outcome.Amount, time.Now().UTC())
+	if err != nil {
+		return DepositOutcome{}, err
+	}
+	if exceeded {
+		outcome.Action = DepositActionHold
+		outcome.Reason = alert.KindDailyLimitExceeded
+		return outcome, nil
+	}
+
+	if bridge.bursts.exceeds(outcome.Sender, outcome.Amount, time.Now()) {
+		outcome.Action = DepositActionHold
+		outcome.Reason = alert.KindDepositBurst
+		return outcome, nil
 	}
 
-	log.Info().Int64("amount", depositedAmount.Int64()).Str("tx_id", tx.Hash).Msgf("target substrate address to mint on: %s", destinationSubstrateAddress)
+	outcome.Action = DepositActionMint
+	return outcome, nil
+}
 
-	accountID, err := substrate.FromAddress(destinationSubstrateAddress)
+// exceedsDailyMintLimit checks if minting amount to target would cross the configured daily mint limit,
+// the limit applies per UTC day so the counters of all validators reset at UTC midnight
+func (bridge *Bridge) exceedsDailyMintLimit(target string, amount int64, now time.Time) (bool, error) {
+	if bridge.config.DailyMintLimit <= 0 {
+		return false, nil
+	}
+
+	minted, err := bridge.blockPersistency.GetDailyMinted(target, now)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	err = bridge.subClient.RetryProposeMintOrVote(ctx, tx.Hash, accountID, depositedAmount)
+	return minted+amount > bridge.config.DailyMintLimit, nil
+}
+
+// holdDeposit parks a deposit for manual review, alerts the operators and moves the cursor past it.
+// The reason of the outcome is the kind of the alert.
+func (bridge *Bridge) holdDeposit(ctx context.Context, outcome DepositOutcome, tx hProtocol.Transaction) error {
+	message := "deposit held for review, it exceeds the daily mint limit of its target"
+	if outcome.Reason == alert.KindDepositBurst {
+		message = "deposit held for review, its source exceeds the deposit burst limits"
+	}
+	log.Warn().Str("tx_id", tx.Hash).Str("sender", outcome.Sender).Str("target", outcome.Target).Int64("amount", outcome.Amount).Str("reason", outcome.Reason).Msg("holding deposit for review")
+
+	err := bridge.blockPersistency.HoldDeposit(pkg.HeldDeposit{
+		TxHash:      tx.Hash,
+		PagingToken: tx.PagingToken(),
+		Sender:      outcome.Sender,
+		Target:      outcome.Target,
+		Amount:      outcome.Amount,
+		Reason:      outcome.Reason,
+		HeldAt:      time.Now(),
+	})
 	if err != nil {
 		return err
 	}
 
+	err = bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    outcome.Reason,
+		Message: message,
+		Fields: map[string]string{
+			"tx_id":  tx.Hash,
+			"sender": outcome.Sender,
+			"target": outcome.Target,
+			"amount": fmt.Sprint(outcome.Amount),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+
 	// save cursor
 	cursor := tx.PagingToken()
-	if err = bridge.blockPersistency.SaveStellarCursor(cursor); err != nil {
-		log.Err(err).Msgf("error while saving cursor")
-		return err
-	}
+	bridge.saveStellarCursor(cursor)
 
 	return nil
 }
 
-func (bridge *Bridge) getSubstrateAddressFromMemo(memo string) (string, error) {
+// getSubstrateAddress gets the address to mint on from a deposit memo, a text memo refers to a grid
+// object or carries an SS58 address while a hash memo carries the public key of the account itself.
+// Hash memos are only minted when they are enabled.
+func (bridge *Bridge) getSubstrateAddress(memo string, memoType string) (string, error) {
+	if memoType == "hash" {
+		if !bridge.config.HashMemos {
+			return "", errors.New("minting to a memo hash is not enabled")
+		}
+		return getSubstrateAddressFromHashMemo(memo)
+	}
+	return bridge.getSubstrateAddressFromMemo(memo)
+}
+
+// getSubstrateAddressFromHashMemo returns the SS58 address of the public key in a base64 encoded hash memo
+func getSubstrateAddressFromHashMemo(memo string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(memo)
+	if err != nil {
+		return "", errors.Wrap(err, "memo hash is not base64 encoded")
+	}
+	if len(key) != 32 {
+		return "", fmt.Errorf("memo hash has length %d, expected a 32 byte public key", len(key))
+	}
+
+	address, err := substrate.FromKeyBytes(key)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode memo hash as an address")
+	}
+
+	// the address is decoded again to make sure it is a valid address on the bridge network
+	if _, err := substrate.FromAddress(address); err != nil {
+		return "", errors.Wrap(err, "invalid address in memo hash")
+	}
+	return address, nil
+}
+
+// memo text versions, a memo without a version is a version 1 memo
+const (
+	memoVersionUnversioned = 1
+	memoVersionPrefix      = "v"
+)
+
+// memoAddressPrefix marks a memo text carrying the SS58 address to mint on: ss58_<address>
+const memoAddressPrefix = "ss58_"
+
+// memoNoteDelimiter separates the routing part of a memo text from the free-form note of the user,
+// the note is everything after the first delimiter: twin_123#coffee
+const memoNoteDelimiter = "#"
+
+// maxMemoTextLength is the limit stellar puts on a memo text in bytes, routing part and note included
+const maxMemoTextLength = 28
+
+// splitMemoNote splits a memo text in its routing part and its note, a memo without the delimiter has no note
+func splitMemoNote(memo string) (routing string, note string) {
+	parts := strings.SplitN(memo, memoNoteDelimiter, 2)
+	if len(parts) == 1 {
+		return memo, ""
+	}
+	return parts[0], parts[1]
+}
+
+// parseMemo parses a memo text of the form [v<version>_]<type>_<id>
+func parseMemo(memo string) (version int, kind string, id int, err error) {
 	chunks := strings.Split(memo, "_")
+	version = memoVersionUnversioned
+	if len(chunks) == 3 {
+		if !strings.HasPrefix(chunks[0], memoVersionPrefix) {
+			return 0, "", 0, errors.New("memo text is not correctly formatted")
+		}
+		version, err = strconv.Atoi(strings.TrimPrefix(chunks[0], memoVersionPrefix))
+		if err != nil || version < memoVersionUnversioned {
+			return 0, "", 0, fmt.Errorf("invalid memo version %q", chunks[0])
+		}
+		chunks = chunks[1:]
+	}
 	if len(chunks) != 2 {
 		// memo is not formatted correctly, issue a refund
-		return "", errors.New("memo text is not correctly formatted")
+		return 0, "", 0, errors.New("memo text is not correctly formatted")
 	}
 
-	id, err := strconv.Atoi(chunks[1])
+	id, err = strconv.Atoi(chunks[1])
+	if err != nil {
+		return 0, "", 0, err
+	}
+	return version, chunks[0], id, nil
+}
+
+// normalizeMemo trims the whitespace around a memo text and lowercases everything before its id,
+// the id itself is left as is so it is still parsed strictly
+func normalizeMemo(memo string) string {
+	memo = strings.TrimSpace(memo)
+	i := strings.LastIndex(memo, "_")
+	if i < 0 {
+		return memo
+	}
+	return strings.ToLower(memo[:i]) + memo[i:]
+}
+
+// getSubstrateAddressFromAddressMemo returns the address of a memo text of the form ss58_<address> or of
+// a bare SS58 address, ok is false if the memo does not carry an address
+func getSubstrateAddressFromAddressMemo(memo string) (address string, ok bool, err error) {
+	if strings.HasPrefix(memo, memoAddressPrefix) {
+		address = strings.TrimPrefix(memo, memoAddressPrefix)
+		if err := validateSS58Address(address); err != nil {
+			return "", true, errors.Wrapf(err, "invalid SS58 address %q in memo", address)
+		}
+		return address, true, nil
+	}
+	if err := validateSS58Address(memo); err != nil {
+		return "", false, nil
+	}
+	return memo, true, nil
+}
+
+// validateSS58Address checks that address is an address on the bridge network, substrate.FromAddress
+// does not verify the checksum so the decoded account is encoded again and compared
+func validateSS58Address(address string) error {
+	account, err := substrate.FromAddress(address)
+	if err != nil {
+		return err
+	}
+	if account.String() != address {
+		return errors.New("invalid address checksum")
+	}
+	return nil
+}
+
+func (bridge *Bridge) getSubstrateAddressFromMemo(memo string) (string, error) {
+	if address, ok, err := getSubstrateAddressFromAddressMemo(memo); ok {
+		return address, err
+	}
+	if bridge.config.LenientMemos {
+		memo = normalizeMemo(memo)
+	}
+	version, kind, id, err := parseMemo(memo)
 	if err != nil {
 		return "", err
 	}
 
-	switch chunks[0] {
-	case "twin":
-		twin, err := bridge.subClient.GetTwin(uint32(id))
-		if err != nil {
-			return "", err
+	if version < bridge.config.MinMemoVersion {
+		return "", fmt.Errorf("memo version %d is no longer supported, use version %d or higher", version, bridge.config.MinMemoVersion)
+	}
+
+	if !bridge.isMemoTypeAllowed(kind) {
+		return "", fmt.Errorf("minting to a %s is not allowed", kind)
+	}
+
+	key := fmt.Sprintf("%s_%d", kind, id)
+	if address, ok := bridge.addressCache.get(key); ok {
+		return address, nil
+	}
+
+	address, err := bridge.lookupSubstrateAddress(kind, uint32(id))
+	if subpkg.IsTransientError(err) {
+		return "", pkg.Transient(errors.Wrapf(err, "failed to look up %s %d", kind, id))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	bridge.addressCache.set(key, address)
+	return address, nil
+}
+
+// isMemoType checks if kind is a grid object type a memo can refer to
+func isMemoType(kind string) bool {
+	for _, memoType := range pkg.MemoTypes {
+		if kind == memoType {
+			return true
 		}
-		return twin.Account.String(), nil
-	case "farm":
-		farm, err := bridge.subClient.GetFarm(uint32(id))
-		if err != nil {
-			return "", err
+	}
+	return false
+}
+
+// isMemoTypeAllowed checks if deposits can be minted to grid objects of type kind, all types are allowed if none are configured
+func (bridge *Bridge) isMemoTypeAllowed(kind string) bool {
+	if len(bridge.config.AllowedMemoTypes) == 0 {
+		return true
+	}
+	for _, allowed := range bridge.config.AllowedMemoTypes {
+		if kind == allowed {
+			return true
 		}
-		twin, err := bridge.subClient.GetTwin(uint32(farm.TwinID))
+	}
+	return false
+}
+
+// lookupSubstrateAddress gets the account of a grid object on chain
+func (bridge *Bridge) lookupSubstrateAddress(kind string, id uint32) (string, error) {
+	switch kind {
+	case pkg.MemoTypeTwin:
+		twin, err := bridge.subClient.GetTwin(id)
 		if err != nil {
 			return "", err
 		}
 		return twin.Account.String(), nil
-	case "node":
-		node, err := bridge.subClient.GetNode(uint32(id))
+	case pkg.MemoTypeFarm:
+		return bridge.lookupFarmAddress(id)
+	case pkg.MemoTypeNode:
+		node, err := bridge.subClient.GetNode(id)
 		if err != nil {
 			return "", err
 		}
+		if bridge.config.NodeMintTarget == pkg.NodeMintTargetFarm {
+			return bridge.lookupFarmAddress(uint32(node.FarmID))
+		}
 		twin, err := bridge.subClient.GetTwin(uint32(node.TwinID))
 		if err != nil {
 			return "", err
 		}
 		return twin.Account.String(), nil
-	case "entity":
-		entity, err := bridge.subClient.GetEntity(uint32(id))
+	case pkg.MemoTypeEntity:
+		entity, err := bridge.subClient.GetEntity(id)
 		if err != nil {
 			return "", err
 		}
@@ -146,3 +659,16 @@ func (bridge *Bridge) getSubstrateAddressFromMemo(memo string) (string, error) {
 		return "", errors.New("grid type not supported")
 	}
 }
+
+// lookupFarmAddress gets the account of the twin of a farm
+func (bridge *Bridge) lookupFarmAddress(id uint32) (string, error) {
+	farm, err := bridge.subClient.GetFarm(id)
+	if err != nil {
+		return "", err
+	}
+	twin, err := bridge.subClient.GetTwin(uint32(farm.TwinID))
+	if err != nil {
+		return "", err
+	}
+	return twin.Account.String(), nil
+}
@@ -0,0 +1,675 @@
+package bridge
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// memoTfchain resolves the twins, farms and nodes of deposit memos
+type memoTfchain struct {
+	tfchainClient
+	twins map[uint32]substrate.AccountID
+	farms map[uint32]substrate.Farm
+	nodes map[uint32]substrate.Node
+}
+
+func (f *memoTfchain) GetTwin(id uint32) (*substrate.Twin, error) {
+	account, ok := f.twins[id]
+	if !ok {
+		return nil, substrate.ErrNotFound
+	}
+	return &substrate.Twin{ID: types.U32(id), Account: account}, nil
+}
+
+func (f *memoTfchain) GetFarm(id uint32) (*substrate.Farm, error) {
+	farm, ok := f.farms[id]
+	if !ok {
+		return nil, substrate.ErrNotFound
+	}
+	return &farm, nil
+}
+
+func (f *memoTfchain) GetNode(id uint32) (*substrate.Node, error) {
+	node, ok := f.nodes[id]
+	if !ok {
+		return nil, substrate.ErrNotFound
+	}
+	return &node, nil
+}
+
+func TestParseMemo(t *testing.T) {
+	tests := []struct {
+		memo    string
+		version int
+		kind    string
+		id      int
+		err     bool
+	}{
+		{memo: "twin_1", version: 1, kind: "twin", id: 1},
+		{memo: "farm_42", version: 1, kind: "farm", id: 42},
+		{memo: "v2_node_7", version: 2, kind: "node", id: 7},
+		{memo: "v1_twin_3", version: 1, kind: "twin", id: 3},
+		{memo: "twin", err: true},
+		{memo: "twin_", err: true},
+		{memo: "twin_abc", err: true},
+		{memo: "twin_1_2", err: true},
+		{memo: "x2_twin_1", err: true},
+		{memo: "v0_twin_1", err: true},
+		{memo: "vx_twin_1", err: true},
+		{memo: "a_b_c_d", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.memo, func(t *testing.T) {
+			version, kind, id, err := parseMemo(test.memo)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected memo %q to be invalid, got %d %s %d", test.memo, version, kind, id)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != test.version || kind != test.kind || id != test.id {
+				t.Errorf("expected %d %s %d, got %d %s %d", test.version, test.kind, test.id, version, kind, id)
+			}
+		})
+	}
+}
+
+func TestNormalizeMemo(t *testing.T) {
+	tests := []struct {
+		memo     string
+		expected string
+	}{
+		{memo: "twin_1", expected: "twin_1"},
+		{memo: " Twin_1 ", expected: "twin_1"},
+		{memo: "V2_FARM_3", expected: "v2_farm_3"},
+		{memo: "twin_1A", expected: "twin_1A"},
+		{memo: "TWIN", expected: "TWIN"},
+		{memo: "", expected: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.memo, func(t *testing.T) {
+			if memo := normalizeMemo(test.memo); memo != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, memo)
+			}
+		})
+	}
+}
+
+func TestDecideDeposit(t *testing.T) {
+	const (
+		sender        = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		other         = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+		twin          = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+		feeCollection = other
+		fee           = 10000000
+	)
+	account, err := substrate.FromAddress(twin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deposit := func(amount int64) map[string]*big.Int {
+		return map[string]*big.Int{sender: big.NewInt(amount)}
+	}
+
+	tests := []struct {
+		name     string
+		cfg      pkg.BridgeConfig
+		senders  map[string]*big.Int
+		memo     string
+		memoType string
+		action   string
+		reason   string
+		target   string
+		note     string
+	}{
+		{name: "mint to twin", senders: deposit(50000000), memo: "twin_1", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "several senders", senders: map[string]*big.Int{sender: big.NewInt(50000000), other: big.NewInt(50000000)}, memo: "twin_1", memoType: "text", action: DepositActionRefund, reason: "multiple senders"},
+		{name: "empty memo", senders: deposit(50000000), action: DepositActionRefund, reason: "empty memo"},
+		{name: "return memo", senders: deposit(50000000), memo: "cmV0dXJu", memoType: "return", action: DepositActionSkip, reason: "return memo"},
+		{name: "invalid memo", senders: deposit(50000000), memo: "twin", memoType: "text", action: DepositActionRefund, reason: "invalid memo: memo text is not correctly formatted"},
+		{name: "hash memo", cfg: pkg.BridgeConfig{HashMemos: true}, senders: deposit(50000000), memo: "1DWTxxX90xxhFBq9BKmf1oIshViFTM3jmlaE56Vton0=", memoType: "hash", action: DepositActionMint, target: twin},
+		{name: "hash memo not enabled", senders: deposit(50000000), memo: "1DWTxxX90xxhFBq9BKmf1oIshViFTM3jmlaE56Vton0=", memoType: "hash", action: DepositActionRefund, reason: "invalid memo: minting to a memo hash is not enabled"},
+		{name: "short hash memo", cfg: pkg.BridgeConfig{HashMemos: true}, senders: deposit(50000000), memo: "1DWTxxX90xxhFBq9BKmf1g==", memoType: "hash", action: DepositActionRefund, reason: "invalid memo: memo hash has length 16, expected a 32 byte public key"},
+		{name: "ss58 memo", senders: deposit(50000000), memo: "ss58_" + twin, memoType: "text", action: DepositActionMint, target: twin},
+		{name: "bare ss58 memo", senders: deposit(50000000), memo: twin, memoType: "text", action: DepositActionMint, target: twin},
+		{name: "malformed ss58 memo", senders: deposit(50000000), memo: "ss58_5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ", memoType: "text", action: DepositActionRefund, reason: `invalid memo: invalid SS58 address "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ" in memo: invalid address checksum`},
+		{name: "malformed bare ss58 memo", senders: deposit(50000000), memo: "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQZ", memoType: "text", action: DepositActionRefund, reason: "invalid memo: memo text is longer than 28 bytes"},
+		{name: "versioned memo", cfg: pkg.BridgeConfig{MinMemoVersion: 2}, senders: deposit(50000000), memo: "v2_twin_1", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "unversioned memo below minimum", cfg: pkg.BridgeConfig{MinMemoVersion: 2}, senders: deposit(50000000), memo: "twin_1", memoType: "text", action: DepositActionRefund, reason: "invalid memo: memo version 1 is no longer supported, use version 2 or higher"},
+		{name: "versioned memo below minimum", cfg: pkg.BridgeConfig{MinMemoVersion: 3}, senders: deposit(50000000), memo: "v2_twin_1", memoType: "text", action: DepositActionRefund, reason: "invalid memo: memo version 2 is no longer supported, use version 3 or higher"},
+		{name: "memo with note", cfg: pkg.BridgeConfig{MemoNotes: true}, senders: deposit(50000000), memo: "twin_1#coffee", memoType: "text", action: DepositActionMint, target: twin, note: "coffee"},
+		{name: "memo with empty note", cfg: pkg.BridgeConfig{MemoNotes: true}, senders: deposit(50000000), memo: "twin_1#", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "memo without note", cfg: pkg.BridgeConfig{MemoNotes: true}, senders: deposit(50000000), memo: "v2_twin_1", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "note not enabled", senders: deposit(50000000), memo: "twin_1#coffee", memoType: "text", action: DepositActionRefund},
+		{name: "memo too long", cfg: pkg.BridgeConfig{MemoNotes: true}, senders: deposit(50000000), memo: "twin_1#a note that is too long", memoType: "text", action: DepositActionRefund, reason: "invalid memo: memo text is longer than 28 bytes"},
+		{name: "padded memo", cfg: pkg.BridgeConfig{LenientMemos: true}, senders: deposit(50000000), memo: " twin_1 ", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "mixed case memo", cfg: pkg.BridgeConfig{LenientMemos: true}, senders: deposit(50000000), memo: "Twin_1", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "mixed case memo not lenient", senders: deposit(50000000), memo: "Twin_1", memoType: "text", action: DepositActionRefund},
+		{name: "lenient memo with invalid id", cfg: pkg.BridgeConfig{LenientMemos: true}, senders: deposit(50000000), memo: "twin_ 1", memoType: "text", action: DepositActionRefund},
+		{name: "allowed memo type", cfg: pkg.BridgeConfig{AllowedMemoTypes: []string{pkg.MemoTypeTwin}}, senders: deposit(50000000), memo: "twin_1", memoType: "text", action: DepositActionMint, target: twin},
+		{name: "disallowed memo type", cfg: pkg.BridgeConfig{AllowedMemoTypes: []string{pkg.MemoTypeFarm, pkg.MemoTypeNode}}, senders: deposit(50000000), memo: "twin_1", memoType: "text", action: DepositActionRefund, reason: "invalid memo: minting to a twin is not allowed"},
+		{name: "unknown twin", senders: deposit(50000000), memo: "twin_2", memoType: "text", action: DepositActionRefund},
+		{name: "below fee refunded", senders: deposit(fee), memo: "twin_1", memoType: "text", action: DepositActionRefund, reason: "amount below deposit fee"},
+		{name: "below fee absorbed", cfg: pkg.BridgeConfig{BelowFeePolicy: pkg.BelowFeePolicyAbsorb, FeeCollectionAccount: feeCollection}, senders: deposit(fee), memo: "twin_1", memoType: "text", action: DepositActionAbsorb, reason: "amount below deposit fee", target: feeCollection},
+		{name: "below fee ignored", cfg: pkg.BridgeConfig{BelowFeePolicy: pkg.BelowFeePolicyIgnore}, senders: deposit(fee), memo: "twin_1", memoType: "text", action: DepositActionSkip, reason: "amount below deposit fee"},
+		{name: "no senders", senders: map[string]*big.Int{}, memo: "twin_1", memoType: "text", action: DepositActionSkip, reason: "no deposited amount"},
+		{name: "no amount", senders: map[string]*big.Int{sender: nil}, memo: "twin_1", memoType: "text", action: DepositActionSkip, reason: "no deposited amount"},
+		{name: "daily limit exceeded", cfg: pkg.BridgeConfig{DailyMintLimit: 40000000}, senders: deposit(50000000), memo: "twin_1", memoType: "text", action: DepositActionHold, reason: alert.KindDailyLimitExceeded, target: twin},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			persistency, err := pkg.InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bridge := &Bridge{
+				subClient:        &memoTfchain{twins: map[uint32]substrate.AccountID{1: account}},
+				blockPersistency: persistency,
+				config:           &test.cfg,
+				depositFee:       fee,
+				addressCache:     newAddressCache(0),
+				bursts:           newBurstTracker(test.cfg.DepositBurstWindow, test.cfg.DepositBurstCount, test.cfg.DepositBurstAmount),
+			}
+
+			outcome, err := bridge.decideDeposit(test.senders, test.memo, test.memoType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome.Action != test.action {
+				t.Errorf("expected action %s, got %s (%s)", test.action, outcome.Action, outcome.Reason)
+			}
+			if test.reason != "" && outcome.Reason != test.reason {
+				t.Errorf("expected reason %q, got %q", test.reason, outcome.Reason)
+			}
+			if outcome.Target != test.target {
+				t.Errorf("expected target %q, got %q", test.target, outcome.Target)
+			}
+			if outcome.Note != test.note {
+				t.Errorf("expected note %q, got %q", test.note, outcome.Note)
+			}
+			if outcome.Action == DepositActionMint && outcome.NetAmount != outcome.Amount-fee {
+				t.Errorf("expected net amount %d, got %d", outcome.Amount-fee, outcome.NetAmount)
+			}
+		})
+	}
+}
+
+func TestMintWithoutSenders(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	tfchain.addTwin(t, 1, testTwinAddress)
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	for _, senders := range []map[string]*big.Int{nil, {}, {testSender: nil}} {
+		deposit := testDeposit(1, testSender, 0, "twin_1")
+		deposit.Senders = senders
+		if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+			t.Fatalf("deposit with senders %v failed: %s", senders, err)
+		}
+	}
+
+	assertCalls(t, nil, calls.get())
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height.StellarCursor != testDeposit(1, testSender, 0, "").Tx.PT {
+		t.Errorf("expected the cursor to be saved past the deposit, got %q", height.StellarCursor)
+	}
+}
+
+func TestSimulateDeposit(t *testing.T) {
+	const twin = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+	account, err := substrate.FromAddress(twin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := &Bridge{
+		subClient:    &memoTfchain{twins: map[uint32]substrate.AccountID{1: account}},
+		config:       &pkg.BridgeConfig{},
+		depositFee:   10000000,
+		addressCache: newAddressCache(0),
+		bursts:       newBurstTracker(0, 0, 0),
+	}
+
+	tests := []struct {
+		name   string
+		amount int64
+		memo   string
+		action string
+		reason string
+	}{
+		{name: "mint", amount: 50000000, memo: "twin_1", action: DepositActionMint},
+		{name: "empty memo", amount: 50000000, action: DepositActionRefund, reason: "empty memo"},
+		{name: "bad memo", amount: 50000000, memo: "twin_x", action: DepositActionRefund},
+		{name: "below fee", amount: 5000000, memo: "twin_1", action: DepositActionRefund, reason: "amount below deposit fee"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outcome, err := bridge.SimulateDeposit("GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ", test.amount, test.memo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outcome.Action != test.action {
+				t.Errorf("expected action %s, got %s (%s)", test.action, outcome.Action, outcome.Reason)
+			}
+			if test.reason != "" && outcome.Reason != test.reason {
+				t.Errorf("expected reason %q, got %q", test.reason, outcome.Reason)
+			}
+		})
+	}
+}
+
+func TestDepositLatency(t *testing.T) {
+	closed := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected time.Duration
+	}{
+		{name: "submitted after close", now: closed.Add(90 * time.Second), expected: 90 * time.Second},
+		{name: "submitted at close", now: closed, expected: 0},
+		{name: "replayed a day later", now: closed.Add(24 * time.Hour), expected: 24 * time.Hour},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx := hProtocol.Transaction{Hash: "tx", LedgerCloseTime: closed}
+			if latency := depositLatency(tx, test.now); latency != test.expected {
+				t.Errorf("expected latency %s, got %s", test.expected, latency)
+			}
+		})
+	}
+}
+
+func TestIgnoreDepositsOlderThan(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	tfchain.addTwin(t, 1, testTwinAddress)
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{IgnoreDepositsOlderThan: time.Hour}, tfchain, wallet, 10000000)
+
+	old := testDeposit(1, testSender, 1000000000, "twin_1")
+	old.Tx.LedgerCloseTime = time.Now().Add(-2 * time.Hour)
+	recent := testDeposit(2, testSender, 1000000000, "twin_1")
+	recent.Tx.LedgerCloseTime = time.Now().Add(-time.Minute)
+
+	for _, deposit := range []stellar.MintEvent{old, recent} {
+		if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+			t.Fatalf("deposit %s failed: %s", deposit.Tx.Hash, err)
+		}
+	}
+
+	assertCalls(t, []string{
+		fmt.Sprintf("ProposeMintOrVote %s %s 1000000000", recent.Tx.Hash, testTwinAddress),
+	}, calls.get())
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height.StellarCursor != recent.Tx.PT {
+		t.Errorf("expected the cursor to be saved past the recent deposit, got %q", height.StellarCursor)
+	}
+}
+
+func TestRecordReturnDeposit(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	refunded := testDeposit(1, testSender, 0, "").Tx.Hash
+	raw, err := hex.DecodeString(refunded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := testDeposit(2, testSender, 1000000000, base64.StdEncoding.EncodeToString(raw))
+	deposit.Tx.MemoType = "return"
+
+	if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+		t.Fatalf("return memo deposit failed: %s", err)
+	}
+
+	assertCalls(t, nil, calls.get())
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height.StellarCursor != deposit.Tx.PT {
+		t.Errorf("expected the cursor to be saved past the deposit, got %q", height.StellarCursor)
+	}
+	if len(height.ReturnDeposits) != 1 {
+		t.Fatalf("expected 1 recorded return deposit, got %d", len(height.ReturnDeposits))
+	}
+	recorded := height.ReturnDeposits[0]
+	if recorded.TxHash != deposit.Tx.Hash || recorded.RefundedTx != refunded {
+		t.Errorf("expected deposit %s refunding %s to be recorded, got %+v", deposit.Tx.Hash, refunded, recorded)
+	}
+}
+
+func TestMintCursorWaitsForInclusion(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	tfchain.addTwin(t, 1, testTwinAddress)
+	tfchain.retractMints = true
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	deposit := testDeposit(1, testSender, 1000000000, "twin_1")
+	err := wallet.deposit(testContext(t), bridge, deposit)
+	if !pkg.IsTransient(err) {
+		t.Fatalf("expected a transient failure for a mint that is not on chain, got %v", err)
+	}
+	height, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height.StellarCursor == deposit.Tx.PT {
+		t.Fatal("expected the cursor not to move past a deposit whose mint is not on chain")
+	}
+
+	// the retry mints the deposit once it is included
+	tfchain.retractMints = false
+	if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+		t.Fatalf("retry of the deposit failed: %s", err)
+	}
+	mint := fmt.Sprintf("ProposeMintOrVote %s %s 1000000000", deposit.Tx.Hash, testTwinAddress)
+	assertCalls(t, []string{mint, mint}, calls.get())
+	if height, err = bridge.blockPersistency.GetHeight(); err != nil {
+		t.Fatal(err)
+	}
+	if height.StellarCursor != deposit.Tx.PT {
+		t.Errorf("expected the cursor to be saved past the minted deposit, got %q", height.StellarCursor)
+	}
+
+	// a deposit seen again after its mint is not minted twice
+	if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+		t.Fatal(err)
+	}
+	assertCalls(t, []string{mint, mint}, calls.get())
+}
+
+func TestDepositMemoLookupFailure(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	wallet := newFakeWallet(calls, 100)
+	tfchain.addTwin(t, 1, testTwinAddress)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	// the chain can not be reached, the deposit is retried instead of refunded
+	tfchain.lookupErr = io.EOF
+	deposit := testDeposit(1, testSender, 1000000000, "twin_1")
+	err := wallet.deposit(testContext(t), bridge, deposit)
+	if !pkg.IsTransient(err) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+	assertCalls(t, nil, calls.get())
+
+	tfchain.lookupErr = nil
+	if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+		t.Fatalf("retried deposit failed: %s", err)
+	}
+
+	// a malformed memo can never be minted, it is refunded
+	if err := wallet.deposit(testContext(t), bridge, testDeposit(2, testSender, 1000000000, "twin_one")); err != nil {
+		t.Fatalf("malformed memo deposit failed: %s", err)
+	}
+	assertCalls(t, []string{
+		"ProposeMintOrVote " + deposit.Tx.Hash + " " + testTwinAddress + " 1000000000",
+		fmt.Sprintf("CreateRefundTransactionOrAddSig %064x %s 1000000000 seq=101", 2, testSender),
+	}, calls.get())
+}
+
+func TestDepositRefundCancelled(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	// the bridge stops while a malformed memo deposit is refunded, the refund is not submitted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := wallet.deposit(ctx, bridge, testDeposit(1, testSender, 1000000000, "twin_one"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the refund to end with the context, got %v", err)
+	}
+	assertCalls(t, nil, calls.get())
+}
+
+func TestNodeMintTarget(t *testing.T) {
+	const (
+		nodeTwin = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+		farmTwin = "5FHneW46xGXgs5mUiveU4sbTyGBzmstUspZC92UhjJM694ty"
+	)
+	nodeAccount, err := substrate.FromAddress(nodeTwin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	farmAccount, err := substrate.FromAddress(farmTwin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &memoTfchain{
+		twins: map[uint32]substrate.AccountID{1: nodeAccount, 2: farmAccount},
+		farms: map[uint32]substrate.Farm{5: {ID: 5, TwinID: 2}},
+		nodes: map[uint32]substrate.Node{9: {ID: 9, FarmID: 5, TwinID: 1}},
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		memo   string
+		expect string
+	}{
+		{name: "node to node twin by default", memo: "node_9", expect: nodeTwin},
+		{name: "node to node twin", target: pkg.NodeMintTargetNode, memo: "node_9", expect: nodeTwin},
+		{name: "node to farm twin", target: pkg.NodeMintTargetFarm, memo: "node_9", expect: farmTwin},
+		{name: "farm by default", memo: "farm_5", expect: farmTwin},
+		{name: "farm with node to farm twin", target: pkg.NodeMintTargetFarm, memo: "farm_5", expect: farmTwin},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bridge := &Bridge{
+				subClient:    chain,
+				config:       &pkg.BridgeConfig{NodeMintTarget: test.target},
+				addressCache: newAddressCache(0),
+			}
+
+			address, err := bridge.getSubstrateAddressFromMemo(test.memo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if address != test.expect {
+				t.Errorf("expected %s to mint to %s, got %s", test.memo, test.expect, address)
+			}
+		})
+	}
+}
+
+func TestMintRole(t *testing.T) {
+	defer func(interval time.Duration) { mintProposalPollInterval = interval }(mintProposalPollInterval)
+	mintProposalPollInterval = time.Millisecond
+
+	const txID = "0000000000000000000000000000000000000000000000000000000000000001"
+	account, err := substrate.FromAddress(testTwinAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		role  string
+		chain func(tfchain *fakeTfchain)
+		calls []string
+	}{
+		{
+			name:  "propose and vote proposes",
+			calls: []string{"ProposeMintOrVote " + txID + " " + testTwinAddress + " 1000000000"},
+		},
+		{
+			name:  "vote only votes on a proposal",
+			role:  pkg.MintRoleVoteOnly,
+			chain: func(tfchain *fakeTfchain) { tfchain.proposedMints[txID] = &subpkg.MintTransaction{Votes: 1} },
+			calls: []string{"ProposeMintOrVote " + txID + " " + testTwinAddress + " 1000000000"},
+		},
+		{
+			name: "vote only waits for the proposal",
+			role: pkg.MintRoleVoteOnly,
+			chain: func(tfchain *fakeTfchain) {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					tfchain.mu.Lock()
+					defer tfchain.mu.Unlock()
+					tfchain.proposedMints[txID] = &subpkg.MintTransaction{Votes: 1}
+				}()
+			},
+			calls: []string{"ProposeMintOrVote " + txID + " " + testTwinAddress + " 1000000000"},
+		},
+		{
+			name:  "vote only skips an executed mint",
+			role:  pkg.MintRoleVoteOnly,
+			chain: func(tfchain *fakeTfchain) { tfchain.executedMints[txID] = &subpkg.MintTransaction{} },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := newFakeTfchain(calls)
+			if test.chain != nil {
+				test.chain(tfchain)
+			}
+			bridge := newTestBridge(t, pkg.BridgeConfig{MintRole: test.role}, tfchain, newFakeWallet(calls, 100), 10000000)
+
+			if err := bridge.proposeOrVoteMint(testContext(t), txID, account, big.NewInt(1000000000)); err != nil {
+				t.Fatal(err)
+			}
+			assertCalls(t, test.calls, calls.get())
+		})
+	}
+}
+
+func TestMintRoleVoteOnlyCancelled(t *testing.T) {
+	defer func(interval time.Duration) { mintProposalPollInterval = interval }(mintProposalPollInterval)
+	mintProposalPollInterval = time.Millisecond
+
+	calls := &callLog{}
+	bridge := newTestBridge(t, pkg.BridgeConfig{MintRole: pkg.MintRoleVoteOnly}, newFakeTfchain(calls), newFakeWallet(calls, 100), 10000000)
+
+	// the mint is never proposed, the wait ends with the bridge
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := bridge.proposeOrVoteMint(ctx, "tx", substrate.AccountID{}, big.NewInt(1000000000))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+	assertCalls(t, nil, calls.get())
+}
+
+func TestVoteOnlyMintWaitIsBounded(t *testing.T) {
+	defer func(interval time.Duration) { mintProposalPollInterval = interval }(mintProposalPollInterval)
+	mintProposalPollInterval = time.Millisecond
+
+	calls := &callLog{}
+	bridge := newTestBridge(t, pkg.BridgeConfig{MintRole: pkg.MintRoleVoteOnly}, newFakeTfchain(calls), newFakeWallet(calls, 100), 0)
+
+	_, err := bridge.awaitMintProposal(testContext(t), "tx", 50*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected the wait for a mint nobody proposes to fail")
+	}
+	if !pkg.IsTransient(err) {
+		t.Errorf("expected a transient error so the deposit is retried, got %s", err)
+	}
+}
+
+func TestDailyMintLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		deposits []int64
+		minted   int
+		held     bool
+	}{
+		{name: "under the limit", deposits: []int64{300000000, 200000000}, minted: 2},
+		{name: "reaching the limit", deposits: []int64{300000000, 300000000}, minted: 2},
+		{name: "crossing the limit", deposits: []int64{300000000, 300000001}, minted: 1, held: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := newFakeTfchain(calls)
+			tfchain.addTwin(t, 1, testTwinAddress)
+			wallet := newFakeWallet(calls, 100)
+			bridge := newTestBridge(t, pkg.BridgeConfig{DailyMintLimit: 600000000}, tfchain, wallet, 0)
+
+			var last stellar.MintEvent
+			for i, amount := range test.deposits {
+				last = testDeposit(i, testSender, amount, "twin_1")
+				if err := wallet.deposit(testContext(t), bridge, last); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if minted := len(calls.get()); minted != test.minted {
+				t.Errorf("expected %d mints, got %d", test.minted, minted)
+			}
+			_, err := bridge.blockPersistency.GetHeldDeposit(last.Tx.Hash)
+			if held := err == nil; held != test.held {
+				t.Errorf("expected held %t, got %t", test.held, held)
+			}
+			if test.held {
+				assertCalls(t, []string{alert.KindDailyLimitExceeded}, bridge.alerter.(*recordingAlerter).kinds())
+			}
+		})
+	}
+}
+
+func TestDailyMintLimitResetsAtUTCMidnight(t *testing.T) {
+	calls := &callLog{}
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{DailyMintLimit: 100}, newFakeTfchain(calls), wallet, 0)
+
+	beforeMidnight := time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC)
+	if err := bridge.blockPersistency.AddDailyMinted(testTwinAddress, 100, beforeMidnight); err != nil {
+		t.Fatal(err)
+	}
+
+	// 01:30 the next day in UTC+2 is still before UTC midnight
+	sameUTCDay := time.Date(2026, 10, 17, 1, 30, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+	afterMidnight := time.Date(2026, 10, 17, 0, 1, 0, 0, time.UTC)
+
+	exceeded, err := bridge.exceedsDailyMintLimit(testTwinAddress, 1, sameUTCDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exceeded {
+		t.Error("the limit reset before UTC midnight")
+	}
+
+	exceeded, err = bridge.exceedsDailyMintLimit(testTwinAddress, 1, afterMidnight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exceeded {
+		t.Error("the limit did not reset at UTC midnight")
+	}
+}
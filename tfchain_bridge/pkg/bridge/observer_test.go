@@ -0,0 +1,30 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+func TestObserverModeSubmitsNothing(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	signatures := []substrate.StellarSignature{{Signature: []byte("signature"), StellarAddress: []byte("validator")}}
+	tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: signatures}
+	tfchain.refunds["a1"] = &substrate.RefundTransaction{TxHash: "a1", Target: testSender, Amount: 200000000, SequenceNumber: 102, Signatures: signatures}
+
+	bridge := newTestBridge(t, pkg.BridgeConfig{ObserverMode: true}, tfchain, newFakeWallet(calls, 100), 10000000)
+	ctx := testContext(t)
+
+	err := bridge.dispatchTfchainEvents(ctx, bridge.events, subpkg.Events{
+		WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 7}},
+		RefundReadyEvents:   []subpkg.RefundTransactionReadyEvent{{Hash: "a1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertCalls(t, nil, calls.get())
+}
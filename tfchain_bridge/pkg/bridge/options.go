@@ -0,0 +1,68 @@
+package bridge
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/metrics"
+)
+
+// Option customizes a bridge created with NewBridge, services embedding the bridge use options
+// to plug in what the binary configures from flags
+type Option func(*options)
+
+type options struct {
+	alerter       alert.Alerter
+	tfchainSigner substrate.Identity
+}
+
+// WithAlerter delivers the alerts of the bridge to alerter instead of the notifiers of the config,
+// alerts are still grouped according to the alert dedup windows
+func WithAlerter(alerter alert.Alerter) Option {
+	return func(o *options) {
+		o.alerter = alerter
+	}
+}
+
+// WithTfchainSigner signs the extrinsics of the bridge with signer instead of the signer of the config
+func WithTfchainSigner(signer substrate.Identity) Option {
+	return func(o *options) {
+		o.tfchainSigner = signer
+	}
+}
+
+// Close releases the connection to tfchain, it is called once Run returned
+func (bridge *Bridge) Close() {
+	bridge.subClient.Close()
+	if err := bridge.accounting.Close(); err != nil {
+		log.Err(err).Msg("failed to close accounting ledger")
+	}
+}
+
+// Config returns the configuration the bridge runs with
+func (bridge *Bridge) Config() pkg.BridgeConfig {
+	return bridge.settings()
+}
+
+// DepositFee returns the deposit fee read from chain when the bridge was created
+func (bridge *Bridge) DepositFee() int64 {
+	return bridge.depositFee
+}
+
+// Fingerprint returns the part of the configuration all validators must agree on
+func (bridge *Bridge) Fingerprint() pkg.ConfigFingerprint {
+	return bridge.fingerprint
+}
+
+// Height returns the last processed tfchain height and stellar cursor
+func (bridge *Bridge) Height() (*pkg.Blockheight, error) {
+	return bridge.blockPersistency.GetHeight()
+}
+
+// MetricsHandler serves the metrics of the bridge in the prometheus text format
+func (bridge *Bridge) MetricsHandler() http.Handler {
+	return metrics.Handler()
+}
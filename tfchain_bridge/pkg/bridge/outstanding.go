@@ -0,0 +1,195 @@
+package bridge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// outstandingPruneInterval is how often the tracked transactions are checked to be executed
+const outstandingPruneInterval = time.Minute
+
+// outstanding tracks the burn and refund transactions seen in tfchain events since the bridge started,
+// entries are dropped once the transaction is executed on chain
+type outstanding struct {
+	mu      sync.Mutex
+	burns   map[uint64]struct{}
+	refunds map[string]struct{}
+}
+
+func newOutstanding() *outstanding {
+	return &outstanding{
+		burns:   make(map[uint64]struct{}),
+		refunds: make(map[string]struct{}),
+	}
+}
+
+func (o *outstanding) trackEvents(events subpkg.Events) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, e := range events.WithdrawCreatedEvents {
+		o.burns[e.ID] = struct{}{}
+	}
+	for _, e := range events.WithdrawExpiredEvents {
+		o.burns[e.ID] = struct{}{}
+	}
+	for _, e := range events.WithdrawReadyEvents {
+		o.burns[e.ID] = struct{}{}
+	}
+	for _, e := range events.RefundCreatedEvents {
+		o.refunds[e.Hash] = struct{}{}
+	}
+	for _, e := range events.RefundExpiredEvents {
+		o.refunds[e.Hash] = struct{}{}
+	}
+	for _, e := range events.RefundReadyEvents {
+		o.refunds[e.Hash] = struct{}{}
+	}
+	o.updateGauges()
+}
+
+// updateGauges exposes the amount of outstanding transactions, it must be called with the lock held
+func (o *outstanding) updateGauges() {
+	outstandingBurns.Set(float64(len(o.burns)))
+	outstandingRefunds.Set(float64(len(o.refunds)))
+}
+
+func (o *outstanding) burnIDs() []uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ids := make([]uint64, 0, len(o.burns))
+	for id := range o.burns {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (o *outstanding) refundHashes() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	hashes := make([]string, 0, len(o.refunds))
+	for hash := range o.refunds {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func (o *outstanding) dropBurn(id uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.burns, id)
+	o.updateGauges()
+}
+
+func (o *outstanding) dropRefund(hash string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.refunds, hash)
+	o.updateGauges()
+}
+
+// PendingWithdraws returns the status of the tracked burn transactions that are not executed yet
+func (bridge *Bridge) PendingWithdraws(ctx context.Context) ([]pkg.WithdrawStatus, error) {
+	statuses := []pkg.WithdrawStatus{}
+	for _, id := range bridge.outstanding.burnIDs() {
+		burned, err := bridge.subClient.IsBurnedAlready(types.U64(id))
+		if err != nil {
+			return nil, err
+		}
+		if burned {
+			bridge.outstanding.dropBurn(id)
+			continue
+		}
+
+		status, err := bridge.WithdrawStatus(ctx, id)
+		if err != nil {
+			log.Debug().Err(err).Uint64("ID", id).Msg("failed to get withdraw status")
+			continue
+		}
+		statuses = append(statuses, *status)
+	}
+
+	return statuses, nil
+}
+
+// PendingRefunds returns the status of the tracked refund transactions that are not executed yet
+func (bridge *Bridge) PendingRefunds(ctx context.Context) ([]pkg.RefundStatus, error) {
+	statuses := []pkg.RefundStatus{}
+	for _, hash := range bridge.outstanding.refundHashes() {
+		refunded, err := bridge.subClient.IsRefundedAlready(hash)
+		if err != nil {
+			return nil, err
+		}
+		if refunded {
+			bridge.outstanding.dropRefund(hash)
+			continue
+		}
+
+		refund, err := bridge.subClient.GetRefundTransaction(hash)
+		if err != nil {
+			log.Debug().Err(err).Str("tx_id", hash).Msg("failed to get refund transaction")
+			continue
+		}
+		statuses = append(statuses, pkg.RefundStatus{
+			TxHash:             hash,
+			Target:             refund.Target,
+			Amount:             uint64(refund.Amount),
+			Signatures:         len(refund.Signatures),
+			RequiredSignatures: bridge.wallet.GetSignatureCount(),
+		})
+	}
+
+	return statuses, nil
+}
+
+// pruneOutstanding periodically drops the tracked transactions that are executed on chain, also when
+// they were executed by another validator, so the outstanding gauges reflect the backlog of the bridge
+func (bridge *Bridge) pruneOutstanding(ctx context.Context) {
+	ticker := time.NewTicker(outstandingPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bridge.dropExecuted()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// dropExecuted drops the tracked transactions that are executed on chain
+func (bridge *Bridge) dropExecuted() {
+	for _, id := range bridge.outstanding.burnIDs() {
+		burned, err := bridge.subClient.IsBurnedAlready(types.U64(id))
+		if err != nil {
+			log.Debug().Err(err).Uint64("ID", id).Msg("failed to check if burn transaction is executed")
+			continue
+		}
+		if burned {
+			bridge.outstanding.dropBurn(id)
+		}
+	}
+
+	for _, hash := range bridge.outstanding.refundHashes() {
+		refunded, err := bridge.subClient.IsRefundedAlready(hash)
+		if err != nil {
+			log.Debug().Err(err).Str("tx_id", hash).Msg("failed to check if refund transaction is executed")
+			continue
+		}
+		if refunded {
+			bridge.outstanding.dropRefund(hash)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+func TestOutstandingGauges(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, newFakeWallet(calls, 100), 10000000)
+
+	assertGauges := func(burns, refunds float64) {
+		t.Helper()
+		if got := outstandingBurns.Get(); got != burns {
+			t.Errorf("expected %v outstanding burns, got %v", burns, got)
+		}
+		if got := outstandingRefunds.Get(); got != refunds {
+			t.Errorf("expected %v outstanding refunds, got %v", refunds, got)
+		}
+	}
+
+	bridge.outstanding.trackEvents(subpkg.Events{
+		WithdrawCreatedEvents: []subpkg.WithdrawCreatedEvent{{ID: 1}, {ID: 2}},
+		RefundCreatedEvents:   []subpkg.RefundTransactionCreatedEvent{{Hash: "a1"}},
+	})
+	assertGauges(2, 1)
+
+	// the same transactions becoming ready are not counted twice
+	bridge.outstanding.trackEvents(subpkg.Events{
+		WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 1}},
+		RefundReadyEvents:   []subpkg.RefundTransactionReadyEvent{{Hash: "a1"}},
+	})
+	assertGauges(2, 1)
+
+	// burn 1 and the refund are executed, by this validator or another one
+	tfchain.executedBurns[1] = true
+	tfchain.refunded["a1"] = true
+	bridge.dropExecuted()
+	assertGauges(1, 0)
+
+	tfchain.executedBurns[2] = true
+	bridge.dropExecuted()
+	assertGauges(0, 0)
+
+	assertCalls(t, nil, calls.get())
+}
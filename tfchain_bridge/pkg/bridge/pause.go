@@ -0,0 +1,60 @@
+package bridge
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pauseState is whether the bridge stopped processing events, changed is closed and replaced on every
+// transition so the event loop wakes up on a pause or resume
+type pauseState struct {
+	mu      sync.Mutex
+	paused  bool
+	changed chan struct{}
+}
+
+func newPauseState() *pauseState {
+	return &pauseState{changed: make(chan struct{})}
+}
+
+func (p *pauseState) set(paused bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused == paused {
+		return false
+	}
+	p.paused = paused
+	close(p.changed)
+	p.changed = make(chan struct{})
+	return true
+}
+
+func (p *pauseState) get() (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused, p.changed
+}
+
+// Pause stops the processing of new events, events keep queueing in the subscriptions
+// and the event being processed is finished
+func (bridge *Bridge) Pause() {
+	if bridge.pause.set(true) {
+		log.Warn().Msg("bridge paused, events are not processed until it is resumed")
+	}
+}
+
+// Resume continues the processing of events after a Pause
+func (bridge *Bridge) Resume() {
+	if bridge.pause.set(false) {
+		log.Info().Msg("bridge resumed")
+	}
+}
+
+// Paused returns whether the processing of events is paused
+func (bridge *Bridge) Paused() bool {
+	paused, _ := bridge.pause.get()
+	return paused
+}
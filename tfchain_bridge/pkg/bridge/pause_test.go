@@ -0,0 +1,42 @@
+package bridge
+
+import (
+	"testing"
+)
+
+func TestPauseResume(t *testing.T) {
+	bridge := &Bridge{pause: newPauseState()}
+	if bridge.Paused() {
+		t.Fatal("a new bridge is paused")
+	}
+
+	_, changed := bridge.pause.get()
+	bridge.Pause()
+	if !bridge.Paused() {
+		t.Fatal("expected the bridge to be paused")
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("the event loop was not woken up on pause")
+	}
+
+	// pausing a paused bridge is not a transition
+	_, changed = bridge.pause.get()
+	bridge.Pause()
+	select {
+	case <-changed:
+		t.Fatal("pausing a paused bridge woke up the event loop")
+	default:
+	}
+
+	bridge.Resume()
+	if bridge.Paused() {
+		t.Fatal("expected the bridge to be resumed")
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatal("the event loop was not woken up on resume")
+	}
+}
@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+)
+
+// peerRequestTimeout bounds the request for the fingerprint of a peer, a peer that is down does not hold back the start
+const peerRequestTimeout = 10 * time.Second
+
+// majorityFingerprint returns the fingerprint most of the validators run with and whether own diverges from it.
+// Own counts as one of the validators and only diverges if more validators agree on another fingerprint than
+// on own, so a tie is not a divergence. Among other fingerprints with as many validators, the first peer wins.
+func majorityFingerprint(own pkg.ConfigFingerprint, peers []pkg.ConfigFingerprint) (pkg.ConfigFingerprint, bool) {
+	counts := map[pkg.ConfigFingerprint]int{own: 1}
+	majority := own
+	for _, peer := range peers {
+		counts[peer]++
+		if counts[peer] > counts[majority] {
+			majority = peer
+		}
+	}
+	if counts[majority] <= counts[own] {
+		return own, false
+	}
+	return majority, true
+}
+
+// getPeerFingerprint gets the configuration fingerprint from the admin server of a peer
+func getPeerFingerprint(ctx context.Context, url string) (pkg.ConfigFingerprint, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(url, "/")+"/fingerprint", nil)
+	if err != nil {
+		return pkg.ConfigFingerprint{}, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return pkg.ConfigFingerprint{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return pkg.ConfigFingerprint{}, fmt.Errorf("peer responded with status %d", resp.StatusCode)
+	}
+
+	var fingerprint pkg.ConfigFingerprint
+	if err := json.NewDecoder(resp.Body).Decode(&fingerprint); err != nil {
+		return pkg.ConfigFingerprint{}, errors.Wrap(err, "failed to decode peer fingerprint")
+	}
+	return fingerprint, nil
+}
+
+// checkPeers compares the configuration fingerprint of the bridge with the fingerprints of the reachable peers. A
+// divergence from the majority is alerted, and returned as an invalid configuration if the bridge halts on it.
+func (bridge *Bridge) checkPeers(ctx context.Context) error {
+	if len(bridge.config.PeerAdminURLs) == 0 {
+		return nil
+	}
+
+	var peers []pkg.ConfigFingerprint
+	for _, url := range bridge.config.PeerAdminURLs {
+		fingerprint, err := getPeerFingerprint(ctx, url)
+		if err != nil {
+			log.Warn().Err(err).Str("peer", url).Msg("failed to get the configuration fingerprint of peer, skipping it")
+			continue
+		}
+		if diff := bridge.fingerprint.Diff(fingerprint); len(diff) > 0 {
+			log.Warn().Str("peer", url).Strs("fields", diff).Msg("configuration of peer differs")
+		}
+		peers = append(peers, fingerprint)
+	}
+
+	majority, diverged := majorityFingerprint(bridge.fingerprint, peers)
+	if !diverged {
+		log.Info().Int("peers", len(peers)).Msg("configuration matches the majority of the reachable peers")
+		return nil
+	}
+
+	fields := strings.Join(bridge.fingerprint.Diff(majority), ", ")
+	log.Error().Str("fields", fields).Int("peers", len(peers)).Msg("configuration diverges from the majority of the reachable peers")
+	err := bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindConfigDivergence,
+		Message: "bridge configuration diverges from the majority of the validators",
+		Fields: map[string]string{
+			"fields": fields,
+			"peers":  fmt.Sprint(len(peers)),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+
+	if bridge.config.PeerCheckHalt {
+		return pkg.InvalidConfig(fmt.Errorf("%s differ from the majority of the peers", fields))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+)
+
+func TestMajorityFingerprint(t *testing.T) {
+	own := pkg.ConfigFingerprint{Network: "testnet", AssetCode: "TFT", DepositFee: 10000000}
+	fee := own
+	fee.DepositFee = 20000000
+	network := own
+	network.Network = "public"
+
+	tests := []struct {
+		name     string
+		peers    []pkg.ConfigFingerprint
+		majority pkg.ConfigFingerprint
+		diverged bool
+	}{
+		{name: "no peers", majority: own},
+		{name: "all agree", peers: []pkg.ConfigFingerprint{own, own}, majority: own},
+		{name: "one peer differs", peers: []pkg.ConfigFingerprint{own, fee}, majority: own},
+		{name: "single peer differs", peers: []pkg.ConfigFingerprint{fee}, majority: own},
+		{name: "tie", peers: []pkg.ConfigFingerprint{fee, fee, own}, majority: own},
+		{name: "majority differs", peers: []pkg.ConfigFingerprint{fee, fee}, majority: fee, diverged: true},
+		{name: "peers disagree among themselves", peers: []pkg.ConfigFingerprint{fee, network}, majority: own},
+		{name: "largest group differs", peers: []pkg.ConfigFingerprint{network, fee, fee, own, network, fee}, majority: fee, diverged: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			majority, diverged := majorityFingerprint(own, test.peers)
+			if diverged != test.diverged {
+				t.Errorf("expected diverged to be %v, got %v", test.diverged, diverged)
+			}
+			if majority != test.majority {
+				t.Errorf("expected majority %+v, got %+v", test.majority, majority)
+			}
+		})
+	}
+}
+
+func TestCheckPeers(t *testing.T) {
+	own := pkg.ConfigFingerprint{Network: "testnet", AssetCode: "TFT", DepositFee: 10000000}
+	divergent := own
+	divergent.DepositFee = 20000000
+
+	peer := func(fingerprint pkg.ConfigFingerprint) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/fingerprint" {
+				http.NotFound(w, r)
+				return
+			}
+			if err := json.NewEncoder(w).Encode(fingerprint); err != nil {
+				t.Error(err)
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tests := []struct {
+		name    string
+		peers   []string
+		halt    bool
+		alerted bool
+		invalid bool
+	}{
+		{name: "matching peers", peers: []string{peer(own), peer(own)}},
+		{name: "one divergent peer", peers: []string{peer(own), peer(divergent)}},
+		{name: "unreachable peers are skipped", peers: []string{down.URL, peer(own)}},
+		{name: "diverges from the majority", peers: []string{peer(divergent), down.URL, peer(divergent)}, alerted: true},
+		{name: "halts on divergence", peers: []string{peer(divergent), peer(divergent)}, halt: true, alerted: true, invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			alerter := &recordingAlerter{}
+			bridge := &Bridge{
+				config:      &pkg.BridgeConfig{PeerAdminURLs: test.peers, PeerCheckHalt: test.halt},
+				fingerprint: own,
+				alerter:     alerter,
+			}
+
+			err := bridge.checkPeers(testContext(t))
+			if test.invalid && !errors.Is(err, pkg.ErrInvalidConfig) {
+				t.Errorf("expected an invalid configuration, got %v", err)
+			}
+			if !test.invalid && err != nil {
+				t.Errorf("expected the bridge to start, got %v", err)
+			}
+			var expected []string
+			if test.alerted {
+				expected = []string{alert.KindConfigDivergence}
+			}
+			if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, expected) {
+				t.Errorf("expected alerts %v, got %v", expected, kinds)
+			}
+		})
+	}
+}
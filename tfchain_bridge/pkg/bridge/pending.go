@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// pendingMintStore saves fetched mint events in the persistency file
+type pendingMintStore struct {
+	persistency *pkg.ChainPersistency
+}
+
+func (s *pendingMintStore) SavePendingMintEvents(events []stellar.MintEvent) error {
+	pending := make([]pkg.PendingMint, 0, len(events))
+	for _, event := range events {
+		raw, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, pkg.PendingMint{TxHash: event.Tx.Hash, Event: raw})
+	}
+
+	return s.persistency.AddPendingMints(pending)
+}
+
+// processPendingMints handles the mint events that were fetched but not processed before the last shutdown
+func (bridge *Bridge) processPendingMints(ctx context.Context) error {
+	blockheight, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	if len(blockheight.PendingMints) > 0 {
+		log.Info().Int("count", len(blockheight.PendingMints)).Msg("processing pending mints")
+	}
+
+	for _, pending := range blockheight.PendingMints {
+		var event stellar.MintEvent
+		if err := json.Unmarshal(pending.Event, &event); err != nil {
+			log.Err(err).Str("hash", pending.TxHash).Msg("failed to decode pending mint, it is processed again from the stellar cursor")
+			if err := bridge.blockPersistency.RemovePendingMint(pending.TxHash); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := bridge.handleMintEvent(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// mintTfchain records the proposed mints
+type mintTfchain struct {
+	memoTfchain
+	minted []string
+}
+
+func (f *mintTfchain) CheckMinted(ctx context.Context, txID string) (bool, error) {
+	for _, minted := range f.minted {
+		if minted == txID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *mintTfchain) GetExecutedMintTransaction(txHash string) (*subpkg.MintTransaction, error) {
+	for _, minted := range f.minted {
+		if minted == txHash {
+			return &subpkg.MintTransaction{}, nil
+		}
+	}
+	return nil, subpkg.ErrNotFound
+}
+
+func (f *mintTfchain) RetryProposeMintOrVote(ctx context.Context, txID string, target substrate.AccountID, amount *big.Int) error {
+	f.minted = append(f.minted, txID)
+	return nil
+}
+
+func TestPendingMintsSurviveRestart(t *testing.T) {
+	const (
+		sender = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		twin   = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+	)
+	account, err := substrate.FromAddress(twin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "persistency.json")
+
+	events := []stellar.MintEvent{
+		{
+			Senders: map[string]*big.Int{sender: big.NewInt(50000000)},
+			Tx:      hProtocol.Transaction{Hash: "a1", PT: "185661728346116353", MemoType: "text", Memo: "twin_1", Successful: true},
+		},
+		{
+			Senders: map[string]*big.Int{sender: big.NewInt(60000000)},
+			Tx:      hProtocol.Transaction{Hash: "b2", PT: "185661728346116354", MemoType: "text", Memo: "twin_1", Successful: true},
+		},
+	}
+
+	// the events are fetched and buffered, the bridge stops before handling them
+	persistency, err := pkg.InitPersist(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &pendingMintStore{persistency: persistency}
+	if err := store.SavePendingMintEvents(events); err != nil {
+		t.Fatal(err)
+	}
+
+	// restart
+	persistency, err = pkg.InitPersist(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tfchain := &mintTfchain{memoTfchain: memoTfchain{twins: map[uint32]substrate.AccountID{1: account}}}
+	bridge := &Bridge{
+		subClient:        tfchain,
+		blockPersistency: persistency,
+		config:           &pkg.BridgeConfig{PersistPendingMints: true},
+		depositFee:       10000000,
+		addressCache:     newAddressCache(0),
+		bursts:           newBurstTracker(0, 0, 0),
+		cursor:           &cursorTracker{},
+		checkpoint:       newCursorCheckpoint(0, 0),
+	}
+	if err := bridge.processPendingMints(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tfchain.minted) != 2 || tfchain.minted[0] != "a1" || tfchain.minted[1] != "b2" {
+		t.Errorf("expected the pending mints to be minted in order, got %v", tfchain.minted)
+	}
+	height, err := persistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(height.PendingMints) != 0 {
+		t.Errorf("expected no pending mints left, got %d", len(height.PendingMints))
+	}
+	if height.StellarCursor != "185661728346116354" {
+		t.Errorf("expected the cursor to be past the pending mints, got %q", height.StellarCursor)
+	}
+}
@@ -2,20 +2,94 @@ package bridge
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
 	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
 )
 
-// refund handler for stellar
-func (bridge *Bridge) refund(ctx context.Context, destination string, amount int64, tx hProtocol.Transaction) error {
-	err := bridge.handleRefundExpired(ctx, subpkg.RefundTransactionExpiredEvent{
+// RetryRefund issues the refund of a stellar transaction again, the refund target and amount are taken from
+// the refund transaction on chain or, if there is none, from the stellar transaction itself
+func (bridge *Bridge) RetryRefund(ctx context.Context, txHash string) error {
+	refunded, err := bridge.subClient.IsRefundedAlready(txHash)
+	if err != nil {
+		return err
+	}
+
+	if refunded {
+		log.Info().Str("tx_id", txHash).Msg("tx is refunded already, skipping...")
+		return pkg.ErrTransactionAlreadyRefunded
+	}
+
+	event := subpkg.RefundTransactionExpiredEvent{Hash: txHash}
+	refund, err := bridge.subClient.GetRefundTransaction(txHash)
+	if err == nil {
+		event.Target = refund.Target
+		event.Amount = uint64(refund.Amount)
+	} else {
+		log.Debug().Err(err).Str("tx_id", txHash).Msg("no refund transaction found on chain, loading stellar transaction")
+		mintEvents, err := bridge.wallet.GetTransactionMintEvents(txHash)
+		if err != nil {
+			return err
+		}
+		if len(mintEvents) == 0 || len(mintEvents[0].Senders) == 0 {
+			return fmt.Errorf("transaction %s has no deposit to refund", txHash)
+		}
+		// the deposits of several senders are refunded one by one when the bridge handles them, a single retry
+		// can not tell which of them failed
+		if len(mintEvents[0].Senders) > 1 {
+			return fmt.Errorf("transaction %s has deposits of %d senders, refund them separately", txHash, len(mintEvents[0].Senders))
+		}
+		for sender, amount := range mintEvents[0].Senders {
+			event.Target = sender
+			event.Amount = amount.Uint64()
+			break
+		}
+	}
+
+	log.Info().Str("tx_id", txHash).Str("target", event.Target).Uint64("amount", event.Amount).Msg("retrying refund")
+	return bridge.handleRefundExpired(ctx, event)
+}
+
+// refund pays amount of the deposit of sender in tx to destination, which is the sender itself unless the deposit
+// is absorbed into the fee collection account
+func (bridge *Bridge) refund(ctx context.Context, sender string, destination string, amount int64, tx hProtocol.Transaction) error {
+	if err := bridge.checkRefundBalance(ctx, tx.Hash, uint64(amount)); err != nil {
+		if !errors.Is(err, stellar.ErrInsufficientReserve) && !errors.Is(err, stellar.ErrInsufficientBalance) {
+			return err
+		}
+
+		err = bridge.blockPersistency.HoldDeposit(pkg.HeldDeposit{
+			TxHash:      tx.Hash,
+			PagingToken: tx.PagingToken(),
+			Sender:      sender,
+			Target:      destination,
+			Amount:      amount,
+			Reason:      alert.KindInsufficientReserve,
+			HeldAt:      time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		cursor := tx.PagingToken()
+		log.Info().Msgf("saving cursor now %s", cursor)
+		bridge.saveStellarCursor(cursor)
+		return nil
+	}
+
+	err := bridge.signRefund(ctx, subpkg.RefundTransactionExpiredEvent{
 		Hash:   tx.Hash,
 		Amount: uint64(amount),
 		Target: destination,
-	})
+	}, uint64(amount))
 	if err != nil {
 		return err
 	}
@@ -23,10 +97,7 @@ func (bridge *Bridge) refund(ctx context.Context, destination string, amount int
 	// save cursor
 	cursor := tx.PagingToken()
 	log.Info().Msgf("saving cursor now %s", cursor)
-	if err = bridge.blockPersistency.SaveStellarCursor(cursor); err != nil {
-		log.Error().Msgf("error while saving cursor:", err.Error())
-		return err
-	}
+	bridge.saveStellarCursor(cursor)
 	return nil
 }
 
@@ -41,12 +112,26 @@ func (bridge *Bridge) handleRefundExpired(ctx context.Context, refundExpiredEven
 		return nil
 	}
 
+	deposited, err := bridge.depositedAmount(refundExpiredEvent.Hash)
+	if err != nil {
+		return err
+	}
+
+	return bridge.signRefund(ctx, refundExpiredEvent, deposited)
+}
+
+// signRefund signs the refund of a deposit of deposited and proposes it on chain
+func (bridge *Bridge) signRefund(ctx context.Context, refundExpiredEvent subpkg.RefundTransactionExpiredEvent, deposited uint64) error {
+	if err := bridge.checkRefundAmount(ctx, refundExpiredEvent.Hash, refundExpiredEvent.Amount, deposited); err != nil {
+		return err
+	}
+
 	signature, sequenceNumber, err := bridge.wallet.CreateRefundAndReturnSignature(ctx, refundExpiredEvent.Target, refundExpiredEvent.Amount, refundExpiredEvent.Hash)
 	if err != nil {
 		return err
 	}
 
-	return bridge.subClient.RetryCreateRefundTransactionOrAddSig(ctx, refundExpiredEvent.Hash, refundExpiredEvent.Target, int64(refundExpiredEvent.Amount), signature, bridge.wallet.GetKeypair().Address(), sequenceNumber)
+	return bridge.subClient.RetryCreateRefundTransactionOrAddSig(ctx, refundExpiredEvent.Hash, refundExpiredEvent.Target, int64(refundExpiredEvent.Amount), signature, bridge.wallet.GetAddress(), sequenceNumber)
 }
 
 func (bridge *Bridge) handleRefundReady(ctx context.Context, refundReadyEvent subpkg.RefundTransactionReadyEvent) error {
@@ -65,10 +150,118 @@ func (bridge *Bridge) handleRefundReady(ctx context.Context, refundReadyEvent su
 		return err
 	}
 
+	deposited, err := bridge.depositedAmount(refund.TxHash)
+	if err != nil {
+		return err
+	}
+	if err = bridge.checkRefundAmount(ctx, refund.TxHash, uint64(refund.Amount), deposited); err != nil {
+		return err
+	}
+
+	if err = bridge.checkRefundBalance(ctx, refund.TxHash, uint64(refund.Amount)); err != nil {
+		if errors.Is(err, stellar.ErrInsufficientReserve) || errors.Is(err, stellar.ErrInsufficientBalance) {
+			// the refund expires and is signed again, by then the account can be funded
+			return nil
+		}
+		return err
+	}
+
+	if bridge.config.ObserverMode {
+		log.Info().Str("tx_id", refund.TxHash).Str("target", refund.Target).Uint64("amount", uint64(refund.Amount)).Msg("observer mode, not submitting refund payment")
+		return nil
+	}
+
 	// Todo, retry here?
 	if err = bridge.wallet.CreateRefundPaymentWithSignaturesAndSubmit(ctx, refund.Target, uint64(refund.Amount), refund.TxHash, refund.Signatures, int64(refund.SequenceNumber)); err != nil {
 		return err
 	}
 
+	bridge.recordAccounting(accounting.Entry{
+		Kind:          accounting.KindRefund,
+		Amount:        uint64(refund.Amount),
+		Destination:   refund.Target,
+		StellarTxHash: refund.TxHash,
+	})
+
 	return bridge.subClient.RetrySetRefundTransactionExecutedTx(ctx, refund.TxHash)
 }
+
+// depositedAmount loads the amount deposited by the stellar transaction a refund refers to
+func (bridge *Bridge) depositedAmount(txHash string) (uint64, error) {
+	mintEvents, err := bridge.wallet.GetTransactionMintEvents(txHash)
+	if err != nil {
+		return 0, err
+	}
+
+	var deposited uint64
+	for _, event := range mintEvents {
+		for _, amount := range event.Senders {
+			if amount != nil {
+				deposited += amount.Uint64()
+			}
+		}
+	}
+	return deposited, nil
+}
+
+// checkRefundAmount refuses a refund above the deposited amount or the refund ceiling, whatever the
+// cause such a refund would drain the bridge account so it is alerted and never retried
+func (bridge *Bridge) checkRefundAmount(ctx context.Context, txHash string, amount uint64, deposited uint64) error {
+	maxRefund := bridge.settings().MaxRefundAmount
+	var reason string
+	switch {
+	case amount > deposited:
+		reason = fmt.Sprintf("refund of %d is above the deposited amount of %d", amount, deposited)
+	case maxRefund > 0 && amount > uint64(maxRefund):
+		reason = fmt.Sprintf("refund of %d is above the refund ceiling of %d", amount, maxRefund)
+	default:
+		return nil
+	}
+
+	log.Error().Str("tx_id", txHash).Uint64("amount", amount).Uint64("deposited", deposited).Msg(reason)
+	err := bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindRefundRefused,
+		Message: reason,
+		Fields: map[string]string{
+			"tx_id":     txHash,
+			"amount":    fmt.Sprint(amount),
+			"deposited": fmt.Sprint(deposited),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+
+	return errors.Wrap(pkg.ErrRefundAmount, reason)
+}
+
+// checkRefundBalance verifies the bridge account can pay a refund, if it can not an alert is raised
+// and the balance error is returned unless the refund reserve policy is to submit anyway
+func (bridge *Bridge) checkRefundBalance(ctx context.Context, txHash string, amount uint64) error {
+	if bridge.config.RefundReservePolicy == pkg.RefundReservePolicySubmit {
+		return nil
+	}
+
+	err := bridge.wallet.CheckPaymentBalance(amount)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, stellar.ErrInsufficientReserve) && !errors.Is(err, stellar.ErrInsufficientBalance) {
+		return err
+	}
+
+	log.Warn().Err(err).Str("tx_id", txHash).Uint64("amount", amount).Msg("holding refund, bridge account can not pay it")
+	alertErr := bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    alert.KindInsufficientReserve,
+		Message: err.Error(),
+		Fields: map[string]string{
+			"tx_id":  txHash,
+			"amount": fmt.Sprint(amount),
+		},
+	})
+	if alertErr != nil {
+		log.Err(alertErr).Msg("failed to send alert")
+	}
+
+	return err
+}
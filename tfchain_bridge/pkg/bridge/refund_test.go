@@ -0,0 +1,178 @@
+package bridge
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+func TestCheckRefundAmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxRefund int64
+		amount    uint64
+		deposited uint64
+		refused   bool
+	}{
+		{name: "full deposit", amount: 50000000, deposited: 50000000},
+		{name: "part of the deposit", amount: 40000000, deposited: 50000000},
+		{name: "above the deposit", amount: 50000001, deposited: 50000000, refused: true},
+		{name: "nothing deposited", amount: 1, refused: true},
+		{name: "below the ceiling", maxRefund: 50000000, amount: 50000000, deposited: 50000000},
+		{name: "above the ceiling", maxRefund: 40000000, amount: 50000000, deposited: 50000000, refused: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			wallet := newFakeWallet(calls, 100)
+			bridge := newTestBridge(t, pkg.BridgeConfig{MaxRefundAmount: test.maxRefund}, newFakeTfchain(calls), wallet, 0)
+
+			err := bridge.checkRefundAmount(testContext(t), "tx", test.amount, test.deposited)
+			if !test.refused {
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertCalls(t, nil, bridge.alerter.(*recordingAlerter).kinds())
+				return
+			}
+			if !errors.Is(err, pkg.ErrRefundAmount) {
+				t.Fatalf("expected the refund to be refused, got %v", err)
+			}
+			assertCalls(t, []string{alert.KindRefundRefused}, bridge.alerter.(*recordingAlerter).kinds())
+		})
+	}
+}
+
+func TestAbsorbedDepositIsHeldForItsSender(t *testing.T) {
+	const (
+		sender        = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		feeCollection = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+		hash          = "1a3c5e7f9b2d4f6a8c0e2b4d6f8a0c1e3b5d7f9a2c4e6b8d0f1a3c5e7b9d2f4a"
+	)
+
+	calls := &callLog{}
+	wallet := newFakeWallet(calls, 100)
+	wallet.balanceErr = stellar.ErrInsufficientReserve
+	cfg := pkg.BridgeConfig{
+		BelowFeePolicy:       pkg.BelowFeePolicyAbsorb,
+		FeeCollectionAccount: feeCollection,
+		RefundReservePolicy:  pkg.RefundReservePolicyHold,
+	}
+	bridge := newTestBridge(t, cfg, newFakeTfchain(calls), wallet, 10000000)
+
+	event := stellar.MintEvent{
+		Senders: map[string]*big.Int{sender: big.NewInt(5000000)},
+		Tx:      hProtocol.Transaction{ID: hash, Hash: hash, PT: "185661728346116352", Successful: true, MemoType: "text", Memo: "twin_1"},
+	}
+	wallet.deposits[hash] = []stellar.MintEvent{event}
+	if err := bridge.handleMintEvent(testContext(t), event); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := bridge.blockPersistency.GetHeldDeposit(hash)
+	if err != nil {
+		t.Fatalf("deposit is not held: %s", err)
+	}
+	if held.Sender != sender {
+		t.Errorf("expected the held deposit to be from %s, got %s", sender, held.Sender)
+	}
+	if held.Target != feeCollection {
+		t.Errorf("expected the held deposit to be paid to %s, got %s", feeCollection, held.Target)
+	}
+}
+
+func TestRetryRefund(t *testing.T) {
+	const (
+		sender = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		other  = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+		hash   = "1a3c5e7f9b2d4f6a8c0e2b4d6f8a0c1e3b5d7f9a2c4e6b8d0f1a3c5e7b9d2f4a"
+	)
+
+	tests := []struct {
+		name     string
+		refunded bool
+		senders  map[string]*big.Int
+		err      bool
+		calls    []string
+	}{
+		{
+			name:     "already refunded",
+			refunded: true,
+			senders:  map[string]*big.Int{sender: big.NewInt(5000000)},
+			err:      true,
+		},
+		{
+			name:    "single sender",
+			senders: map[string]*big.Int{sender: big.NewInt(5000000)},
+			calls:   []string{"CreateRefundTransactionOrAddSig " + hash + " " + sender + " 5000000 seq=101"},
+		},
+		{
+			name:    "several senders",
+			senders: map[string]*big.Int{sender: big.NewInt(5000000), other: big.NewInt(3000000)},
+			err:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := newFakeTfchain(calls)
+			tfchain.refunded[hash] = test.refunded
+			wallet := newFakeWallet(calls, 100)
+			wallet.deposits[hash] = []stellar.MintEvent{{
+				Senders: test.senders,
+				Tx:      hProtocol.Transaction{ID: hash, Hash: hash, Successful: true},
+			}}
+			bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 0)
+
+			err := bridge.RetryRefund(testContext(t), hash)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			assertCalls(t, test.calls, calls.get())
+		})
+	}
+}
+
+func TestRefundReserveBreach(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		held   bool
+	}{
+		{name: "submit", policy: pkg.RefundReservePolicySubmit},
+		{name: "hold", policy: pkg.RefundReservePolicyHold, held: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			wallet := newFakeWallet(calls, 100)
+			wallet.balanceErr = stellar.ErrInsufficientReserve
+			tfchain := newFakeTfchain(calls)
+			bridge := newTestBridge(t, pkg.BridgeConfig{RefundReservePolicy: test.policy}, tfchain, wallet, 0)
+
+			deposit := testDeposit(1, testSender, 50000000, "not_a_memo")
+			if err := wallet.deposit(testContext(t), bridge, deposit); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := bridge.blockPersistency.GetHeldDeposit(deposit.Tx.Hash)
+			if held := err == nil; held != test.held {
+				t.Fatalf("expected the refund to be held: %t, got %t", test.held, held)
+			}
+			_, proposed := tfchain.refunds[deposit.Tx.Hash]
+			if proposed == test.held {
+				t.Errorf("expected the refund to be proposed: %t, got %t", !test.held, proposed)
+			}
+			var alerts []string
+			if test.held {
+				alerts = []string{alert.KindInsufficientReserve}
+			}
+			assertCalls(t, alerts, bridge.alerter.(*recordingAlerter).kinds())
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// ErrImmutableConfig is returned when a reload changes configuration fields that are only used when the bridge starts
+var ErrImmutableConfig = errors.New("configuration fields can not change while the bridge runs")
+
+// reloadableFields are the fields of the configuration that are read every time they are used,
+// so they can change while the bridge runs
+var reloadableFields = map[string]bool{
+	"LogLevel":            true,
+	"LowBalanceThreshold": true,
+	"SignatureTimeout":    true,
+	"MaxRefundAmount":     true,
+}
+
+// Reload applies the reloadable fields of cfg, the reload is rejected with ErrImmutableConfig if cfg changes
+// any other field. The log level is only recorded, setting the level of the logger is up to the caller.
+func (bridge *Bridge) Reload(cfg pkg.BridgeConfig) error {
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+
+	current := reflect.ValueOf(bridge.config).Elem()
+	next := reflect.ValueOf(&cfg).Elem()
+
+	var changed []string
+	for i := 0; i < current.NumField(); i++ {
+		name := current.Type().Field(i).Name
+		if reloadableFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(current.Field(i).Interface(), next.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) > 0 {
+		return errors.Wrap(ErrImmutableConfig, strings.Join(changed, ", "))
+	}
+
+	for name := range reloadableFields {
+		if !reflect.DeepEqual(current.FieldByName(name).Interface(), next.FieldByName(name).Interface()) {
+			log.Info().Str("field", name).Interface("value", next.FieldByName(name).Interface()).Msg("configuration field reloaded")
+		}
+		current.FieldByName(name).Set(next.FieldByName(name))
+	}
+
+	return nil
+}
+
+// settings returns a copy of the configuration, the reloadable fields must be read through it
+func (bridge *Bridge) settings() pkg.BridgeConfig {
+	bridge.mu.RLock()
+	defer bridge.mu.RUnlock()
+	return *bridge.config
+}
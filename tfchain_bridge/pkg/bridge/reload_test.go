@@ -0,0 +1,34 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestReload(t *testing.T) {
+	running := pkg.BridgeConfig{TfchainURL: "wss://tfchain.grid.tf/ws", LogLevel: "info", SignatureTimeout: time.Hour}
+	bridge := &Bridge{config: &running}
+
+	changed := running
+	changed.LogLevel = "debug"
+	changed.MaxRefundAmount = 1000000000
+	if err := bridge.Reload(changed); err != nil {
+		t.Fatal(err)
+	}
+	if settings := bridge.settings(); settings.LogLevel != "debug" || settings.MaxRefundAmount != 1000000000 {
+		t.Errorf("expected the reloadable fields to be applied, got %+v", settings)
+	}
+
+	immutable := changed
+	immutable.TfchainURL = "wss://tfchain.dev.grid.tf/ws"
+	immutable.SignatureTimeout = time.Minute
+	if err := bridge.Reload(immutable); !errors.Is(err, ErrImmutableConfig) {
+		t.Fatalf("expected a reload changing the tfchain url to be rejected, got %v", err)
+	}
+	if settings := bridge.settings(); settings.TfchainURL != running.TfchainURL || settings.SignatureTimeout != time.Hour {
+		t.Errorf("expected a rejected reload to apply nothing, got %+v", settings)
+	}
+}
@@ -0,0 +1,182 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// replayFixture is a recorded sequence of stellar deposits and tfchain blocks together with the extrinsics
+// and stellar submissions the bridge is expected to make for them, in order
+type replayFixture struct {
+	Name       string `json:"name"`
+	DepositFee int64  `json:"deposit_fee"`
+	// Sequence is the sequence number of the bridge account when the replay starts
+	Sequence int64 `json:"sequence"`
+	// Twins maps twin ids to their ss58 address
+	Twins map[uint32]string `json:"twins"`
+	Steps []replayStep      `json:"steps"`
+	Calls []string          `json:"calls"`
+}
+
+// replayStep is either a stellar deposit or a tfchain block
+type replayStep struct {
+	Deposit *replayDeposit `json:"deposit,omitempty"`
+	Block   *replayBlock   `json:"block,omitempty"`
+}
+
+// replayDeposit is a transaction on the bridge account as horizon returns it with the amounts of its senders
+type replayDeposit struct {
+	Tx      hProtocol.Transaction `json:"tx"`
+	Senders map[string]int64      `json:"senders"`
+}
+
+// replayBlock holds the bridge events of a tfchain block, accounts are ss58 addresses
+type replayBlock struct {
+	WithdrawCreated []struct {
+		ID     uint64 `json:"id"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+		Amount uint64 `json:"amount"`
+	} `json:"withdraw_created"`
+	WithdrawReady []uint64 `json:"withdraw_ready"`
+	RefundReady   []string `json:"refund_ready"`
+}
+
+// loadFixtures loads the replay fixtures of a testdata directory
+func loadFixtures(t *testing.T, dir string) []replayFixture {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no fixtures in %s", dir)
+	}
+
+	fixtures := make([]replayFixture, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fixture replayFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			t.Fatalf("failed to load fixture %s: %s", file, err)
+		}
+		if fixture.Name == "" {
+			fixture.Name = strings.TrimSuffix(filepath.Base(file), ".json")
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	return fixtures
+}
+
+// events converts a recorded block to the events the tfchain subscription delivers
+func (b *replayBlock) events(t *testing.T) subpkg.Events {
+	t.Helper()
+
+	var events subpkg.Events
+	for _, withdraw := range b.WithdrawCreated {
+		source, err := substrate.FromAddress(withdraw.Source)
+		if err != nil {
+			t.Fatalf("invalid withdraw source %s: %s", withdraw.Source, err)
+		}
+		events.WithdrawCreatedEvents = append(events.WithdrawCreatedEvents, subpkg.WithdrawCreatedEvent{
+			ID:     withdraw.ID,
+			Source: types.AccountID(source),
+			Target: withdraw.Target,
+			Amount: withdraw.Amount,
+		})
+	}
+	for _, id := range b.WithdrawReady {
+		events.WithdrawReadyEvents = append(events.WithdrawReadyEvents, subpkg.WithdrawReadyEvent{ID: id})
+	}
+	for _, hash := range b.RefundReady {
+		events.RefundReadyEvents = append(events.RefundReadyEvents, subpkg.RefundTransactionReadyEvent{Hash: hash})
+	}
+	return events
+}
+
+// replay feeds the steps of a fixture through the handlers of the bridge the way Run dispatches them and
+// returns the calls made on the fakes
+func replay(t *testing.T, fixture replayFixture) []string {
+	t.Helper()
+
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	for id, address := range fixture.Twins {
+		tfchain.addTwin(t, id, address)
+	}
+	wallet := newFakeWallet(calls, fixture.Sequence)
+
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, fixture.DepositFee)
+	ctx := testContext(t)
+
+	for i, step := range fixture.Steps {
+		switch {
+		case step.Deposit != nil:
+			event := stellar.MintEvent{Senders: make(map[string]*big.Int), Tx: step.Deposit.Tx}
+			for sender, amount := range step.Deposit.Senders {
+				event.Senders[sender] = big.NewInt(amount)
+			}
+			wallet.deposits[event.Tx.Hash] = []stellar.MintEvent{event}
+			if err := bridge.events.mint.dispatch(ctx, func(ctx context.Context) error {
+				return bridge.handleMintEvent(ctx, event)
+			}); err != nil {
+				t.Fatalf("step %d: deposit %s failed: %s", i, event.Tx.Hash, err)
+			}
+		case step.Block != nil:
+			if err := bridge.dispatchTfchainEvents(ctx, bridge.events, step.Block.events(t)); err != nil {
+				t.Fatalf("step %d: block failed: %s", i, err)
+			}
+		default:
+			t.Fatalf("step %d has no deposit or block", i)
+		}
+	}
+
+	return calls.get()
+}
+
+// assertCalls fails the test if the calls are not exactly the expected calls in the same order
+func assertCalls(t *testing.T, expected, calls []string) {
+	t.Helper()
+
+	n := len(expected)
+	if len(calls) > n {
+		n = len(calls)
+	}
+	for i := 0; i < n; i++ {
+		var want, got string
+		if i < len(expected) {
+			want = expected[i]
+		}
+		if i < len(calls) {
+			got = calls[i]
+		}
+		if want != got {
+			t.Errorf("call %d: expected %q, got %q", i, want, got)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	for _, fixture := range loadFixtures(t, filepath.Join("testdata", "replay")) {
+		fixture := fixture
+		t.Run(fixture.Name, func(t *testing.T) {
+			assertCalls(t, fixture.Calls, replay(t, fixture))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// signatureCheckInterval is how often the withdraws awaiting signatures are checked against the timeout
+const signatureCheckInterval = time.Minute
+
+// signatureTracker tracks how long withdraws wait for the validators to add their signatures, from
+// their withdraw created event to their withdraw ready event
+type signatureTracker struct {
+	mu      sync.Mutex
+	created map[uint64]time.Time
+	alerted map[uint64]struct{}
+}
+
+func newSignatureTracker() *signatureTracker {
+	return &signatureTracker{
+		created: make(map[uint64]time.Time),
+		alerted: make(map[uint64]struct{}),
+	}
+}
+
+func (t *signatureTracker) trackEvents(events subpkg.Events, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, e := range events.WithdrawCreatedEvents {
+		if _, ok := t.created[e.ID]; !ok {
+			t.created[e.ID] = now
+		}
+	}
+	for _, e := range events.WithdrawReadyEvents {
+		created, ok := t.created[e.ID]
+		if !ok {
+			// created before the bridge started
+			continue
+		}
+		withdrawSignatureDuration.Observe(now.Sub(created).Seconds())
+		t.drop(e.ID)
+	}
+	t.updateGauge(now)
+}
+
+// drop stops tracking a withdraw, it must be called with the lock held
+func (t *signatureTracker) drop(id uint64) {
+	delete(t.created, id)
+	delete(t.alerted, id)
+}
+
+// updateGauge exposes how long the oldest withdraw has been waiting, it must be called with the lock held
+func (t *signatureTracker) updateGauge(now time.Time) {
+	var oldest time.Duration
+	for _, created := range t.created {
+		if waiting := now.Sub(created); waiting > oldest {
+			oldest = waiting
+		}
+	}
+	withdrawAwaitingSignatures.Set(float64(len(t.created)))
+	withdrawOldestAwaitingSignatures.Set(oldest.Seconds())
+}
+
+// overdue returns the withdraws waiting longer than timeout that were not alerted yet
+func (t *signatureTracker) overdue(now time.Time, timeout time.Duration) map[uint64]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.updateGauge(now)
+	overdue := make(map[uint64]time.Duration)
+	for id, created := range t.created {
+		if _, ok := t.alerted[id]; ok {
+			continue
+		}
+		if waiting := now.Sub(created); waiting > timeout {
+			overdue[id] = waiting
+		}
+	}
+	return overdue
+}
+
+// refresh updates the gauge of the oldest withdraw, it keeps growing without new events
+func (t *signatureTracker) refresh(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.updateGauge(now)
+}
+
+func (t *signatureTracker) markAlerted(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.created[id]; ok {
+		t.alerted[id] = struct{}{}
+	}
+}
+
+func (t *signatureTracker) dropWithdraw(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drop(id)
+}
+
+// monitorSignatures alerts once for every withdraw that waits for signatures longer than the
+// configured timeout, a slow withdraw usually means some validators are not responding
+func (bridge *Bridge) monitorSignatures(ctx context.Context) {
+	ticker := time.NewTicker(signatureCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		bridge.checkSignatures(ctx, time.Now())
+	}
+}
+
+// checkSignatures alerts for the withdraws waiting for signatures longer than the timeout at now
+func (bridge *Bridge) checkSignatures(ctx context.Context, now time.Time) {
+	timeout := bridge.settings().SignatureTimeout
+	if timeout <= 0 {
+		bridge.signatures.refresh(now)
+		return
+	}
+
+	for id, waiting := range bridge.signatures.overdue(now, timeout) {
+		// the withdraw may have been paid without the bridge seeing its ready event
+		burned, err := bridge.subClient.IsBurnedAlready(types.U64(id))
+		if err != nil {
+			log.Debug().Err(err).Uint64("ID", id).Msg("failed to check if burn transaction is executed")
+			continue
+		}
+		if burned {
+			bridge.signatures.dropWithdraw(id)
+			continue
+		}
+
+		log.Warn().Uint64("ID", id).Dur("waiting", waiting).Msg("withdraw is waiting for signatures longer than the timeout")
+		err = bridge.alerter.Alert(ctx, alert.Alert{
+			Kind:    alert.KindSignatureTimeout,
+			Message: "withdraw did not collect enough signatures within the timeout",
+			Fields: map[string]string{
+				"withdraw_id": fmt.Sprint(id),
+				"waiting":     waiting.String(),
+			},
+		})
+		if err != nil {
+			log.Err(err).Msg("failed to send alert")
+		}
+		bridge.signatures.markAlerted(id)
+	}
+}
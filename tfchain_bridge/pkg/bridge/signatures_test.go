@@ -0,0 +1,47 @@
+package bridge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+func TestSignatureTimeout(t *testing.T) {
+	tfchain := newFakeTfchain(&callLog{})
+	alerter := &recordingAlerter{}
+	bridge := &Bridge{
+		subClient:  tfchain,
+		alerter:    alerter,
+		config:     &pkg.BridgeConfig{SignatureTimeout: 10 * time.Minute},
+		signatures: newSignatureTracker(),
+	}
+	ctx := context.Background()
+	start := time.Now()
+
+	// withdraw 1 is slow to get ready, 2 gets ready in time and 3 is paid without the bridge seeing it ready
+	bridge.signatures.trackEvents(subpkg.Events{WithdrawCreatedEvents: []subpkg.WithdrawCreatedEvent{{ID: 1}, {ID: 2}, {ID: 3}}}, start)
+	bridge.signatures.trackEvents(subpkg.Events{WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 2}}}, start.Add(time.Minute))
+	tfchain.executedBurns[3] = true
+
+	bridge.checkSignatures(ctx, start.Add(5*time.Minute))
+	if kinds := alerter.kinds(); len(kinds) != 0 {
+		t.Fatalf("expected no alerts within the timeout, got %v", kinds)
+	}
+
+	bridge.checkSignatures(ctx, start.Add(11*time.Minute))
+	bridge.checkSignatures(ctx, start.Add(12*time.Minute))
+	if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, []string{alert.KindSignatureTimeout}) {
+		t.Fatalf("expected a single signature timeout alert, got %v", kinds)
+	}
+	if id := alerter.alerts[0].Fields["withdraw_id"]; id != "1" {
+		t.Errorf("expected the alert for withdraw 1, got withdraw %s", id)
+	}
+	if _, ok := bridge.signatures.created[3]; ok {
+		t.Error("expected the paid withdraw to no longer be tracked")
+	}
+}
@@ -0,0 +1,134 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/strkey"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+// expectedSigners returns the stellar keys of the validators on chain, a validator signs stellar payments
+// with the ed25519 key of its tfchain account
+func expectedSigners(validators []substrate.AccountID) ([]string, error) {
+	signers := make([]string, 0, len(validators))
+	for _, validator := range validators {
+		signer, err := strkey.Encode(strkey.VersionByteAccountID, validator[:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode validator %s as a stellar key", validator.String())
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// compareSignerSet returns how the signers of the bridge account diverge from the signer set derived from the
+// bridge validators on chain. Every validator is expected to sign with its own key of weight 1, the account must
+// have no other signers, own must be one of them and the medium threshold must be a majority of the validators.
+func compareSignerSet(validators []string, config stellar.AccountConfig, own string) []string {
+	var divergences []string
+
+	expected := make(map[string]bool, len(validators))
+	for _, validator := range validators {
+		expected[validator] = true
+	}
+
+	signers := make([]string, 0, len(config.Signers))
+	for key, weight := range config.Signers {
+		if key != config.Account && weight > 0 {
+			signers = append(signers, key)
+		}
+	}
+	sort.Strings(signers)
+
+	for _, validator := range validators {
+		if config.Signers[validator] <= 0 {
+			divergences = append(divergences, fmt.Sprintf("validator key %s is not a signer of the bridge account", validator))
+		}
+	}
+	for _, signer := range signers {
+		if !expected[signer] {
+			divergences = append(divergences, fmt.Sprintf("signer %s is not a bridge validator", signer))
+		}
+	}
+
+	if config.Signers[own] <= 0 {
+		divergences = append(divergences, fmt.Sprintf("bridge key %s is not a signer of the bridge account", own))
+	}
+
+	if threshold := len(validators)/2 + 1; int(config.MediumThreshold) != threshold {
+		divergences = append(divergences, fmt.Sprintf("medium threshold %d is not the majority %d of %d validators", config.MediumThreshold, threshold, len(validators)))
+	}
+
+	return divergences
+}
+
+// monitorSignerSet periodically compares the signers of the bridge account with the validators on chain,
+// validators are added and removed on chain while the stellar account is configured by hand
+func (bridge *Bridge) monitorSignerSet(ctx context.Context) {
+	interval := bridge.config.SignerCheckInterval
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// only a change of the divergences is alerted
+	last := ""
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		validators, err := bridge.subClient.GetBridgeValidators()
+		if err != nil {
+			log.Err(err).Msg("failed to get the bridge validators")
+			continue
+		}
+
+		signers, err := expectedSigners(validators)
+		if err != nil {
+			log.Err(err).Msg("failed to derive the signers of the bridge validators")
+			continue
+		}
+
+		config, err := bridge.wallet.GetAccountConfig()
+		if err != nil {
+			log.Err(err).Msg("failed to get the bridge account configuration")
+			continue
+		}
+
+		divergences := strings.Join(compareSignerSet(signers, config, bridge.wallet.GetAddress()), "; ")
+		if divergences == last {
+			continue
+		}
+		last = divergences
+		if divergences == "" {
+			log.Info().Msg("bridge account signers match the bridge validators again")
+			continue
+		}
+
+		log.Warn().Str("divergences", divergences).Msg("bridge account signers diverge from the bridge validators")
+		err = bridge.alerter.Alert(ctx, alert.Alert{
+			Kind:    alert.KindSignerSetMismatch,
+			Message: "bridge account signers diverge from the bridge validators on chain",
+			Fields: map[string]string{
+				"divergences": divergences,
+				"validators":  fmt.Sprint(len(validators)),
+			},
+		})
+		if err != nil {
+			log.Err(err).Msg("failed to send alert")
+		}
+	}
+}
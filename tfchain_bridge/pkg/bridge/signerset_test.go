@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stellar/go/strkey"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+)
+
+func TestExpectedSigners(t *testing.T) {
+	const signer = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+	key, err := strkey.Decode(strkey.VersionByteAccountID, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var validator substrate.AccountID
+	copy(validator[:], key)
+
+	signers, err := expectedSigners([]substrate.AccountID{validator})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(signers, []string{signer}) {
+		t.Errorf("expected the validator to sign with %s, got %v", signer, signers)
+	}
+}
+
+func TestCompareSignerSet(t *testing.T) {
+	const account = "GDCAMOLMOTTIKJ6MRQ4WPXIUBWEV4CZS7QNVDNO65XKYOOEPYV5NZGDG"
+	keys, err := expectedSigners([]substrate.AccountID{{1}, {2}, {3}, {4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, other, third, fourth := keys[0], keys[1], keys[2], keys[3]
+
+	tests := []struct {
+		name        string
+		validators  []string
+		config      stellar.AccountConfig
+		divergences []string
+	}{
+		{
+			name:       "matching",
+			validators: []string{own, other, third},
+			config:     stellar.AccountConfig{Account: account, MediumThreshold: 2, Signers: map[string]int32{account: 0, own: 1, other: 1, third: 1}},
+		},
+		{
+			name:        "validator without signer",
+			validators:  []string{own, other, third, fourth},
+			config:      stellar.AccountConfig{Account: account, MediumThreshold: 3, Signers: map[string]int32{own: 1, other: 1, third: 1}},
+			divergences: []string{"validator key " + fourth + " is not a signer of the bridge account"},
+		},
+		{
+			name:        "signer is not a validator",
+			validators:  []string{own, other, third},
+			config:      stellar.AccountConfig{Account: account, MediumThreshold: 2, Signers: map[string]int32{own: 1, other: 1, fourth: 1}},
+			divergences: []string{"validator key " + third + " is not a signer of the bridge account", "signer " + fourth + " is not a bridge validator"},
+		},
+		{
+			name:        "own key is not a signer",
+			validators:  []string{other, third},
+			config:      stellar.AccountConfig{Account: account, MediumThreshold: 2, Signers: map[string]int32{other: 1, third: 1}},
+			divergences: []string{"bridge key " + own + " is not a signer of the bridge account"},
+		},
+		{
+			name:        "threshold is not a majority",
+			validators:  []string{own, other, third},
+			config:      stellar.AccountConfig{Account: account, MediumThreshold: 1, Signers: map[string]int32{own: 1, other: 1, third: 1}},
+			divergences: []string{"medium threshold 1 is not the majority 2 of 3 validators"},
+		},
+		{
+			name:        "threshold above the majority",
+			validators:  []string{own, other, third},
+			config:      stellar.AccountConfig{Account: account, MediumThreshold: 3, Signers: map[string]int32{own: 1, other: 1, third: 1}},
+			divergences: []string{"medium threshold 3 is not the majority 2 of 3 validators"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			divergences := compareSignerSet(test.validators, test.config, own)
+			if !reflect.DeepEqual(divergences, test.divergences) {
+				t.Errorf("expected divergences %q, got %q", test.divergences, divergences)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// WithdrawStatus returns the lifecycle status of a withdraw
+func (bridge *Bridge) WithdrawStatus(ctx context.Context, id uint64) (*pkg.WithdrawStatus, error) {
+	status := &pkg.WithdrawStatus{
+		ID:                 id,
+		RequiredSignatures: bridge.wallet.GetSignatureCount(),
+	}
+
+	burned, err := bridge.subClient.IsBurnedAlready(types.U64(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if burned {
+		status.Status = pkg.WithdrawStatusPaid
+		burnTx, err := bridge.subClient.GetExecutedBurnTransaction(id)
+		if err != nil {
+			// the withdraw is paid, only the details are missing
+			log.Debug().Err(err).Uint64("ID", id).Msg("failed to get executed burn transaction")
+			return status, nil
+		}
+
+		status.Target = burnTx.Target
+		status.Amount = uint64(burnTx.Amount)
+		status.Signatures = len(burnTx.Signatures)
+		status.StellarTxHash, err = bridge.wallet.PaymentTransactionHash(burnTx.Target, uint64(burnTx.Amount), int64(burnTx.SequenceNumber), burnTx.Signatures)
+		if err != nil {
+			log.Debug().Err(err).Uint64("ID", id).Msg("failed to compute stellar payment hash")
+		}
+		return status, nil
+	}
+
+	burnTx, err := bridge.subClient.GetBurnTransaction(types.U64(id))
+	if err != nil {
+		log.Debug().Err(err).Uint64("ID", id).Msg("failed to get burn transaction")
+		return nil, pkg.ErrNotFound
+	}
+
+	status.Target = burnTx.Target
+	status.Amount = uint64(burnTx.Amount)
+	status.Signatures = len(burnTx.Signatures)
+	switch {
+	case status.Signatures == 0:
+		status.Status = pkg.WithdrawStatusCreated
+	case status.Signatures < status.RequiredSignatures:
+		status.Status = pkg.WithdrawStatusSignaturesCollected
+	default:
+		status.Status = pkg.WithdrawStatusReady
+	}
+
+	return status, nil
+}
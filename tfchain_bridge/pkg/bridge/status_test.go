@@ -0,0 +1,107 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// statusTfchain holds the burn transactions of the withdraw status test
+type statusTfchain struct {
+	tfchainClient
+	burns    map[uint64]*substrate.BurnTransaction
+	executed map[uint64]bool
+}
+
+func (f *statusTfchain) IsBurnedAlready(id types.U64) (bool, error) {
+	return f.executed[uint64(id)], nil
+}
+
+func (f *statusTfchain) GetBurnTransaction(id types.U64) (*substrate.BurnTransaction, error) {
+	if burn, ok := f.burns[uint64(id)]; ok && !f.executed[uint64(id)] {
+		return burn, nil
+	}
+	return nil, substrate.ErrBurnTransactionNotFound
+}
+
+func (f *statusTfchain) GetExecutedBurnTransaction(id uint64) (*substrate.BurnTransaction, error) {
+	if burn, ok := f.burns[id]; ok && f.executed[id] {
+		return burn, nil
+	}
+	return nil, substrate.ErrBurnTransactionNotFound
+}
+
+// statusWallet requires 2 signatures for a payment
+type statusWallet struct {
+	stellarWallet
+}
+
+func (w *statusWallet) GetSignatureCount() int { return 2 }
+
+func (w *statusWallet) PaymentTransactionHash(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (string, error) {
+	return fmt.Sprintf("payment-%s-%d-%d", target, amount, sequenceNumber), nil
+}
+
+func TestWithdrawStatus(t *testing.T) {
+	const target = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+
+	burn := func(signatures int) *substrate.BurnTransaction {
+		burn := &substrate.BurnTransaction{Target: target, Amount: 50000000, SequenceNumber: 101}
+		for i := 0; i < signatures; i++ {
+			burn.Signatures = append(burn.Signatures, substrate.StellarSignature{Signature: []byte{byte(i)}})
+		}
+		return burn
+	}
+
+	tests := []struct {
+		name       string
+		burn       *substrate.BurnTransaction
+		executed   bool
+		status     string
+		signatures int
+		hash       string
+	}{
+		{name: "created", burn: burn(0), status: pkg.WithdrawStatusCreated},
+		{name: "signatures collected", burn: burn(1), status: pkg.WithdrawStatusSignaturesCollected, signatures: 1},
+		{name: "ready", burn: burn(2), status: pkg.WithdrawStatusReady, signatures: 2},
+		{name: "paid", burn: burn(2), executed: true, status: pkg.WithdrawStatusPaid, signatures: 2, hash: "payment-" + target + "-50000000-101"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tfchain := &statusTfchain{
+				burns:    map[uint64]*substrate.BurnTransaction{1: test.burn},
+				executed: map[uint64]bool{1: test.executed},
+			}
+			bridge := &Bridge{subClient: tfchain, wallet: &statusWallet{}}
+
+			status, err := bridge.WithdrawStatus(context.Background(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := pkg.WithdrawStatus{
+				ID:                 1,
+				Status:             test.status,
+				Target:             target,
+				Amount:             50000000,
+				Signatures:         test.signatures,
+				RequiredSignatures: 2,
+				StellarTxHash:      test.hash,
+			}
+			if *status != expected {
+				t.Errorf("expected %+v, got %+v", expected, *status)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		bridge := &Bridge{subClient: &statusTfchain{}, wallet: &statusWallet{}}
+		if _, err := bridge.WithdrawStatus(context.Background(), 1); !errors.Is(err, pkg.ErrNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// trace statuses
+const (
+	TraceStatusNotProcessed  = "not_processed"
+	TraceStatusMintProposed  = "mint_proposed"
+	TraceStatusMinted        = "minted"
+	TraceStatusRefundPending = "refund_pending"
+	TraceStatusRefunded      = "refunded"
+	TraceStatusHeld          = "held"
+	TraceStatusSkipped       = "skipped"
+)
+
+// Trace is everything the bridge knows about a deposit on the bridge account
+type Trace struct {
+	TxHash string `json:"tx_hash"`
+	Status string `json:"status"`
+	Memo   string `json:"memo,omitempty"`
+	// Deposit is what the bridge decides to do with the deposit under the current configuration
+	Deposit *DepositOutcome  `json:"deposit,omitempty"`
+	Mint    *MintTrace       `json:"mint,omitempty"`
+	Refund  *RefundTrace     `json:"refund,omitempty"`
+	Held    *pkg.HeldDeposit `json:"held,omitempty"`
+}
+
+// MintTrace is the mint transaction of a deposit on tfchain
+type MintTrace struct {
+	Target string `json:"target"`
+	Amount uint64 `json:"amount"`
+	// Block is the tfchain block the mint was proposed in, the mint extrinsics are not indexed
+	Block    uint32 `json:"block"`
+	Votes    uint32 `json:"votes"`
+	Executed bool   `json:"executed"`
+}
+
+// RefundTrace is the refund transaction of a deposit on tfchain
+type RefundTrace struct {
+	Target     string `json:"target"`
+	Amount     uint64 `json:"amount"`
+	Block      uint32 `json:"block"`
+	Signatures int    `json:"signatures"`
+	Executed   bool   `json:"executed"`
+}
+
+// Trace follows a stellar transaction on the bridge account through the bridge: the deposit, the resolved
+// target and the mint on tfchain, or the refund if the deposit is refunded
+func (bridge *Bridge) Trace(ctx context.Context, stellarTxHash string) (*Trace, error) {
+	mintEvents, err := bridge.wallet.GetTransactionMintEvents(stellarTxHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mintEvents) == 0 {
+		return nil, pkg.ErrNotFound
+	}
+
+	tx := mintEvents[0].Tx
+	trace := &Trace{
+		TxHash: stellarTxHash,
+		Status: TraceStatusNotProcessed,
+		Memo:   tx.Memo,
+	}
+
+	if len(mintEvents[0].Senders) > 0 {
+		outcome, err := bridge.decideDeposit(mintEvents[0].Senders, tx.Memo, tx.MemoType)
+		if err != nil {
+			log.Debug().Err(err).Str("tx_id", stellarTxHash).Msg("failed to decide deposit")
+		} else {
+			trace.Deposit = &outcome
+			if outcome.Action == DepositActionSkip {
+				trace.Status = TraceStatusSkipped
+			}
+		}
+	}
+
+	if err := bridge.traceMint(trace); err != nil {
+		return nil, err
+	}
+	if trace.Mint != nil {
+		return trace, nil
+	}
+
+	if err := bridge.traceRefund(trace); err != nil {
+		return nil, err
+	}
+	if trace.Refund != nil {
+		return trace, nil
+	}
+
+	blockheight, err := bridge.blockPersistency.GetHeight()
+	if err != nil {
+		return nil, err
+	}
+	for i := range blockheight.HeldDeposits {
+		if blockheight.HeldDeposits[i].TxHash == stellarTxHash {
+			trace.Held = &blockheight.HeldDeposits[i]
+			trace.Status = TraceStatusHeld
+			break
+		}
+	}
+
+	return trace, nil
+}
+
+func (bridge *Bridge) traceMint(trace *Trace) error {
+	executed := true
+	mintTx, err := bridge.subClient.GetExecutedMintTransaction(trace.TxHash)
+	if errors.Is(err, subpkg.ErrNotFound) {
+		executed = false
+		mintTx, err = bridge.subClient.GetProposedMintTransaction(trace.TxHash)
+	}
+	if errors.Is(err, subpkg.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	trace.Mint = &MintTrace{
+		Target:   mintTx.Target.String(),
+		Amount:   uint64(mintTx.Amount),
+		Block:    uint32(mintTx.Block),
+		Votes:    uint32(mintTx.Votes),
+		Executed: executed,
+	}
+	trace.Status = TraceStatusMintProposed
+	if executed {
+		trace.Status = TraceStatusMinted
+	}
+
+	return nil
+}
+
+func (bridge *Bridge) traceRefund(trace *Trace) error {
+	refunded, err := bridge.subClient.IsRefundedAlready(trace.TxHash)
+	if err != nil {
+		return err
+	}
+
+	refund, err := bridge.subClient.GetRefundTransaction(trace.TxHash)
+	if err != nil || refund.Amount == 0 {
+		// refund transactions are a value query, an empty transaction means there is none
+		if refunded {
+			trace.Refund = &RefundTrace{Executed: true}
+			trace.Status = TraceStatusRefunded
+		}
+		return nil
+	}
+
+	trace.Refund = &RefundTrace{
+		Target:     refund.Target,
+		Amount:     uint64(refund.Amount),
+		Block:      uint32(refund.Block),
+		Signatures: len(refund.Signatures),
+		Executed:   refunded,
+	}
+	trace.Status = TraceStatusRefundPending
+	if refunded {
+		trace.Status = TraceStatusRefunded
+	}
+
+	return nil
+}
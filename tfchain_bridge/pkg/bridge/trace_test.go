@@ -0,0 +1,153 @@
+package bridge
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// traceTfchain holds the mint and refund transactions of the traced deposit
+type traceTfchain struct {
+	memoTfchain
+	proposed *subpkg.MintTransaction
+	executed *subpkg.MintTransaction
+	refund   *substrate.RefundTransaction
+	refunded bool
+}
+
+func (f *traceTfchain) GetProposedMintTransaction(txHash string) (*subpkg.MintTransaction, error) {
+	if f.proposed == nil {
+		return nil, subpkg.ErrNotFound
+	}
+	return f.proposed, nil
+}
+
+func (f *traceTfchain) GetExecutedMintTransaction(txHash string) (*subpkg.MintTransaction, error) {
+	if f.executed == nil {
+		return nil, subpkg.ErrNotFound
+	}
+	return f.executed, nil
+}
+
+func (f *traceTfchain) IsRefundedAlready(txHash string) (bool, error) {
+	return f.refunded, nil
+}
+
+func (f *traceTfchain) GetRefundTransaction(txHash string) (*substrate.RefundTransaction, error) {
+	// refund transactions are a value query, a missing transaction is empty
+	if f.refund == nil {
+		return &substrate.RefundTransaction{}, nil
+	}
+	return f.refund, nil
+}
+
+// traceWallet knows the deposits on the bridge account
+type traceWallet struct {
+	stellarWallet
+	deposits map[string][]stellar.MintEvent
+}
+
+func (w *traceWallet) GetTransactionMintEvents(txHash string) ([]stellar.MintEvent, error) {
+	return w.deposits[txHash], nil
+}
+
+func TestTrace(t *testing.T) {
+	const (
+		sender = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		twin   = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+		hash   = "1a3c5e7f9b2d4f6a8c0e2b4d6f8a0c1e3b5d7f9a2c4e6b8d0f1a3c5e7b9d2f4a"
+	)
+	account, err := substrate.FromAddress(twin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mint := &subpkg.MintTransaction{Amount: 40000000, Target: account, Block: 12, Votes: 2}
+	refund := &substrate.RefundTransaction{TxHash: hash, Target: sender, Amount: 40000000, Block: 14, Signatures: []substrate.StellarSignature{{}}}
+
+	tests := []struct {
+		name     string
+		memo     string
+		tfchain  traceTfchain
+		held     bool
+		status   string
+		action   string
+		executed bool
+	}{
+		{name: "not processed", memo: "twin_1", status: TraceStatusNotProcessed, action: DepositActionMint},
+		{name: "mint proposed", memo: "twin_1", tfchain: traceTfchain{proposed: mint}, status: TraceStatusMintProposed, action: DepositActionMint},
+		{name: "minted", memo: "twin_1", tfchain: traceTfchain{executed: mint}, status: TraceStatusMinted, action: DepositActionMint, executed: true},
+		{name: "refund pending", memo: "twin_x", tfchain: traceTfchain{refund: refund}, status: TraceStatusRefundPending, action: DepositActionRefund},
+		{name: "refunded", memo: "twin_x", tfchain: traceTfchain{refund: refund, refunded: true}, status: TraceStatusRefunded, action: DepositActionRefund, executed: true},
+		{name: "refunded without refund transaction", memo: "twin_x", tfchain: traceTfchain{refunded: true}, status: TraceStatusRefunded, action: DepositActionRefund, executed: true},
+		{name: "held", memo: "twin_1", held: true, status: TraceStatusHeld, action: DepositActionMint},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			persistency, err := pkg.InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.held {
+				if err := persistency.HoldDeposit(pkg.HeldDeposit{TxHash: hash, Sender: sender, Target: twin, Amount: 50000000}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			tfchain := test.tfchain
+			tfchain.twins = map[uint32]substrate.AccountID{1: account}
+			wallet := &traceWallet{deposits: map[string][]stellar.MintEvent{
+				hash: {{
+					Senders: map[string]*big.Int{sender: big.NewInt(50000000)},
+					Tx:      hProtocol.Transaction{Hash: hash, MemoType: "text", Memo: test.memo, Successful: true},
+				}},
+			}}
+			bridge := &Bridge{
+				subClient:        &tfchain,
+				wallet:           wallet,
+				blockPersistency: persistency,
+				config:           &pkg.BridgeConfig{},
+				depositFee:       10000000,
+				addressCache:     newAddressCache(0),
+				bursts:           newBurstTracker(0, 0, 0),
+			}
+
+			trace, err := bridge.Trace(context.Background(), hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if trace.Status != test.status {
+				t.Errorf("expected status %s, got %s", test.status, trace.Status)
+			}
+			if trace.Deposit == nil || trace.Deposit.Action != test.action {
+				t.Errorf("expected the deposit to be decided as %s, got %+v", test.action, trace.Deposit)
+			}
+			switch {
+			case trace.Mint != nil:
+				if trace.Mint.Target != twin || trace.Mint.Amount != 40000000 || trace.Mint.Executed != test.executed {
+					t.Errorf("unexpected mint %+v", trace.Mint)
+				}
+			case trace.Refund != nil:
+				if trace.Refund.Executed != test.executed {
+					t.Errorf("unexpected refund %+v", trace.Refund)
+				}
+			}
+			if test.held && (trace.Held == nil || trace.Held.Target != twin) {
+				t.Errorf("expected the held deposit, got %+v", trace.Held)
+			}
+		})
+	}
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		bridge := &Bridge{subClient: &traceTfchain{}, wallet: &traceWallet{}}
+		if _, err := bridge.Trace(context.Background(), hash); !errors.Is(err, pkg.ErrNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+)
+
+// monitorValidator periodically checks the bridge account is still a validator, a removed validator
+// stops submitting extrinsics until it is added again or, if configured, stops the bridge
+func (bridge *Bridge) monitorValidator(ctx context.Context, stop func(error)) {
+	interval := bridge.config.ValidatorCheckInterval
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	removed := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		isValidator, err := bridge.subClient.IsBridgeValidator()
+		if err != nil {
+			log.Err(err).Msg("failed to check if the account is a bridge validator")
+			continue
+		}
+
+		switch {
+		case !isValidator && !removed:
+			removed = true
+			log.Error().Msg("ACCOUNT IS NO LONGER A BRIDGE VALIDATOR, pausing extrinsic submissions")
+			bridge.subClient.PauseSubmissions()
+			err = bridge.alerter.Alert(ctx, alert.Alert{
+				Kind:    alert.KindValidatorRemoved,
+				Message: "account is no longer a bridge validator, extrinsic submissions are paused",
+			})
+			if err != nil {
+				log.Err(err).Msg("failed to send alert")
+			}
+			if bridge.config.ExitWhenNotValidator {
+				stop(pkg.ErrNotValidator)
+				return
+			}
+		case isValidator && removed:
+			removed = false
+			log.Info().Msg("account is a bridge validator again, resuming extrinsic submissions")
+			bridge.subClient.ResumeSubmissions()
+		}
+	}
+}
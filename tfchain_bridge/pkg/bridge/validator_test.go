@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+)
+
+// validatorTfchain is a validator until it is removed, it records whether submissions are paused
+type validatorTfchain struct {
+	tfchainClient
+
+	mu        sync.Mutex
+	validator bool
+	paused    bool
+}
+
+func (f *validatorTfchain) IsBridgeValidator() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.validator, nil
+}
+
+func (f *validatorTfchain) PauseSubmissions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paused = true
+}
+
+func (f *validatorTfchain) ResumeSubmissions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paused = false
+}
+
+func (f *validatorTfchain) setValidator(validator bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.validator = validator
+}
+
+func (f *validatorTfchain) isPaused() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.paused
+}
+
+// eventually polls condition until it holds or the test times out
+func eventually(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatal(message)
+}
+
+func TestMonitorValidatorPausesRemovedValidator(t *testing.T) {
+	tfchain := &validatorTfchain{validator: true}
+	alerter := &recordingAlerter{}
+	bridge := &Bridge{subClient: tfchain, alerter: alerter, config: &pkg.BridgeConfig{ValidatorCheckInterval: 5 * time.Millisecond}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bridge.monitorValidator(ctx, func(err error) { t.Errorf("the bridge was stopped: %v", err) })
+
+	// the account is removed from the validators and added back mid run
+	tfchain.setValidator(false)
+	eventually(t, tfchain.isPaused, "submissions were not paused after the account was removed")
+	tfchain.setValidator(true)
+	eventually(t, func() bool { return !tfchain.isPaused() }, "submissions were not resumed after the account was added back")
+}
+
+func TestMonitorValidatorStopsRemovedValidator(t *testing.T) {
+	tfchain := &validatorTfchain{validator: true}
+	alerter := &recordingAlerter{}
+	bridge := &Bridge{subClient: tfchain, alerter: alerter, config: &pkg.BridgeConfig{ValidatorCheckInterval: 5 * time.Millisecond, ExitWhenNotValidator: true}}
+
+	stopped := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bridge.monitorValidator(context.Background(), func(err error) { stopped <- err })
+	}()
+
+	tfchain.setValidator(false)
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, pkg.ErrNotValidator) {
+			t.Errorf("expected the bridge to stop as not validator, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the bridge was not stopped after the account was removed")
+	}
+	<-done
+	if !tfchain.isPaused() {
+		t.Error("expected submissions to be paused")
+	}
+	if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, []string{alert.KindValidatorRemoved}) {
+		t.Errorf("expected the removal to be alerted, got %v", kinds)
+	}
+}
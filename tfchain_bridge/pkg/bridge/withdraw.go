@@ -2,54 +2,178 @@ package bridge
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/substrate-client"
 	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/accounting"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
 	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
 )
 
-func (bridge *Bridge) handleWithdrawCreated(ctx context.Context, withdraw subpkg.WithdrawCreatedEvent) error {
+// withdrawSignature is the signature of a withdraw payment that still has to be proposed on tfchain
+type withdrawSignature struct {
+	withdraw       subpkg.WithdrawCreatedEvent
+	signature      string
+	sequenceNumber uint64
+}
+
+// handleWithdrawCreatedEvents handles the withdraw created events of a block. The payments are signed one by
+// one in withdraw id order, so every validator reserves the same sequence number for the same withdraw, only
+// the proposals on tfchain are submitted by a bounded amount of workers. Results are evaluated in event order
+// so the returned error does not depend on scheduling
+func (bridge *Bridge) handleWithdrawCreatedEvents(ctx context.Context, events []subpkg.WithdrawCreatedEvent) error {
+	concurrency := bridge.config.WithdrawConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]error, len(events))
+	signatures := make([]*withdrawSignature, len(events))
+	for i, event := range events {
+		signatures[i], results[i] = bridge.signWithdraw(ctx, event)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, signature := range signatures {
+		if signature == nil {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, signature *withdrawSignature) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = bridge.proposeWithdraw(ctx, signature)
+		}(i, signature)
+	}
+	wg.Wait()
+
+	var failed []uint64
+	var firstErr error
+	for i, err := range results {
+		if err == nil {
+			continue
+		}
+		// If the TX is already withdrawn or refunded (minted on tfchain) skip
+		if errors.Is(err, pkg.ErrTransactionAlreadyBurned) || errors.Is(err, pkg.ErrTransactionAlreadyMinted) {
+			continue
+		}
+		err = errors.Wrapf(err, "withdraw %d of %d to %s", events[i].ID, events[i].Amount, events[i].Target)
+		log.Err(err).Uint64("ID", events[i].ID).Msg("failed to handle withdraw created")
+		failed = append(failed, events[i].ID)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		return errors.Wrapf(firstErr, "failed to handle withdraw created (%d failed: %v)", len(failed), failed)
+	}
+
+	return nil
+}
+
+// signWithdraw signs the payment of a withdraw, it returns no signature if the withdraw is handled otherwise
+func (bridge *Bridge) signWithdraw(ctx context.Context, withdraw subpkg.WithdrawCreatedEvent) (*withdrawSignature, error) {
 	burned, err := bridge.subClient.IsBurnedAlready(types.U64(withdraw.ID))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if burned {
 		log.Info().Uint64("ID", uint64(withdraw.ID)).Msgf("tx is burned already, skipping...")
-		return pkg.ErrTransactionAlreadyBurned
+		return nil, pkg.ErrTransactionAlreadyBurned
+	}
+
+	if signed, err := bridge.isWithdrawSigned(withdraw.ID); err != nil || signed {
+		return nil, err
 	}
 
-	if err := bridge.wallet.CheckAccount(withdraw.Target); err != nil {
-		return bridge.handleBadWithdraw(ctx, withdraw)
+	if !bridge.isWithdrawAllowed(withdraw.Target) {
+		log.Warn().Uint64("ID", withdraw.ID).Str("target", withdraw.Target).Msg("withdraw destination is not allowlisted")
+		bridge.alertWithdraw(ctx, withdraw.ID, withdraw.Target, withdraw.Amount, alert.KindWithdrawNotAllowed, "withdraw to a destination that is not allowlisted, minting it back")
+		return nil, bridge.handleBadWithdraw(ctx, withdraw)
 	}
 
-	signature, sequenceNumber, err := bridge.wallet.CreatePaymentAndReturnSignature(ctx, withdraw.Target, withdraw.Amount, withdraw.ID)
+	accountErr := bridge.wallet.CheckAccount(ctx, withdraw.Target)
+	if accountErr != nil {
+		if stellar.IsRetryableError(accountErr) {
+			return nil, accountErr
+		}
+		if errors.Is(accountErr, stellar.ErrMemoRequired) {
+			return nil, bridge.holdWithdraw(ctx, withdraw.ID, withdraw.Target, withdraw.Amount, alert.KindMemoRequired, "withdraw held for manual handling, its destination requires a memo")
+		}
+		if !bridge.isClaimable(accountErr) {
+			return nil, bridge.handleBadWithdraw(ctx, withdraw)
+		}
+	}
+
+	claimable, err := bridge.paymentKind(withdraw.ID, withdraw.Target, withdraw.Amount, accountErr)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	signature, sequenceNumber, err := bridge.wallet.CreatePaymentAndReturnSignature(ctx, withdraw.Target, withdraw.Amount, withdraw.ID, claimable)
+	if err != nil {
+		return nil, err
 	}
 	log.Debug().Msgf("stellar account sequence number: %d", sequenceNumber)
 
-	return bridge.subClient.RetryProposeWithdrawOrAddSig(ctx, withdraw.ID, withdraw.Target, big.NewInt(int64(withdraw.Amount)), signature, bridge.wallet.GetKeypair().Address(), sequenceNumber)
+	return &withdrawSignature{withdraw: withdraw, signature: signature, sequenceNumber: sequenceNumber}, nil
+}
+
+// proposeWithdraw proposes the signed payment of a withdraw on tfchain or adds the signature to it
+func (bridge *Bridge) proposeWithdraw(ctx context.Context, signature *withdrawSignature) error {
+	withdraw := signature.withdraw
+	return bridge.subClient.RetryProposeWithdrawOrAddSig(ctx, withdraw.ID, withdraw.Target, big.NewInt(int64(withdraw.Amount)), signature.signature, bridge.wallet.GetAddress(), signature.sequenceNumber)
 }
 
 func (bridge *Bridge) handleWithdrawExpired(ctx context.Context, withdrawExpired subpkg.WithdrawExpiredEvent) error {
-	if err := bridge.wallet.CheckAccount(withdrawExpired.Target); err != nil {
-		log.Info().Uint64("ID", uint64(withdrawExpired.ID)).Msg("tx is an invalid burn transaction, setting burn as executed since we have no way to recover...")
-		return bridge.subClient.RetrySetWithdrawExecuted(ctx, withdrawExpired.ID)
+	if signed, err := bridge.isWithdrawSigned(withdrawExpired.ID); err != nil || signed {
+		return err
+	}
+
+	// the expired event does not carry the source of the burn, so it can not be minted back from here
+	if !bridge.isWithdrawAllowed(withdrawExpired.Target) {
+		return bridge.holdWithdraw(ctx, withdrawExpired.ID, withdrawExpired.Target, withdrawExpired.Amount, alert.KindWithdrawNotAllowed, "withdraw held for manual handling, its destination is not allowlisted")
+	}
+
+	accountErr := bridge.wallet.CheckAccount(ctx, withdrawExpired.Target)
+	if accountErr != nil {
+		if stellar.IsRetryableError(accountErr) {
+			return accountErr
+		}
+		if errors.Is(accountErr, stellar.ErrMemoRequired) {
+			return bridge.holdWithdraw(ctx, withdrawExpired.ID, withdrawExpired.Target, withdrawExpired.Amount, alert.KindMemoRequired, "withdraw held for manual handling, its destination requires a memo")
+		}
+		if !bridge.isClaimable(accountErr) {
+			log.Info().Uint64("ID", uint64(withdrawExpired.ID)).Msg("tx is an invalid burn transaction, setting burn as executed since we have no way to recover...")
+			return bridge.subClient.RetrySetWithdrawExecuted(ctx, withdrawExpired.ID)
+		}
+	}
+
+	claimable, err := bridge.paymentKind(withdrawExpired.ID, withdrawExpired.Target, withdrawExpired.Amount, accountErr)
+	if err != nil {
+		return err
 	}
 
-	signature, sequenceNumber, err := bridge.wallet.CreatePaymentAndReturnSignature(ctx, withdrawExpired.Target, withdrawExpired.Amount, withdrawExpired.ID)
+	signature, sequenceNumber, err := bridge.wallet.CreatePaymentAndReturnSignature(ctx, withdrawExpired.Target, withdrawExpired.Amount, withdrawExpired.ID, claimable)
 	if err != nil {
 		return err
 	}
 	log.Debug().Msgf("stellar account sequence number: %d", sequenceNumber)
 
-	return bridge.subClient.RetryProposeWithdrawOrAddSig(ctx, withdrawExpired.ID, withdrawExpired.Target, big.NewInt(int64(withdrawExpired.Amount)), signature, bridge.wallet.GetKeypair().Address(), sequenceNumber)
+	return bridge.subClient.RetryProposeWithdrawOrAddSig(ctx, withdrawExpired.ID, withdrawExpired.Target, big.NewInt(int64(withdrawExpired.Amount)), signature, bridge.wallet.GetAddress(), sequenceNumber)
 }
 
 func (bridge *Bridge) handleWithdrawReady(ctx context.Context, withdrawReady subpkg.WithdrawReadyEvent) error {
@@ -68,40 +192,239 @@ func (bridge *Bridge) handleWithdrawReady(ctx context.Context, withdrawReady sub
 		return err
 	}
 
+	// a withdraw below the signature quorum is not a failure, it is paid by the validator seeing it ready
+	// once enough signatures are collected or it expires and is signed again
 	if len(burnTx.Signatures) == 0 {
-		log.Info().Msg("found 0 signatures, aborting")
-		return pkg.ErrNoSignatures
+		log.Info().Uint64("ID", withdrawReady.ID).Msg("found 0 signatures, skipping")
+		return nil
+	}
+
+	if !bridge.wallet.HasSignatureQuorum(burnTx.Signatures) {
+		log.Info().Uint64("ID", withdrawReady.ID).Int("signatures", len(burnTx.Signatures)).Msg("signature weight is below the account threshold, skipping")
+		return nil
+	}
+
+	// the payment of a withdraw is deterministic, if it landed while its submission failed it must not be paid again
+	paymentHash, err := bridge.wallet.PaymentTransactionHash(burnTx.Target, uint64(burnTx.Amount), int64(burnTx.SequenceNumber), burnTx.Signatures)
+	if err != nil && !errors.Is(err, stellar.ErrStaleSignatures) {
+		return err
+	}
+	// without a hash the signatures match no payment that can be on the network, the sequence check handles them
+	if err == nil {
+		submitted, err := bridge.wallet.IsTransactionSubmitted(ctx, paymentHash)
+		if err != nil {
+			return err
+		}
+		if submitted {
+			log.Info().Uint64("ID", withdrawReady.ID).Str("hash", paymentHash).Msg("withdraw payment is on the stellar network already, setting it executed")
+			return bridge.subClient.RetrySetWithdrawExecuted(ctx, withdrawReady.ID)
+		}
+	}
+
+	// signatures can be collected from validators that do not check for a required memo
+	if err := bridge.wallet.CheckAccount(ctx, burnTx.Target); errors.Is(err, stellar.ErrMemoRequired) {
+		return bridge.holdWithdraw(ctx, withdrawReady.ID, burnTx.Target, uint64(burnTx.Amount), alert.KindMemoRequired, "withdraw held for manual handling, its destination requires a memo")
+	}
+
+	err = bridge.wallet.CheckPaymentSequence(burnTx.Target, uint64(burnTx.Amount), int64(burnTx.SequenceNumber), burnTx.Signatures)
+	if errors.Is(err, stellar.ErrStaleSignatures) {
+		return bridge.handleStaleWithdraw(withdrawReady.ID, uint64(burnTx.SequenceNumber))
+	}
+	if err != nil {
+		return err
+	}
+
+	if bridge.config.ObserverMode {
+		log.Info().Uint64("ID", withdrawReady.ID).Str("target", burnTx.Target).Uint64("amount", uint64(burnTx.Amount)).Msg("observer mode, not submitting withdraw payment")
+		return nil
 	}
 
 	// todo add memo hash
 	err = bridge.wallet.CreatePaymentWithSignaturesAndSubmit(ctx, burnTx.Target, uint64(burnTx.Amount), "", burnTx.Signatures, int64(burnTx.SequenceNumber))
+	if errors.Is(err, stellar.ErrNotEnoughSignatureWeight) {
+		// the burn transaction expires and is signed again by the validators
+		log.Warn().Uint64("ID", withdrawReady.ID).Msg("valid signature weight is below the account threshold, not submitting")
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
+	bridge.recordAccounting(accounting.Entry{
+		Kind:          accounting.KindWithdraw,
+		Amount:        uint64(burnTx.Amount),
+		Destination:   burnTx.Target,
+		StellarTxHash: paymentHash,
+		TfchainTxID:   strconv.FormatUint(withdrawReady.ID, 10),
+	})
+
 	return bridge.subClient.RetrySetWithdrawExecuted(ctx, withdrawReady.ID)
 }
 
+// isWithdrawSigned checks if we signed the withdraw already, an event delivered again after a restart must not
+// sign it again as that reserves another stellar sequence number and the signature is rejected on chain
+func (bridge *Bridge) isWithdrawSigned(id uint64) (bool, error) {
+	signed, err := bridge.subClient.HasWithdrawSignature(id, bridge.wallet.GetAddress())
+	if err != nil {
+		return false, err
+	}
+	if signed {
+		log.Info().Uint64("ID", id).Msg("withdraw is signed by us already, skipping...")
+	}
+	return signed, nil
+}
+
+// isClaimable checks if a withdraw to a destination that failed the account check with err is paid with a claimable
+// balance, the destination can claim it once it exists and holds a trustline
+func (bridge *Bridge) isClaimable(err error) bool {
+	if !bridge.config.StellarClaimableBalances {
+		return false
+	}
+	return errors.Is(err, stellar.ErrAccountNotFound) || errors.Is(err, stellar.ErrNoTrustline)
+}
+
+// paymentKind returns whether the withdraw with id is paid with a claimable balance. All validators must sign the same
+// envelope, so the first signer decides: once the burn transaction has a signature the kind it signs is used, even if
+// the destination was created or got a trustline since. Only the first signer, or a validator signing after the
+// signatures were reset, decides from the account check that failed with accountErr.
+func (bridge *Bridge) paymentKind(id uint64, target string, amount uint64, accountErr error) (bool, error) {
+	if !bridge.config.StellarClaimableBalances {
+		return false, nil
+	}
+
+	burnTx, err := bridge.subClient.GetBurnTransaction(types.U64(id))
+	if err != nil && !errors.Is(err, substrate.ErrBurnTransactionNotFound) {
+		return false, err
+	}
+	if err == nil && len(burnTx.Signatures) > 0 {
+		claimable, err := bridge.wallet.SignsClaimableBalance(target, amount, int64(burnTx.SequenceNumber), burnTx.Signatures[:1])
+		if err == nil {
+			return claimable, nil
+		}
+		if !errors.Is(err, stellar.ErrStaleSignatures) {
+			return false, err
+		}
+		// stale signatures are reset and signed again, there is no kind to agree on
+	}
+
+	if accountErr != nil {
+		log.Info().Uint64("ID", id).Str("target", target).Err(accountErr).Msg("destination can not receive the payment, paying it with a claimable balance")
+	}
+	return accountErr != nil, nil
+}
+
+// handleStaleWithdraw handles a burn transaction whose signatures were collected for a stellar sequence number that
+// can no longer be used, for example because another payment of the bridge account consumed it. Our sequence number
+// is resynced and the signatures are reset on chain, which expires the burn transaction so all validators sign it
+// again with a fresh sequence number. Validators detecting the same stale sequence number all reset it, the runtime
+// only resets the signatures once. Without a reset call in the runtime the burn transaction expires on its own.
+func (bridge *Bridge) handleStaleWithdraw(id uint64, sequenceNumber uint64) error {
+	log.Warn().Uint64("ID", id).Uint64("sequence", sequenceNumber).Msg("burn signatures are stale, not submitting and resetting them")
+	staleWithdraws.Inc()
+	if err := bridge.wallet.ResetAccountSequence(); err != nil {
+		return err
+	}
+
+	if bridge.config.ObserverMode {
+		return nil
+	}
+
+	err := bridge.subClient.ResetWithdraw(id, sequenceNumber)
+	if errors.Is(err, subpkg.ErrCallNotSupported) {
+		log.Info().Uint64("ID", id).Msg("runtime can not reset burn transactions, waiting for the burn transaction to expire")
+		return nil
+	}
+	if err != nil {
+		// the burn transaction still expires after the retry interval
+		log.Err(err).Uint64("ID", id).Msg("failed to reset stale burn transaction, waiting for it to expire")
+	}
+
+	return nil
+}
+
+// holdWithdraw parks a withdraw that can not be paid automatically, for example because its destination
+// requires a memo and burns carry none. The withdraw is not signed and operators are alerted to handle it manually.
+func (bridge *Bridge) holdWithdraw(ctx context.Context, id uint64, target string, amount uint64, reason string, message string) error {
+	held, err := bridge.blockPersistency.HoldWithdraw(pkg.HeldWithdraw{
+		ID:     id,
+		Target: target,
+		Amount: amount,
+		Reason: reason,
+		HeldAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		log.Debug().Uint64("ID", id).Msg("withdraw is held already, skipping...")
+		return nil
+	}
+
+	log.Warn().Uint64("ID", id).Str("target", target).Str("reason", reason).Msg("holding withdraw for manual handling")
+	bridge.alertWithdraw(ctx, id, target, amount, reason, message)
+	return nil
+}
+
+func (bridge *Bridge) alertWithdraw(ctx context.Context, id uint64, target string, amount uint64, kind string, message string) {
+	err := bridge.alerter.Alert(ctx, alert.Alert{
+		Kind:    kind,
+		Message: message,
+		Fields: map[string]string{
+			"id":     fmt.Sprint(id),
+			"target": target,
+			"amount": fmt.Sprint(amount),
+		},
+	})
+	if err != nil {
+		log.Err(err).Msg("failed to send alert")
+	}
+}
+
+// isWithdrawAllowed checks a withdraw target against the configured allowlist, a muxed target is
+// allowed if its own address or the address of its underlying account is listed
+func (bridge *Bridge) isWithdrawAllowed(target string) bool {
+	if len(bridge.config.WithdrawDestinationAllowlist) == 0 {
+		return true
+	}
+
+	base, _, err := stellar.ParseAccountAddress(target)
+	for _, allowed := range bridge.config.WithdrawDestinationAllowlist {
+		if allowed == target || (err == nil && allowed == base) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBadWithdraw mints the amount of an invalid burn back to its source and sets the burn as executed.
+// Both steps are idempotent on chain, the remint is looked up by its id, so when the bridge stops between
+// them the remint is skipped on the next run and the burn is still set as executed.
 func (bridge *Bridge) handleBadWithdraw(ctx context.Context, withdraw subpkg.WithdrawCreatedEvent) error {
 	log.Info().Uint64("ID", uint64(withdraw.ID)).Msg("tx is an invalid burn transaction, minting on chain again...")
 	mintID := fmt.Sprintf("refund-%d", withdraw.ID)
 
-	minted, err := bridge.subClient.IsMintedAlready(mintID)
+	minted, err := bridge.subClient.CheckMinted(ctx, mintID)
 	if err != nil {
-		if !errors.Is(err, substrate.ErrMintTransactionNotFound) {
-			return err
-		}
+		return err
 	}
 
 	if minted {
-		log.Debug().Str("txHash", mintID).Msg("transaction is already minted")
-		return pkg.ErrTransactionAlreadyMinted
-	}
+		log.Info().Str("mintID", mintID).Msg("invalid burn transaction is already minted, resuming from setting it as executed")
+	} else {
+		log.Info().Str("mintID", mintID).Msg("going to propose mint transaction")
+		err = bridge.proposeOrVoteMint(ctx, mintID, substrate.AccountID(withdraw.Source), big.NewInt(int64(withdraw.Amount)))
+		if err != nil {
+			return err
+		}
 
-	log.Info().Str("mintID", mintID).Msg("going to propose mint transaction")
-	err = bridge.subClient.RetryProposeMintOrVote(ctx, mintID, substrate.AccountID(withdraw.Source), big.NewInt(int64(withdraw.Amount)))
-	if err != nil {
-		return err
+		bridge.recordAccounting(accounting.Entry{
+			Kind:        accounting.KindRemint,
+			Amount:      withdraw.Amount,
+			Source:      withdraw.Target,
+			Destination: substrate.AccountID(withdraw.Source).String(),
+			TfchainTxID: mintID,
+		})
 	}
 
 	log.Info().Uint64("ID", uint64(withdraw.ID)).Msg("setting invalid burn transaction as executed")
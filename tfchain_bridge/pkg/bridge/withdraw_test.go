@@ -0,0 +1,511 @@
+package bridge
+
+import (
+	"context"
+	"encoding/base64"
+	"math/big"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/alert"
+	"github.com/threefoldtech/tfchain_bridge/pkg/stellar"
+	subpkg "github.com/threefoldtech/tfchain_bridge/pkg/substrate"
+)
+
+// unpaidTfchain has no burn transaction executed, any extrinsic fails the test by panicking
+type unpaidTfchain struct {
+	tfchainClient
+}
+
+// handleWithdraw signs a single withdraw and proposes its payment like handleWithdrawCreatedEvents does
+func handleWithdraw(bridge *Bridge, withdraw subpkg.WithdrawCreatedEvent) error {
+	signature, err := bridge.signWithdraw(context.Background(), withdraw)
+	if err != nil || signature == nil {
+		return err
+	}
+	return bridge.proposeWithdraw(context.Background(), signature)
+}
+
+func (f *unpaidTfchain) IsBurnedAlready(id types.U64) (bool, error) {
+	return false, nil
+}
+
+func (f *unpaidTfchain) HasWithdrawSignature(id uint64, stellarAddress string) (bool, error) {
+	return false, nil
+}
+
+// memoRequiredWallet has destinations that all require a memo
+type memoRequiredWallet struct {
+	stellarWallet
+}
+
+func (w *memoRequiredWallet) GetAddress() string { return "bridge" }
+
+func (w *memoRequiredWallet) CheckAccount(ctx context.Context, account string) error {
+	return stellar.ErrMemoRequired
+}
+
+func TestWithdrawToMemoRequiredDestinationIsHeld(t *testing.T) {
+	const target = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+
+	persistency, err := pkg.InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := &recordingAlerter{}
+	bridge := &Bridge{
+		subClient:        &unpaidTfchain{},
+		wallet:           &memoRequiredWallet{},
+		blockPersistency: persistency,
+		config:           &pkg.BridgeConfig{},
+		alerter:          alerter,
+	}
+
+	// the withdraw is created and expires, it is held and alerted once and never signed
+	if err := handleWithdraw(bridge, subpkg.WithdrawCreatedEvent{ID: 1, Target: target, Amount: 50000000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.handleWithdrawExpired(context.Background(), subpkg.WithdrawExpiredEvent{ID: 1, Target: target, Amount: 50000000}); err != nil {
+		t.Fatal(err)
+	}
+
+	blockheight, err := persistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blockheight.HeldWithdraws) != 1 {
+		t.Fatalf("expected the withdraw to be held once, got %+v", blockheight.HeldWithdraws)
+	}
+	held := blockheight.HeldWithdraws[0]
+	if held.ID != 1 || held.Target != target || held.Amount != 50000000 || held.Reason != alert.KindMemoRequired {
+		t.Errorf("unexpected held withdraw %+v", held)
+	}
+	if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, []string{alert.KindMemoRequired}) {
+		t.Errorf("expected a single memo required alert, got %v", kinds)
+	}
+}
+
+// remintTfchain records the remints and executed burns, the bridge crashes on setting a burn executed while crashed is set
+type remintTfchain struct {
+	mintTfchain
+	executed []uint64
+	crashed  bool
+}
+
+func (f *remintTfchain) HasWithdrawSignature(id uint64, stellarAddress string) (bool, error) {
+	return false, nil
+}
+
+func (f *remintTfchain) IsBurnedAlready(id types.U64) (bool, error) {
+	for _, executed := range f.executed {
+		if executed == uint64(id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *remintTfchain) RetrySetWithdrawExecuted(ctx context.Context, txID uint64) error {
+	if f.crashed {
+		return errors.New("bridge stopped")
+	}
+	f.executed = append(f.executed, txID)
+	return nil
+}
+
+// invalidTargetWallet rejects every destination
+type invalidTargetWallet struct {
+	stellarWallet
+}
+
+func (w *invalidTargetWallet) GetAddress() string { return "bridge" }
+
+func (w *invalidTargetWallet) CheckAccount(ctx context.Context, account string) error {
+	return errors.New("invalid account")
+}
+
+func TestInvalidWithdrawResumesAfterRemint(t *testing.T) {
+	source, err := substrate.FromAddress("5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &remintTfchain{crashed: true}
+	bridge := &Bridge{
+		subClient: chain,
+		wallet:    &invalidTargetWallet{},
+		config:    &pkg.BridgeConfig{},
+	}
+	withdraw := subpkg.WithdrawCreatedEvent{ID: 1, Source: types.AccountID(source), Target: "not_an_address", Amount: 50000000}
+
+	// the bridge stops after the remint, before the burn is set as executed
+	if err := handleWithdraw(bridge, withdraw); err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	if !reflect.DeepEqual(chain.minted, []string{"refund-1"}) || len(chain.executed) != 0 {
+		t.Fatalf("expected a remint and no executed burn, got %v and %v", chain.minted, chain.executed)
+	}
+
+	// on restart the remint is skipped and the burn is set as executed
+	chain.crashed = false
+	if err := handleWithdraw(bridge, withdraw); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(chain.minted, []string{"refund-1"}) {
+		t.Errorf("expected the burn to be reminted once, got %v", chain.minted)
+	}
+	if !reflect.DeepEqual(chain.executed, []uint64{1}) {
+		t.Errorf("expected the burn to be set as executed, got %v", chain.executed)
+	}
+
+	// a replay of the event is a no-op
+	if err := handleWithdraw(bridge, withdraw); !errors.Is(err, pkg.ErrTransactionAlreadyBurned) {
+		t.Errorf("expected the burn to be already executed, got %v", err)
+	}
+}
+
+// signingTfchain records the proposed withdraws
+type signingTfchain struct {
+	remintTfchain
+	proposed []uint64
+}
+
+func (f *signingTfchain) RetryProposeWithdrawOrAddSig(ctx context.Context, txID uint64, target string, amount *big.Int, signature string, stellarAddress string, sequenceNumber uint64) error {
+	f.proposed = append(f.proposed, txID)
+	return nil
+}
+
+// signingWallet signs payments to any destination
+type signingWallet struct {
+	stellarWallet
+	keypair *keypair.Full
+}
+
+func (w *signingWallet) GetAddress() string {
+	return w.keypair.Address()
+}
+
+func (w *signingWallet) CheckAccount(ctx context.Context, account string) error {
+	return nil
+}
+
+func (w *signingWallet) CreatePaymentAndReturnSignature(ctx context.Context, target string, amount uint64, txID uint64, claimable bool) (string, uint64, error) {
+	return "signature", 1, nil
+}
+
+func TestWithdrawDestinationAllowlist(t *testing.T) {
+	const (
+		allowed = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		muxed   = "MBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU2AAAAAAAAAAAA7LCE"
+		blocked = "GDCAMOLMOTTIKJ6MRQ4WPXIUBWEV4CZS7QNVDNO65XKYOOEPYV5NZGDG"
+	)
+	source, err := substrate.FromAddress("5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		target    string
+		expired   bool
+		signed    bool
+		reminted  bool
+		held      bool
+	}{
+		{name: "no allowlist", target: blocked, signed: true},
+		{name: "allowlisted", allowlist: []string{allowed}, target: allowed, signed: true},
+		{name: "muxed account of an allowlisted account", allowlist: []string{allowed}, target: muxed, signed: true},
+		{name: "blocked", allowlist: []string{allowed}, target: blocked, reminted: true},
+		{name: "expired allowlisted", allowlist: []string{allowed}, target: allowed, expired: true, signed: true},
+		{name: "expired blocked", allowlist: []string{allowed}, target: blocked, expired: true, held: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			persistency, err := pkg.InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain := &signingTfchain{}
+			alerter := &recordingAlerter{}
+			bridge := &Bridge{
+				subClient:        chain,
+				wallet:           &signingWallet{keypair: keypair.MustRandom()},
+				blockPersistency: persistency,
+				config:           &pkg.BridgeConfig{WithdrawDestinationAllowlist: test.allowlist},
+				alerter:          alerter,
+			}
+
+			if test.expired {
+				err = bridge.handleWithdrawExpired(context.Background(), subpkg.WithdrawExpiredEvent{ID: 1, Target: test.target, Amount: 50000000})
+			} else {
+				err = handleWithdraw(bridge, subpkg.WithdrawCreatedEvent{ID: 1, Source: types.AccountID(source), Target: test.target, Amount: 50000000})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if signed := len(chain.proposed) == 1; signed != test.signed {
+				t.Errorf("expected signed %t, got proposed withdraws %v", test.signed, chain.proposed)
+			}
+			if reminted := reflect.DeepEqual(chain.minted, []string{"refund-1"}) && reflect.DeepEqual(chain.executed, []uint64{1}); reminted != test.reminted {
+				t.Errorf("expected reminted %t, got mints %v and executed burns %v", test.reminted, chain.minted, chain.executed)
+			}
+			blockheight, err := persistency.GetHeight()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if held := len(blockheight.HeldWithdraws) == 1; held != test.held {
+				t.Errorf("expected held %t, got %+v", test.held, blockheight.HeldWithdraws)
+			}
+			var alerts []string
+			if test.reminted || test.held {
+				alerts = []string{alert.KindWithdrawNotAllowed}
+			}
+			if kinds := alerter.kinds(); !reflect.DeepEqual(kinds, alerts) {
+				t.Errorf("expected alerts %v, got %v", alerts, kinds)
+			}
+		})
+	}
+}
+
+func TestWithdrawPaidAlready(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	signatures := []substrate.StellarSignature{{Signature: []byte("signature"), StellarAddress: []byte("validator")}}
+	tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: signatures}
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	// the payment landed on the stellar network while its submission timed out
+	wallet.submitted[fakePaymentHash(testSender, 500000000, 101)] = true
+
+	err := bridge.dispatchTfchainEvents(testContext(t), bridge.events, subpkg.Events{
+		WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 7}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertCalls(t, []string{"SetWithdrawExecuted 7"}, calls.get())
+}
+
+func TestWithdrawStaleSequence(t *testing.T) {
+	calls := &callLog{}
+	tfchain := newFakeTfchain(calls)
+	signatures := []substrate.StellarSignature{{Signature: []byte("signature"), StellarAddress: []byte("validator")}}
+	tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: signatures}
+	wallet := newFakeWallet(calls, 100)
+	bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+	// another payment of the bridge account used sequence number 101 while the signatures were collected
+	wallet.consumed = 101
+
+	err := bridge.dispatchTfchainEvents(testContext(t), bridge.events, subpkg.Events{
+		WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 7}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the payment is not submitted and the signatures are reset so the withdraw is signed again
+	assertCalls(t, []string{"ResetWithdraw 7 101"}, calls.get())
+	if signatures := tfchain.burns[7].Signatures; len(signatures) != 0 {
+		t.Errorf("expected the stale signatures to be reset, got %d signatures", len(signatures))
+	}
+}
+
+func TestWithdrawBelowQuorumIsSkipped(t *testing.T) {
+	signature := substrate.StellarSignature{Signature: []byte("signature"), StellarAddress: []byte("validator")}
+	tests := []struct {
+		name       string
+		signatures []substrate.StellarSignature
+		quorum     int
+		submitErr  error
+		calls      []string
+	}{
+		{name: "no signatures"},
+		{name: "below the quorum", signatures: []substrate.StellarSignature{signature}, quorum: 2},
+		{
+			name:       "valid weight below the threshold",
+			signatures: []substrate.StellarSignature{signature},
+			submitErr:  stellar.ErrNotEnoughSignatureWeight,
+			calls:      []string{"SubmitPayment " + testSender + " 500000000 seq=101"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := newFakeTfchain(calls)
+			tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: test.signatures}
+			wallet := newFakeWallet(calls, 100)
+			wallet.signatureCount = test.quorum
+			wallet.submitErr = test.submitErr
+			bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+			err := bridge.dispatchTfchainEvents(testContext(t), bridge.events, subpkg.Events{
+				WithdrawReadyEvents: []subpkg.WithdrawReadyEvent{{ID: 7}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the withdraw waits for more signatures, it is neither dead lettered nor alerted
+			assertCalls(t, test.calls, calls.get())
+			assertCalls(t, nil, bridge.alerter.(*recordingAlerter).kinds())
+		})
+	}
+}
+
+func TestWithdrawSignedAlready(t *testing.T) {
+	tests := []struct {
+		name   string
+		signer func(wallet *fakeWallet) string
+		calls  []string
+	}{
+		{
+			name:   "signed by us",
+			signer: func(wallet *fakeWallet) string { return wallet.GetAddress() },
+		},
+		{
+			name:   "signed by another validator",
+			signer: func(wallet *fakeWallet) string { return "validator" },
+			calls:  []string{"ProposeWithdrawOrAddSig 7 " + testSender + " 500000000 seq=101"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := &callLog{}
+			tfchain := newFakeTfchain(calls)
+			wallet := newFakeWallet(calls, 100)
+			// the withdraw was signed before the node restarted and its created event is delivered again
+			signatures := []substrate.StellarSignature{{Signature: []byte("signature"), StellarAddress: []byte(test.signer(wallet))}}
+			tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: signatures}
+			bridge := newTestBridge(t, pkg.BridgeConfig{}, tfchain, wallet, 10000000)
+
+			err := bridge.dispatchTfchainEvents(testContext(t), bridge.events, subpkg.Events{
+				WithdrawCreatedEvents: []subpkg.WithdrawCreatedEvent{{ID: 7, Target: testSender, Amount: 500000000}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertCalls(t, test.calls, calls.get())
+		})
+	}
+}
+
+func TestWithdrawPaymentKindFollowsFirstSigner(t *testing.T) {
+	plain := base64.StdEncoding.EncodeToString([]byte("validator:" + testSender + ":500000000:101"))
+	claimable := base64.StdEncoding.EncodeToString([]byte("validator:" + testSender + ":500000000:101" + fakeClaimableSuffix))
+
+	tests := []struct {
+		name       string
+		first      string
+		accountErr error
+		expired    bool
+		claimable  bool
+	}{
+		{name: "first signer of an existing destination", claimable: false},
+		{name: "first signer of a missing destination", accountErr: stellar.ErrAccountNotFound, claimable: true},
+		{name: "first signer of a destination without trustline", accountErr: stellar.ErrNoTrustline, claimable: true},
+		{name: "destination created after a claimable signature", first: claimable, claimable: true},
+		{name: "destination lost after a plain signature", first: plain, accountErr: stellar.ErrAccountNotFound, claimable: false},
+		{name: "expired withdraw signed claimable", first: claimable, expired: true, claimable: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tfchain := newFakeTfchain(&callLog{})
+			wallet := newFakeWallet(&callLog{}, 100)
+			wallet.accountErr = test.accountErr
+			if test.first != "" {
+				signatures := []substrate.StellarSignature{{Signature: []byte(test.first), StellarAddress: []byte("validator")}}
+				tfchain.burns[7] = &substrate.BurnTransaction{Target: testSender, Amount: 500000000, SequenceNumber: 101, Signatures: signatures}
+			}
+			cfg := pkg.BridgeConfig{}
+			cfg.StellarClaimableBalances = true
+			bridge := newTestBridge(t, cfg, tfchain, wallet, 10000000)
+
+			var err error
+			if test.expired {
+				err = bridge.handleWithdrawExpired(context.Background(), subpkg.WithdrawExpiredEvent{ID: 7, Target: testSender, Amount: 500000000})
+			} else {
+				err = handleWithdraw(bridge, subpkg.WithdrawCreatedEvent{ID: 7, Target: testSender, Amount: 500000000})
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signatures := tfchain.burns[7].Signatures
+			signature := signatures[len(signatures)-1]
+			if string(signature.StellarAddress) != wallet.GetAddress() {
+				t.Fatalf("expected the withdraw to be signed by us, got signatures %+v", signatures)
+			}
+			if got := isFakeClaimableSignature(string(signature.Signature)); got != test.claimable {
+				t.Errorf("expected claimable %t, got %t", test.claimable, got)
+			}
+		})
+	}
+}
+
+// randomDelay sleeps up to a millisecond so concurrent handlers interleave differently on every run
+func randomDelay() {
+	time.Sleep(time.Duration(rand.Int63n(int64(time.Millisecond))))
+}
+
+func TestWithdrawSequenceNumbersAgreeAcrossValidators(t *testing.T) {
+	const (
+		validators = 3
+		withdraws  = 10
+		sequence   = 100
+	)
+
+	var created []subpkg.WithdrawCreatedEvent
+	for id := uint64(1); id <= withdraws; id++ {
+		created = append(created, subpkg.WithdrawCreatedEvent{
+			ID:     id,
+			Target: "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV",
+			Amount: 100000000 * id,
+		})
+	}
+
+	sequences := make([]map[uint64]uint64, validators)
+	for v := 0; v < validators; v++ {
+		tfchain := newFakeTfchain(&callLog{})
+		tfchain.delay = randomDelay
+		wallet := newFakeWallet(&callLog{}, sequence)
+		wallet.delay = randomDelay
+		bridge := newTestBridge(t, pkg.BridgeConfig{WithdrawConcurrency: 4}, tfchain, wallet, 0)
+
+		// every validator receives the events of the block in another order
+		events := subpkg.Events{WithdrawCreatedEvents: append([]subpkg.WithdrawCreatedEvent(nil), created...)}
+		rand.Shuffle(len(events.WithdrawCreatedEvents), func(i, j int) {
+			events.WithdrawCreatedEvents[i], events.WithdrawCreatedEvents[j] = events.WithdrawCreatedEvents[j], events.WithdrawCreatedEvents[i]
+		})
+		events.Sort()
+		if err := bridge.dispatchTfchainEvents(testContext(t), bridge.events, events); err != nil {
+			t.Fatalf("validator %d: %s", v, err)
+		}
+
+		sequences[v] = make(map[uint64]uint64)
+		for id, burn := range tfchain.burns {
+			sequences[v][id] = uint64(burn.SequenceNumber)
+		}
+	}
+
+	for id := uint64(1); id <= withdraws; id++ {
+		expected := sequence + id
+		for v := 0; v < validators; v++ {
+			if got, ok := sequences[v][id]; !ok || got != expected {
+				t.Errorf("validator %d signed withdraw %d with sequence %d, expected %d", v, id, got, expected)
+			}
+		}
+	}
+}
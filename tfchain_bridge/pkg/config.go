@@ -1,12 +1,135 @@
 package pkg
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 type BridgeConfig struct {
 	TfchainURL          string
 	TfchainSeed         string
 	RescanBridgeAccount bool
 	PersistencyFile     string
+	// tfchain urls the bridge fails over to in order when the subscription to the active endpoint fails
+	TfchainStandbyURLs []string
+	// amount of stellar transactions fetched and checked for being minted at the same time during a rescan, deposits are
+	// still minted and the cursor still saved in paging order
+	RescanConcurrency int
+	// file the mints, withdraws and refunds of the bridge are appended to for audits, no ledger is kept when empty
+	AccountingLedger string
+	// chain the entries of the accounting ledger with their hashes so changes to the ledger are detected
+	AccountingHashChain bool
+	// level and output format of the logs
+	LogLevel  string
+	LogFormat string
+	// tip paid for the bridge extrinsics to prioritize them during congestion
+	TfchainTip uint64
+	// amount of blocks the bridge extrinsics are valid for, 0 submits immortal extrinsics
+	TfchainMortality uint64
+	// track the tfchain account nonce locally instead of fetching it for every extrinsic
+	TfchainLocalNonces bool
+	// url of a remote signing service holding the tfchain key, the TfchainSeed is not used when set
+	TfchainSignerURL string
+	// tfchain address of the key held by the remote signer
+	TfchainSignerAddress string
+	// seed of the account that signs the bridge extrinsics and pays their fees through the proxy pallet,
+	// the validator account must have added it as a proxy. Empty pays the fees from the validator account
+	TfchainProxySeed string
+	// how long extrinsic submissions wait before they are retried once the account can not pay the fees
+	TfchainFeeRetryInterval time.Duration
+	// persist fetched mint events until they are processed so they are handled first after a restart
+	PersistPendingMints bool
+	// amount of fetched stellar transactions buffered until they are processed, fetching pauses while the buffer is full
+	StellarEventBuffer int
+	// what to do with malformed tfchain events, skip (record and alert) or fail
+	MalformedEventPolicy string
+	// amount of withdraw created events of a block that are handled concurrently
+	WithdrawConcurrency int
+	// address the admin http server listens on, empty disables it
+	AdminAddress string
+	// bearer token required by the pending transactions api of the admin server, empty disables the api
+	AdminToken string
+	// what to do with a refund the bridge account can not pay without going below its minimum balance, hold or submit
+	RefundReservePolicy string
+	// what to do with deposits that do not cover the deposit fee, refund, absorb or ignore
+	BelowFeePolicy string
+	// stellar account below fee deposits are sent to with the absorb policy
+	FeeCollectionAccount string
+	// how long the addresses resolved from deposit memos are cached, 0 disables the cache
+	MemoCacheTTL time.Duration
+	// XLM balance in stroops of the bridge account below which operators are alerted, 0 disables the alert
+	LowBalanceThreshold int64
+	// interval of the bridge account balance check
+	BalanceCheckInterval time.Duration
+	// track bridge events and export metrics without submitting extrinsics or stellar payments,
+	// the tfchain account does not have to be a validator
+	ObserverMode bool
+	// consecutive failures of an event type after which its processing is paused, 0 disables the circuit breakers
+	BreakerThreshold int
+	// how long the processing of an event type is paused once its circuit breaker opens
+	BreakerCooldown time.Duration
+	// time a single attempt to handle events can take before it is aborted and retried, 0 means no timeout
+	EventTimeout time.Duration
+	// interval at which the tfchain account is checked to still be a bridge validator
+	ValidatorCheckInterval time.Duration
+	// stop the bridge instead of pausing extrinsic submissions when the account is no longer a validator
+	ExitWhenNotValidator bool
+	// interval of the stellar cursor reconciliation, a jitter of up to half the interval is added
+	CursorReconcileInterval time.Duration
+	// the stellar cursor is checkpointed after this amount of scanned transactions that did not save it, 0 disables the count
+	CursorCheckpointCount int
+	// the stellar cursor is checkpointed when it was not saved for this long while transactions are scanned, 0 disables the interval
+	CursorCheckpointInterval time.Duration
+	// time the bridge gets to stop after a shutdown signal before the process is forced to exit, 0 waits forever
+	ShutdownGracePeriod time.Duration
+	// lowest memo text version deposits are minted for, deposits with an older memo are refunded
+	MinMemoVersion int
+	// accept deposit memo texts with a free-form note after the routing part, the note is logged and recorded but not routed on
+	MemoNotes bool
+	// trim the whitespace around deposit memo texts and lowercase their prefix before parsing them, the id is parsed strictly
+	LenientMemos bool
+	// mint deposits with a hash memo to the account whose public key the hash carries, hash memos are refunded otherwise
+	HashMemos bool
+	// deposits that closed longer ago are skipped unless the bridge account is rescanned, 0 disables the check
+	IgnoreDepositsOlderThan time.Duration
+	// grid object types deposit memos can mint to, deposits to other types are refunded. Empty allows all types
+	AllowedMemoTypes []string
+	// account a node memo mints to, the twin of the node or the twin of its farm
+	NodeMintTarget string
+	// role of the validator in mints, propose and vote or only vote on mints proposed by other validators
+	MintRole string
+	// time a withdraw can wait for validator signatures before an alert is raised, 0 disables the alert
+	SignatureTimeout time.Duration
+	// highest amount the bridge refunds, larger refunds are refused and alerted. 0 means no limit
+	MaxRefundAmount int64
+	// what to do with payments of other assets than the bridged asset, ignore, alert or refund (held for a manual refund)
+	UnsupportedAssetPolicy string
+	// interval at which the bridge account signers are compared with the validators on chain, 0 disables the check
+	SignerCheckInterval time.Duration
+	// admin server urls of the other validators, their configuration fingerprint is compared with ours on startup
+	PeerAdminURLs []string
+	// refuse to start when the configuration diverges from the majority of the reachable peers instead of only alerting
+	PeerCheckHalt bool
+	// stellar addresses withdraws can be paid to, other withdraws are minted back. Empty allows all destinations
+	WithdrawDestinationAllowlist []string
+	// highest deposit fee read from chain the bridge starts with, 0 means no limit
+	MaxDepositFee int64
+	// maximum amount that can be minted to a single target per UTC day, 0 means no limit
+	DailyMintLimit int64
+	// sliding window in which the deposits of a single source are counted, 0 disables the burst quarantine
+	DepositBurstWindow time.Duration
+	// amount of deposits of a single source within the window above which deposits are quarantined, 0 means no limit
+	DepositBurstCount int
+	// cumulative amount deposited by a single source within the window above which deposits are quarantined, 0 means no limit
+	DepositBurstAmount int64
+	// window in which identical alerts are grouped, 0 disables grouping
+	AlertDedupWindow time.Duration
+	// grouping window per alert kind, overrides AlertDedupWindow
+	AlertDedupWindows map[string]time.Duration
+	// alerts are always logged and also delivered to every configured notifier
+	AlertWebhookURL          string
+	AlertSlackWebhookURL     string
+	AlertPagerDutyRoutingKey string
 	StellarConfig
 }
 
@@ -19,6 +142,190 @@ type StellarConfig struct {
 	StellarSeed string
 	// url for stellar horizon
 	StellarHorizonUrl string
+	// horizon urls the bridge fails over to in order when the active horizon keeps failing
+	StellarHorizonStandbyUrls []string
+	// passphrase of the stellar network, overrides the passphrase of StellarNetwork when set
+	StellarNetworkPassphrase string
+	// code and issuer of the bridged asset, TFT of StellarNetwork is bridged when not set
+	StellarAssetCode   string
+	StellarAssetIssuer string
+	// timeout of a single horizon request, 0 means no timeout
+	HorizonTimeout time.Duration
+	// amount of times a horizon request failing with a retryable error is retried
+	HorizonMaxRetries int
+	// base fee in stroops of the bridge payments, must be the same for all validators as it is part of the signed payment
+	StellarBaseFee int64
+	// highest base fee in stroops a payment rejected for an insufficient fee is fee bumped to, 0 disables fee bumps
+	StellarMaxFee int64
+	// percentile of the recent network fees the first fee bump of a rejected payment pays, 0 doubles the base fee instead
+	StellarFeePercentile int
+	// window the time bounds of withdraw payments are aligned on, signatures stay valid for one to two windows. It must be
+	// the same for all validators as it is part of the signed payment, 0 means withdraw payments do not expire
+	StellarPaymentTimeout time.Duration
+	// pay withdraws to destinations that do not exist or have no trustline with a claimable balance instead of minting them back.
+	// It must be the same for all validators as only validators with the option enabled sign a claimable balance
+	StellarClaimableBalances bool
+	// url of a remote signing service holding the bridge key, the StellarSeed is not used when set
+	StellarSignerURL string
+	// public address of the key held by the remote signer
+	StellarSignerAddress string
+}
+
+// TfchainURLs returns the tfchain endpoints in order of preference
+func (c BridgeConfig) TfchainURLs() []string {
+	return append([]string{c.TfchainURL}, c.TfchainStandbyURLs...)
+}
+
+// memo types, the grid objects a deposit memo can refer to
+const (
+	MemoTypeTwin   = "twin"
+	MemoTypeFarm   = "farm"
+	MemoTypeNode   = "node"
+	MemoTypeEntity = "entity"
+)
+
+// MemoTypes are all the memo types the bridge can mint to
+var MemoTypes = []string{MemoTypeTwin, MemoTypeFarm, MemoTypeNode, MemoTypeEntity}
+
+// node mint targets, a node memo mints to the twin of the node or to the twin of the farm the node belongs to.
+// Farms have no other tfchain account than their twin, their payout address is a stellar address.
+const (
+	NodeMintTargetNode = "node"
+	NodeMintTargetFarm = "farm"
+)
+
+// mint roles, a vote only validator never proposes a mint, it waits for another validator to propose it
+// and votes on the proposal. At least one validator has to propose for deposits to be minted.
+const (
+	MintRoleProposeAndVote = "propose_and_vote"
+	MintRoleVoteOnly       = "vote_only"
+)
+
+// refund reserve policies
+const (
+	RefundReservePolicyHold   = "hold"
+	RefundReservePolicySubmit = "submit"
+)
+
+// unsupported asset policies, the refunds signed by the validators on chain only carry payments of the
+// bridged asset so payments of other assets are held for an operator to pay them back
+const (
+	UnsupportedAssetPolicyIgnore = "ignore"
+	UnsupportedAssetPolicyAlert  = "alert"
+	UnsupportedAssetPolicyHold   = "hold"
+)
+
+// malformed event policies
+const (
+	MalformedEventPolicySkip = "skip"
+	MalformedEventPolicyFail = "fail"
+)
+
+// log formats
+const (
+	LogFormatConsole = "console"
+	LogFormatJSON    = "json"
+)
+
+// below fee policies
+const (
+	BelowFeePolicyRefund = "refund"
+	BelowFeePolicyAbsorb = "absorb"
+	BelowFeePolicyIgnore = "ignore"
+)
+
+// ReturnDeposit is a payment to the bridge account with a return memo, these are the refunds
+// the bridge issued that were sent back and consumed without minting
+type ReturnDeposit struct {
+	TxHash string `json:"txHash"`
+	Amount int64  `json:"amount"`
+	// RefundedTx is the hash of the deposit the return memo refers to
+	RefundedTx string    `json:"refundedTx"`
+	SeenAt     time.Time `json:"seenAt"`
+}
+
+// DeadLetter is an event that failed permanently and was dropped
+type DeadLetter struct {
+	Route string    `json:"route"`
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+// MalformedEvent is a tfchain event that could not be handled because its content is invalid
+type MalformedEvent struct {
+	Height uint32 `json:"height"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	Event  string `json:"event"`
+}
+
+// withdraw lifecycle states
+const (
+	WithdrawStatusCreated             = "created"
+	WithdrawStatusSignaturesCollected = "signatures_collected"
+	WithdrawStatusReady               = "ready"
+	WithdrawStatusPaid                = "paid"
+)
+
+// WithdrawStatus is the lifecycle status of a withdraw (burn) transaction
+type WithdrawStatus struct {
+	ID                 uint64 `json:"id"`
+	Status             string `json:"status"`
+	Target             string `json:"target,omitempty"`
+	Amount             uint64 `json:"amount,omitempty"`
+	Signatures         int    `json:"signatures"`
+	RequiredSignatures int    `json:"required_signatures"`
+	// StellarTxHash is the hash of the stellar payment, only set once the withdraw is paid
+	StellarTxHash string `json:"stellar_tx_hash,omitempty"`
+}
+
+// ConfigFingerprint is the part of the configuration all validators must agree on, it is served by the
+// admin server so the validators can compare their configurations
+type ConfigFingerprint struct {
+	Network           string        `json:"network"`
+	BridgeAccount     string        `json:"bridge_account"`
+	AssetCode         string        `json:"asset_code"`
+	AssetIssuer       string        `json:"asset_issuer"`
+	DepositFee        int64         `json:"deposit_fee"`
+	BaseFee           int64         `json:"base_fee"`
+	PaymentTimeout    time.Duration `json:"payment_timeout"`
+	ClaimableBalances bool          `json:"claimable_balances"`
+}
+
+// Diff returns the fields of the fingerprint that differ from other
+func (f ConfigFingerprint) Diff(other ConfigFingerprint) []string {
+	var fields []string
+	if f.Network != other.Network {
+		fields = append(fields, "network")
+	}
+	if f.BridgeAccount != other.BridgeAccount {
+		fields = append(fields, "bridge_account")
+	}
+	if f.AssetCode != other.AssetCode || f.AssetIssuer != other.AssetIssuer {
+		fields = append(fields, "asset")
+	}
+	if f.DepositFee != other.DepositFee {
+		fields = append(fields, "deposit_fee")
+	}
+	if f.BaseFee != other.BaseFee {
+		fields = append(fields, "base_fee")
+	}
+	if f.PaymentTimeout != other.PaymentTimeout {
+		fields = append(fields, "payment_timeout")
+	}
+	if f.ClaimableBalances != other.ClaimableBalances {
+		fields = append(fields, "claimable_balances")
+	}
+	return fields
+}
+
+// RefundStatus is the status of a refund transaction that is not executed yet
+type RefundStatus struct {
+	TxHash             string `json:"tx_hash"`
+	Target             string `json:"target"`
+	Amount             uint64 `json:"amount"`
+	Signatures         int    `json:"signatures"`
+	RequiredSignatures int    `json:"required_signatures"`
 }
 
 type StellarSignature struct {
@@ -26,7 +333,19 @@ type StellarSignature struct {
 	StellarAddress []byte
 }
 
-var ErrTransactionAlreadyRefunded = errors.New("transaction is already refunded")
-var ErrTransactionAlreadyMinted = errors.New("transaction is already minted")
-var ErrTransactionAlreadyBurned = errors.New("transaction is already burned")
-var ErrNoSignatures = errors.New("transaction has no signatures")
+// transactions that are handled already or can not be handled yet fail the same way on a retry
+var ErrTransactionAlreadyRefunded = Permanent(errors.New("transaction is already refunded"))
+var ErrTransactionAlreadyMinted = Permanent(errors.New("transaction is already minted"))
+var ErrTransactionAlreadyBurned = Permanent(errors.New("transaction is already burned"))
+var ErrNoSignatures = Permanent(errors.New("transaction has no signatures"))
+
+// ErrRefundAmount is returned for a refund above the deposited amount or the refund ceiling, it is never paid
+var ErrRefundAmount = Permanent(errors.New("refund amount is not allowed"))
+var ErrNotFound = errors.New("not found")
+var ErrNotValidator = errors.New("account is not a bridge validator")
+
+// ErrInvalidConfig is returned when the bridge can not start with its configuration
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// ErrSubscriptionFailed is returned when the tfchain or stellar subscription stopped with an error
+var ErrSubscriptionFailed = errors.New("subscription failed")
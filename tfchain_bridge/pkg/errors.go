@@ -0,0 +1,74 @@
+package pkg
+
+import "github.com/pkg/errors"
+
+// failure classes, a failure is classified where it happens so the event handling knows whether
+// retrying it can help. Unclassified failures are handled according to the policy of the event type.
+var (
+	// ErrTransient is the class of failures that can go away by retrying, like rpc timeouts
+	ErrTransient = errors.New("transient failure")
+	// ErrPermanent is the class of failures that fail the same way on every retry, like a malformed memo
+	ErrPermanent = errors.New("permanent failure")
+)
+
+// classifiedError is an error marked with its failure class, it matches both the class and the error itself
+type classifiedError struct {
+	err   error
+	class error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+// Transient marks err as a transient failure
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: ErrTransient}
+}
+
+// Permanent marks err as a permanent failure
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: err, class: ErrPermanent}
+}
+
+// InvalidConfig marks err as the reason the bridge can not start with its configuration, the result
+// matches both ErrInvalidConfig and err
+func InvalidConfig(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: errors.Wrap(err, ErrInvalidConfig.Error()), class: ErrInvalidConfig}
+}
+
+// SubscriptionFailed marks err as the failure of a subscription, the result matches both
+// ErrSubscriptionFailed and err
+func SubscriptionFailed(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{err: errors.Wrap(err, ErrSubscriptionFailed.Error()), class: ErrSubscriptionFailed}
+}
+
+// IsTransient returns true if err is marked as a transient failure
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrTransient)
+}
+
+// IsPermanent returns true if err is marked as a permanent failure
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
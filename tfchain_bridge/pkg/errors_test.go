@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestErrorClassification(t *testing.T) {
+	cause := errors.New("cause")
+
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+		permanent bool
+	}{
+		{name: "unclassified", err: cause},
+		{name: "transient", err: Transient(cause), transient: true},
+		{name: "permanent", err: Permanent(cause), permanent: true},
+		{name: "wrapped transient", err: errors.Wrap(Transient(cause), "failed to get account"), transient: true},
+		{name: "wrapped permanent", err: errors.Wrap(Permanent(cause), "failed to mint"), permanent: true},
+		{name: "already refunded", err: ErrTransactionAlreadyRefunded, permanent: true},
+		{name: "already minted", err: ErrTransactionAlreadyMinted, permanent: true},
+		{name: "already burned", err: ErrTransactionAlreadyBurned, permanent: true},
+		{name: "no signatures", err: ErrNoSignatures, permanent: true},
+		{name: "wrapped sentinel", err: errors.Wrap(ErrTransactionAlreadyRefunded, "failed to refund"), permanent: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if transient := IsTransient(test.err); transient != test.transient {
+				t.Errorf("expected transient %t, got %t", test.transient, transient)
+			}
+			if permanent := IsPermanent(test.err); permanent != test.permanent {
+				t.Errorf("expected permanent %t, got %t", test.permanent, permanent)
+			}
+		})
+	}
+
+	t.Run("keeps the cause", func(t *testing.T) {
+		err := errors.Wrap(Permanent(cause), "failed to mint")
+		if !errors.Is(err, cause) {
+			t.Error("expected the classified error to match its cause")
+		}
+		if err.Error() != "failed to mint: cause" {
+			t.Errorf("expected the message of the cause, got %q", err.Error())
+		}
+	})
+
+	t.Run("keeps the cause of a configuration or subscription failure", func(t *testing.T) {
+		for class, err := range map[error]error{
+			ErrInvalidConfig:      InvalidConfig(cause),
+			ErrSubscriptionFailed: SubscriptionFailed(cause),
+		} {
+			if !errors.Is(err, class) || !errors.Is(err, cause) {
+				t.Errorf("expected %q to match both %q and its cause", err, class)
+			}
+			if expected := class.Error() + ": cause"; err.Error() != expected {
+				t.Errorf("expected message %q, got %q", expected, err.Error())
+			}
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		if Transient(nil) != nil || Permanent(nil) != nil || InvalidConfig(nil) != nil || SubscriptionFailed(nil) != nil {
+			t.Error("expected nil to stay nil")
+		}
+	})
+}
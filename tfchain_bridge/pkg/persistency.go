@@ -3,15 +3,75 @@ package pkg
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	dayFormat = "2006-01-02"
+	// maxMalformedEvents is the amount of malformed events kept in the persistency file
+	maxMalformedEvents = 100
+	// maxDeadLetters is the amount of dead letters kept in the persistency file
+	maxDeadLetters = 100
+	// maxReturnDeposits is the amount of return memo deposits kept in the persistency file
+	maxReturnDeposits = 1000
 )
 
 type Blockheight struct {
 	LastHeight    uint32 `json:"lastHeight"`
 	StellarCursor string `json:"stellarCursor"`
+	// MintDay is the UTC day the DailyMints counters belong to
+	MintDay      string           `json:"mintDay,omitempty"`
+	DailyMints   map[string]int64 `json:"dailyMints,omitempty"`
+	HeldDeposits []HeldDeposit    `json:"heldDeposits,omitempty"`
+	// HeldWithdraws are withdraws that can not be paid automatically and need manual handling
+	HeldWithdraws []HeldWithdraw `json:"heldWithdraws,omitempty"`
+	// MalformedEvents are the most recent malformed tfchain events kept for investigation
+	MalformedEvents []MalformedEvent `json:"malformedEvents,omitempty"`
+	// DeadLetters are the most recent events that failed permanently and were dropped
+	DeadLetters []DeadLetter `json:"deadLetters,omitempty"`
+	// ReturnDeposits are the most recent deposits with a return memo, kept for reconciliation
+	ReturnDeposits []ReturnDeposit `json:"returnDeposits,omitempty"`
+	// PendingMints are fetched mint events that are not processed yet
+	PendingMints []PendingMint `json:"pendingMints,omitempty"`
+}
+
+// PendingMint is a serialized mint event of a stellar transaction
+type PendingMint struct {
+	TxHash string          `json:"txHash"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// HeldDeposit is a deposit that is parked for manual review instead of being minted
+type HeldDeposit struct {
+	TxHash      string    `json:"txHash"`
+	PagingToken string    `json:"pagingToken"`
+	Sender      string    `json:"sender"`
+	Target      string    `json:"target"`
+	Amount      int64     `json:"amount"`
+	Reason      string    `json:"reason"`
+	HeldAt      time.Time `json:"heldAt"`
+	// Asset is set for deposits of another asset than the bridged asset
+	Asset string `json:"asset,omitempty"`
+}
+
+// HeldWithdraw is a withdraw that is parked for manual handling instead of being paid
+type HeldWithdraw struct {
+	ID     uint64    `json:"id"`
+	Target string    `json:"target"`
+	Amount uint64    `json:"amount"`
+	Reason string    `json:"reason"`
+	HeldAt time.Time `json:"heldAt"`
 }
 
 type ChainPersistency struct {
 	location string
+	// mu serializes the read-modify-write updates of the persistency file
+	mu sync.Mutex
 }
 
 func InitPersist(location string) (*ChainPersistency, error) {
@@ -21,6 +81,9 @@ func InitPersist(location string) (*ChainPersistency, error) {
 }
 
 func (b *ChainPersistency) SaveHeight(height uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	blockheight, err := b.GetHeight()
 	if err != nil {
 		return err
@@ -30,19 +93,289 @@ func (b *ChainPersistency) SaveHeight(height uint32) error {
 	return b.Save(blockheight)
 }
 
+// SaveStellarCursor saves the stellar cursor, a cursor before the stored one is ignored
+// so replayed or out of order events can not make the bridge mint again
 func (b *ChainPersistency) SaveStellarCursor(cursor string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	blockheight, err := b.GetHeight()
 	if err != nil {
 		return err
 	}
 
+	if compareCursors(cursor, blockheight.StellarCursor) <= 0 {
+		return nil
+	}
+
 	blockheight.StellarCursor = cursor
 	return b.Save(blockheight)
 }
 
+// ResetStellarCursor saves the stellar cursor even if it is before the stored one, used for a rescan
+func (b *ChainPersistency) ResetStellarCursor(cursor string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	blockheight.StellarCursor = cursor
+	return b.Save(blockheight)
+}
+
+// compareCursors compares 2 stellar paging tokens numerically, an empty cursor is before any other cursor
+func compareCursors(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// GetDailyMinted returns the amount minted to target on the UTC day of now
+func (b *ChainPersistency) GetDailyMinted(target string, now time.Time) (int64, error) {
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return 0, err
+	}
+
+	if blockheight.MintDay != mintDay(now) {
+		return 0, nil
+	}
+
+	return blockheight.DailyMints[target], nil
+}
+
+// mintDay is the UTC day the daily mint counters of now belong to, the counters reset at UTC midnight
+// whatever the time zone of the validator
+func mintDay(now time.Time) string {
+	return now.UTC().Format(dayFormat)
+}
+
+// AddDailyMinted adds amount to the minted total of target for the UTC day of now,
+// counters of previous days are dropped
+func (b *ChainPersistency) AddDailyMinted(target string, amount int64, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	day := mintDay(now)
+	if blockheight.MintDay != day || blockheight.DailyMints == nil {
+		blockheight.MintDay = day
+		blockheight.DailyMints = make(map[string]int64)
+	}
+
+	blockheight.DailyMints[target] += amount
+	return b.Save(blockheight)
+}
+
+// HoldDeposit parks a deposit for manual review, holding the same payment of a transaction twice is a no-op
+func (b *ChainPersistency) HoldDeposit(deposit HeldDeposit) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	for _, held := range blockheight.HeldDeposits {
+		if held.TxHash == deposit.TxHash && held.Sender == deposit.Sender && held.Asset == deposit.Asset {
+			return nil
+		}
+	}
+
+	blockheight.HeldDeposits = append(blockheight.HeldDeposits, deposit)
+	return b.Save(blockheight)
+}
+
+// HoldWithdraw parks a withdraw for manual handling, it returns false if the withdraw was held already
+func (b *ChainPersistency) HoldWithdraw(withdraw HeldWithdraw) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return false, err
+	}
+
+	for _, held := range blockheight.HeldWithdraws {
+		if held.ID == withdraw.ID {
+			return false, nil
+		}
+	}
+
+	blockheight.HeldWithdraws = append(blockheight.HeldWithdraws, withdraw)
+	return true, b.Save(blockheight)
+}
+
+// GetHeldDeposit returns the held deposit of the bridged asset of the transaction with txHash, ErrNotFound
+// is returned if the transaction is not held
+func (b *ChainPersistency) GetHeldDeposit(txHash string) (HeldDeposit, error) {
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return HeldDeposit{}, err
+	}
+
+	for _, held := range blockheight.HeldDeposits {
+		if held.TxHash == txHash && held.Asset == "" {
+			return held, nil
+		}
+	}
+	return HeldDeposit{}, ErrNotFound
+}
+
+// RemoveHeldDeposit drops the held deposit of the bridged asset of the transaction with txHash once it is resolved
+func (b *ChainPersistency) RemoveHeldDeposit(txHash string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	kept := blockheight.HeldDeposits[:0]
+	for _, held := range blockheight.HeldDeposits {
+		if held.TxHash != txHash || held.Asset != "" {
+			kept = append(kept, held)
+		}
+	}
+	blockheight.HeldDeposits = kept
+	return b.Save(blockheight)
+}
+
+// RecordMalformedEvent keeps a malformed event for investigation, only the most recent ones are kept
+func (b *ChainPersistency) RecordMalformedEvent(event MalformedEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	blockheight.MalformedEvents = append(blockheight.MalformedEvents, event)
+	if len(blockheight.MalformedEvents) > maxMalformedEvents {
+		blockheight.MalformedEvents = blockheight.MalformedEvents[len(blockheight.MalformedEvents)-maxMalformedEvents:]
+	}
+	return b.Save(blockheight)
+}
+
+// RecordDeadLetter keeps a permanently failed event for investigation, only the most recent ones are kept
+func (b *ChainPersistency) RecordDeadLetter(letter DeadLetter) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	blockheight.DeadLetters = append(blockheight.DeadLetters, letter)
+	if len(blockheight.DeadLetters) > maxDeadLetters {
+		blockheight.DeadLetters = blockheight.DeadLetters[len(blockheight.DeadLetters)-maxDeadLetters:]
+	}
+	return b.Save(blockheight)
+}
+
+// RecordReturnDeposit keeps a deposit with a return memo for reconciliation, only the most recent ones are kept
+func (b *ChainPersistency) RecordReturnDeposit(deposit ReturnDeposit) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	blockheight.ReturnDeposits = append(blockheight.ReturnDeposits, deposit)
+	if len(blockheight.ReturnDeposits) > maxReturnDeposits {
+		blockheight.ReturnDeposits = blockheight.ReturnDeposits[len(blockheight.ReturnDeposits)-maxReturnDeposits:]
+	}
+	return b.Save(blockheight)
+}
+
+// AddPendingMints appends mint events to the pending list, already pending transactions are not added twice
+func (b *ChainPersistency) AddPendingMints(mints []PendingMint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	for _, mint := range mints {
+		if !hasPendingMint(blockheight.PendingMints, mint.TxHash) {
+			blockheight.PendingMints = append(blockheight.PendingMints, mint)
+		}
+	}
+	return b.Save(blockheight)
+}
+
+// RemovePendingMint drops the mint events of a processed transaction from the pending list
+func (b *ChainPersistency) RemovePendingMint(txHash string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blockheight, err := b.GetHeight()
+	if err != nil {
+		return err
+	}
+
+	if !hasPendingMint(blockheight.PendingMints, txHash) {
+		return nil
+	}
+
+	pending := blockheight.PendingMints[:0]
+	for _, mint := range blockheight.PendingMints {
+		if mint.TxHash != txHash {
+			pending = append(pending, mint)
+		}
+	}
+	blockheight.PendingMints = pending
+	return b.Save(blockheight)
+}
+
+func hasPendingMint(mints []PendingMint, txHash string) bool {
+	for _, mint := range mints {
+		if mint.TxHash == txHash {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHeight reads the persistency file, if it is corrupt the backup copy is read instead
 func (b *ChainPersistency) GetHeight() (*Blockheight, error) {
+	blockheight, err := readBlockheight(b.location)
+	if err == nil {
+		return blockheight, nil
+	}
+
+	backup, backupErr := readBlockheight(b.backupLocation())
+	if backupErr != nil {
+		return nil, err
+	}
+	log.Warn().Err(err).Str("file", b.location).Msg("persistency file is corrupt, using its backup")
+	return backup, nil
+}
+
+func readBlockheight(location string) (*Blockheight, error) {
 	var blockheight Blockheight
-	file, err := os.ReadFile(b.location)
+	file, err := os.ReadFile(location)
 	if os.IsNotExist(err) {
 		return &blockheight, nil
 	}
@@ -57,11 +390,58 @@ func (b *ChainPersistency) GetHeight() (*Blockheight, error) {
 	return &blockheight, nil
 }
 
+// Save writes the persistency file and then its backup copy, both are replaced atomically so
+// a crash while saving leaves at least one of them intact
 func (b *ChainPersistency) Save(blockheight *Blockheight) error {
 	updatedPersistency, err := json.Marshal(blockheight)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(b.location, updatedPersistency, 0644)
+	if err := writeFileAtomic(b.location, updatedPersistency); err != nil {
+		return err
+	}
+	return writeFileAtomic(b.backupLocation(), updatedPersistency)
+}
+
+func (b *ChainPersistency) backupLocation() string {
+	return b.location + ".bak"
+}
+
+// writeFileAtomic writes data to a temporary file next to location, syncs it and renames it over location
+func writeFileAtomic(location string, data []byte) error {
+	dir := filepath.Dir(location)
+	tmp, err := os.CreateTemp(dir, filepath.Base(location)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), location); err != nil {
+		return err
+	}
+
+	// sync the directory so the rename itself survives a crash
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
@@ -0,0 +1,230 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompareCursors(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "185661728346116352", b: "185661728346116352", expected: 0},
+		{a: "185661728346116351", b: "185661728346116352", expected: -1},
+		{a: "185661728346116353", b: "185661728346116352", expected: 1},
+		// a longer cursor is a larger number even if it sorts before as a string
+		{a: "99", b: "100", expected: -1},
+		{a: "100", b: "99", expected: 1},
+		{a: "", b: "1", expected: -1},
+		{a: "1", b: "", expected: 1},
+		{a: "", b: "", expected: 0},
+		{a: "0042", b: "42", expected: 0},
+		{a: "0", b: "", expected: 0},
+	}
+	for _, test := range tests {
+		if result := compareCursors(test.a, test.b); result != test.expected {
+			t.Errorf("compareCursors(%q, %q): expected %d, got %d", test.a, test.b, test.expected, result)
+		}
+	}
+}
+
+func TestSaveStellarCursor(t *testing.T) {
+	persistency, err := InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.SaveStellarCursor("185661728346116352"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		cursor   string
+		reset    bool
+		expected string
+	}{
+		{name: "forward", cursor: "185661728346116360", expected: "185661728346116360"},
+		{name: "equal", cursor: "185661728346116360", expected: "185661728346116360"},
+		{name: "backward", cursor: "185661728346116352", expected: "185661728346116360"},
+		{name: "shorter", cursor: "99", expected: "185661728346116360"},
+		{name: "reset", cursor: "0", reset: true, expected: "0"},
+		{name: "forward after reset", cursor: "185661728346116352", expected: "185661728346116352"},
+	}
+	for _, test := range tests {
+		save := persistency.SaveStellarCursor
+		if test.reset {
+			save = persistency.ResetStellarCursor
+		}
+		if err := save(test.cursor); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		blockheight, err := persistency.GetHeight()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blockheight.StellarCursor != test.expected {
+			t.Errorf("%s: expected cursor %s, got %s", test.name, test.expected, blockheight.StellarCursor)
+		}
+	}
+}
+
+func TestPersistencyRoundTrip(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "persistency.json")
+	persistency, err := InitPersist(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	deposit := HeldDeposit{TxHash: "tx1", PagingToken: "100", Sender: "sender", Target: "target", Amount: 5, Reason: "daily_limit_exceeded", HeldAt: now}
+	withdraw := HeldWithdraw{ID: 7, Target: "target", Amount: 9, Reason: "invalid_target", HeldAt: now}
+	malformed := MalformedEvent{Height: 12, Type: "Undecodable", Reason: "reason", Event: "0x00"}
+	letter := DeadLetter{Route: "mint", Error: "failure", At: now}
+	pending := PendingMint{TxHash: "tx3", Event: json.RawMessage(`{"tx":"tx3"}`)}
+
+	steps := []func() error{
+		func() error { return persistency.SaveHeight(42) },
+		func() error { return persistency.SaveStellarCursor("200") },
+		// an older cursor does not move the saved cursor back
+		func() error { return persistency.SaveStellarCursor("150") },
+		func() error { return persistency.AddDailyMinted("target", 10, now) },
+		func() error { return persistency.AddDailyMinted("target", 5, now) },
+		func() error { return persistency.HoldDeposit(deposit) },
+		func() error { return persistency.HoldDeposit(deposit) },
+		func() error { _, err := persistency.HoldWithdraw(withdraw); return err },
+		func() error { return persistency.RecordMalformedEvent(malformed) },
+		func() error { return persistency.RecordDeadLetter(letter) },
+		func() error { return persistency.AddPendingMints([]PendingMint{pending, pending}) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %s", i, err)
+		}
+	}
+
+	expected := &Blockheight{
+		LastHeight:      42,
+		StellarCursor:   "200",
+		MintDay:         "2026-10-16",
+		DailyMints:      map[string]int64{"target": 15},
+		HeldDeposits:    []HeldDeposit{deposit},
+		HeldWithdraws:   []HeldWithdraw{withdraw},
+		MalformedEvents: []MalformedEvent{malformed},
+		DeadLetters:     []DeadLetter{letter},
+		PendingMints:    []PendingMint{pending},
+	}
+
+	// a new persistency on the same file reads what was saved
+	reopened, err := InitPersist(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockheight, err := reopened.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(blockheight, expected) {
+		t.Errorf("expected %+v, got %+v", expected, blockheight)
+	}
+}
+
+func TestPersistencyRecoversTruncatedFile(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "persistency.json")
+	persistency, err := InitPersist(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.SaveHeight(42); err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.SaveStellarCursor("185661728346116352"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the process was killed while the file was written
+	content, err := os.ReadFile(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(location, content[:len(content)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	blockheight, err := persistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockheight.LastHeight != 42 || blockheight.StellarCursor != "185661728346116352" {
+		t.Errorf("expected the backup to be read, got %+v", blockheight)
+	}
+
+	// the next save repairs the file
+	if err := persistency.SaveHeight(43); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBlockheight(location); err != nil {
+		t.Errorf("expected the file to be repaired, got %v", err)
+	}
+
+	// both copies being corrupt is an error rather than a reset of the cursor
+	for _, file := range []string{location, persistency.backupLocation()} {
+		if err := os.WriteFile(file, []byte("{"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := persistency.GetHeight(); err == nil {
+		t.Error("expected an error when the file and its backup are corrupt")
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected only the file and its backup, got %d files", len(entries))
+	}
+}
+
+func TestPersistencyRemovesResolvedEntries(t *testing.T) {
+	persistency, err := InitPersist(filepath.Join(t.TempDir(), "persistency.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := persistency.HoldDeposit(HeldDeposit{TxHash: "tx1", Sender: "sender"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.HoldDeposit(HeldDeposit{TxHash: "tx2", Sender: "sender"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.AddPendingMints([]PendingMint{{TxHash: "tx1"}, {TxHash: "tx2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.RemoveHeldDeposit("tx1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := persistency.RemovePendingMint("tx1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := persistency.GetHeldDeposit("tx1"); err != ErrNotFound {
+		t.Errorf("expected the held deposit to be removed, got %v", err)
+	}
+	if held, err := persistency.GetHeldDeposit("tx2"); err != nil || held.Sender != "sender" {
+		t.Errorf("expected tx2 to stay held, got %+v, %v", held, err)
+	}
+	blockheight, err := persistency.GetHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blockheight.PendingMints) != 1 || blockheight.PendingMints[0].TxHash != "tx2" {
+		t.Errorf("expected only tx2 to be pending, got %+v", blockheight.PendingMints)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/threefoldtech/tfchain_bridge/pkg/metrics"
+)
+
+// Bridge is the part of the bridge exposed over the admin http server
+type Bridge interface {
+	WithdrawStatus(ctx context.Context, id uint64) (*pkg.WithdrawStatus, error)
+	PendingWithdraws(ctx context.Context) ([]pkg.WithdrawStatus, error)
+	PendingRefunds(ctx context.Context) ([]pkg.RefundStatus, error)
+	Breakers() map[string]bool
+	Paused() bool
+	Fingerprint() pkg.ConfigFingerprint
+}
+
+// Server is the admin http server of the bridge
+type Server struct {
+	bridge Bridge
+	token  string
+	http   *http.Server
+}
+
+// NewServer creates the admin server, the pending transactions api is only served if token is set
+func NewServer(address string, token string, bridge Bridge) *Server {
+	s := &Server{
+		bridge: bridge,
+		token:  token,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.health)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/withdraws/", s.withdrawStatus)
+	mux.HandleFunc("/fingerprint", s.fingerprint)
+	if token != "" {
+		mux.HandleFunc("/pending/burns", s.authorized(s.pendingBurns))
+		mux.HandleFunc("/pending/refunds", s.authorized(s.pendingRefunds))
+	}
+
+	s.http = &http.Server{
+		Addr:    address,
+		Handler: mux,
+	}
+
+	return s
+}
+
+// Serve listens for requests until the context is cancelled
+func (s *Server) Serve(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.http.Shutdown(shutdownCtx)
+	}()
+
+	log.Info().Str("address", s.http.Addr).Msg("starting admin server")
+	if err := s.http.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// health reports the bridge as degraded while the processing of an event type is paused
+// and as paused while the processing of all events is paused by an operator
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	status := "ok"
+	breakers := make(map[string]string)
+	for name, open := range s.bridge.Breakers() {
+		breakers[name] = "closed"
+		if open {
+			breakers[name] = "open"
+			status = "degraded"
+		}
+	}
+
+	if s.bridge.Paused() {
+		status = "paused"
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":   status,
+		"breakers": breakers,
+	})
+}
+
+// fingerprint handles GET /fingerprint, the other validators compare it with their own configuration
+func (s *Server) fingerprint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.bridge.Fingerprint())
+}
+
+// withdrawStatus handles GET /withdraws/{id}
+func (s *Server) withdrawStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/withdraws/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid withdraw id")
+		return
+	}
+
+	status, err := s.bridge.WithdrawStatus(r.Context(), id)
+	if errors.Is(err, pkg.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "withdraw not found")
+		return
+	}
+	if err != nil {
+		log.Err(err).Uint64("ID", id).Msg("failed to get withdraw status")
+		writeError(w, http.StatusInternalServerError, "failed to get withdraw status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// pendingBurns handles GET /pending/burns
+func (s *Server) pendingBurns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	withdraws, err := s.bridge.PendingWithdraws(r.Context())
+	if err != nil {
+		log.Err(err).Msg("failed to list pending withdraws")
+		writeError(w, http.StatusInternalServerError, "failed to list pending burns")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, withdraws)
+}
+
+// pendingRefunds handles GET /pending/refunds
+func (s *Server) pendingRefunds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	refunds, err := s.bridge.PendingRefunds(r.Context())
+	if err != nil {
+		log.Err(err).Msg("failed to list pending refunds")
+		writeError(w, http.StatusInternalServerError, "failed to list pending refunds")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, refunds)
+}
+
+// authorized only calls next if the request carries the configured bearer token
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Err(err).Msg("failed to write response")
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
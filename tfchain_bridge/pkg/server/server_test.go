@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+const testToken = "secret"
+
+// fakeBridge answers with fixed withdraws and refunds
+type fakeBridge struct {
+	withdraws map[uint64]pkg.WithdrawStatus
+	refunds   []pkg.RefundStatus
+	breakers  map[string]bool
+	paused    bool
+	err       error
+}
+
+var testFingerprint = pkg.ConfigFingerprint{Network: "testnet", BridgeAccount: "bridge", AssetCode: "TFT", AssetIssuer: "issuer", DepositFee: 10000000, BaseFee: 1000}
+
+func (b *fakeBridge) WithdrawStatus(ctx context.Context, id uint64) (*pkg.WithdrawStatus, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	status, ok := b.withdraws[id]
+	if !ok {
+		return nil, pkg.ErrNotFound
+	}
+	return &status, nil
+}
+
+func (b *fakeBridge) PendingWithdraws(ctx context.Context) ([]pkg.WithdrawStatus, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	var pending []pkg.WithdrawStatus
+	for _, status := range b.withdraws {
+		if status.Status != pkg.WithdrawStatusPaid {
+			pending = append(pending, status)
+		}
+	}
+	return pending, nil
+}
+
+func (b *fakeBridge) PendingRefunds(ctx context.Context) ([]pkg.RefundStatus, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.refunds, nil
+}
+
+func (b *fakeBridge) Breakers() map[string]bool {
+	return b.breakers
+}
+
+func (b *fakeBridge) Paused() bool {
+	return b.paused
+}
+
+func (b *fakeBridge) Fingerprint() pkg.ConfigFingerprint {
+	return testFingerprint
+}
+
+func TestServer(t *testing.T) {
+	bridge := &fakeBridge{
+		withdraws: map[uint64]pkg.WithdrawStatus{
+			1: {ID: 1, Status: pkg.WithdrawStatusReady, Target: "target", Amount: 5, Signatures: 2, RequiredSignatures: 2},
+		},
+		refunds:  []pkg.RefundStatus{{TxHash: "tx", Target: "sender", Amount: 3, Signatures: 1, RequiredSignatures: 2}},
+		breakers: map[string]bool{"mint events": false},
+	}
+	failing := &fakeBridge{err: errors.New("tfchain is down")}
+	degraded := &fakeBridge{breakers: map[string]bool{"mint events": true, "withdraw ready": false}}
+	paused := &fakeBridge{breakers: map[string]bool{"mint events": false}, paused: true}
+
+	tests := []struct {
+		name   string
+		bridge Bridge
+		token  string
+		method string
+		path   string
+		auth   string
+		code   int
+		body   string
+	}{
+		{name: "health", path: "/health", code: http.StatusOK, body: `{"breakers":{"mint events":"closed"},"status":"ok"}`},
+		{name: "health with an open breaker", bridge: degraded, path: "/health", code: http.StatusOK, body: `{"breakers":{"mint events":"open","withdraw ready":"closed"},"status":"degraded"}`},
+		{name: "health while paused", bridge: paused, path: "/health", code: http.StatusOK, body: `{"breakers":{"mint events":"closed"},"status":"paused"}`},
+		{name: "withdraw status", path: "/withdraws/1", code: http.StatusOK, body: `{"id":1,"status":"ready","target":"target","amount":5,"signatures":2,"required_signatures":2}`},
+		{name: "unknown withdraw", path: "/withdraws/2", code: http.StatusNotFound, body: `{"error":"withdraw not found"}`},
+		{name: "invalid withdraw id", path: "/withdraws/abc", code: http.StatusBadRequest, body: `{"error":"invalid withdraw id"}`},
+		{name: "withdraw status post", method: http.MethodPost, path: "/withdraws/1", code: http.StatusMethodNotAllowed},
+		{name: "withdraw status failure", bridge: failing, path: "/withdraws/1", code: http.StatusInternalServerError, body: `{"error":"failed to get withdraw status"}`},
+		{name: "fingerprint", path: "/fingerprint", code: http.StatusOK, body: `{"network":"testnet","bridge_account":"bridge","asset_code":"TFT","asset_issuer":"issuer","deposit_fee":10000000,"base_fee":1000,"payment_timeout":0,"claimable_balances":false}`},
+		{name: "fingerprint post", method: http.MethodPost, path: "/fingerprint", code: http.StatusMethodNotAllowed},
+		{name: "pending burns", token: testToken, path: "/pending/burns", auth: "Bearer " + testToken, code: http.StatusOK, body: `[{"id":1,"status":"ready","target":"target","amount":5,"signatures":2,"required_signatures":2}]`},
+		{name: "pending refunds", token: testToken, path: "/pending/refunds", auth: "Bearer " + testToken, code: http.StatusOK, body: `[{"tx_hash":"tx","target":"sender","amount":3,"signatures":1,"required_signatures":2}]`},
+		{name: "pending refunds failure", bridge: failing, token: testToken, path: "/pending/refunds", auth: "Bearer " + testToken, code: http.StatusInternalServerError},
+		{name: "pending burns without token", token: testToken, path: "/pending/burns", code: http.StatusUnauthorized},
+		{name: "pending burns with wrong token", token: testToken, path: "/pending/burns", auth: "Bearer wrong", code: http.StatusUnauthorized},
+		{name: "pending burns disabled", path: "/pending/burns", auth: "Bearer ", code: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.bridge == nil {
+				test.bridge = bridge
+			}
+			if test.method == "" {
+				test.method = http.MethodGet
+			}
+			server := NewServer(":0", test.token, test.bridge)
+
+			request := httptest.NewRequest(test.method, test.path, nil)
+			if test.auth != "" {
+				request.Header.Set("Authorization", test.auth)
+			}
+			response := httptest.NewRecorder()
+			server.http.Handler.ServeHTTP(response, request)
+
+			if response.Code != test.code {
+				t.Errorf("expected status %d, got %d", test.code, response.Code)
+			}
+			if body := strings.TrimSpace(response.Body.String()); test.body != "" && body != test.body {
+				t.Errorf("expected body %s, got %s", test.body, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package stellar
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+const testIssuer = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+
+func TestValidateAsset(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		issuer string
+		err    bool
+	}{
+		{name: "network tft"},
+		{name: "alphanum4", code: "USDC", issuer: testIssuer},
+		{name: "alphanum12", code: "BRIDGETOKEN", issuer: testIssuer},
+		{name: "code too long", code: "BRIDGETOKENXY", issuer: testIssuer, err: true},
+		{name: "invalid code", code: "TF-T", issuer: testIssuer, err: true},
+		{name: "missing issuer", code: "USDC", err: true},
+		{name: "invalid issuer", code: "USDC", issuer: "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEW", err: true},
+		{name: "seed as issuer", code: "USDC", issuer: "SBGWKM3CD4IL47QN6X54N6Y33T3JDNVI6AIJ6CD5IM47HG3IG4O36XCU", err: true},
+		{name: "missing code", issuer: testIssuer, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAsset(&pkg.StellarConfig{StellarAssetCode: test.code, StellarAssetIssuer: test.issuer})
+			if (err != nil) != test.err {
+				t.Errorf("expected error %t, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestConfiguredAsset(t *testing.T) {
+	usdc := base.Asset{Type: "credit_alphanum4", Code: "USDC", Issuer: testIssuer}
+	tftAsset := strings.Split(TFTTest, ":")
+	tft := base.Asset{Type: "credit_alphanum4", Code: tftAsset[0], Issuer: tftAsset[1]}
+
+	w := &StellarWallet{config: &pkg.StellarConfig{
+		StellarBridgeAccount:     testBridgeAccount,
+		StellarNetwork:           "testnet",
+		StellarNetworkPassphrase: "Custom Network ; 2026",
+		StellarAssetCode:         usdc.Code,
+		StellarAssetIssuer:       usdc.Issuer,
+	}}
+
+	asset := w.getAssetCodeAndIssuer()
+	if !reflect.DeepEqual(asset, []string{usdc.Code, usdc.Issuer}) {
+		t.Fatalf("expected the configured asset, got %v", asset)
+	}
+	if passphrase := w.getNetworkPassPhrase(); passphrase != "Custom Network ; 2026" {
+		t.Errorf("expected the configured passphrase, got %q", passphrase)
+	}
+
+	// only the configured asset is bridged, the tft of the network is not
+	ops := []operations.Operation{
+		operations.Payment{Asset: usdc, From: testTarget, To: testBridgeAccount, Amount: "5.0000000"},
+		operations.Payment{Asset: tft, From: testTarget, To: testBridgeAccount, Amount: "1.0000000"},
+	}
+	senders, foreign, _ := w.bridgedAssetSenders(ops, asset)
+	if !reflect.DeepEqual(senders, map[string]*big.Int{testTarget: big.NewInt(50000000)}) {
+		t.Errorf("expected only the configured asset to be bridged, got %v", senders)
+	}
+	if len(foreign) != 1 || foreign[0].Asset != tft.Code+":"+tft.Issuer {
+		t.Errorf("expected the tft payment to be a foreign payment, got %v", foreign)
+	}
+
+	// without configuration the tft of the network is bridged
+	w = &StellarWallet{config: &pkg.StellarConfig{StellarNetwork: "testnet"}}
+	if asset := w.getAssetCodeAndIssuer(); !reflect.DeepEqual(asset, tftAsset) {
+		t.Errorf("expected the testnet tft, got %v", asset)
+	}
+	if passphrase := w.getNetworkPassPhrase(); passphrase != network.TestNetworkPassphrase {
+		t.Errorf("expected the testnet passphrase, got %q", passphrase)
+	}
+}
@@ -0,0 +1,90 @@
+package stellar
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/amount"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+)
+
+// baseReserve is the stellar base reserve in stroops (0.5 XLM)
+const baseReserve = 5000000
+
+var (
+	// ErrInsufficientReserve is returned when paying the network fee would leave the bridge account below its minimum balance
+	ErrInsufficientReserve = errors.New("payment would leave the bridge account below the minimum balance reserve")
+	// ErrInsufficientBalance is returned when the bridge account does not hold enough of the bridged asset
+	ErrInsufficientBalance = errors.New("bridge account has insufficient balance for payment")
+)
+
+// CheckPaymentBalance checks that the bridge account can pay amount of the bridged asset
+// while keeping its native balance above the minimum reserve after paying the network fee
+func (w *StellarWallet) CheckPaymentBalance(paymentAmount uint64) error {
+	account, err := w.getAccountDetails(w.config.StellarBridgeAccount)
+	if err != nil {
+		return err
+	}
+
+	native, err := nativeBalance(account)
+	if err != nil {
+		return err
+	}
+
+	if native-w.baseFee() < minimumBalance(account) {
+		return ErrInsufficientReserve
+	}
+
+	asset := w.getAssetCodeAndIssuer()
+	for _, balance := range account.Balances {
+		if balance.Code != asset[0] || balance.Issuer != asset[1] {
+			continue
+		}
+		held, err := amount.ParseInt64(balance.Balance)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse asset balance")
+		}
+		if held < int64(paymentAmount) {
+			return ErrInsufficientBalance
+		}
+		return nil
+	}
+
+	return ErrInsufficientBalance
+}
+
+// GetBalance returns the XLM balance of the bridge account in stroops
+func (w *StellarWallet) GetBalance(ctx context.Context) (int64, error) {
+	var account hProtocol.Account
+	err := w.retry(ctx, func() (err error) {
+		account, err = w.getAccountDetails(w.config.StellarBridgeAccount)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return nativeBalance(account)
+}
+
+// nativeBalance returns the XLM balance of an account in stroops
+func nativeBalance(account hProtocol.Account) (int64, error) {
+	for _, balance := range account.Balances {
+		if balance.Type != "native" {
+			continue
+		}
+		native, err := amount.ParseInt64(balance.Balance)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to parse native balance")
+		}
+		return native, nil
+	}
+
+	return 0, nil
+}
+
+// minimumBalance returns the minimum XLM balance in stroops an account must hold
+func minimumBalance(account hProtocol.Account) int64 {
+	entries := 2 + int64(account.SubentryCount) + int64(account.NumSponsoring) - int64(account.NumSponsored)
+	return entries * baseReserve
+}
@@ -0,0 +1,84 @@
+package stellar
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// horizonFailoverThreshold is the amount of consecutive failed requests after which the next horizon endpoint is used
+const horizonFailoverThreshold = 3
+
+// horizonEndpoints tracks the horizon endpoint the wallet uses, it moves to the next endpoint once the active one
+// keeps failing and wraps around to the first endpoint after the last one
+type horizonEndpoints struct {
+	mu       sync.Mutex
+	urls     []string
+	active   int
+	failures int
+}
+
+func newHorizonEndpoints(urls []string) *horizonEndpoints {
+	e := &horizonEndpoints{urls: urls}
+	e.setMetric()
+	return e
+}
+
+// url returns the active endpoint
+func (e *horizonEndpoints) url() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.urls[e.active]
+}
+
+// report records the outcome of a request to url, failures of an endpoint that is no longer active are ignored
+func (e *horizonEndpoints) report(url string, failed bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if url != e.urls[e.active] {
+		return
+	}
+	if !failed {
+		e.failures = 0
+		return
+	}
+
+	e.failures++
+	if e.failures < horizonFailoverThreshold || len(e.urls) == 1 {
+		return
+	}
+
+	e.active = (e.active + 1) % len(e.urls)
+	e.failures = 0
+	e.setMetric()
+	horizonFailovers.Inc()
+	log.Warn().Str("failed", url).Str("active", e.urls[e.active]).Msg("horizon endpoint keeps failing, switching to the next endpoint")
+}
+
+func (e *horizonEndpoints) setMetric() {
+	for i, url := range e.urls {
+		value := 0.0
+		if i == e.active {
+			value = 1
+		}
+		horizonActive.Set(value, url)
+	}
+}
+
+// failoverTransport reports the outcome of the requests to a horizon endpoint, server errors, rate limiting and
+// requests that got no response count as failures
+type failoverTransport struct {
+	base      http.RoundTripper
+	url       string
+	endpoints *horizonEndpoints
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	failed := err != nil || resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+	t.endpoints.report(t.url, failed)
+	return resp, err
+}
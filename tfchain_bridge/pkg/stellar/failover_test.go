@@ -0,0 +1,89 @@
+package stellar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHorizonFailover(t *testing.T) {
+	var primaryRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryRequests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"type": "error", "title": "Service Unavailable", "status": 503}`)
+	}))
+	t.Cleanup(primary.Close)
+	secondary := pagingHorizon(t, 6, 10, nil)
+
+	w := newStreamWallet(secondary)
+	w.horizon = newHorizonEndpoints([]string{primary.URL, secondary.URL})
+
+	// the primary keeps failing, the wallet switches to the secondary
+	for i := 0; i < horizonFailoverThreshold; i++ {
+		client, err := w.getHorizonClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.HorizonURL != primary.URL {
+			t.Fatalf("expected request %d to go to the primary, got %s", i, client.HorizonURL)
+		}
+		if _, err := w.getAccountDetails(testBridgeAccount); err == nil {
+			t.Fatal("expected the primary to fail")
+		}
+	}
+	if active := w.horizon.url(); active != secondary.URL {
+		t.Fatalf("expected the secondary to be active after %d failures, got %s", horizonFailoverThreshold, active)
+	}
+
+	// monitoring continues from the cursor on the secondary
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mintChan := make(chan MintEventSubscription)
+	go func() {
+		_ = w.StreamBridgeStellarTransactions(ctx, mintChan, "4", StreamOptions{})
+	}()
+	select {
+	case sub := <-mintChan:
+		if token := sub.Events[0].Tx.PT; token != "5" {
+			t.Errorf("expected the deposit after the cursor, got the deposit with paging token %s", token)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no deposit was streamed from the secondary")
+	}
+	if requests := atomic.LoadInt32(&primaryRequests); requests != horizonFailoverThreshold {
+		t.Errorf("expected %d requests to the primary, got %d", horizonFailoverThreshold, requests)
+	}
+}
+
+func TestHorizonEndpointsIgnoreStaleFailures(t *testing.T) {
+	e := newHorizonEndpoints([]string{"http://primary", "http://secondary"})
+	for i := 0; i < horizonFailoverThreshold; i++ {
+		e.report("http://primary", true)
+	}
+	if active := e.url(); active != "http://secondary" {
+		t.Fatalf("expected the secondary to be active, got %s", active)
+	}
+
+	// requests still in flight to the primary do not move the secondary on
+	for i := 0; i < horizonFailoverThreshold; i++ {
+		e.report("http://primary", true)
+	}
+	if active := e.url(); active != "http://secondary" {
+		t.Errorf("expected failures of the primary to be ignored, got %s active", active)
+	}
+
+	// a success resets the failure count of the active endpoint
+	e.report("http://secondary", true)
+	e.report("http://secondary", true)
+	e.report("http://secondary", false)
+	e.report("http://secondary", true)
+	if active := e.url(); active != "http://secondary" {
+		t.Errorf("expected the secondary to stay active, got %s", active)
+	}
+}
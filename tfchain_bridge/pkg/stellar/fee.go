@@ -0,0 +1,184 @@
+package stellar
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// insufficientFeeCode is the result code of a transaction rejected because its fee is below the surge price
+const insufficientFeeCode = "tx_insufficient_fee"
+
+// feeStatsTTL is how long fetched fee stats are used before they are fetched again
+const feeStatsTTL = 30 * time.Second
+
+// feeStatsCache keeps the fee stats of horizon briefly so not every fee bump queries them
+type feeStatsCache struct {
+	mu        sync.Mutex
+	stats     hProtocol.FeeStats
+	fetchedAt time.Time
+}
+
+func (c *feeStatsCache) get(now time.Time, fetch func() (hProtocol.FeeStats, error)) (hProtocol.FeeStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && now.Sub(c.fetchedAt) < feeStatsTTL {
+		return c.stats, nil
+	}
+
+	stats, err := fetch()
+	if err != nil {
+		return hProtocol.FeeStats{}, err
+	}
+	c.stats, c.fetchedAt = stats, now
+	return stats, nil
+}
+
+// feePercentile returns the percentile of the max fees bid in the last ledgers
+func feePercentile(fees hProtocol.FeeDistribution, percentile int) (int64, error) {
+	switch percentile {
+	case 10:
+		return fees.P10, nil
+	case 20:
+		return fees.P20, nil
+	case 30:
+		return fees.P30, nil
+	case 40:
+		return fees.P40, nil
+	case 50:
+		return fees.P50, nil
+	case 60:
+		return fees.P60, nil
+	case 70:
+		return fees.P70, nil
+	case 80:
+		return fees.P80, nil
+	case 90:
+		return fees.P90, nil
+	case 95:
+		return fees.P95, nil
+	case 99:
+		return fees.P99, nil
+	}
+	return 0, fmt.Errorf("unsupported fee percentile %d, expected one of 10, 20, ..., 90, 95 or 99", percentile)
+}
+
+// baseFee returns the base fee of the bridge payments, the fee is part of the signed transaction
+// so all validators must be configured with the same base fee for their signatures to match
+func (w *StellarWallet) baseFee() int64 {
+	if w.config.StellarBaseFee > 0 {
+		return w.config.StellarBaseFee
+	}
+	return paymentFee
+}
+
+// validateFees checks the configured base fee and fee bump ceiling
+func validateFees(config *pkg.StellarConfig) error {
+	if config.StellarBaseFee != 0 && config.StellarBaseFee < txnbuild.MinBaseFee {
+		return fmt.Errorf("stellar base fee %d is below the minimum of %d", config.StellarBaseFee, txnbuild.MinBaseFee)
+	}
+	if config.StellarMaxFee < 0 {
+		return fmt.Errorf("stellar max fee %d can not be negative", config.StellarMaxFee)
+	}
+	if config.StellarFeePercentile != 0 {
+		if _, err := feePercentile(hProtocol.FeeDistribution{}, config.StellarFeePercentile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feeBumpBaseFee returns the base fee of the first fee bump of a rejected payment, twice the payment base
+// fee or the configured percentile of the recent network fees if that is higher, bounded by the max fee
+func (w *StellarWallet) feeBumpBaseFee(client *horizonclient.Client) int64 {
+	fee := w.baseFee() * 2
+	if w.config.StellarFeePercentile == 0 {
+		return fee
+	}
+
+	stats, err := w.feeStats.get(time.Now(), client.FeeStats)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get fee stats, doubling the base fee instead")
+		return fee
+	}
+
+	// the percentile is validated on startup
+	networkFee, _ := feePercentile(stats.MaxFee, w.config.StellarFeePercentile)
+	if networkFee > fee {
+		fee = networkFee
+	}
+	if fee > w.config.StellarMaxFee {
+		fee = w.config.StellarMaxFee
+	}
+	return fee
+}
+
+// isInsufficientFee returns true if horizon rejected a transaction because its fee is too low
+func isInsufficientFee(err error) bool {
+	var hError *horizonclient.Error
+	if !errors.As(err, &hError) {
+		return false
+	}
+
+	codes, err := hError.ResultCodes()
+	if err != nil || codes == nil {
+		return false
+	}
+	return codes.TransactionCode == insufficientFeeCode
+}
+
+// submitFeeBump wraps a transaction rejected for its fee in fee bump transactions paid by the bridge account,
+// doubling the base fee on every rejection up to the configured maximum fee. The fee bump is signed with the
+// bridge key only, so its weight must meet the low threshold of the bridge account. Unlike the base fee of the
+// payment, the fee bump is not signed by the other validators so its fee can follow the network fees.
+func (w *StellarWallet) submitFeeBump(ctx context.Context, client *horizonclient.Client, txn *txnbuild.Transaction) error {
+	err := errors.New("fee bumps are disabled")
+	for fee := w.feeBumpBaseFee(client); fee <= w.config.StellarMaxFee; fee *= 2 {
+		feeBump, bErr := txnbuild.NewFeeBumpTransaction(txnbuild.FeeBumpTransactionParams{
+			Inner:               txn,
+			FeeAccount:          w.config.StellarBridgeAccount,
+			BaseFee:             fee,
+			EnableMuxedAccounts: true,
+		})
+		if bErr != nil {
+			return errors.Wrap(bErr, "failed to build fee bump transaction")
+		}
+
+		hash, bErr := feeBump.Hash(w.getNetworkPassPhrase())
+		if bErr != nil {
+			return errors.Wrap(bErr, "failed to hash fee bump transaction")
+		}
+		signature, bErr := w.signer.Sign(ctx, hash)
+		if bErr != nil {
+			return errors.Wrap(bErr, "failed to sign fee bump transaction")
+		}
+		feeBump, bErr = feeBump.AddSignatureBase64(w.getNetworkPassPhrase(), w.signer.Address(), base64.StdEncoding.EncodeToString(signature))
+		if bErr != nil {
+			return errors.Wrap(bErr, "failed to sign fee bump transaction")
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		log.Warn().Int64("base_fee", fee).Msg("payment rejected for its fee, submitting a fee bump")
+		err = w.retry(ctx, func() error {
+			_, err := client.SubmitFeeBumpTransaction(feeBump)
+			return err
+		})
+		if !isInsufficientFee(err) {
+			return err
+		}
+	}
+
+	return errors.Wrapf(err, "fee is still insufficient at the maximum fee of %d", w.config.StellarMaxFee)
+}
@@ -0,0 +1,191 @@
+package stellar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// surgeHorizon rejects the submitted transactions with a base fee below the surge price
+type surgeHorizon struct {
+	*httptest.Server
+	surge int64
+
+	mu sync.Mutex
+	// fees are the base fees of the submitted transactions, fee bumps are negative
+	fees []int64
+}
+
+func newSurgeHorizon(t *testing.T, account string, surge int64) *surgeHorizon {
+	horizon := &surgeHorizon{surge: surge}
+	horizon.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet && r.URL.Path == "/accounts/"+account {
+			fmt.Fprintf(w, `{"id": %q, "account_id": %q, "sequence": "100"}`, account, account)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/transactions" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type": "not_found", "title": "Resource Missing", "status": 404}`)
+			return
+		}
+
+		generic, err := txnbuild.TransactionFromXDR(r.FormValue("tx"))
+		if err != nil {
+			t.Errorf("invalid transaction submitted: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var fee int64
+		if feeBump, ok := generic.FeeBump(); ok {
+			fee = -feeBump.BaseFee()
+		} else {
+			txn, _ := generic.Transaction()
+			fee = txn.BaseFee()
+		}
+		horizon.mu.Lock()
+		horizon.fees = append(horizon.fees, fee)
+		horizon.mu.Unlock()
+
+		if fee < 0 {
+			fee = -fee
+		}
+		if fee < horizon.surge {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"type": "transaction_failed", "title": "Transaction Failed", "status": 400, "extras": {"result_codes": {"transaction": "tx_insufficient_fee"}}}`)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(hProtocol.Transaction{Hash: "accepted", Successful: true}); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(horizon.Close)
+	return horizon
+}
+
+func TestSubmitTransactionFeeBump(t *testing.T) {
+	tests := []struct {
+		name   string
+		surge  int64
+		maxFee int64
+		fees   []int64
+		failed bool
+	}{
+		{name: "accepted at the base fee", surge: 100, maxFee: 1000, fees: []int64{100}},
+		{name: "rejected then fee bumped", surge: 400, maxFee: 1000, fees: []int64{100, -200, -400}},
+		{name: "fee bumps disabled", surge: 400, fees: []int64{100}, failed: true},
+		{name: "surge above the maximum fee", surge: 400, maxFee: 300, fees: []int64{100, -200}, failed: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kp := keypair.MustRandom()
+			horizon := newSurgeHorizon(t, kp.Address(), test.surge)
+			wallet := &StellarWallet{
+				signer: &keypairSigner{kp: kp},
+				config: &pkg.StellarConfig{
+					StellarBridgeAccount: kp.Address(),
+					StellarNetwork:       "testnet",
+					StellarHorizonUrl:    horizon.URL,
+					HorizonTimeout:       time.Second,
+					StellarBaseFee:       txnbuild.MinBaseFee,
+					StellarMaxFee:        test.maxFee,
+				},
+				horizon: newHorizonEndpoints([]string{horizon.URL}),
+			}
+
+			txn, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+				SourceAccount: &txnbuild.SimpleAccount{AccountID: kp.Address(), Sequence: 100},
+				Operations:    []txnbuild.Operation{&txnbuild.Payment{Destination: testTarget, Amount: "1", Asset: txnbuild.NativeAsset{}}},
+				BaseFee:       wallet.baseFee(),
+				Timebounds:    txnbuild.NewInfiniteTimeout(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			txn, err = txn.Sign(wallet.getNetworkPassPhrase(), kp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = wallet.submitTransaction(context.Background(), txn)
+			if (err != nil) != test.failed {
+				t.Errorf("expected failure %t, got %v", test.failed, err)
+			}
+			if !reflect.DeepEqual(horizon.fees, test.fees) {
+				t.Errorf("expected submissions with base fees %v, got %v", test.fees, horizon.fees)
+			}
+		})
+	}
+}
+
+func TestFeeBumpBaseFee(t *testing.T) {
+	tests := []struct {
+		name       string
+		percentile int
+		maxFee     int64
+		fee        int64
+	}{
+		{name: "percentile disabled", maxFee: 10000, fee: 200},
+		{name: "network fee above the doubled base fee", percentile: 90, maxFee: 10000, fee: 1500},
+		{name: "network fee below the doubled base fee", percentile: 10, maxFee: 10000, fee: 200},
+		{name: "network fee above the maximum fee", percentile: 99, maxFee: 3000, fee: 3000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int
+			horizon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/fee_stats" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				requests++
+				w.Header().Set("Content-Type", "application/json")
+				stats := hProtocol.FeeStats{MaxFee: hProtocol.FeeDistribution{Max: 10000, P10: 100, P50: 300, P90: 1500, P99: 5000}}
+				if err := json.NewEncoder(w).Encode(stats); err != nil {
+					t.Error(err)
+				}
+			}))
+			t.Cleanup(horizon.Close)
+
+			wallet := &StellarWallet{
+				config: &pkg.StellarConfig{
+					StellarNetwork:       "testnet",
+					StellarHorizonUrl:    horizon.URL,
+					HorizonTimeout:       time.Second,
+					StellarBaseFee:       txnbuild.MinBaseFee,
+					StellarMaxFee:        test.maxFee,
+					StellarFeePercentile: test.percentile,
+				},
+				horizon: newHorizonEndpoints([]string{horizon.URL}),
+			}
+			client, err := wallet.getHorizonClient()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				if fee := wallet.feeBumpBaseFee(client); fee != test.fee {
+					t.Errorf("expected a fee bump base fee of %d, got %d", test.fee, fee)
+				}
+			}
+			expected := 1
+			if test.percentile == 0 {
+				expected = 0
+			}
+			if requests != expected {
+				t.Errorf("expected the fee stats to be fetched %d times, got %d", expected, requests)
+			}
+		})
+	}
+}
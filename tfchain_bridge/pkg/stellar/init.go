@@ -0,0 +1,130 @@
+package stellar
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+)
+
+// AccountConfig is the multisig configuration of the bridge account
+type AccountConfig struct {
+	Account         string           `json:"account"`
+	LowThreshold    uint8            `json:"low_threshold"`
+	MediumThreshold uint8            `json:"medium_threshold"`
+	HighThreshold   uint8            `json:"high_threshold"`
+	Signers         map[string]int32 `json:"signers"`
+}
+
+// SignerOperations returns the set options operations that add the validator signers with weight 1 to the
+// account and set its thresholds, signers and thresholds that are already configured are left out so running
+// it on a configured account results in no operations. Signers are added before the thresholds are raised.
+func SignerOperations(account hProtocol.Account, signers []string, threshold uint8) ([]txnbuild.Operation, error) {
+	if threshold == 0 || int(threshold) > len(signers) {
+		return nil, fmt.Errorf("threshold %d must be between 1 and the amount of signers %d", threshold, len(signers))
+	}
+
+	current := make(map[string]int32, len(account.Signers))
+	for _, signer := range account.Signers {
+		current[signer.Key] = signer.Weight
+	}
+
+	var ops []txnbuild.Operation
+	for _, signer := range signers {
+		if !strkey.IsValidEd25519PublicKey(signer) {
+			return nil, fmt.Errorf("invalid signer %s", signer)
+		}
+		if current[signer] == 1 {
+			continue
+		}
+		ops = append(ops, &txnbuild.SetOptions{
+			Signer: &txnbuild.Signer{Address: signer, Weight: 1},
+		})
+	}
+
+	thresholds := account.Thresholds
+	if thresholds.LowThreshold != threshold || thresholds.MedThreshold != threshold || thresholds.HighThreshold != threshold {
+		ops = append(ops, &txnbuild.SetOptions{
+			LowThreshold:    txnbuild.NewThreshold(txnbuild.Threshold(threshold)),
+			MediumThreshold: txnbuild.NewThreshold(txnbuild.Threshold(threshold)),
+			HighThreshold:   txnbuild.NewThreshold(txnbuild.Threshold(threshold)),
+		})
+	}
+
+	return ops, nil
+}
+
+// ConfigureSigners sets up the validator signers and thresholds of the bridge account, the transaction is
+// signed with the bridge key which must be able to change the account. The transaction is only submitted if
+// submit is set, its envelope is returned either way and is empty if the account is configured already.
+func (w *StellarWallet) ConfigureSigners(ctx context.Context, signers []string, threshold uint8, submit bool) (AccountConfig, string, error) {
+	account, err := w.getAccountDetails(w.config.StellarBridgeAccount)
+	if err != nil {
+		return AccountConfig{}, "", err
+	}
+
+	ops, err := SignerOperations(account, signers, threshold)
+	if err != nil {
+		return AccountConfig{}, "", err
+	}
+	if len(ops) == 0 {
+		return accountConfig(account), "", nil
+	}
+
+	txn, err := w.createTransaction(ctx, txnbuild.TransactionParams{
+		SourceAccount:        &account,
+		IncrementSequenceNum: true,
+		Operations:           ops,
+		BaseFee:              w.baseFee(),
+		Timebounds:           txnbuild.NewTimeout(300),
+	}, true)
+	if err != nil {
+		return AccountConfig{}, "", err
+	}
+
+	envelope, err := txn.Base64()
+	if err != nil {
+		return AccountConfig{}, "", errors.Wrap(err, "failed to encode transaction")
+	}
+
+	if !submit {
+		return accountConfig(account), envelope, nil
+	}
+
+	if err := w.submitTransaction(ctx, txn); err != nil {
+		return AccountConfig{}, envelope, err
+	}
+
+	account, err = w.getAccountDetails(w.config.StellarBridgeAccount)
+	if err != nil {
+		return AccountConfig{}, envelope, err
+	}
+	return accountConfig(account), envelope, nil
+}
+
+// GetAccountConfig loads the current multisig configuration of the bridge account
+func (w *StellarWallet) GetAccountConfig() (AccountConfig, error) {
+	account, err := w.getAccountDetails(w.config.StellarBridgeAccount)
+	if err != nil {
+		return AccountConfig{}, err
+	}
+	return accountConfig(account), nil
+}
+
+func accountConfig(account hProtocol.Account) AccountConfig {
+	signers := make(map[string]int32, len(account.Signers))
+	for _, signer := range account.Signers {
+		signers[signer.Key] = signer.Weight
+	}
+
+	return AccountConfig{
+		Account:         account.AccountID,
+		LowThreshold:    account.Thresholds.LowThreshold,
+		MediumThreshold: account.Thresholds.MedThreshold,
+		HighThreshold:   account.Thresholds.HighThreshold,
+		Signers:         signers,
+	}
+}
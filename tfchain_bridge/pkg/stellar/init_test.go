@@ -0,0 +1,131 @@
+package stellar
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+)
+
+func TestSignerOperations(t *testing.T) {
+	const (
+		first  = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		second = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+	)
+	signers := []string{first, second}
+	configured := hProtocol.AccountThresholds{LowThreshold: 2, MedThreshold: 2, HighThreshold: 2}
+
+	tests := []struct {
+		name      string
+		account   hProtocol.Account
+		signers   []string
+		threshold uint8
+		// added are the signers the operations add, thresholds is set if the operations change the thresholds
+		added      []string
+		thresholds bool
+		invalid    bool
+	}{
+		{name: "new account", signers: signers, threshold: 2, added: signers, thresholds: true},
+		{
+			name:       "signer configured already",
+			account:    hProtocol.Account{Signers: []hProtocol.Signer{{Key: first, Weight: 1}}},
+			signers:    signers,
+			threshold:  2,
+			added:      []string{second},
+			thresholds: true,
+		},
+		{
+			name:      "signer with another weight",
+			account:   hProtocol.Account{Signers: []hProtocol.Signer{{Key: first, Weight: 5}, {Key: second, Weight: 1}}, Thresholds: configured},
+			signers:   signers,
+			threshold: 2,
+			added:     []string{first},
+		},
+		{
+			name:       "thresholds changed",
+			account:    hProtocol.Account{Signers: []hProtocol.Signer{{Key: first, Weight: 1}, {Key: second, Weight: 1}}, Thresholds: configured},
+			signers:    signers,
+			threshold:  1,
+			thresholds: true,
+		},
+		{
+			name:      "configured already",
+			account:   hProtocol.Account{Signers: []hProtocol.Signer{{Key: first, Weight: 1}, {Key: second, Weight: 1}}, Thresholds: configured},
+			signers:   signers,
+			threshold: 2,
+		},
+		{name: "threshold above the signers", signers: signers, threshold: 3, invalid: true},
+		{name: "zero threshold", signers: signers, invalid: true},
+		{name: "invalid signer", signers: []string{first, "SBQWY3DNPFWGSZTFNV4WQZLBOJ2GQYLTMJSWK3TTMVXWIZLSMVZXI23ZPE4EQHZP"}, threshold: 1, invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ops, err := SignerOperations(test.account, test.signers, test.threshold)
+			if test.invalid {
+				if err == nil {
+					t.Error("expected the configuration to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var added []string
+			thresholds := false
+			for i, op := range ops {
+				options, ok := op.(*txnbuild.SetOptions)
+				if !ok {
+					t.Fatalf("operation %d is a %T, expected set options", i, op)
+				}
+				switch {
+				case options.Signer != nil:
+					if thresholds {
+						t.Error("signers must be added before the thresholds are raised")
+					}
+					if options.Signer.Weight != 1 {
+						t.Errorf("expected signer %s to get weight 1, got %d", options.Signer.Address, options.Signer.Weight)
+					}
+					added = append(added, options.Signer.Address)
+				case options.LowThreshold != nil:
+					thresholds = true
+					for _, threshold := range []*txnbuild.Threshold{options.LowThreshold, options.MediumThreshold, options.HighThreshold} {
+						if threshold == nil || *threshold != txnbuild.Threshold(test.threshold) {
+							t.Errorf("expected all thresholds to be %d", test.threshold)
+						}
+					}
+				default:
+					t.Errorf("operation %d changes nothing", i)
+				}
+			}
+			if strings.Join(added, ",") != strings.Join(test.added, ",") {
+				t.Errorf("expected signers %v to be added, got %v", test.added, added)
+			}
+			if thresholds != test.thresholds {
+				t.Errorf("expected thresholds set %t, got %t", test.thresholds, thresholds)
+			}
+		})
+	}
+}
+
+func TestConfigureSignersConfiguredAlready(t *testing.T) {
+	account := hProtocol.Account{
+		AccountID:  testBridgeAccount,
+		Signers:    []hProtocol.Signer{{Key: testTarget, Weight: 1}},
+		Thresholds: hProtocol.AccountThresholds{LowThreshold: 1, MedThreshold: 1, HighThreshold: 1},
+	}
+	wallet := newTestWallet(newTestHorizon(t, account))
+
+	config, envelope, err := wallet.ConfigureSigners(context.Background(), []string{testTarget}, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope != "" {
+		t.Errorf("expected no transaction for a configured account, got %s", envelope)
+	}
+	if config.Account != testBridgeAccount || config.MediumThreshold != 1 || config.Signers[testTarget] != 1 {
+		t.Errorf("unexpected account configuration %+v", config)
+	}
+}
@@ -0,0 +1,11 @@
+package stellar
+
+import (
+	"github.com/threefoldtech/tfchain_bridge/pkg/metrics"
+)
+
+var (
+	horizonActive     = metrics.NewGauge("bridge_horizon_active", "1 for the horizon endpoint the bridge uses, 0 for the standby endpoints", "url")
+	horizonFailovers  = metrics.NewCounter("bridge_horizon_failovers_total", "Switches to another horizon endpoint after repeated failures")
+	invalidSignatures = metrics.NewCounter("bridge_invalid_signatures_total", "Collected signatures dropped before submission because they do not verify against the payment")
+)
@@ -0,0 +1,19 @@
+package stellar
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ParseAccountAddress parses a G... account address or an M... muxed account address and returns
+// the address of the underlying account and whether the address is muxed. Payments to a muxed
+// address carry its sub-account id, so they do not need a memo to be attributed.
+func ParseAccountAddress(address string) (string, bool, error) {
+	account, err := xdr.AddressToMuxedAccount(address)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "invalid stellar address %s", address)
+	}
+
+	accountID := account.ToAccountId()
+	return accountID.Address(), account.Type == xdr.CryptoKeyTypeKeyTypeMuxedEd25519, nil
+}
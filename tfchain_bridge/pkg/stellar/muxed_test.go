@@ -0,0 +1,41 @@
+package stellar
+
+import "testing"
+
+// testMuxedTarget is sub-account 7 of testTarget
+const testMuxedTarget = "MBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU2AAAAAAAAAAAA7LCE"
+
+func TestParseAccountAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		account string
+		muxed   bool
+		invalid bool
+	}{
+		{name: "account", address: testTarget, account: testTarget},
+		{name: "muxed account", address: testMuxedTarget, account: testTarget, muxed: true},
+		{name: "empty", address: "", invalid: true},
+		{name: "garbage", address: "not_an_address", invalid: true},
+		{name: "bad checksum", address: testMuxedTarget[:len(testMuxedTarget)-1] + "A", invalid: true},
+		{name: "truncated muxed account", address: testMuxedTarget[:56], invalid: true},
+		{name: "secret seed", address: "SBQWY3DNPFWGSZTFNV4WQZLBOJ2GQYLTMJSWK3TTMVXWIZLSMVZXI23ZPE4EQHZP", invalid: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			account, muxed, err := ParseAccountAddress(test.address)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected %q to be rejected", test.address)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if account != test.account || muxed != test.muxed {
+				t.Errorf("expected account %s muxed %t, got %s muxed %t", test.account, test.muxed, account, muxed)
+			}
+		})
+	}
+}
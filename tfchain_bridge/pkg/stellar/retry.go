@@ -0,0 +1,51 @@
+package stellar
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// IsRetryableError returns true if a horizon request failed for a reason that can go away by retrying,
+// server side errors, rate limiting and timeouts are retryable while other client errors are not
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var hError *horizonclient.Error
+	if errors.As(err, &hError) {
+		status := hError.Problem.Status
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// retry calls fn until it succeeds, fails with a non retryable error, the configured amount
+// of retries is exhausted or the context is cancelled
+func (w *StellarWallet) retry(ctx context.Context, fn func() error) error {
+	bo := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), uint64(w.config.HorizonMaxRetries)), ctx)
+
+	err := backoff.Retry(func() error {
+		err := fn()
+		if err != nil && !IsRetryableError(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, bo)
+	if IsRetryableError(err) {
+		return pkg.Transient(err)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package stellar
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/txnbuild"
+)
+
+// reserveSequence reserves the next sequence number of the bridge account for a payment signed by this validator,
+// payments created concurrently or back to back each get their own sequence number
+func (w *StellarWallet) reserveSequence() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sequenceNumber++
+	return w.sequenceNumber
+}
+
+// useSequence records that a payment with sequenceNumber is submitted, the next reservation follows it. Sequence
+// numbers reserved after it are kept so the pending payments signed with them do not collide with new payments.
+func (w *StellarWallet) useSequence(sequenceNumber int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if sequenceNumber > w.sequenceNumber {
+		w.sequenceNumber = sequenceNumber
+	}
+}
+
+// releaseSequence gives back the sequence number of a payment that was not signed. It can only be reserved again if
+// nothing was reserved after it, otherwise the gap is left and payments signed after it become stale.
+func (w *StellarWallet) releaseSequence(sequenceNumber int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if sequenceNumber == w.sequenceNumber {
+		w.sequenceNumber--
+		return
+	}
+	log.Warn().Int64("sequence", sequenceNumber).Int64("reserved", w.sequenceNumber).Msg("can not release sequence number, a later one is reserved already")
+}
+
+// signReserved signs a payment with a reserved sequence number, the sequence number is released if the payment
+// can not be signed
+func (w *StellarWallet) signReserved(ctx context.Context, params txnbuild.TransactionParams) (*txnbuild.Transaction, error) {
+	txn, err := w.createTransaction(ctx, params, true)
+	if err != nil {
+		if sequenceNumber, sErr := params.SourceAccount.GetSequenceNumber(); sErr == nil {
+			w.releaseSequence(sequenceNumber)
+		}
+		return nil, err
+	}
+	return txn, nil
+}
@@ -0,0 +1,104 @@
+package stellar
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+)
+
+// testSigner signs with a random key, it fails while err is set
+type testSigner struct {
+	kp  *keypair.Full
+	err error
+}
+
+func (s *testSigner) Address() string {
+	return s.kp.Address()
+}
+
+func (s *testSigner) Sign(ctx context.Context, hash [32]byte) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.kp.Sign(hash[:])
+}
+
+func TestReserveSequence(t *testing.T) {
+	signer := &testSigner{kp: keypair.MustRandom()}
+	w := newTestWallet(newTestHorizon(t, hProtocol.Account{AccountID: testBridgeAccount}))
+	w.signer = signer
+	w.sequenceNumber = 100
+	deposit := hex.EncodeToString(make([]byte, 32))
+	ctx := context.Background()
+
+	// back to back refunds and withdraws each get their own sequence number
+	_, first, err := w.CreateRefundAndReturnSignature(ctx, testTarget, 50000000, deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, err := w.CreateRefundAndReturnSignature(ctx, testTarget, 50000000, deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, third, err := w.CreatePaymentAndReturnSignature(ctx, testTarget, 50000000, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 101 || second != 102 || third != 103 {
+		t.Errorf("expected sequence numbers 101, 102 and 103, got %d, %d and %d", first, second, third)
+	}
+
+	// the sequence number of a refund that could not be signed is reserved again
+	signer.err = errors.New("signer unavailable")
+	if _, _, err := w.CreateRefundAndReturnSignature(ctx, testTarget, 50000000, deposit); err == nil {
+		t.Fatal("expected the refund to fail")
+	}
+	signer.err = nil
+	_, next, err := w.CreateRefundAndReturnSignature(ctx, testTarget, 50000000, deposit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 104 {
+		t.Errorf("expected the released sequence number 104, got %d", next)
+	}
+}
+
+func TestReserveSequenceConcurrent(t *testing.T) {
+	const refunds = 20
+	w := newTestWallet(newTestHorizon(t, hProtocol.Account{AccountID: testBridgeAccount}))
+	w.signer = &testSigner{kp: keypair.MustRandom()}
+	deposit := hex.EncodeToString(make([]byte, 32))
+
+	var wg sync.WaitGroup
+	sequences := make(chan uint64, refunds)
+	for i := 0; i < refunds; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, sequence, err := w.CreateRefundAndReturnSignature(context.Background(), testTarget, 50000000, deposit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			sequences <- sequence
+		}()
+	}
+	wg.Wait()
+	close(sequences)
+
+	seen := make(map[uint64]bool)
+	for sequence := range sequences {
+		if seen[sequence] {
+			t.Errorf("sequence number %d was reserved twice", sequence)
+		}
+		seen[sequence] = true
+	}
+	if len(seen) != refunds {
+		t.Errorf("expected %d distinct sequence numbers, got %d", refunds, len(seen))
+	}
+}
@@ -0,0 +1,128 @@
+package stellar
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// remoteSignerTimeout bounds a single request to a remote signer
+const remoteSignerTimeout = 10 * time.Second
+
+// Signer signs the transaction hashes of the bridge wallet, the key can be held
+// in process or by a remote signing service or HSM that never exposes it
+type Signer interface {
+	// Address is the public address of the signing key
+	Address() string
+	// Sign returns the ed25519 signature of the transaction hash
+	Sign(ctx context.Context, hash [32]byte) ([]byte, error)
+}
+
+// NewSigner creates the signer of the config, the remote signer if a signer url is set
+// and the keypair of the stellar seed otherwise
+func NewSigner(config *pkg.StellarConfig) (Signer, error) {
+	if config.StellarSignerURL == "" {
+		kp, err := keypair.ParseFull(config.StellarSeed)
+		if err != nil {
+			return nil, err
+		}
+		return &keypairSigner{kp: kp}, nil
+	}
+
+	if !strkey.IsValidEd25519PublicKey(config.StellarSignerAddress) {
+		return nil, fmt.Errorf("invalid stellar signer address %q", config.StellarSignerAddress)
+	}
+	return &remoteSigner{
+		url:     config.StellarSignerURL,
+		address: config.StellarSignerAddress,
+		client:  &http.Client{Timeout: remoteSignerTimeout},
+	}, nil
+}
+
+// keypairSigner signs with a keypair held in process
+type keypairSigner struct {
+	kp *keypair.Full
+}
+
+func (s *keypairSigner) Address() string {
+	return s.kp.Address()
+}
+
+func (s *keypairSigner) Sign(ctx context.Context, hash [32]byte) ([]byte, error) {
+	return s.kp.Sign(hash[:])
+}
+
+// remoteSigner delegates signing to a remote service, the service receives the address
+// and the hex encoded transaction hash and returns the base64 encoded signature
+type remoteSigner struct {
+	url     string
+	address string
+	client  *http.Client
+}
+
+type remoteSignRequest struct {
+	Address string `json:"address"`
+	Hash    string `json:"hash"`
+}
+
+type remoteSignResponse struct {
+	Signature string `json:"signature"`
+}
+
+func (s *remoteSigner) Address() string {
+	return s.address
+}
+
+func (s *remoteSigner) Sign(ctx context.Context, hash [32]byte) ([]byte, error) {
+	body, err := json.Marshal(remoteSignRequest{Address: s.address, Hash: hex.EncodeToString(hash[:])})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode sign request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create sign request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, pkg.Transient(errors.Wrap(err, "failed to reach remote signer"))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer responded with status %d", resp.StatusCode)
+	}
+
+	var signed remoteSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return nil, errors.Wrap(err, "failed to decode sign response")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote signer returned an invalid signature")
+	}
+
+	// never hand a signature of another key to the chain or the network
+	kp, err := keypair.ParseAddress(s.address)
+	if err != nil {
+		return nil, err
+	}
+	if err := kp.Verify(hash[:], signature); err != nil {
+		return nil, errors.Wrap(err, "remote signer returned a signature that does not verify")
+	}
+
+	return signature, nil
+}
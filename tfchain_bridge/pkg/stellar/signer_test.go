@@ -0,0 +1,142 @@
+package stellar
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// fakeRemoteSigner signs the requested hashes with kp, or with wrong when it is set
+type fakeRemoteSigner struct {
+	*httptest.Server
+	kp     *keypair.Full
+	wrong  *keypair.Full
+	status int
+}
+
+func newFakeRemoteSigner(t *testing.T, kp *keypair.Full) *fakeRemoteSigner {
+	signer := &fakeRemoteSigner{kp: kp, status: http.StatusOK}
+	signer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		if request.Address != kp.Address() {
+			t.Errorf("expected a request for %s, got %s", kp.Address(), request.Address)
+		}
+		if signer.status != http.StatusOK {
+			w.WriteHeader(signer.status)
+			return
+		}
+
+		hash, err := hex.DecodeString(request.Hash)
+		if err != nil {
+			t.Error(err)
+		}
+		key := signer.kp
+		if signer.wrong != nil {
+			key = signer.wrong
+		}
+		signature, err := key.Sign(hash)
+		if err != nil {
+			t.Error(err)
+		}
+		if err := json.NewEncoder(w).Encode(remoteSignResponse{Signature: base64.StdEncoding.EncodeToString(signature)}); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(signer.Close)
+	return signer
+}
+
+func TestNewSigner(t *testing.T) {
+	kp := keypair.MustRandom()
+
+	tests := []struct {
+		name    string
+		config  pkg.StellarConfig
+		address string
+		err     bool
+	}{
+		{name: "seed", config: pkg.StellarConfig{StellarSeed: kp.Seed()}, address: kp.Address()},
+		{name: "invalid seed", config: pkg.StellarConfig{StellarSeed: "seed"}, err: true},
+		{name: "remote", config: pkg.StellarConfig{StellarSignerURL: "http://signer", StellarSignerAddress: kp.Address()}, address: kp.Address()},
+		{name: "remote without address", config: pkg.StellarConfig{StellarSignerURL: "http://signer"}, err: true},
+		{name: "remote with a seed as address", config: pkg.StellarConfig{StellarSignerURL: "http://signer", StellarSignerAddress: kp.Seed()}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signer, err := NewSigner(&test.config)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if err == nil && signer.Address() != test.address {
+				t.Errorf("expected address %s, got %s", test.address, signer.Address())
+			}
+		})
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	kp := keypair.MustRandom()
+	hash := sha256.Sum256([]byte("transaction"))
+
+	tests := []struct {
+		name      string
+		wrong     bool
+		status    int
+		closed    bool
+		err       bool
+		transient bool
+	}{
+		{name: "signed", status: http.StatusOK},
+		{name: "signature of another key", wrong: true, status: http.StatusOK, err: true},
+		{name: "signer failure", status: http.StatusInternalServerError, err: true},
+		{name: "signer unreachable", closed: true, err: true, transient: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remote := newFakeRemoteSigner(t, kp)
+			remote.status = test.status
+			if test.wrong {
+				remote.wrong = keypair.MustRandom()
+			}
+			if test.closed {
+				remote.Close()
+			}
+
+			signer, err := NewSigner(&pkg.StellarConfig{StellarSignerURL: remote.URL, StellarSignerAddress: kp.Address()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			signature, err := signer.Sign(context.Background(), hash)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if pkg.IsTransient(err) != test.transient {
+				t.Errorf("expected transient %t, got %v", test.transient, err)
+			}
+			if err != nil {
+				return
+			}
+
+			// the remote signature is the signature of the in process key
+			local, err := (&keypairSigner{kp: kp}).Sign(context.Background(), hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(signature, local) {
+				t.Error("expected the remote signature to match the keypair signature")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package stellar
+
+import (
+	"encoding/base64"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// ErrNotEnoughSignatureWeight is returned when the combined weight of signatures is below the account threshold
+var ErrNotEnoughSignatureWeight = errors.New("not enough signature weight, aborting")
+
+// loadSigners stores the signer weights and medium threshold of the bridge account
+func (w *StellarWallet) loadSigners(account hProtocol.Account) {
+	w.signerWeights = make(map[string]int32, len(account.Signers))
+	for _, signer := range account.Signers {
+		w.signerWeights[signer.Key] = signer.Weight
+	}
+	w.signatureCount = int(account.Thresholds.MedThreshold)
+}
+
+// HasSignatureQuorum returns true if the combined weight of the signatures reaches the account threshold
+func (w *StellarWallet) HasSignatureQuorum(signatures []substrate.StellarSignature) bool {
+	_, err := w.selectSignatures(signatures)
+	return err == nil
+}
+
+// selectSignatures picks the heaviest signatures, in order, until their combined weight reaches
+// the medium threshold of the bridge account. Signatures of unknown signers are ignored.
+func (w *StellarWallet) selectSignatures(signatures []substrate.StellarSignature) ([]substrate.StellarSignature, error) {
+	candidates := make([]substrate.StellarSignature, 0, len(signatures))
+	seen := make(map[string]bool)
+	for _, sig := range signatures {
+		address := string(sig.StellarAddress)
+		if w.signerWeights[address] <= 0 || seen[address] {
+			continue
+		}
+		seen[address] = true
+		candidates = append(candidates, sig)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return w.signerWeights[string(candidates[i].StellarAddress)] > w.signerWeights[string(candidates[j].StellarAddress)]
+	})
+
+	var weight int
+	for i, sig := range candidates {
+		weight += int(w.signerWeights[string(sig.StellarAddress)])
+		if weight >= w.signatureCount {
+			return candidates[:i+1], nil
+		}
+	}
+
+	return nil, ErrNotEnoughSignatureWeight
+}
+
+// verifiedSignatures drops the signatures that do not verify against the envelope of txn or are not from a signer
+// of the bridge account, a corrupt or foreign signature would otherwise fail the submission of the whole payment
+func (w *StellarWallet) verifiedSignatures(txn *txnbuild.Transaction, signatures []substrate.StellarSignature) ([]substrate.StellarSignature, error) {
+	hash, err := txn.Hash(w.getNetworkPassPhrase())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash transaction")
+	}
+
+	valid := make([]substrate.StellarSignature, 0, len(signatures))
+	for _, sig := range signatures {
+		address := string(sig.StellarAddress)
+		if w.signerWeights[address] <= 0 {
+			log.Warn().Str("address", address).Msg("dropping signature of an address that is not a signer of the bridge account")
+			invalidSignatures.Inc()
+			continue
+		}
+		if err := verifySignature(address, hash[:], string(sig.Signature)); err != nil {
+			log.Warn().Err(err).Str("address", address).Msg("dropping signature that does not verify against the payment")
+			invalidSignatures.Inc()
+			continue
+		}
+		valid = append(valid, sig)
+	}
+
+	return valid, nil
+}
+
+// SignatureCheck is the outcome of verifying the signature of a signer on a payment
+type SignatureCheck struct {
+	Signer string `json:"signer"`
+	Weight int32  `json:"weight"`
+	Signed bool   `json:"signed"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+}
+
+// VerifyPaymentSignatures verifies the signatures collected for the withdraw payment of amount to target with
+// sequenceNumber against the payment envelope. Every signer of the bridge account is reported, signatures of
+// addresses that are not a signer are reported with weight 0.
+func (w *StellarWallet) VerifyPaymentSignatures(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) ([]SignatureCheck, error) {
+	// if no signature matches any time bounds they are reported against the payment signed now
+	maxTime, claimable, err := w.resolvePayment(target, amount, sequenceNumber, signatures)
+	if errors.Is(err, ErrStaleSignatures) {
+		maxTime, claimable, err = w.paymentMaxTime(time.Now()), false, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := w.paymentHash(target, amount, sequenceNumber, maxTime, claimable)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := make(map[string]bool)
+	var checks []SignatureCheck
+	for _, sig := range signatures {
+		address := string(sig.StellarAddress)
+		signed[address] = true
+		check := SignatureCheck{
+			Signer: address,
+			Weight: w.signerWeights[address],
+			Signed: true,
+		}
+
+		if err := verifySignature(address, hash[:], string(sig.Signature)); err != nil {
+			check.Error = err.Error()
+		} else {
+			check.Valid = true
+		}
+		checks = append(checks, check)
+	}
+
+	for signer, weight := range w.signerWeights {
+		if !signed[signer] {
+			checks = append(checks, SignatureCheck{Signer: signer, Weight: weight})
+		}
+	}
+
+	return checks, nil
+}
+
+func verifySignature(address string, hash []byte, signature string) error {
+	kp, err := keypair.ParseAddress(address)
+	if err != nil {
+		return errors.Wrap(err, "invalid signer address")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.Wrap(err, "signature is not base64 encoded")
+	}
+
+	return kp.Verify(hash, raw)
+}
@@ -0,0 +1,242 @@
+package stellar
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestHasSignatureQuorum(t *testing.T) {
+	const (
+		heavy   = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+		light   = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+		other   = "GDCAMOLMOTTIKJ6MRQ4WPXIUBWEV4CZS7QNVDNO65XKYOOEPYV5NZGDG"
+		unknown = "GA47YZA3PKFUZMPLQ3B5F2E3CJIB57TGGU7SPCQT2WAEYKN766PWIMB3"
+	)
+
+	var w StellarWallet
+	w.loadSigners(hProtocol.Account{
+		Signers: []hProtocol.Signer{
+			{Key: heavy, Weight: 2},
+			{Key: light, Weight: 1},
+			{Key: other, Weight: 1},
+		},
+		Thresholds: hProtocol.AccountThresholds{MedThreshold: 3},
+	})
+
+	tests := []struct {
+		name    string
+		signers []string
+		quorum  bool
+	}{
+		{name: "heavy signer alone", signers: []string{heavy}},
+		{name: "light signers", signers: []string{light, other}},
+		{name: "heavy and light signer", signers: []string{light, heavy}, quorum: true},
+		{name: "all signers", signers: []string{light, other, heavy}, quorum: true},
+		{name: "unknown signer", signers: []string{unknown, light, other}},
+		{name: "duplicate signature", signers: []string{heavy, heavy}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var signatures []substrate.StellarSignature
+			for _, signer := range test.signers {
+				signatures = append(signatures, substrate.StellarSignature{Signature: []byte("signature"), StellarAddress: []byte(signer)})
+			}
+			if quorum := w.HasSignatureQuorum(signatures); quorum != test.quorum {
+				t.Errorf("expected quorum %t, got %t", test.quorum, quorum)
+			}
+		})
+	}
+
+	t.Run("heaviest signatures are selected", func(t *testing.T) {
+		signatures := []substrate.StellarSignature{
+			{Signature: []byte("signature"), StellarAddress: []byte(light)},
+			{Signature: []byte("signature"), StellarAddress: []byte(other)},
+			{Signature: []byte("signature"), StellarAddress: []byte(heavy)},
+		}
+		selected, err := w.selectSignatures(signatures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(selected) != 2 || string(selected[0].StellarAddress) != heavy || string(selected[1].StellarAddress) != light {
+			t.Errorf("expected the heavy and the first light signature, got %d signatures", len(selected))
+		}
+	})
+}
+
+func TestVerifyPaymentSignatures(t *testing.T) {
+	const (
+		amount   = 50000000
+		sequence = 101
+	)
+	signers := []*keypair.Full{keypair.MustRandom(), keypair.MustRandom(), keypair.MustRandom()}
+	outsider := keypair.MustRandom()
+
+	w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount, StellarNetwork: "testnet"}}
+	account := hProtocol.Account{Thresholds: hProtocol.AccountThresholds{MedThreshold: 2}}
+	for _, signer := range signers {
+		account.Signers = append(account.Signers, hProtocol.Signer{Key: signer.Address(), Weight: 1})
+	}
+	w.loadSigners(account)
+
+	txHash, err := w.PaymentTransactionHash(testTarget, amount, sequence, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := hex.DecodeString(txHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sign returns the signature of kp on the payment, stored on chain for address
+	sign := func(kp *keypair.Full, address string) substrate.StellarSignature {
+		signature, err := kp.Sign(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return substrate.StellarSignature{Signature: []byte(base64.StdEncoding.EncodeToString(signature)), StellarAddress: []byte(address)}
+	}
+
+	type expected struct {
+		weight int32
+		signed bool
+		valid  bool
+	}
+	tests := []struct {
+		name       string
+		signatures []substrate.StellarSignature
+		checks     map[string]expected
+	}{
+		{
+			name:       "fully signed",
+			signatures: []substrate.StellarSignature{sign(signers[0], signers[0].Address()), sign(signers[1], signers[1].Address()), sign(signers[2], signers[2].Address())},
+			checks: map[string]expected{
+				signers[0].Address(): {weight: 1, signed: true, valid: true},
+				signers[1].Address(): {weight: 1, signed: true, valid: true},
+				signers[2].Address(): {weight: 1, signed: true, valid: true},
+			},
+		},
+		{
+			name:       "partially signed",
+			signatures: []substrate.StellarSignature{sign(signers[1], signers[1].Address())},
+			checks: map[string]expected{
+				signers[0].Address(): {weight: 1},
+				signers[1].Address(): {weight: 1, signed: true, valid: true},
+				signers[2].Address(): {weight: 1},
+			},
+		},
+		{
+			name:       "signature of another key",
+			signatures: []substrate.StellarSignature{sign(outsider, signers[0].Address()), sign(signers[1], signers[1].Address())},
+			checks: map[string]expected{
+				signers[0].Address(): {weight: 1, signed: true},
+				signers[1].Address(): {weight: 1, signed: true, valid: true},
+				signers[2].Address(): {weight: 1},
+			},
+		},
+		{
+			name:       "not a signer",
+			signatures: []substrate.StellarSignature{sign(outsider, outsider.Address())},
+			checks: map[string]expected{
+				outsider.Address():   {signed: true, valid: true},
+				signers[0].Address(): {weight: 1},
+				signers[1].Address(): {weight: 1},
+				signers[2].Address(): {weight: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checks, err := w.VerifyPaymentSignatures(testTarget, amount, sequence, test.signatures)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(checks) != len(test.checks) {
+				t.Fatalf("expected %d checks, got %+v", len(test.checks), checks)
+			}
+			for _, check := range checks {
+				want, ok := test.checks[check.Signer]
+				if !ok {
+					t.Errorf("unexpected check of %s", check.Signer)
+					continue
+				}
+				if check.Weight != want.weight || check.Signed != want.signed || check.Valid != want.valid {
+					t.Errorf("expected %s to be %+v, got %+v", check.Signer, want, check)
+				}
+				if check.Signed && !check.Valid && check.Error == "" {
+					t.Errorf("expected the error of the invalid signature of %s", check.Signer)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifiedSignatures(t *testing.T) {
+	const (
+		amount   = 50000000
+		sequence = 101
+	)
+	signers := []*keypair.Full{keypair.MustRandom(), keypair.MustRandom()}
+	outsider := keypair.MustRandom()
+
+	for _, test := range []struct {
+		name      string
+		threshold byte
+		submitted bool
+	}{
+		{name: "valid weight meets threshold", threshold: 1, submitted: true},
+		{name: "valid weight below threshold", threshold: 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount, StellarNetwork: "testnet"}}
+			account := hProtocol.Account{Thresholds: hProtocol.AccountThresholds{MedThreshold: test.threshold}}
+			for _, signer := range signers {
+				account.Signers = append(account.Signers, hProtocol.Signer{Key: signer.Address(), Weight: 1})
+			}
+			w.loadSigners(account)
+
+			txn, err := txnbuild.NewTransaction(w.paymentTransactionParams(testBridgeAccount, amount, testTarget, sequence, 0, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash, err := txn.Hash(w.getNetworkPassPhrase())
+			if err != nil {
+				t.Fatal(err)
+			}
+			sign := func(kp *keypair.Full, address string) substrate.StellarSignature {
+				signature, err := kp.Sign(hash[:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				return substrate.StellarSignature{Signature: []byte(base64.StdEncoding.EncodeToString(signature)), StellarAddress: []byte(address)}
+			}
+
+			valid := sign(signers[0], signers[0].Address())
+			// a signature stored for the second signer that was made with another key
+			invalid := sign(outsider, signers[1].Address())
+
+			verified, err := w.verifiedSignatures(txn, []substrate.StellarSignature{invalid, valid})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(verified, []substrate.StellarSignature{valid}) {
+				t.Fatalf("expected only the valid signature to be kept, got %d signatures", len(verified))
+			}
+
+			_, err = w.selectSignatures(verified)
+			if test.submitted && err != nil {
+				t.Errorf("expected the valid signature to be submitted, got %s", err)
+			}
+			if !test.submitted && !errors.Is(err, ErrNotEnoughSignatureWeight) {
+				t.Errorf("expected the payment not to be submitted, got %v", err)
+			}
+		})
+	}
+}
@@ -6,19 +6,21 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/stellar/go/amount"
 	"github.com/stellar/go/clients/horizonclient"
-	"github.com/stellar/go/keypair"
 	"github.com/stellar/go/network"
 	hProtocol "github.com/stellar/go/protocols/horizon"
 	horizoneffects "github.com/stellar/go/protocols/horizon/effects"
 	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/strkey"
 	"github.com/stellar/go/txnbuild"
 	"github.com/threefoldtech/substrate-client"
 	"github.com/threefoldtech/tfchain_bridge/pkg"
@@ -30,35 +32,74 @@ const (
 
 	stellarPrecision       = 1e7
 	stellarPrecisionDigits = 7
+
+	// paymentFee is the default base fee of a single operation payment of the bridge account
+	paymentFee = txnbuild.MinBaseFee * 1000
 )
 
+// ErrStaleSignatures is returned when collected signatures are for a transaction that can no longer be submitted
+var ErrStaleSignatures = errors.New("signatures are stale")
+
+// ErrMemoRequired is returned when an account only accepts payments with a memo
+var ErrMemoRequired = errors.New("account requires a memo")
+
+// ErrAccountNotFound is returned when an account does not exist on the stellar network
+var ErrAccountNotFound = errors.New("account does not exist")
+
+// ErrNoTrustline is returned when an account can not hold the bridged asset
+var ErrNoTrustline = errors.New("account has no trustline")
+
+// memoRequiredDataKey is the account data entry marking an account as requiring a memo
+const memoRequiredDataKey = "config.memo_required"
+
 // stellarWallet is the bridge wallet
 // Payments will be funded and fees will be taken with this wallet
 type StellarWallet struct {
-	keypair        *keypair.Full
-	config         *pkg.StellarConfig
+	signer Signer
+	config *pkg.StellarConfig
+	// signatureCount is the medium threshold of the bridge account
 	signatureCount int
+	// signerWeights maps the signers of the bridge account to their weight
+	signerWeights map[string]int32
+	// mu guards sequenceNumber, payments can be created concurrently. sequenceNumber is the
+	// last sequence number reserved for a payment of the bridge account
+	mu             sync.Mutex
 	sequenceNumber int64
+	feeStats       feeStatsCache
+	horizon        *horizonEndpoints
+	resolved       resolvedPayments
 }
 
 func NewStellarWallet(ctx context.Context, config *pkg.StellarConfig) (*StellarWallet, error) {
-	kp, err := keypair.ParseFull(config.StellarSeed)
+	if err := validateFees(config); err != nil {
+		return nil, pkg.InvalidConfig(err)
+	}
+	if err := validateAsset(config); err != nil {
+		return nil, pkg.InvalidConfig(err)
+	}
 
+	signer, err := NewSigner(config)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := horizonURLs(config)
 	if err != nil {
 		return nil, err
 	}
 
 	w := &StellarWallet{
-		keypair: kp,
+		signer:  signer,
 		config:  config,
+		horizon: newHorizonEndpoints(urls),
 	}
 
 	account, err := w.getAccountDetails(config.StellarBridgeAccount)
 	if err != nil {
 		return nil, err
 	}
-	log.Info().Msgf("required signature count %d", int(account.Thresholds.MedThreshold))
-	w.signatureCount = int(account.Thresholds.MedThreshold)
+	log.Info().Msgf("required signature weight %d", int(account.Thresholds.MedThreshold))
+	w.loadSigners(account)
 
 	w.sequenceNumber, err = account.GetSequenceNumber()
 	if err != nil {
@@ -69,13 +110,15 @@ func NewStellarWallet(ctx context.Context, config *pkg.StellarConfig) (*StellarW
 	return w, nil
 }
 
-func (w *StellarWallet) CreatePaymentAndReturnSignature(ctx context.Context, target string, amount uint64, txID uint64) (string, uint64, error) {
-	txnBuild, err := w.generatePaymentOperation(amount, target, 0)
+// CreatePaymentAndReturnSignature signs the withdraw payment of amount to target, a claimable payment creates a
+// claimable balance for target instead of paying it so a destination that can not receive the asset yet can claim it later
+func (w *StellarWallet) CreatePaymentAndReturnSignature(ctx context.Context, target string, amount uint64, txID uint64, claimable bool) (string, uint64, error) {
+	txnBuild, err := w.generatePaymentOperation(amount, target, 0, w.paymentMaxTime(time.Now()), claimable)
 	if err != nil {
 		return "", 0, err
 	}
 
-	txn, err := w.createTransaction(ctx, txnBuild, true)
+	txn, err := w.signReserved(ctx, txnBuild)
 	if err != nil {
 		return "", 0, err
 	}
@@ -86,7 +129,12 @@ func (w *StellarWallet) CreatePaymentAndReturnSignature(ctx context.Context, tar
 }
 
 func (w *StellarWallet) CreatePaymentWithSignaturesAndSubmit(ctx context.Context, target string, amount uint64, txHash string, signatures []substrate.StellarSignature, sequenceNumber int64) error {
-	txnBuild, err := w.generatePaymentOperation(amount, target, sequenceNumber)
+	maxTime, claimable, err := w.resolvePayment(target, amount, sequenceNumber, signatures)
+	if err != nil {
+		return err
+	}
+
+	txnBuild, err := w.generatePaymentOperation(amount, target, sequenceNumber, maxTime, claimable)
 	if err != nil {
 		return err
 	}
@@ -96,11 +144,17 @@ func (w *StellarWallet) CreatePaymentWithSignaturesAndSubmit(ctx context.Context
 		return err
 	}
 
-	if len(signatures) < w.signatureCount {
-		return errors.New("not enough signatures, aborting")
+	// signatures collected in another time bound window are for another payment and are dropped as well
+	signatures, err = w.verifiedSignatures(txn, signatures)
+	if err != nil {
+		return err
+	}
+
+	requiredSignatures, err := w.selectSignatures(signatures)
+	if err != nil {
+		return err
 	}
 
-	requiredSignatures := signatures[:w.signatureCount]
 	for _, sig := range requiredSignatures {
 		log.Debug().Str("signature", string(sig.Signature)).Str("address", string(sig.StellarAddress)).Msg("adding signature")
 		txn, err = txn.AddSignatureBase64(w.getNetworkPassPhrase(), string(sig.StellarAddress), string(sig.Signature))
@@ -113,7 +167,7 @@ func (w *StellarWallet) CreatePaymentWithSignaturesAndSubmit(ctx context.Context
 }
 
 func (w *StellarWallet) CreateRefundPaymentWithSignaturesAndSubmit(ctx context.Context, target string, amount uint64, txHash string, signatures []substrate.StellarSignature, sequenceNumber int64) error {
-	txnBuild, err := w.generatePaymentOperation(amount, target, sequenceNumber)
+	txnBuild, err := w.generatePaymentOperation(amount, target, sequenceNumber, 0, false)
 	if err != nil {
 		return err
 	}
@@ -133,11 +187,16 @@ func (w *StellarWallet) CreateRefundPaymentWithSignaturesAndSubmit(ctx context.C
 		return err
 	}
 
-	if len(signatures) < w.signatureCount {
-		return errors.New("not enough signatures, aborting")
+	signatures, err = w.verifiedSignatures(txn, signatures)
+	if err != nil {
+		return err
+	}
+
+	requiredSignatures, err := w.selectSignatures(signatures)
+	if err != nil {
+		return err
 	}
 
-	requiredSignatures := signatures[:w.signatureCount]
 	for _, sig := range requiredSignatures {
 		log.Debug().Msgf("adding signature %s, account %s", string(sig.Signature), string(sig.StellarAddress))
 		txn, err = txn.AddSignatureBase64(w.getNetworkPassPhrase(), string(sig.StellarAddress), string(sig.Signature))
@@ -150,12 +209,12 @@ func (w *StellarWallet) CreateRefundPaymentWithSignaturesAndSubmit(ctx context.C
 }
 
 func (w *StellarWallet) CreateRefundAndReturnSignature(ctx context.Context, target string, amount uint64, message string) (string, uint64, error) {
-	txnBuild, err := w.generatePaymentOperation(amount, target, 0)
+	parsedMessage, err := hex.DecodeString(message)
 	if err != nil {
 		return "", 0, err
 	}
 
-	parsedMessage, err := hex.DecodeString(message)
+	txnBuild, err := w.generatePaymentOperation(amount, target, 0, 0, false)
 	if err != nil {
 		return "", 0, err
 	}
@@ -165,7 +224,7 @@ func (w *StellarWallet) CreateRefundAndReturnSignature(ctx context.Context, targ
 
 	txnBuild.Memo = txnbuild.MemoReturn(memo)
 
-	txn, err := w.createTransaction(ctx, txnBuild, true)
+	txn, err := w.signReserved(ctx, txnBuild)
 	if err != nil {
 		return "", 0, err
 	}
@@ -175,15 +234,62 @@ func (w *StellarWallet) CreateRefundAndReturnSignature(ctx context.Context, targ
 	return base64.StdEncoding.EncodeToString(signatures[0].Signature), uint64(txn.SequenceNumber()), nil
 }
 
-func (w *StellarWallet) CheckAccount(account string) error {
-	acc, err := w.getAccountDetails(account)
+func (w *StellarWallet) CheckAccount(ctx context.Context, account string) error {
+	address, muxed, err := ParseAccountAddress(account)
 	if err != nil {
 		return err
 	}
 
+	var acc hProtocol.Account
+	err = w.retry(ctx, func() (err error) {
+		acc, err = w.getAccountDetails(address)
+		return err
+	})
+	if horizonclient.IsNotFoundError(err) {
+		return ErrAccountNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// SEP-29, the account rejects payments without a memo unless they are sent to one of its muxed addresses
+	if _, ok := acc.Data[memoRequiredDataKey]; ok && !muxed {
+		return ErrMemoRequired
+	}
+
+	if !w.hasTrustline(acc) {
+		return ErrNoTrustline
+	}
+	return nil
+}
+
+// CheckBridgeTrustline checks the bridge account can hold the bridged asset, without a trustline it can not
+// receive deposits nor pay withdraws. The issuer of the asset needs no trustline.
+func (w *StellarWallet) CheckBridgeTrustline(ctx context.Context) error {
+	if w.config.StellarBridgeAccount == w.getAssetCodeAndIssuer()[1] {
+		return nil
+	}
+
+	var account hProtocol.Account
+	err := w.retry(ctx, func() (err error) {
+		account, err = w.getAccountDetails(w.config.StellarBridgeAccount)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !w.hasTrustline(account) {
+		return ErrNoTrustline
+	}
+	return nil
+}
+
+// hasTrustline checks if account has a trustline with a limit for the bridged asset
+func (w *StellarWallet) hasTrustline(account hProtocol.Account) bool {
 	asset := w.getAssetCodeAndIssuer()
 
-	for _, balance := range acc.Balances {
+	for _, balance := range account.Balances {
 		if balance.Code != asset[0] || balance.Issuer != asset[1] {
 			continue
 		}
@@ -194,14 +300,14 @@ func (w *StellarWallet) CheckAccount(account string) error {
 		}
 		if limit > 0 {
 			//valid address
-			return nil
+			return true
 		}
 	}
 
-	return fmt.Errorf("addess has no trustline")
+	return false
 }
 
-func (w *StellarWallet) generatePaymentOperation(amount uint64, destination string, sequenceNumber int64) (txnbuild.TransactionParams, error) {
+func (w *StellarWallet) generatePaymentOperation(amount uint64, destination string, sequenceNumber int64, maxTime int64, claimable bool) (txnbuild.TransactionParams, error) {
 	// if amount is zero, do nothing
 	if amount == 0 {
 		return txnbuild.TransactionParams{}, errors.New("invalid amount")
@@ -212,35 +318,157 @@ func (w *StellarWallet) generatePaymentOperation(amount uint64, destination stri
 		return txnbuild.TransactionParams{}, errors.Wrap(err, "failed to get source account")
 	}
 
-	asset := w.getAssetCodeAndIssuer()
+	// a payment to sign gets a reserved sequence number, a payment to submit has the one it was signed with
+	sequence := sequenceNumber
+	if sequence == 0 {
+		sequence = w.reserveSequence()
+	} else {
+		w.useSequence(sequence)
+	}
 
-	var paymentOperations []txnbuild.Operation
-	paymentOP := txnbuild.Payment{
-		Destination: destination,
-		Amount:      big.NewRat(int64(amount), stellarPrecision).FloatString(stellarPrecisionDigits),
-		Asset: txnbuild.CreditAsset{
-			Code:   asset[0],
-			Issuer: asset[1],
-		},
-		SourceAccount: sourceAccount.AccountID,
+	return w.paymentTransactionParams(sourceAccount.AccountID, amount, destination, sequence, maxTime, claimable), nil
+}
+
+// paymentTransactionParams builds the parameters of a payment from the bridge account valid until maxTime, 0 meaning
+// it does not expire. A claimable payment creates a claimable balance the destination can claim unconditionally, the
+// reserve of the balance is paid by the bridge account. It has no side effects so the same input always results in
+// the same transaction
+func (w *StellarWallet) paymentTransactionParams(sourceAccount string, amount uint64, destination string, sequenceNumber int64, maxTime int64, claimable bool) txnbuild.TransactionParams {
+	code := w.getAssetCodeAndIssuer()
+	asset := txnbuild.CreditAsset{
+		Code:   code[0],
+		Issuer: code[1],
 	}
-	paymentOperations = append(paymentOperations, &paymentOP)
+	paymentAmount := big.NewRat(int64(amount), stellarPrecision).FloatString(stellarPrecisionDigits)
 
-	if sequenceNumber == 0 {
-		w.sequenceNumber = w.sequenceNumber + 1
-	} else {
-		w.sequenceNumber = int64(sequenceNumber)
+	var paymentOP txnbuild.Operation = &txnbuild.Payment{
+		Destination:   destination,
+		Amount:        paymentAmount,
+		Asset:         asset,
+		SourceAccount: sourceAccount,
+	}
+	if claimable {
+		paymentOP = &txnbuild.CreateClaimableBalance{
+			Amount:        paymentAmount,
+			Asset:         asset,
+			Destinations:  []txnbuild.Claimant{txnbuild.NewClaimant(claimantAddress(destination), nil)},
+			SourceAccount: sourceAccount,
+		}
 	}
 
-	txnBuild := txnbuild.TransactionParams{
-		Operations:           paymentOperations,
-		Timebounds:           txnbuild.NewInfiniteTimeout(),
-		SourceAccount:        &txnbuild.SimpleAccount{AccountID: sourceAccount.AccountID, Sequence: w.sequenceNumber},
-		BaseFee:              txnbuild.MinBaseFee * 1000,
+	return txnbuild.TransactionParams{
+		Operations:           []txnbuild.Operation{paymentOP},
+		Timebounds:           paymentTimebounds(maxTime),
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: sourceAccount, Sequence: sequenceNumber},
+		BaseFee:              w.baseFee(),
 		IncrementSequenceNum: false,
+		EnableMuxedAccounts:  true,
+	}
+}
+
+// claimantAddress returns the account a claimable balance for destination is created for, a claimant can not be a muxed
+// address so the balance goes to its underlying account. An invalid address is returned as is and fails the transaction.
+func claimantAddress(destination string) string {
+	address, _, err := ParseAccountAddress(destination)
+	if err != nil {
+		return destination
+	}
+	return address
+}
+
+// PaymentTransactionHash computes the hash of the withdraw payment to target, the payment envelope is
+// deterministic so this is the hash of the payment submitted to the stellar network. The time bounds of
+// the payment are those the signatures were collected for.
+func (w *StellarWallet) PaymentTransactionHash(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (string, error) {
+	maxTime, claimable, err := w.resolvePayment(target, amount, sequenceNumber, signatures)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := w.paymentHash(target, amount, sequenceNumber, maxTime, claimable)
+	if err != nil {
+		return "", err
 	}
 
-	return txnBuild, nil
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// SignsClaimableBalance returns whether the signatures of the withdraw payment to target sign a claimable balance
+// instead of a plain payment. ErrStaleSignatures is returned if no signature of a bridge signer signs the payment.
+func (w *StellarWallet) SignsClaimableBalance(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (bool, error) {
+	_, claimable, err := w.resolvePayment(target, amount, sequenceNumber, signatures)
+	return claimable, err
+}
+
+// IsTransactionSubmitted returns true if a successful transaction with hash is on the stellar network
+func (w *StellarWallet) IsTransactionSubmitted(ctx context.Context, hash string) (bool, error) {
+	client, err := w.getHorizonClient()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get horizon client")
+	}
+
+	var tx hProtocol.Transaction
+	err = w.retry(ctx, func() (err error) {
+		tx, err = client.TransactionDetail(hash)
+		return err
+	})
+	if horizonclient.IsNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get transaction %s", hash)
+	}
+
+	return tx.Successful, nil
+}
+
+// CheckPaymentSequence verifies that signatures collected for a payment with sequenceNumber can still be submitted,
+// the sequence number must be the next one of the bridge account and the payment time bounds must not have passed
+func (w *StellarWallet) CheckPaymentSequence(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) error {
+	account, err := w.getAccountDetails(w.config.StellarBridgeAccount)
+	if err != nil {
+		return err
+	}
+
+	current, err := account.GetSequenceNumber()
+	if err != nil {
+		return err
+	}
+
+	if sequenceNumber != current+1 {
+		log.Warn().Int64("sequence", sequenceNumber).Int64("account_sequence", current).Msg("payment sequence number does not follow the account sequence number")
+		return ErrStaleSignatures
+	}
+
+	maxTime, claimable, err := w.resolvePayment(target, amount, sequenceNumber, signatures)
+	if err != nil {
+		return err
+	}
+	if maxTime == 0 {
+		return nil
+	}
+
+	if time.Now().Unix() > maxTime {
+		log.Warn().Int64("max_time", maxTime).Msg("payment time bounds have passed")
+		return ErrStaleSignatures
+	}
+
+	// validators signing in different windows signed different payments
+	hash, err := w.paymentHash(target, amount, sequenceNumber, maxTime, claimable)
+	if err != nil {
+		return err
+	}
+	if !w.HasSignatureQuorum(w.validSignatures(hash, signatures)) {
+		log.Warn().Int64("max_time", maxTime).Msg("signatures for the payment time bounds do not reach the account threshold")
+		return ErrStaleSignatures
+	}
+
+	return nil
+}
+
+// GetSignatureCount returns the amount of signatures required to submit a transaction from the bridge account
+func (w *StellarWallet) GetSignatureCount() int {
+	return w.signatureCount
 }
 
 func (w *StellarWallet) createTransaction(ctx context.Context, txn txnbuild.TransactionParams, sign bool) (*txnbuild.Transaction, error) {
@@ -250,12 +478,17 @@ func (w *StellarWallet) createTransaction(ctx context.Context, txn txnbuild.Tran
 	}
 
 	if sign {
-		tx, err = tx.Sign(w.getNetworkPassPhrase(), w.keypair)
+		hash, err := tx.Hash(w.getNetworkPassPhrase())
 		if err != nil {
-			if hError, ok := err.(*horizonclient.Error); ok {
-				log.Error().Msgf("Error submitting tx %+v", hError.Problem.Extras)
-			}
-			return nil, errors.Wrap(err, "failed to sign transaction with keypair")
+			return nil, errors.Wrap(err, "failed to hash transaction")
+		}
+		signature, err := w.signer.Sign(ctx, hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to sign transaction")
+		}
+		tx, err = tx.AddSignatureBase64(w.getNetworkPassPhrase(), w.signer.Address(), base64.StdEncoding.EncodeToString(signature))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to add signature to transaction")
 		}
 	}
 
@@ -263,21 +496,36 @@ func (w *StellarWallet) createTransaction(ctx context.Context, txn txnbuild.Tran
 }
 
 func (w *StellarWallet) submitTransaction(ctx context.Context, txn *txnbuild.Transaction) error {
+	// the context of a handler that timed out is cancelled, its abandoned attempt must not submit anymore
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	client, err := w.getHorizonClient()
 	if err != nil {
 		return errors.Wrap(err, "failed to get horizon client")
 	}
 
-	// Submit the transaction
-	txResult, err := client.SubmitTransaction(txn)
+	// Submit the transaction, resubmitting the same envelope is safe as the sequence number can only be used once
+	var txResult hProtocol.Transaction
+	err = w.retry(ctx, func() (err error) {
+		txResult, err = client.SubmitTransaction(txn)
+		return err
+	})
+	if isInsufficientFee(err) && w.config.StellarMaxFee > 0 {
+		err = w.submitFeeBump(ctx, client, txn)
+		if err == nil {
+			log.Info().Msg("fee bump transaction submitted to the stellar network")
+			return nil
+		}
+	}
 	if err != nil {
 		log.Info().Msg(err.Error())
-		if hError, ok := err.(*horizonclient.Error); ok {
-			if ok {
-				log.Err(err).Msgf("error while submitting transaction %+v", hError.Problem.Extras)
-			}
+		var hError *horizonclient.Error
+		if errors.As(err, &hError) {
+			log.Err(err).Msgf("error while submitting transaction %+v", hError.Problem.Extras)
 		}
-		errSequence := w.resetAccountSequence()
+		errSequence := w.ResetAccountSequence()
 		if errSequence != nil {
 			return errSequence
 		}
@@ -287,36 +535,80 @@ func (w *StellarWallet) submitTransaction(ctx context.Context, txn *txnbuild.Tra
 	return nil
 }
 
-func (w *StellarWallet) resetAccountSequence() error {
+func (w *StellarWallet) ResetAccountSequence() error {
 	log.Info().Msgf("resetting account sequence")
 	account, err := w.getAccountDetails(w.config.StellarBridgeAccount)
 	if err != nil {
 		return err
 	}
 
-	w.sequenceNumber, err = account.GetSequenceNumber()
+	sequence, err := account.GetSequenceNumber()
 	if err != nil {
 		return err
 	}
 
+	w.mu.Lock()
+	w.sequenceNumber = sequence
+	w.mu.Unlock()
+
 	return nil
 }
 
-func (w *StellarWallet) GetKeypair() *keypair.Full {
-	return w.keypair
+// GetAddress returns the public address of the key the bridge signs with
+func (w *StellarWallet) GetAddress() string {
+	return w.signer.Address()
+}
+
+// Asset returns the code and issuer of the bridged asset
+func (w *StellarWallet) Asset() (code string, issuer string) {
+	asset := w.getAssetCodeAndIssuer()
+	return asset[0], asset[1]
 }
 
 type MintEventSubscription struct {
 	Events []MintEvent
+	// Cursor is the paging token of the transaction the events are from
+	Cursor string
 	Err    error
 }
 
 type MintEvent struct {
 	Senders map[string]*big.Int
-	Tx      hProtocol.Transaction
-	Error   error
+	// ForeignPayments are the payments of other assets than the bridged asset to the bridge account
+	ForeignPayments []ForeignPayment `json:",omitempty"`
+	Tx              hProtocol.Transaction
+	Error           error `json:"-"`
+	// Minted is set if the deposit was found minted already while the transaction was fetched
+	Minted bool `json:"-"`
+}
+
+// ForeignPayment is a payment of an asset that is not bridged, XLM payments are not foreign payments
+// as they fund the fees of the bridge account
+type ForeignPayment struct {
+	From   string
+	Asset  string
+	Amount int64
+}
+
+// MintEventStore keeps fetched mint events until they are processed, so they survive a restart
+type MintEventStore interface {
+	SavePendingMintEvents(events []MintEvent) error
+}
+
+// StreamOptions are the optional parts of streaming the transactions of the bridge account
+type StreamOptions struct {
+	// Store saves the mint events before they are sent, nil saves nothing
+	Store MintEventStore
+	// Concurrency is the amount of transactions of a page fetched at the same time, the events are
+	// still sent in paging order. Values below 2 fetch the transactions one by one
+	Concurrency int
+	// Minted checks whether the deposit of a transaction is minted already while it is fetched, nil skips the check
+	Minted func(txHash string) (bool, error)
 }
 
+// rescanPageLimit is the page size of transactions fetched concurrently, horizon pages hold 10 transactions by default
+const rescanPageLimit = 200
+
 // getAccountDetails gets account details based an a Stellar address
 func (w *StellarWallet) getAccountDetails(address string) (account hProtocol.Account, err error) {
 	client, err := w.getHorizonClient()
@@ -331,22 +623,29 @@ func (w *StellarWallet) getAccountDetails(address string) (account hProtocol.Acc
 	return account, nil
 }
 
-func (w *StellarWallet) StreamBridgeStellarTransactions(ctx context.Context, mintChan chan<- MintEventSubscription, cursor string) error {
-	client, err := w.getHorizonClient()
-	if err != nil {
-		return err
-	}
-
+// StreamBridgeStellarTransactions sends the mint events of the transactions on the bridge account starting from cursor.
+// The next page is only fetched once the events of the page are sent, a full mintChan pauses the paging until the events
+// are consumed.
+func (w *StellarWallet) StreamBridgeStellarTransactions(ctx context.Context, mintChan chan<- MintEventSubscription, cursor string, opts StreamOptions) error {
 	opRequest := horizonclient.TransactionRequest{
 		ForAccount: w.config.StellarBridgeAccount,
 		Cursor:     cursor,
 	}
+	if opts.Concurrency > 1 {
+		opRequest.Limit = rescanPageLimit
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
+			// the client is taken for every page so the paging continues from the cursor on another endpoint after a failover
+			client, err := w.getHorizonClient()
+			if err != nil {
+				return err
+			}
+
 			log.Info().Str("account", opRequest.ForAccount).Str("horizon", client.HorizonURL).Str("cursor", opRequest.Cursor).Msgf("fetching stellar transactions")
 			response, err := client.Transactions(opRequest)
 			if err != nil {
@@ -359,15 +658,20 @@ func (w *StellarWallet) StreamBridgeStellarTransactions(ctx context.Context, min
 				}
 			}
 
-			for _, tx := range response.Embedded.Records {
-				mintEvents, err := w.processTransaction(tx)
-				if err != nil {
-					return err
+			err = w.processPage(ctx, response.Embedded.Records, opts, func(tx hProtocol.Transaction, mintEvents []MintEvent) error {
+				if opts.Store != nil && len(mintEvents) > 0 {
+					if err := opts.Store.SavePendingMintEvents(mintEvents); err != nil {
+						log.Err(err).Str("hash", tx.Hash).Msg("failed to save pending mint events")
+					}
 				}
-				mintChan <- MintEventSubscription{
-					Events: mintEvents,
+				if err := sendMintEvents(ctx, mintChan, MintEventSubscription{Events: mintEvents, Cursor: tx.PagingToken()}); err != nil {
+					return err
 				}
 				opRequest.Cursor = tx.PagingToken()
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 
 			if len(response.Embedded.Records) == 0 {
@@ -381,6 +685,114 @@ func (w *StellarWallet) StreamBridgeStellarTransactions(ctx context.Context, min
 	}
 }
 
+// processPage fetches the mint events of the transactions of a page with up to opts.Concurrency workers and calls send
+// for them in paging order. Transactions completing out of order wait until the transactions before them are sent, so
+// the cursor never moves past a transaction that is not handled yet.
+func (w *StellarWallet) processPage(ctx context.Context, records []hProtocol.Transaction, opts StreamOptions, send func(tx hProtocol.Transaction, mintEvents []MintEvent) error) error {
+	type result struct {
+		events []MintEvent
+		err    error
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan result, len(records))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	go func() {
+		sem := make(chan struct{}, concurrency)
+		for i, tx := range records {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, tx hProtocol.Transaction) {
+				defer func() { <-sem }()
+				events, err := w.processTransaction(tx)
+				if err == nil {
+					w.checkMinted(events, opts.Minted)
+				}
+				results[i] <- result{events: events, err: err}
+			}(i, tx)
+		}
+	}()
+
+	for i, tx := range records {
+		var r result
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		if err := send(tx, r.events); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkMinted marks the mint events whose deposit is minted already, a minted deposit stays minted so the mint
+// handler can rely on it. A failed check leaves the event unmarked and the mint handler checks it again.
+func (w *StellarWallet) checkMinted(events []MintEvent, minted func(txHash string) (bool, error)) {
+	if minted == nil {
+		return
+	}
+
+	for i := range events {
+		ok, err := minted(events[i].Tx.Hash)
+		if err != nil {
+			log.Warn().Err(err).Str("hash", events[i].Tx.Hash).Msg("failed to check if transaction is minted, leaving it to the mint handler")
+			continue
+		}
+		events[i].Minted = ok
+	}
+}
+
+// sendMintEvents blocks until sub is sent or the context is cancelled
+func sendMintEvents(ctx context.Context, mintChan chan<- MintEventSubscription, sub MintEventSubscription) error {
+	select {
+	case mintChan <- sub:
+		return nil
+	default:
+	}
+
+	log.Debug().Msg("mint event buffer is full, pausing the paging of stellar transactions")
+	select {
+	case mintChan <- sub:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetTransactionMintEvents fetches a transaction on the bridge account by hash and returns its mint events
+func (w *StellarWallet) GetTransactionMintEvents(txHash string) ([]MintEvent, error) {
+	client, err := w.getHorizonClient()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := client.TransactionDetail(txHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get transaction %s", txHash)
+	}
+
+	return w.processTransaction(tx)
+}
+
 func (w *StellarWallet) processTransaction(tx hProtocol.Transaction) ([]MintEvent, error) {
 	if !tx.Successful {
 		return nil, nil
@@ -395,7 +807,7 @@ func (w *StellarWallet) processTransaction(tx hProtocol.Transaction) ([]MintEven
 
 	asset := w.getAssetCodeAndIssuer()
 
-	var mintEvents []MintEvent
+	credited, foreignCredited := false, false
 	for _, effect := range effects.Embedded.Records {
 		if effect.GetAccount() != w.config.StellarBridgeAccount {
 			continue
@@ -406,51 +818,97 @@ func (w *StellarWallet) processTransaction(tx hProtocol.Transaction) ([]MintEven
 		}
 
 		creditedEffect := effect.(horizoneffects.AccountCredited)
-		if creditedEffect.Asset.Code != asset[0] && creditedEffect.Asset.Issuer != asset[1] {
+		switch {
+		case creditedEffect.Asset.Code == asset[0] && creditedEffect.Asset.Issuer == asset[1]:
+			credited = true
+		case creditedEffect.Asset.Type != "native":
+			foreignCredited = true
+		}
+	}
+	if !credited && !foreignCredited {
+		return nil, nil
+	}
+
+	ops, err := w.getOperationEffect(tx.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	senders, foreign, ignored := w.bridgedAssetSenders(ops.Embedded.Records, asset)
+	if ignored > 0 {
+		// only the bridged asset is minted or refunded, anything else sent along needs manual handling
+		log.Warn().Str("hash", tx.Hash).Int("operations", ignored).Msg("transaction carries operations that are not payments of the bridged asset to the bridge, ignoring them")
+	}
+	if len(senders) == 0 && len(foreign) == 0 {
+		return nil, nil
+	}
+
+	return []MintEvent{{
+		Senders:         senders,
+		ForeignPayments: foreign,
+		Tx:              tx,
+		Error:           nil,
+	}}, nil
+}
+
+// bridgedAssetSenders sums the payments of the bridged asset to the bridge account per sender,
+// it also returns the payments of foreign assets and the amount of other operations that were ignored
+func (w *StellarWallet) bridgedAssetSenders(ops []operations.Operation, asset []string) (map[string]*big.Int, []ForeignPayment, int) {
+	senders := make(map[string]*big.Int)
+	var foreign []ForeignPayment
+	ignored := 0
+	for _, op := range ops {
+		paymentOpation, ok := op.(operations.Payment)
+		if !ok {
+			ignored++
 			continue
 		}
 
-		ops, err := w.getOperationEffect(tx.Hash)
-		if err != nil {
+		if paymentOpation.To != w.config.StellarBridgeAccount {
 			continue
 		}
 
-		senders := make(map[string]*big.Int)
-		for _, op := range ops.Embedded.Records {
-			if op.GetType() != "payment" {
-				return nil, nil
-			}
+		parsedAmount, err := amount.ParseInt64(paymentOpation.Amount)
+		if err != nil {
+			ignored++
+			continue
+		}
 
-			paymentOpation := op.(operations.Payment)
-			if paymentOpation.To != w.config.StellarBridgeAccount {
-				continue
-			}
+		// refunds of payments from a muxed account go back to the same muxed account
+		from := paymentOpation.From
+		if paymentOpation.FromMuxed != "" {
+			from = paymentOpation.FromMuxed
+		}
 
-			parsedAmount, err := amount.ParseInt64(paymentOpation.Amount)
-			if err != nil {
+		if paymentOpation.Code != asset[0] || paymentOpation.Issuer != asset[1] {
+			if paymentOpation.Asset.Type == "native" {
+				ignored++
 				continue
 			}
-
-			depositedAmount := big.NewInt(int64(parsedAmount))
-			if _, ok := senders[paymentOpation.From]; !ok {
-				senders[paymentOpation.From] = depositedAmount
-			} else {
-				senderAmount := senders[paymentOpation.From]
-				senderAmount = senderAmount.Add(senderAmount, depositedAmount)
-				senders[paymentOpation.From] = senderAmount
-			}
+			foreign = append(foreign, ForeignPayment{
+				From:   from,
+				Asset:  paymentOpation.Code + ":" + paymentOpation.Issuer,
+				Amount: parsedAmount,
+			})
+			continue
 		}
 
-		mintEvents = append(mintEvents, MintEvent{
-			Senders: senders,
-			Tx:      tx,
-			Error:   nil,
-		})
+		depositedAmount := big.NewInt(int64(parsedAmount))
+		if senderAmount, ok := senders[from]; ok {
+			senders[from] = senderAmount.Add(senderAmount, depositedAmount)
+		} else {
+			senders[from] = depositedAmount
+		}
 	}
 
-	return mintEvents, nil
+	return senders, foreign, ignored
 }
 
+// transactionPageLimit is the page size of the effects and operations of a transaction, horizon pages hold 10 records
+// by default while a transaction can have up to 100 operations, a payment split over more of them must be read entirely
+const transactionPageLimit = 200
+
+// getTransactionEffects gets all effects of a transaction, following the pages of horizon
 func (w *StellarWallet) getTransactionEffects(txHash string) (effects horizoneffects.EffectsPage, err error) {
 	client, err := w.getHorizonClient()
 	if err != nil {
@@ -459,15 +917,26 @@ func (w *StellarWallet) getTransactionEffects(txHash string) (effects horizoneff
 
 	effectsReq := horizonclient.EffectRequest{
 		ForTransaction: txHash,
+		Limit:          transactionPageLimit,
 	}
 	effects, err = client.Effects(effectsReq)
 	if err != nil {
 		return effects, err
 	}
 
+	page := effects
+	for len(page.Embedded.Records) == transactionPageLimit {
+		page, err = client.NextEffectsPage(page)
+		if err != nil {
+			return effects, err
+		}
+		effects.Embedded.Records = append(effects.Embedded.Records, page.Embedded.Records...)
+	}
+
 	return effects, nil
 }
 
+// getOperationEffect gets all operations of a transaction, following the pages of horizon
 func (w *StellarWallet) getOperationEffect(txHash string) (ops operations.OperationsPage, err error) {
 	client, err := w.getHorizonClient()
 	if err != nil {
@@ -476,33 +945,60 @@ func (w *StellarWallet) getOperationEffect(txHash string) (ops operations.Operat
 
 	opsRequest := horizonclient.OperationRequest{
 		ForTransaction: txHash,
+		Limit:          transactionPageLimit,
 	}
 	ops, err = client.Operations(opsRequest)
 	if err != nil {
 		return ops, err
 	}
 
+	page := ops
+	for len(page.Embedded.Records) == transactionPageLimit {
+		page, err = client.NextOperationsPage(page)
+		if err != nil {
+			return ops, err
+		}
+		ops.Embedded.Records = append(ops.Embedded.Records, page.Embedded.Records...)
+	}
+
 	return ops, nil
 }
 
-// getHorizonClient gets the horizon client based on the wallet's network
+// getHorizonClient returns a client of the active horizon endpoint
 func (w *StellarWallet) getHorizonClient() (*horizonclient.Client, error) {
-	if w.config.StellarHorizonUrl != "" {
-		return &horizonclient.Client{HorizonURL: w.config.StellarHorizonUrl}, nil
-	}
+	url := w.horizon.url()
+	return &horizonclient.Client{
+		HorizonURL: url,
+		HTTP: &http.Client{
+			Timeout:   w.config.HorizonTimeout,
+			Transport: &failoverTransport{base: http.DefaultTransport, url: url, endpoints: w.horizon},
+		},
+	}, nil
+}
 
-	switch w.config.StellarNetwork {
-	case "testnet":
-		return horizonclient.DefaultTestNetClient, nil
-	case "production":
-		return horizonclient.DefaultPublicNetClient, nil
-	default:
-		return nil, errors.New("network is not supported")
+// horizonURLs returns the horizon endpoints of the config, the configured horizon or the one of the network first
+func horizonURLs(config *pkg.StellarConfig) ([]string, error) {
+	url := config.StellarHorizonUrl
+	if url == "" {
+		switch config.StellarNetwork {
+		case "testnet":
+			url = horizonclient.DefaultTestNetClient.HorizonURL
+		case "production":
+			url = horizonclient.DefaultPublicNetClient.HorizonURL
+		default:
+			return nil, errors.New("network is not supported")
+		}
 	}
+
+	return append([]string{url}, config.StellarHorizonStandbyUrls...), nil
 }
 
 // getNetworkPassPhrase gets the Stellar network passphrase based on the wallet's network
 func (w *StellarWallet) getNetworkPassPhrase() string {
+	if w.config.StellarNetworkPassphrase != "" {
+		return w.config.StellarNetworkPassphrase
+	}
+
 	switch w.config.StellarNetwork {
 	case "testnet":
 		return network.TestNetworkPassphrase
@@ -513,7 +1009,33 @@ func (w *StellarWallet) getNetworkPassPhrase() string {
 	}
 }
 
+// validateAsset checks a configured bridged asset has a valid code and issuer
+func validateAsset(config *pkg.StellarConfig) error {
+	if config.StellarAssetCode == "" && config.StellarAssetIssuer == "" {
+		return nil
+	}
+
+	asset := txnbuild.CreditAsset{Code: config.StellarAssetCode, Issuer: config.StellarAssetIssuer}
+	if _, err := asset.ToXDR(); err != nil {
+		return errors.Wrap(err, "invalid stellar asset code")
+	}
+	for _, c := range config.StellarAssetCode {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return fmt.Errorf("invalid stellar asset code %q, it must be alphanumeric", config.StellarAssetCode)
+		}
+	}
+	if !strkey.IsValidEd25519PublicKey(config.StellarAssetIssuer) {
+		return fmt.Errorf("invalid stellar asset issuer %q", config.StellarAssetIssuer)
+	}
+	return nil
+}
+
+// getAssetCodeAndIssuer returns the code and issuer of the bridged asset, TFT of the network unless configured otherwise
 func (w *StellarWallet) getAssetCodeAndIssuer() []string {
+	if w.config.StellarAssetCode != "" {
+		return []string{w.config.StellarAssetCode, w.config.StellarAssetIssuer}
+	}
+
 	switch w.config.StellarNetwork {
 	case "testnet":
 		return strings.Split(TFTTest, ":")
@@ -0,0 +1,462 @@
+package stellar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/clients/horizonclient"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/protocols/horizon/base"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/support/render/problem"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+const (
+	testBridgeAccount = "GDCAMOLMOTTIKJ6MRQ4WPXIUBWEV4CZS7QNVDNO65XKYOOEPYV5NZGDG"
+	testTarget        = "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ"
+)
+
+// testHorizon serves the details of an account, other requests are answered with not found
+type testHorizon struct {
+	*httptest.Server
+	// failures are the statuses the first requests are answered with
+	failures []int
+	requests int32
+}
+
+func newTestHorizon(t *testing.T, account hProtocol.Account, failures ...int) *testHorizon {
+	horizon := &testHorizon{failures: failures}
+	horizon.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := atomic.AddInt32(&horizon.requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if int(request) <= len(horizon.failures) {
+			status := horizon.failures[request-1]
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"type": "error", "title": %q, "status": %d}`, http.StatusText(status), status)
+			return
+		}
+		if r.URL.Path != "/accounts/"+account.AccountID {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"type": "not_found", "title": "Resource Missing", "status": %d}`, http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(account); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(horizon.Close)
+	return horizon
+}
+
+// newTestWallet is a wallet of the bridge account on horizon, it signs nothing
+func newTestWallet(horizon *testHorizon) *StellarWallet {
+	return &StellarWallet{
+		config: &pkg.StellarConfig{
+			StellarBridgeAccount: testBridgeAccount,
+			StellarNetwork:       "testnet",
+			StellarHorizonUrl:    horizon.URL,
+			HorizonTimeout:       time.Second,
+			HorizonMaxRetries:    1,
+		},
+		horizon: newHorizonEndpoints([]string{horizon.URL}),
+	}
+}
+
+func TestCheckPaymentSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence int64
+		stale    bool
+	}{
+		{name: "next sequence number", sequence: 101},
+		{name: "used sequence number", sequence: 100, stale: true},
+		{name: "old sequence number", sequence: 90, stale: true},
+		{name: "sequence number ahead", sequence: 105, stale: true},
+	}
+	horizon := newTestHorizon(t, hProtocol.Account{AccountID: testBridgeAccount, Sequence: "100"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newTestWallet(horizon).CheckPaymentSequence(testTarget, 50000000, test.sequence, nil)
+			if !test.stale {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrStaleSignatures) {
+				t.Errorf("expected the signatures to be stale, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckAccount(t *testing.T) {
+	tft := strings.Split(TFTTest, ":")
+	trustline := hProtocol.Balance{Balance: "0.0000000", Limit: "1000.0000000", Asset: base.Asset{Type: "credit_alphanum4", Code: tft[0], Issuer: tft[1]}}
+	other := hProtocol.Balance{Balance: "0.0000000", Limit: "1000.0000000", Asset: base.Asset{Type: "credit_alphanum4", Code: "USDC", Issuer: tft[1]}}
+
+	tests := []struct {
+		name     string
+		address  string
+		account  hProtocol.Account
+		err      error
+		anyError bool
+	}{
+		{name: "trustline", account: hProtocol.Account{Balances: []hProtocol.Balance{trustline}}},
+		{name: "no trustline", account: hProtocol.Account{Balances: []hProtocol.Balance{other}}, err: ErrNoTrustline},
+		{name: "memo required", account: hProtocol.Account{Balances: []hProtocol.Balance{trustline}, Data: map[string]string{"config.memo_required": "MQ=="}}, err: ErrMemoRequired},
+		{name: "muxed", address: testMuxedTarget, account: hProtocol.Account{Balances: []hProtocol.Balance{trustline}}},
+		{name: "muxed memo required", address: testMuxedTarget, account: hProtocol.Account{Balances: []hProtocol.Balance{trustline}, Data: map[string]string{"config.memo_required": "MQ=="}}},
+		{name: "invalid address", address: "MBMMTFUUYMB2EHLJTXIGXOJY34Y7", anyError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.account.AccountID = testTarget
+			if test.address == "" {
+				test.address = testTarget
+			}
+			err := newTestWallet(newTestHorizon(t, test.account)).CheckAccount(context.Background(), test.address)
+			switch {
+			case test.err != nil:
+				if !errors.Is(err, test.err) {
+					t.Errorf("expected %v, got %v", test.err, err)
+				}
+			case test.anyError:
+				if err == nil {
+					t.Error("expected the account check to fail")
+				}
+			case err != nil:
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCheckBridgeTrustline(t *testing.T) {
+	tft := strings.Split(TFTTest, ":")
+	trustline := hProtocol.Balance{Balance: "10.0000000", Limit: "1000.0000000", Asset: base.Asset{Type: "credit_alphanum4", Code: tft[0], Issuer: tft[1]}}
+	removed := hProtocol.Balance{Balance: "0.0000000", Limit: "0.0000000", Asset: base.Asset{Type: "credit_alphanum4", Code: tft[0], Issuer: tft[1]}}
+
+	tests := []struct {
+		name     string
+		balances []hProtocol.Balance
+		err      error
+	}{
+		{name: "trustline", balances: []hProtocol.Balance{trustline}},
+		{name: "no trustline", err: ErrNoTrustline},
+		{name: "zero limit", balances: []hProtocol.Balance{removed}, err: ErrNoTrustline},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			horizon := newTestHorizon(t, hProtocol.Account{AccountID: testBridgeAccount, Balances: test.balances})
+			err := newTestWallet(horizon).CheckBridgeTrustline(context.Background())
+			if test.err == nil && err != nil {
+				t.Fatal(err)
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckAccountRetries(t *testing.T) {
+	tft := strings.Split(TFTTest, ":")
+	account := hProtocol.Account{
+		AccountID: testTarget,
+		Balances: []hProtocol.Balance{
+			{Balance: "10.0000000", Limit: "1000.0000000", Asset: base.Asset{Type: "credit_alphanum4", Code: tft[0], Issuer: tft[1]}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		failures  []int
+		err       bool
+		transient bool
+		requests  int32
+	}{
+		{name: "available", requests: 1},
+		{name: "unavailable once", failures: []int{http.StatusServiceUnavailable}, requests: 2},
+		{name: "rate limited once", failures: []int{http.StatusTooManyRequests}, requests: 2},
+		{name: "unavailable", failures: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}, err: true, transient: true, requests: 2},
+		{name: "bad request", failures: []int{http.StatusBadRequest}, err: true, requests: 1},
+		{name: "not found", failures: []int{http.StatusNotFound}, err: true, requests: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			horizon := newTestHorizon(t, account, test.failures...)
+
+			err := newTestWallet(horizon).CheckAccount(context.Background(), testTarget)
+			if test.err && err == nil {
+				t.Error("expected the account check to fail")
+			}
+			if !test.err && err != nil {
+				t.Error(err)
+			}
+			if transient := pkg.IsTransient(err); transient != test.transient {
+				t.Errorf("expected transient %t, got %t (%v)", test.transient, transient, err)
+			}
+			if requests := atomic.LoadInt32(&horizon.requests); requests != test.requests {
+				t.Errorf("expected %d requests, got %d", test.requests, requests)
+			}
+		})
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{name: "server error", err: &horizonclient.Error{Problem: problem.P{Status: http.StatusInternalServerError}}, retryable: true},
+		{name: "unavailable", err: &horizonclient.Error{Problem: problem.P{Status: http.StatusServiceUnavailable}}, retryable: true},
+		{name: "rate limited", err: &horizonclient.Error{Problem: problem.P{Status: http.StatusTooManyRequests}}, retryable: true},
+		{name: "bad request", err: &horizonclient.Error{Problem: problem.P{Status: http.StatusBadRequest}}},
+		{name: "not found", err: errors.Wrap(&horizonclient.Error{Problem: problem.P{Status: http.StatusNotFound}}, "failed to get account details")},
+		{name: "timeout", err: errors.Wrap(context.DeadlineExceeded, "request failed"), retryable: true},
+		{name: "other", err: errors.New("failed")},
+		{name: "nil"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if retryable := IsRetryableError(test.err); retryable != test.retryable {
+				t.Errorf("expected retryable %t, got %t", test.retryable, retryable)
+			}
+		})
+	}
+}
+
+func TestBridgedAssetSenders(t *testing.T) {
+	const (
+		issuer = "GBOVQKJYHXRR3DX6NOX2RRYFRCUMSADGDESTDNBDS6CDVLGVESRTAC47"
+		sender = testTarget
+		other  = "GDJG37NMYAUXXMST7MP6Z2JDXNGJP72OYNWKHTDLS3ZKZR4ZCLZKKAEV"
+	)
+	tft := base.Asset{Type: "credit_alphanum4", Code: "TFT", Issuer: issuer}
+	payment := func(from string, to string, asset base.Asset, amount string) operations.Payment {
+		return operations.Payment{Asset: asset, From: from, To: to, Amount: amount}
+	}
+
+	tests := []struct {
+		name    string
+		ops     []operations.Operation
+		senders map[string]*big.Int
+		foreign []ForeignPayment
+		ignored int
+	}{
+		{
+			name:    "single payment",
+			ops:     []operations.Operation{payment(sender, testBridgeAccount, tft, "5.0000000")},
+			senders: map[string]*big.Int{sender: big.NewInt(50000000)},
+		},
+		{
+			name: "payments of a sender are summed",
+			ops: []operations.Operation{
+				payment(sender, testBridgeAccount, tft, "5.0000000"),
+				payment(sender, testBridgeAccount, tft, "0.5000000"),
+			},
+			senders: map[string]*big.Int{sender: big.NewInt(55000000)},
+		},
+		{
+			name: "several senders",
+			ops: []operations.Operation{
+				payment(sender, testBridgeAccount, tft, "5.0000000"),
+				payment(other, testBridgeAccount, tft, "1.0000000"),
+			},
+			senders: map[string]*big.Int{sender: big.NewInt(50000000), other: big.NewInt(10000000)},
+		},
+		{
+			name: "muxed sender",
+			ops: []operations.Operation{
+				operations.Payment{Asset: tft, From: sender, FromMuxed: testMuxedTarget, To: testBridgeAccount, Amount: "5.0000000"},
+			},
+			senders: map[string]*big.Int{testMuxedTarget: big.NewInt(50000000)},
+		},
+		{
+			name:    "payment to another account",
+			ops:     []operations.Operation{payment(sender, other, tft, "5.0000000")},
+			senders: map[string]*big.Int{},
+		},
+		{
+			name: "mixed assets",
+			ops: []operations.Operation{
+				payment(sender, testBridgeAccount, base.Asset{Type: "credit_alphanum4", Code: "USDC", Issuer: other}, "200.0000000"),
+				payment(sender, testBridgeAccount, tft, "0.5000000"),
+			},
+			senders: map[string]*big.Int{sender: big.NewInt(5000000)},
+			foreign: []ForeignPayment{{From: sender, Asset: "USDC:" + other, Amount: 2000000000}},
+		},
+		{
+			name:    "same code of another issuer",
+			ops:     []operations.Operation{payment(sender, testBridgeAccount, base.Asset{Type: "credit_alphanum4", Code: "TFT", Issuer: other}, "2.0000000")},
+			senders: map[string]*big.Int{},
+			foreign: []ForeignPayment{{From: sender, Asset: "TFT:" + other, Amount: 20000000}},
+		},
+		{
+			name: "native payment and other operations are ignored",
+			ops: []operations.Operation{
+				payment(sender, testBridgeAccount, base.Asset{Type: "native"}, "2.0000000"),
+				operations.CreateAccount{Funder: sender, Account: other, StartingBalance: "1.0000000"},
+				payment(sender, testBridgeAccount, tft, "5.0000000"),
+			},
+			senders: map[string]*big.Int{sender: big.NewInt(50000000)},
+			ignored: 2,
+		},
+		{
+			name:    "invalid amount is ignored",
+			ops:     []operations.Operation{payment(sender, testBridgeAccount, tft, "five")},
+			senders: map[string]*big.Int{},
+			ignored: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount}}
+
+			senders, foreign, ignored := w.bridgedAssetSenders(test.ops, []string{tft.Code, tft.Issuer})
+			if !reflect.DeepEqual(senders, test.senders) {
+				t.Errorf("expected senders %v, got %v", test.senders, senders)
+			}
+			if !reflect.DeepEqual(foreign, test.foreign) {
+				t.Errorf("expected foreign payments %v, got %v", test.foreign, foreign)
+			}
+			if ignored != test.ignored {
+				t.Errorf("expected %d ignored operations, got %d", test.ignored, ignored)
+			}
+		})
+	}
+}
+
+func TestIsTransactionSubmitted(t *testing.T) {
+	transactions := map[string]hProtocol.Transaction{
+		"paid":   {Hash: "paid", Successful: true},
+		"failed": {Hash: "failed", Successful: false},
+	}
+	horizon := &testHorizon{Server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		tx, ok := transactions[strings.TrimPrefix(r.URL.Path, "/transactions/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"type": "https://stellar.org/horizon-errors/not_found", "title": "Resource Missing", "status": %d}`, http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(tx); err != nil {
+			t.Error(err)
+		}
+	}))}
+	defer horizon.Close()
+	w := newTestWallet(horizon)
+
+	tests := []struct {
+		hash      string
+		submitted bool
+	}{
+		{hash: "paid", submitted: true},
+		{hash: "failed"},
+		{hash: "unknown"},
+	}
+	for _, test := range tests {
+		t.Run(test.hash, func(t *testing.T) {
+			submitted, err := w.IsTransactionSubmitted(context.Background(), test.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if submitted != test.submitted {
+				t.Errorf("expected submitted %t, got %t", test.submitted, submitted)
+			}
+		})
+	}
+}
+
+// transactionHorizon serves the effects and operations of a deposit transaction made of payments to the bridge,
+// padding effects of other accounts come before the credits of the bridge. The records are paged like horizon does.
+func transactionHorizon(t *testing.T, hash string, payments int, padding int) *httptest.Server {
+	var effects, ops []string
+	for i := 0; i < padding; i++ {
+		effects = append(effects, fmt.Sprintf(`{"type": "account_debited", "account": %q, "asset_type": "native", "amount": "0.0000100"}`, testTarget))
+	}
+	for i := 0; i < payments; i++ {
+		effects = append(effects, fmt.Sprintf(`{"type": "account_credited", "account": %q, "asset_type": "credit_alphanum4", "asset_code": "TFT", "asset_issuer": %q, "amount": "0.5000000"}`, testBridgeAccount, testIssuerTFT))
+		ops = append(ops, fmt.Sprintf(`{"type": "payment", "type_i": 1, "from": %q, "to": %q, "asset_type": "credit_alphanum4", "asset_code": "TFT", "asset_issuer": %q, "amount": "0.5000000"}`, testTarget, testBridgeAccount, testIssuerTFT))
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var records []string
+		switch r.URL.Path {
+		case "/transactions/" + hash + "/effects":
+			records = effects
+		case "/transactions/" + hash + "/operations":
+			records = ops
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/not_found", "title": "Resource Missing", "status": 404}`)
+			return
+		}
+
+		cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			limit = 10
+		}
+		end := cursor + limit
+		if end > len(records) {
+			end = len(records)
+		}
+		if cursor > end {
+			cursor = end
+		}
+		next := fmt.Sprintf("%s%s?cursor=%d&limit=%d", server.URL, r.URL.Path, end, limit)
+		fmt.Fprintf(w, `{"_links": {"next": {"href": %q}}, "_embedded": {"records": [%s]}}`, next, strings.Join(records[cursor:end], ","))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessTransactionPaymentOperations(t *testing.T) {
+	const hash = "0000000000000000000000000000000000000000000000000000000000000001"
+
+	tests := []struct {
+		name     string
+		payments int
+		padding  int
+		amount   int64
+	}{
+		{name: "single payment", payments: 1, amount: 5000000},
+		{name: "two payments of a sender", payments: 2, amount: 10000000},
+		{name: "payments over several pages", payments: 450, amount: 2250000000},
+		{name: "credit on a later page", payments: 1, padding: 250, amount: 5000000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := newStreamWallet(transactionHorizon(t, hash, test.payments, test.padding))
+
+			events, err := w.processTransaction(hProtocol.Transaction{Hash: hash, Successful: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("expected one mint event, got %d", len(events))
+			}
+			expected := map[string]*big.Int{testTarget: big.NewInt(test.amount)}
+			if !reflect.DeepEqual(events[0].Senders, expected) {
+				t.Errorf("expected senders %v, got %v", expected, events[0].Senders)
+			}
+		})
+	}
+}
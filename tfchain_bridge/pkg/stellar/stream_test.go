@@ -0,0 +1,138 @@
+package stellar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+const testIssuerTFT = "GA47YZA3PKFUZMPLQ3B5F2E3CJIB57TGGU7SPCQT2WAEYKN766PWIMB3"
+
+// pagingHorizon serves deposits of TFT to the bridge account, a page of transactions at a time. If delay is not nil
+// it is called with the number of the deposit before its effects are served.
+func pagingHorizon(t *testing.T, deposits int, pageSize int, delay func(deposit int)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/accounts/"+testBridgeAccount+"/transactions":
+			cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+			var records []string
+			for i := cursor + 1; i <= deposits && len(records) < pageSize; i++ {
+				records = append(records, fmt.Sprintf(`{"id": "%064x", "hash": "%064x", "paging_token": "%d", "successful": true, "memo_type": "text", "memo": "twin_1"}`, i, i, i))
+			}
+			fmt.Fprintf(w, `{"_embedded": {"records": [%s]}}`, strings.Join(records, ","))
+		case strings.HasSuffix(r.URL.Path, "/effects"):
+			if delay != nil {
+				deposit, _ := strconv.ParseInt(strings.Split(r.URL.Path, "/")[2], 16, 64)
+				delay(int(deposit))
+			}
+			fmt.Fprintf(w, `{"_embedded": {"records": [{"type": "account_credited", "account": %q, "asset_type": "credit_alphanum4", "asset_code": "TFT", "asset_issuer": %q, "amount": "5.0000000"}]}}`, testBridgeAccount, testIssuerTFT)
+		case strings.HasSuffix(r.URL.Path, "/operations"):
+			fmt.Fprintf(w, `{"_embedded": {"records": [{"type": "payment", "type_i": 1, "from": %q, "to": %q, "asset_type": "credit_alphanum4", "asset_code": "TFT", "asset_issuer": %q, "amount": "5.0000000"}]}}`, testTarget, testBridgeAccount, testIssuerTFT)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type": "https://stellar.org/horizon-errors/not_found", "title": "Resource Missing", "status": 404}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newStreamWallet is a wallet of the bridge account served by horizon
+func newStreamWallet(horizon *httptest.Server) *StellarWallet {
+	return &StellarWallet{
+		config: &pkg.StellarConfig{
+			StellarBridgeAccount: testBridgeAccount,
+			StellarNetwork:       "testnet",
+			StellarHorizonUrl:    horizon.URL,
+			StellarAssetCode:     "TFT",
+			StellarAssetIssuer:   testIssuerTFT,
+			HorizonTimeout:       time.Second,
+		},
+		horizon: newHorizonEndpoints([]string{horizon.URL}),
+	}
+}
+
+func TestStreamSlowConsumer(t *testing.T) {
+	const deposits = 25
+	horizon := pagingHorizon(t, deposits, 4, nil)
+	w := newStreamWallet(horizon)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mintChan := make(chan MintEventSubscription, 2)
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- w.StreamBridgeStellarTransactions(ctx, mintChan, "", StreamOptions{})
+	}()
+
+	// the consumer is much slower than the paging, the stream waits for it instead of dropping events
+	for i := 1; i <= deposits; i++ {
+		select {
+		case sub := <-mintChan:
+			if len(sub.Events) != 1 {
+				t.Fatalf("expected a single deposit, got %d events", len(sub.Events))
+			}
+			if token := sub.Events[0].Tx.PT; token != strconv.Itoa(i) {
+				t.Fatalf("expected deposit %d, got the deposit with paging token %s", i, token)
+			}
+		case err := <-streamed:
+			t.Fatalf("stream stopped: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("deposit %d was not streamed", i)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-streamed; err != context.Canceled {
+		t.Errorf("expected the stream to stop with the context, got %v", err)
+	}
+}
+
+func TestStreamConcurrentInOrder(t *testing.T) {
+	const deposits = 12
+	// the later deposits of a page are fetched first
+	horizon := pagingHorizon(t, deposits, 6, func(deposit int) {
+		time.Sleep(time.Duration(6-deposit%6) * 5 * time.Millisecond)
+	})
+	w := newStreamWallet(horizon)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mintChan := make(chan MintEventSubscription)
+	streamed := make(chan error, 1)
+	go func() {
+		streamed <- w.StreamBridgeStellarTransactions(ctx, mintChan, "", StreamOptions{
+			Concurrency: 6,
+			Minted: func(txHash string) (bool, error) {
+				deposit, err := strconv.ParseInt(txHash, 16, 64)
+				return deposit%2 == 0, err
+			},
+		})
+	}()
+
+	for i := 1; i <= deposits; i++ {
+		select {
+		case sub := <-mintChan:
+			event := sub.Events[0]
+			if token := event.Tx.PT; token != strconv.Itoa(i) {
+				t.Fatalf("expected deposit %d, got the deposit with paging token %s", i, token)
+			}
+			if event.Minted != (i%2 == 0) {
+				t.Errorf("expected deposit %d minted to be %t", i, i%2 == 0)
+			}
+		case err := <-streamed:
+			t.Fatalf("stream stopped: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("deposit %d was not streamed", i)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package stellar
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// maxTimeboundsLookback is the amount of windows searched for the time bounds signatures of a withdraw were collected for
+const maxTimeboundsLookback = 2048
+
+// paymentWindow returns the time bound window of withdraw payments in seconds, 0 means payments do not expire
+func (w *StellarWallet) paymentWindow() int64 {
+	return int64(w.config.StellarPaymentTimeout / time.Second)
+}
+
+// paymentMaxTime returns the upper time bound of a withdraw payment signed at now, 0 means the payment does not expire.
+// All validators sign the same envelope so the bound can not depend on the exact clock of a validator, it is aligned
+// on the window: validators signing within the same window produce the same payment which stays valid for at least a window.
+func (w *StellarWallet) paymentMaxTime(now time.Time) int64 {
+	window := w.paymentWindow()
+	if window <= 0 {
+		return 0
+	}
+	return (now.Unix()/window + 2) * window
+}
+
+func paymentTimebounds(maxTime int64) txnbuild.Timebounds {
+	if maxTime == 0 {
+		return txnbuild.NewInfiniteTimeout()
+	}
+	return txnbuild.NewTimebounds(0, maxTime)
+}
+
+// paymentHash computes the hash of the withdraw payment of amount to target with sequenceNumber and maxTime
+func (w *StellarWallet) paymentHash(target string, amount uint64, sequenceNumber int64, maxTime int64, claimable bool) ([32]byte, error) {
+	txn, err := txnbuild.NewTransaction(w.paymentTransactionParams(w.config.StellarBridgeAccount, amount, target, sequenceNumber, maxTime, claimable))
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "failed to build transaction")
+	}
+
+	return txn.Hash(w.getNetworkPassPhrase())
+}
+
+// paymentKinds returns whether the withdraw payments signed by the validators can be plain payments or claimable balances
+func (w *StellarWallet) paymentKinds() []bool {
+	if w.config.StellarClaimableBalances {
+		return []bool{false, true}
+	}
+	return []bool{false}
+}
+
+// resolvedPayments caches the payments resolved for the latest time bound window, the search walks back over up
+// to maxTimeboundsLookback windows while a ready withdraw needs its payment for the hash, the sequence check and
+// the submission. A resolved payment only depends on the latest window, entries of earlier windows are dropped.
+type resolvedPayments struct {
+	mu      sync.Mutex
+	latest  int64
+	entries map[[32]byte]resolvedPayment
+}
+
+type resolvedPayment struct {
+	maxTime   int64
+	claimable bool
+	err       error
+}
+
+func (c *resolvedPayments) get(latest int64, key [32]byte) (resolvedPayment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.latest != latest {
+		return resolvedPayment{}, false
+	}
+	payment, ok := c.entries[key]
+	return payment, ok
+}
+
+func (c *resolvedPayments) set(latest int64, key [32]byte, payment resolvedPayment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.latest != latest || c.entries == nil {
+		c.latest = latest
+		c.entries = make(map[[32]byte]resolvedPayment)
+	}
+	c.entries[key] = payment
+}
+
+// paymentKey identifies the withdraw payment of amount to target with sequenceNumber and its signatures
+func paymentKey(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) [32]byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d", target, amount, sequenceNumber)
+	for _, sig := range signatures {
+		fmt.Fprintf(h, "\x00%s\x00%s", sig.StellarAddress, sig.Signature)
+	}
+	var key [32]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// resolvePayment finds the upper time bound the signatures of a withdraw payment were collected for, walking back from
+// the window of the current time, and whether they sign a claimable balance. ErrStaleSignatures is returned if no
+// signature of a bridge signer matches a window. Payments that do not expire are a plain payment unless signed otherwise.
+// The search is done once per payment and window, later calls get the resolved payment.
+func (w *StellarWallet) resolvePayment(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature) (int64, bool, error) {
+	window := w.paymentWindow()
+	if window <= 0 {
+		claimable, err := w.signedKind(target, amount, sequenceNumber, 0, signatures)
+		return 0, claimable, err
+	}
+
+	// a validator with a clock ahead of ours could have signed for the next window
+	latest := w.paymentMaxTime(time.Now()) + window
+	key := paymentKey(target, amount, sequenceNumber, signatures)
+	if payment, ok := w.resolved.get(latest, key); ok {
+		return payment.maxTime, payment.claimable, payment.err
+	}
+
+	maxTime, claimable, err := w.searchPayment(target, amount, sequenceNumber, signatures, latest)
+	if err == nil || errors.Is(err, ErrStaleSignatures) {
+		w.resolved.set(latest, key, resolvedPayment{maxTime: maxTime, claimable: claimable, err: err})
+	}
+	return maxTime, claimable, err
+}
+
+// searchPayment walks back from the latest window to the window whose payment the signatures sign
+func (w *StellarWallet) searchPayment(target string, amount uint64, sequenceNumber int64, signatures []substrate.StellarSignature, latest int64) (int64, bool, error) {
+	window := w.paymentWindow()
+	for i := int64(0); i < maxTimeboundsLookback; i++ {
+		maxTime := latest - i*window
+		for _, claimable := range w.paymentKinds() {
+			hash, err := w.paymentHash(target, amount, sequenceNumber, maxTime, claimable)
+			if err != nil {
+				return 0, false, err
+			}
+
+			if len(w.validSignatures(hash, signatures)) > 0 {
+				return maxTime, claimable, nil
+			}
+		}
+	}
+
+	return 0, false, ErrStaleSignatures
+}
+
+// signedKind returns whether the signatures of the withdraw payment with maxTime sign a claimable balance
+func (w *StellarWallet) signedKind(target string, amount uint64, sequenceNumber int64, maxTime int64, signatures []substrate.StellarSignature) (bool, error) {
+	if !w.config.StellarClaimableBalances {
+		return false, nil
+	}
+
+	hash, err := w.paymentHash(target, amount, sequenceNumber, maxTime, true)
+	if err != nil {
+		return false, err
+	}
+	return len(w.validSignatures(hash, signatures)) > 0, nil
+}
+
+// validSignatures returns the signatures of bridge signers that are valid for the payment with hash
+func (w *StellarWallet) validSignatures(hash [32]byte, signatures []substrate.StellarSignature) []substrate.StellarSignature {
+	var valid []substrate.StellarSignature
+	for _, sig := range signatures {
+		address := string(sig.StellarAddress)
+		if w.signerWeights[address] <= 0 {
+			continue
+		}
+		if verifySignature(address, hash[:], string(sig.Signature)) == nil {
+			valid = append(valid, sig)
+		}
+	}
+	return valid
+}
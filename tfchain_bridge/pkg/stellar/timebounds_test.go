@@ -0,0 +1,188 @@
+package stellar
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stellar/go/keypair"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+func TestPaymentTimebounds(t *testing.T) {
+	const amount, sequence = 50000000, 101
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{name: "no expiry"},
+		{name: "ten minute window", timeout: 10 * time.Minute},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount, StellarNetwork: "testnet", StellarPaymentTimeout: test.timeout}}
+
+			maxTime := w.paymentMaxTime(now)
+			txn, err := txnbuild.NewTransaction(w.paymentTransactionParams(testBridgeAccount, amount, testTarget, sequence, maxTime, false))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bounds := txn.Timebounds()
+			if bounds.MinTime != 0 || bounds.MaxTime != maxTime {
+				t.Errorf("expected time bounds [0, %d], got [%d, %d]", maxTime, bounds.MinTime, bounds.MaxTime)
+			}
+
+			if test.timeout == 0 {
+				if maxTime != 0 {
+					t.Errorf("expected a payment without expiry, got max time %d", maxTime)
+				}
+				return
+			}
+			window := int64(test.timeout / time.Second)
+			if maxTime%window != 0 {
+				t.Errorf("expected the max time %d to be aligned on the %d second window", maxTime, window)
+			}
+			if valid := maxTime - now.Unix(); valid <= window || valid > 2*window {
+				t.Errorf("expected the payment to be valid for one to two windows, valid for %d seconds", valid)
+			}
+		})
+	}
+}
+
+func TestResolvePaymentMaxTime(t *testing.T) {
+	const amount, sequence = 50000000, 101
+	signer := keypair.MustRandom()
+
+	w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount, StellarNetwork: "testnet", StellarPaymentTimeout: 10 * time.Minute}}
+	w.loadSigners(hProtocol.Account{
+		Thresholds: hProtocol.AccountThresholds{MedThreshold: 1},
+		Signers:    []hProtocol.Signer{{Key: signer.Address(), Weight: 1}},
+	})
+
+	// the signatures were collected a window ago
+	signedMaxTime := w.paymentMaxTime(time.Now().Add(-10 * time.Minute))
+	hash, err := w.paymentHash(testTarget, amount, sequence, signedMaxTime, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatures := []substrate.StellarSignature{{Signature: []byte(base64.StdEncoding.EncodeToString(signature)), StellarAddress: []byte(signer.Address())}}
+
+	maxTime, claimable, err := w.resolvePayment(testTarget, amount, sequence, signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxTime != signedMaxTime {
+		t.Errorf("expected the max time the signatures were collected for %d, got %d", signedMaxTime, maxTime)
+	}
+	if claimable {
+		t.Error("expected a plain payment")
+	}
+
+	if _, _, err := w.resolvePayment(testTarget, amount+1, sequence, signatures); !errors.Is(err, ErrStaleSignatures) {
+		t.Errorf("expected signatures of another payment to be stale, got %v", err)
+	}
+}
+
+func TestResolvePaymentOnce(t *testing.T) {
+	const amount, sequence = 50000000, 101
+	signer := keypair.MustRandom()
+
+	w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount, StellarNetwork: "testnet", StellarPaymentTimeout: 10 * time.Minute}}
+	w.loadSigners(hProtocol.Account{
+		Thresholds: hProtocol.AccountThresholds{MedThreshold: 1},
+		Signers:    []hProtocol.Signer{{Key: signer.Address(), Weight: 1}},
+	})
+
+	signedMaxTime := w.paymentMaxTime(time.Now())
+	hash, err := w.paymentHash(testTarget, amount, sequence, signedMaxTime, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatures := []substrate.StellarSignature{{Signature: []byte(base64.StdEncoding.EncodeToString(signature)), StellarAddress: []byte(signer.Address())}}
+
+	if _, _, err := w.resolvePayment(testTarget, amount, sequence, signatures); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := w.resolvePayment(testTarget, amount+1, sequence, signatures); !errors.Is(err, ErrStaleSignatures) {
+		t.Fatalf("expected signatures of another payment to be stale, got %v", err)
+	}
+
+	// without signers a search matches no window, the payments resolved before are not searched again
+	w.signerWeights = nil
+	maxTime, _, err := w.resolvePayment(testTarget, amount, sequence, signatures)
+	if err != nil {
+		t.Fatalf("expected the resolved payment, got %s", err)
+	}
+	if maxTime != signedMaxTime {
+		t.Errorf("expected the resolved max time %d, got %d", signedMaxTime, maxTime)
+	}
+	if _, _, err := w.resolvePayment(testTarget, amount+1, sequence, signatures); !errors.Is(err, ErrStaleSignatures) {
+		t.Errorf("expected the resolved stale payment, got %v", err)
+	}
+	if len(w.resolved.entries) != 2 {
+		t.Errorf("expected 2 resolved payments, got %d", len(w.resolved.entries))
+	}
+}
+
+func TestClaimablePayment(t *testing.T) {
+	const amount, sequence = 50000000, 101
+	signer := keypair.MustRandom()
+
+	w := &StellarWallet{config: &pkg.StellarConfig{StellarBridgeAccount: testBridgeAccount, StellarNetwork: "testnet", StellarClaimableBalances: true}}
+	w.loadSigners(hProtocol.Account{
+		Thresholds: hProtocol.AccountThresholds{MedThreshold: 1},
+		Signers:    []hProtocol.Signer{{Key: signer.Address(), Weight: 1}},
+	})
+
+	// a claimable balance can not be created for a muxed address, it goes to the underlying account
+	params := w.paymentTransactionParams(testBridgeAccount, amount, testMuxedTarget, sequence, 0, true)
+	if len(params.Operations) != 1 {
+		t.Fatalf("expected a single operation, got %d", len(params.Operations))
+	}
+	op, ok := params.Operations[0].(*txnbuild.CreateClaimableBalance)
+	if !ok {
+		t.Fatalf("expected a claimable balance operation, got %T", params.Operations[0])
+	}
+	if op.Amount != "5.0000000" || op.SourceAccount != testBridgeAccount {
+		t.Errorf("expected a claimable balance of 5.0000000 from the bridge account, got %s from %s", op.Amount, op.SourceAccount)
+	}
+	if len(op.Destinations) != 1 || op.Destinations[0].Destination != testTarget {
+		t.Errorf("expected the destination %s to be the only claimant, got %+v", testTarget, op.Destinations)
+	}
+	if _, err := txnbuild.NewTransaction(params); err != nil {
+		t.Fatalf("expected a valid transaction, got %s", err)
+	}
+
+	// the payment kind is resolved from the signatures
+	hash, err := w.paymentHash(testTarget, amount, sequence, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatures := []substrate.StellarSignature{{Signature: []byte(base64.StdEncoding.EncodeToString(signature)), StellarAddress: []byte(signer.Address())}}
+
+	_, claimable, err := w.resolvePayment(testTarget, amount, sequence, signatures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claimable {
+		t.Error("expected the signatures to sign a claimable balance")
+	}
+}
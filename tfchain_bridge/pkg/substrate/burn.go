@@ -0,0 +1,57 @@
+package substrate
+
+import (
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// GetExecutedBurnTransaction gets a burn transaction that was already executed,
+// executed burn transactions are moved out of the pending burn transactions storage
+func (s *SubstrateClient) GetExecutedBurnTransaction(burnTransactionID uint64) (*substrate.BurnTransaction, error) {
+	cl, meta, err := s.GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := types.Encode(types.U64(burnTransactionID))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode burn transaction id")
+	}
+
+	key, err := types.CreateStorageKey(meta, "TFTBridgeModule", "ExecutedBurnTransactions", bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create storage key")
+	}
+
+	var burnTx substrate.BurnTransaction
+	ok, err := cl.RPC.State.GetStorageLatest(key, &burnTx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lookup executed burn transaction")
+	}
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &burnTx, nil
+}
+
+// HasWithdrawSignature checks if stellarAddress signed the pending burn transaction with burnTransactionID, the
+// signatures of a burn transaction are reset when it expires so a signature found is one of the current round
+func (s *SubstrateClient) HasWithdrawSignature(burnTransactionID uint64, stellarAddress string) (bool, error) {
+	burnTx, err := s.GetBurnTransaction(types.U64(burnTransactionID))
+	if errors.Is(err, substrate.ErrBurnTransactionNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get burn transaction")
+	}
+
+	for _, sig := range burnTx.Signatures {
+		if string(sig.StellarAddress) == stellarAddress {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,148 @@
+package substrate
+
+import (
+	"math/big"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// the bridge pallet extrinsics are submitted through callExtrinsic so the extrinsic options apply to them
+
+func (s *SubstrateClient) proposeOrVoteMintTransaction(txID string, target substrate.AccountID, amount *big.Int) error {
+	_, meta, _, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	c, err := types.NewCall(meta, "TFTBridgeModule.propose_or_vote_mint_transaction",
+		txID, target, types.U64(amount.Uint64()),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to create call")
+	}
+
+	traceMint(c, txID, target, amount)
+	return errors.Wrap(s.callExtrinsic(c), "failed to propose mint transaction")
+}
+
+func (s *SubstrateClient) proposeBurnTransactionOrAddSig(txID uint64, target string, amount *big.Int, signature string, stellarAddress string, sequenceNumber uint64) error {
+	_, meta, _, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	c, err := types.NewCall(meta, "TFTBridgeModule.propose_burn_transaction_or_add_sig",
+		txID, target, types.U64(amount.Uint64()), signature, stellarAddress, sequenceNumber,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to create call")
+	}
+
+	traceBurn(c, txID, target, amount, signature, stellarAddress, sequenceNumber)
+	return errors.Wrap(s.callExtrinsic(c), "failed to propose burn transaction")
+}
+
+func (s *SubstrateClient) setBurnTransactionExecuted(txID uint64) error {
+	_, meta, _, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	c, err := types.NewCall(meta, "TFTBridgeModule.set_burn_transaction_executed", txID)
+	if err != nil {
+		return errors.Wrap(err, "failed to create call")
+	}
+
+	traceCall("set_burn_transaction_executed", c, true).Uint64("tx_id", txID).Msg("submitting extrinsic")
+	return errors.Wrap(s.callExtrinsic(c), "failed to set burn transaction executed")
+}
+
+func (s *SubstrateClient) resetBurnTransaction(txID uint64, sequenceNumber uint64) error {
+	_, meta, _, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	if _, err := meta.FindCallIndex("TFTBridgeModule.reset_burn_transaction"); err != nil {
+		return ErrCallNotSupported
+	}
+
+	c, err := types.NewCall(meta, "TFTBridgeModule.reset_burn_transaction", txID, sequenceNumber)
+	if err != nil {
+		return errors.Wrap(err, "failed to create call")
+	}
+
+	traceCall("reset_burn_transaction", c, true).Uint64("tx_id", txID).Uint64("sequence", sequenceNumber).Msg("submitting extrinsic")
+	return errors.Wrap(s.callExtrinsic(c), "failed to reset burn transaction")
+}
+
+func (s *SubstrateClient) createRefundTransactionOrAddSig(txHash string, target string, amount int64, signature string, stellarAddress string, sequenceNumber uint64) error {
+	_, meta, _, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	c, err := types.NewCall(meta, "TFTBridgeModule.create_refund_transaction_or_add_sig",
+		txHash, target, types.U64(amount), signature, stellarAddress, sequenceNumber,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to create call")
+	}
+
+	traceCall("create_refund_transaction_or_add_sig", c, false).Str("tx_hash", txHash).Str("target", target).Int64("amount", amount).
+		Str("signature", redact(signature)).Str("stellar_address", stellarAddress).Uint64("sequence", sequenceNumber).Msg("submitting extrinsic")
+	return errors.Wrap(s.callExtrinsic(c), "failed to create refund transaction")
+}
+
+func (s *SubstrateClient) setRefundTransactionExecuted(txHash string) error {
+	_, meta, _, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	c, err := types.NewCall(meta, "TFTBridgeModule.set_refund_transaction_executed", txHash)
+	if err != nil {
+		return errors.Wrap(err, "failed to create call")
+	}
+
+	traceCall("set_refund_transaction_executed", c, true).Str("tx_hash", txHash).Msg("submitting extrinsic")
+	return errors.Wrap(s.callExtrinsic(c), "failed to set refund transaction executed")
+}
+
+// traceMint logs the decoded arguments of a mint proposal together with the encoded call
+func traceMint(c types.Call, txID string, target substrate.AccountID, amount *big.Int) {
+	traceCall("propose_or_vote_mint_transaction", c, true).Str("tx_id", txID).Str("target", target.String()).Str("amount", amount.String()).Msg("submitting extrinsic")
+}
+
+// traceBurn logs the decoded arguments of a burn proposal, the call carries the stellar signature so it is left out
+func traceBurn(c types.Call, txID uint64, target string, amount *big.Int, signature string, stellarAddress string, sequenceNumber uint64) {
+	traceCall("propose_burn_transaction_or_add_sig", c, false).Uint64("tx_id", txID).Str("target", target).Str("amount", amount.String()).
+		Str("signature", redact(signature)).Str("stellar_address", stellarAddress).Uint64("sequence", sequenceNumber).Msg("submitting extrinsic")
+}
+
+// traceCall starts a trace log of a bridge call, the scale encoded call is only added if withEncoded is set
+// as the encoding of calls carrying a stellar signature would contain the signature in full
+func traceCall(name string, call types.Call, withEncoded bool) *zerolog.Event {
+	event := log.Trace().Str("call", name)
+	if !withEncoded || !event.Enabled() {
+		return event
+	}
+
+	encoded, err := types.Encode(call)
+	if err != nil {
+		return event.AnErr("encode_error", err)
+	}
+	return event.Hex("encoded", encoded)
+}
+
+// redact shortens a signature to a prefix that is enough to tell signatures apart
+func redact(signature string) string {
+	if len(signature) <= 8 {
+		return signature
+	}
+	return signature[:8] + "..."
+}
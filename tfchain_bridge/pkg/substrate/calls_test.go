@@ -0,0 +1,79 @@
+package substrate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// captureTrace enables trace logging into a buffer until the test ends
+func captureTrace(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&buf)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+	return &buf
+}
+
+func TestTraceMint(t *testing.T) {
+	const twin = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+	target, err := substrate.FromAddress(twin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := types.Call{CallIndex: types.CallIndex{SectionIndex: 35, MethodIndex: 0}, Args: types.Args{0x01, 0x02}}
+	buf := captureTrace(t)
+
+	traceMint(call, "a1", target, big.NewInt(50000000))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid trace %q: %s", buf.String(), err)
+	}
+	if entry["level"] != "trace" || entry["call"] != "propose_or_vote_mint_transaction" {
+		t.Errorf("unexpected trace %v", entry)
+	}
+	if entry["target"] != twin || entry["amount"] != "50000000" || entry["tx_id"] != "a1" {
+		t.Errorf("expected the trace to contain the decoded target and amount, got %v", entry)
+	}
+	encoded, _ := types.Encode(call)
+	if entry["encoded"] != hex.EncodeToString(encoded) {
+		t.Errorf("expected the encoded call %x, got %v", encoded, entry["encoded"])
+	}
+}
+
+func TestTraceBurnRedactsSignature(t *testing.T) {
+	const signature = "c2lnbmF0dXJlIG9mIHRoZSB3aXRoZHJhdyBwYXltZW50"
+	buf := captureTrace(t)
+
+	traceBurn(types.Call{Args: types.Args(signature)}, 7, "GBMMTFUUYMB2EHLJTXIGXOJY34Y7CFZ2PG4NNTALEDE5QP72SLEU3ZXJ", big.NewInt(50000000), signature, "GDCAMOLMOTTIKJ6MRQ4WPXIUBWEV4CZS7QNVDNO65XKYOOEPYV5NZGDG", 101)
+
+	if strings.Contains(buf.String(), signature) {
+		t.Errorf("the trace contains the signature in full: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"signature":"c2lnbmF0..."`) {
+		t.Errorf("expected the trace to contain the redacted signature, got %s", buf.String())
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	buf := captureTrace(t)
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	traceMint(types.Call{}, "a1", substrate.AccountID{}, big.NewInt(1))
+	if buf.Len() != 0 {
+		t.Errorf("expected no trace below the trace level, got %s", buf.String())
+	}
+}
@@ -2,14 +2,16 @@ package substrate
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
 )
 
 var (
@@ -19,49 +21,105 @@ var (
 	ErrUnknownVersion = fmt.Errorf("unknown version")
 	//ErrNotFound is returned if an object is not found
 	ErrNotFound = fmt.Errorf("object not found")
+	//ErrCallNotSupported is returned if the runtime does not have a call
+	ErrCallNotSupported = fmt.Errorf("call not supported by the runtime")
+	//ErrMintAlreadyExecuted is returned if a mint transaction was executed before the vote was included
+	ErrMintAlreadyExecuted = fmt.Errorf("mint transaction already executed")
+	//ErrSignatureExists is returned if the burn transaction has a signature of the bridge account already
+	ErrSignatureExists = fmt.Errorf("signature exists already")
+	//ErrResetExists is returned if the bridge account reported the signatures of the burn transaction as stale already
+	ErrResetExists = fmt.Errorf("reset exists already")
+	//ErrInsufficientFunds is returned if the account submitting the extrinsics can not pay their fees
+	ErrInsufficientFunds = fmt.Errorf("account can not pay the extrinsic fees")
 )
 
+// moduleErrors maps the names of the module errors the bridge handles to their sentinel error
+var moduleErrors = map[string]error{
+	"MintTransactionAlreadyExecuted": ErrMintAlreadyExecuted,
+	"BurnSignatureExists":            ErrSignatureExists,
+	"BurnResetExists":                ErrResetExists,
+}
+
 // Versioned base for all types
 type Versioned struct {
 	Version uint32
 }
 
 type SubstrateClient struct {
-	*substrate.Substrate
+	// connMu guards the connection, it is replaced when the bridge fails over to another endpoint
+	connMu sync.RWMutex
+	sub    *substrate.Substrate
+	// urls are the tfchain endpoints in order of preference, active is the index of the connected one
+	urls     []string
+	active   int
 	identity substrate.Identity
+	options  ExtrinsicOptions
+	gate     *submissionGate
+	nonces   *NonceManager
+	runtime  runtimeMetadata
+	funds    fundsState
 }
 
-// NewSubstrate creates a substrate client
-func NewSubstrateClient(url string, seed string) (*SubstrateClient, error) {
-	mngr := substrate.NewManager(url)
-	cl, err := mngr.Substrate()
-	if err != nil {
+// NewSubstrate creates a substrate client submitting the extrinsics signed by identity, it connects to the first
+// of urls that can be reached and fails over to the next ones
+func NewSubstrateClient(urls []string, identity substrate.Identity, options ExtrinsicOptions) (*SubstrateClient, error) {
+	if err := options.Validate(); err != nil {
 		return nil, err
 	}
-	tfchainIdentity, err := substrate.NewIdentityFromSr25519Phrase(seed)
+	if len(urls) == 0 {
+		return nil, errors.New("no tfchain url configured")
+	}
+
+	cl, active, err := connect(urls, 0)
 	if err != nil {
 		return nil, err
 	}
+	log.Info().Msgf("key with address %s loaded", identity.Address())
+	if options.Proxy != nil && !options.DryRun {
+		if err := checkProxySupported(cl); err != nil {
+			return nil, err
+		}
+		log.Info().Str("proxy", options.Proxy.Address()).Msg("extrinsics are submitted through a proxy account paying their fees")
+	}
 
-	log.Info().Msgf("key with address %s loaded", tfchainIdentity.Address())
+	// a dry run client never submits extrinsics so it can run with any account
+	if !options.DryRun {
+		isValidator, err := cl.IsValidator(identity)
+		if err != nil {
+			return nil, err
+		}
 
-	isValidator, err := cl.IsValidator(tfchainIdentity)
-	if err != nil {
-		return nil, err
+		if !isValidator {
+			return nil, pkg.ErrNotValidator
+		}
 	}
 
-	if !isValidator {
-		return nil, fmt.Errorf("account provided is not a validator for the bridge runtime")
+	client := &SubstrateClient{
+		sub:      cl,
+		urls:     urls,
+		active:   active,
+		identity: identity,
+		options:  options,
+		gate:     newSubmissionGate(),
 	}
+	client.nonces = newNonceManager(options.LocalNonces, func() (uint64, error) {
+		account, err := client.GetAccount(client.submitter())
+		if err != nil {
+			return 0, err
+		}
+		return uint64(account.Nonce), nil
+	})
 
-	return &SubstrateClient{
-		cl,
-		tfchainIdentity,
-	}, nil
+	return client, nil
 }
 
 func (s *SubstrateClient) RetrySetWithdrawExecuted(ctx context.Context, tixd uint64) error {
-	err := s.SetBurnTransactionExecuted(s.identity, tixd)
+	// the context of a handler that timed out is cancelled, its abandoned attempt must not submit anymore
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.setBurnTransactionExecuted(tixd)
 	for err != nil {
 		log.Err(err).Msg("error while setting refund transaction as executed")
 
@@ -75,7 +133,7 @@ func (s *SubstrateClient) RetrySetWithdrawExecuted(ctx context.Context, tixd uin
 			}
 
 			if !burnedAlready {
-				err = s.SetBurnTransactionExecuted(s.identity, tixd)
+				err = s.setBurnTransactionExecuted(tixd)
 			} else {
 				err = nil
 			}
@@ -85,9 +143,19 @@ func (s *SubstrateClient) RetrySetWithdrawExecuted(ctx context.Context, tixd uin
 	return nil
 }
 
+// RetryProposeWithdrawOrAddSig proposes or signs the burn transaction until it is burned. A signature that is
+// rejected because the bridge account signed the burn transaction already, before a restart, is a success.
 func (s *SubstrateClient) RetryProposeWithdrawOrAddSig(ctx context.Context, txID uint64, target string, amount *big.Int, signature string, stellarAddress string, sequence_number uint64) error {
-	err := s.ProposeBurnTransactionOrAddSig(s.identity, txID, target, amount, signature, stellarAddress, sequence_number)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.proposeBurnTransactionOrAddSig(txID, target, amount, signature, stellarAddress, sequence_number)
 	for err != nil {
+		if errors.Is(err, ErrSignatureExists) {
+			log.Info().Uint64("ID", txID).Msg("burn transaction is signed by us already")
+			return nil
+		}
 		log.Err(err).Msg("error while proposing withdraw or adding signature")
 
 		select {
@@ -100,7 +168,7 @@ func (s *SubstrateClient) RetryProposeWithdrawOrAddSig(ctx context.Context, txID
 			}
 
 			if !burnedAlready {
-				err = s.ProposeBurnTransactionOrAddSig(s.identity, txID, target, amount, signature, stellarAddress, sequence_number)
+				err = s.proposeBurnTransactionOrAddSig(txID, target, amount, signature, stellarAddress, sequence_number)
 			} else {
 				err = nil
 			}
@@ -110,8 +178,26 @@ func (s *SubstrateClient) RetryProposeWithdrawOrAddSig(ctx context.Context, txID
 	return nil
 }
 
+// ResetWithdraw reports the signatures of the burn transaction with txID collected for the stellar sequenceNumber as
+// stale, once a majority of the validators reported them the burn transaction expires so validators sign it again with
+// a fresh sequence number. A report that is rejected because the bridge account reported them already is a success.
+// ErrCallNotSupported is returned if the runtime can not reset burn transactions, the burn transaction then expires
+// after the retry interval.
+func (s *SubstrateClient) ResetWithdraw(txID uint64, sequenceNumber uint64) error {
+	err := s.resetBurnTransaction(txID, sequenceNumber)
+	if errors.Is(err, ErrResetExists) {
+		log.Info().Uint64("ID", txID).Msg("stale burn transaction is reported by us already")
+		return nil
+	}
+	return err
+}
+
 func (s *SubstrateClient) RetryCreateRefundTransactionOrAddSig(ctx context.Context, txHash string, target string, amount int64, signature string, stellarAddress string, sequence_number uint64) error {
-	err := s.CreateRefundTransactionOrAddSig(s.identity, txHash, target, amount, signature, stellarAddress, sequence_number)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.createRefundTransactionOrAddSig(txHash, target, amount, signature, stellarAddress, sequence_number)
 	for err != nil {
 		log.Err(err).Msg("error while creating refund tx or adding signature")
 
@@ -125,7 +211,7 @@ func (s *SubstrateClient) RetryCreateRefundTransactionOrAddSig(ctx context.Conte
 			}
 
 			if !refundedAlready {
-				err = s.CreateRefundTransactionOrAddSig(s.identity, txHash, target, amount, signature, stellarAddress, sequence_number)
+				err = s.createRefundTransactionOrAddSig(txHash, target, amount, signature, stellarAddress, sequence_number)
 			} else {
 				err = nil
 			}
@@ -137,7 +223,11 @@ func (s *SubstrateClient) RetryCreateRefundTransactionOrAddSig(ctx context.Conte
 }
 
 func (s *SubstrateClient) RetrySetRefundTransactionExecutedTx(ctx context.Context, txHash string) error {
-	err := s.SetRefundTransactionExecuted(s.identity, txHash)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.setRefundTransactionExecuted(txHash)
 	for err != nil {
 		log.Err(err).Msg("error while setting refund transaction as executed")
 
@@ -151,7 +241,7 @@ func (s *SubstrateClient) RetrySetRefundTransactionExecutedTx(ctx context.Contex
 			}
 
 			if !refundedAlready {
-				err = s.SetRefundTransactionExecuted(s.identity, txHash)
+				err = s.setRefundTransactionExecuted(txHash)
 			} else {
 				err = nil
 			}
@@ -161,24 +251,32 @@ func (s *SubstrateClient) RetrySetRefundTransactionExecutedTx(ctx context.Contex
 	return nil
 }
 
+// RetryProposeMintOrVote proposes or votes for the mint transaction until it is executed. A vote that
+// is rejected because the other validators executed the mint already is not needed, so it is a success.
 func (s *SubstrateClient) RetryProposeMintOrVote(ctx context.Context, txID string, target substrate.AccountID, amount *big.Int) error {
-	err := s.ProposeOrVoteMintTransaction(s.identity, txID, target, amount)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := s.proposeOrVoteMintTransaction(txID, target, amount)
 	for err != nil {
+		if errors.Is(err, ErrMintAlreadyExecuted) {
+			log.Info().Str("tx_id", txID).Msg("mint transaction executed already, vote not needed")
+			return nil
+		}
 		log.Err(err).Msg("error while proposing mint or voting")
 
 		select {
 		case <-ctx.Done():
 			return err
 		case <-time.After(10 * time.Second):
-			mintedAlready, mErr := s.IsMintedAlready(txID)
+			mintedAlready, mErr := s.CheckMinted(ctx, txID)
 			if mErr != nil {
-				if !errors.Is(mErr, substrate.ErrMintTransactionNotFound) {
-					return err
-				}
+				return mErr
 			}
 
 			if !mintedAlready {
-				err = s.ProposeOrVoteMintTransaction(s.identity, txID, target, amount)
+				err = s.proposeOrVoteMintTransaction(txID, target, amount)
 			} else {
 				err = nil
 			}
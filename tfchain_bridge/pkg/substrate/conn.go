@@ -0,0 +1,142 @@
+package substrate
+
+import (
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// dial opens a connection to a tfchain endpoint
+var dial = func(url string) (*substrate.Substrate, error) {
+	return substrate.NewManager(url).Substrate()
+}
+
+// connect connects to the first endpoint of urls that can be reached, starting at start and wrapping around.
+// It returns the connection and the index of its endpoint.
+func connect(urls []string, start int) (*substrate.Substrate, int, error) {
+	var err error
+	for i := range urls {
+		index := (start + i) % len(urls)
+		var cl *substrate.Substrate
+		cl, err = dial(urls[index])
+		if err == nil {
+			setActiveMetric(urls, index)
+			return cl, index, nil
+		}
+		log.Err(err).Str("url", urls[index]).Msg("failed to connect to tfchain endpoint")
+	}
+	return nil, 0, errors.Wrap(err, "failed to connect to any tfchain endpoint")
+}
+
+func setActiveMetric(urls []string, active int) {
+	for i, url := range urls {
+		value := 0.0
+		if i == active {
+			value = 1
+		}
+		tfchainActive.Set(value, url)
+	}
+}
+
+// conn returns the connection to the active endpoint
+func (s *SubstrateClient) conn() *substrate.Substrate {
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+
+	return s.sub
+}
+
+// Failover connects to the next endpoint that can be reached and closes the connection to the failed endpoint,
+// calls in flight on the failed connection fail and are retried on the new one
+func (s *SubstrateClient) Failover() error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	cl, active, err := connect(s.urls, s.active+1)
+	if err != nil {
+		return err
+	}
+
+	log.Warn().Str("failed", s.urls[s.active]).Str("active", s.urls[active]).Msg("reconnected to tfchain after the active endpoint failed")
+	tfchainFailovers.Inc()
+	s.sub.Close()
+	s.sub, s.active = cl, active
+	return nil
+}
+
+// the calls of the tfchain connection go through the active connection so they follow a failover
+
+func (s *SubstrateClient) Close() {
+	s.conn().Close()
+}
+
+func (s *SubstrateClient) GetClient() (substrate.Conn, substrate.Meta, error) {
+	return s.conn().GetClient()
+}
+
+func (s *SubstrateClient) Time() (time.Time, error) {
+	return s.conn().Time()
+}
+
+func (s *SubstrateClient) GetAccount(identity substrate.Identity) (substrate.AccountInfo, error) {
+	return s.conn().GetAccount(identity)
+}
+
+func (s *SubstrateClient) GetBalance(account substrate.AccountID) (substrate.Balance, error) {
+	return s.conn().GetBalance(account)
+}
+
+func (s *SubstrateClient) GetBlock(block types.Hash) (*types.SignedBlock, error) {
+	return s.conn().GetBlock(block)
+}
+
+func (s *SubstrateClient) GetEventsForBlock(height uint32) (*substrate.EventRecords, error) {
+	return s.conn().GetEventsForBlock(height)
+}
+
+func (s *SubstrateClient) IsValidator(identity substrate.Identity) (bool, error) {
+	return s.conn().IsValidator(identity)
+}
+
+func (s *SubstrateClient) GetDepositFee() (int64, error) {
+	return s.conn().GetDepositFee()
+}
+
+func (s *SubstrateClient) GetTwin(id uint32) (*substrate.Twin, error) {
+	return s.conn().GetTwin(id)
+}
+
+func (s *SubstrateClient) GetFarm(id uint32) (*substrate.Farm, error) {
+	return s.conn().GetFarm(id)
+}
+
+func (s *SubstrateClient) GetNode(id uint32) (*substrate.Node, error) {
+	return s.conn().GetNode(id)
+}
+
+func (s *SubstrateClient) GetEntity(id uint32) (*substrate.Entity, error) {
+	return s.conn().GetEntity(id)
+}
+
+func (s *SubstrateClient) GetBurnTransaction(id types.U64) (*substrate.BurnTransaction, error) {
+	return s.conn().GetBurnTransaction(id)
+}
+
+func (s *SubstrateClient) GetRefundTransaction(txHash string) (*substrate.RefundTransaction, error) {
+	return s.conn().GetRefundTransaction(txHash)
+}
+
+func (s *SubstrateClient) IsBurnedAlready(id types.U64) (bool, error) {
+	return s.conn().IsBurnedAlready(id)
+}
+
+func (s *SubstrateClient) IsMintedAlready(txID string) (bool, error) {
+	return s.conn().IsMintedAlready(txID)
+}
+
+func (s *SubstrateClient) IsRefundedAlready(txHash string) (bool, error) {
+	return s.conn().IsRefundedAlready(txHash)
+}
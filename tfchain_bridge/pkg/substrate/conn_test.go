@@ -0,0 +1,79 @@
+package substrate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// fakeDial replaces dial with endpoints that can only be reached if they are up, it records the dialed urls
+func fakeDial(t *testing.T, up map[string]bool) *[]string {
+	dialed := []string{}
+	original := dial
+	dial = func(url string) (*substrate.Substrate, error) {
+		dialed = append(dialed, url)
+		if !up[url] {
+			return nil, errors.Errorf("failed to connect to %s", url)
+		}
+		return &substrate.Substrate{}, nil
+	}
+	t.Cleanup(func() { dial = original })
+	return &dialed
+}
+
+func TestConnectFailover(t *testing.T) {
+	urls := []string{"ws://primary", "ws://standby1", "ws://standby2"}
+
+	tests := []struct {
+		name   string
+		up     map[string]bool
+		start  int
+		active int
+		dialed []string
+	}{
+		{name: "primary up", up: map[string]bool{"ws://primary": true, "ws://standby1": true}, active: 0, dialed: []string{"ws://primary"}},
+		{name: "primary down", up: map[string]bool{"ws://standby1": true}, active: 1, dialed: []string{"ws://primary", "ws://standby1"}},
+		{name: "only last standby up", up: map[string]bool{"ws://standby2": true}, active: 2, dialed: urls},
+		// a failover starts after the failed endpoint and wraps around
+		{name: "failover from last standby", up: map[string]bool{"ws://primary": true, "ws://standby2": true}, start: 3, active: 0, dialed: []string{"ws://primary"}},
+		{name: "failover wraps around", up: map[string]bool{"ws://primary": true}, start: 1, active: 0, dialed: []string{"ws://standby1", "ws://standby2", "ws://primary"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dialed := fakeDial(t, test.up)
+
+			cl, active, err := connect(urls, test.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cl == nil || active != test.active {
+				t.Errorf("expected to connect to %s, got endpoint %d", urls[test.active], active)
+			}
+			if !reflect.DeepEqual(*dialed, test.dialed) {
+				t.Errorf("expected the endpoints %v to be dialed, got %v", test.dialed, *dialed)
+			}
+			for i, url := range urls {
+				expected := 0.0
+				if i == test.active {
+					expected = 1
+				}
+				if value := tfchainActive.Get(url); value != expected {
+					t.Errorf("expected the active metric of %s to be %v, got %v", url, expected, value)
+				}
+			}
+		})
+	}
+}
+
+func TestConnectAllEndpointsDown(t *testing.T) {
+	dialed := fakeDial(t, nil)
+
+	if _, _, err := connect([]string{"ws://primary", "ws://standby"}, 0); err == nil {
+		t.Error("expected the connection to fail when no endpoint can be reached")
+	}
+	if len(*dialed) != 2 {
+		t.Errorf("expected every endpoint to be dialed once, got %v", *dialed)
+	}
+}
@@ -0,0 +1,247 @@
+package substrate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/scale"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// undecodableEventType is the type of the malformed events recorded for events the event records can not hold
+const undecodableEventType = "Undecodable"
+
+// getEventsForBlock fetches the events of a block and decodes them one by one, an event that can not be decoded
+// is returned as a malformed event instead of failing the whole block
+func (client *SubstrateClient) getEventsForBlock(height uint32) (*substrate.EventRecords, []pkg.MalformedEvent, error) {
+	cl, _, err := client.GetClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hash, err := cl.RPC.Chain.GetBlockHash(uint64(height))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta, err := cl.RPC.State.GetMetadata(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := types.CreateStorageKey(meta, "System", "Events", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw types.StorageDataRaw
+	ok, err := cl.RPC.State.GetStorage(key, &raw, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, errors.New("failed to get storage")
+	}
+
+	return decodeEventRecords(meta, types.EventRecordsRaw(raw))
+}
+
+// decodeEventRecords decodes the events of a block one by one. The encoded length of an event is measured with the
+// type registry of the metadata, so an event that does not fit the event records is skipped and returned as a
+// malformed event. Only a block that can not be split in events fails, older metadata decodes the block at once.
+func decodeEventRecords(meta *types.Metadata, raw types.EventRecordsRaw) (*substrate.EventRecords, []pkg.MalformedEvent, error) {
+	records := &substrate.EventRecords{}
+	if meta.Version != 14 {
+		return records, nil, raw.DecodeEventRecords(meta, records)
+	}
+
+	reader := bytes.NewReader(raw)
+	decoder := scale.NewDecoder(reader)
+	n, err := decoder.DecodeUintCompact()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var undecodable []pkg.MalformedEvent
+	for i := uint64(0); i < n.Uint64(); i++ {
+		start := len(raw) - reader.Len()
+
+		var phase types.Phase
+		if err := decoder.Decode(&phase); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to decode the phase of event #%d", i)
+		}
+		var id types.EventID
+		if err := decoder.Decode(&id); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to decode the id of event #%d", i)
+		}
+
+		module, variant, err := findEventVariant(&meta.AsMetadataV14, id)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "event #%d", i)
+		}
+		for _, field := range variant.Fields {
+			if err := skipType(&meta.AsMetadataV14, field.Type.Int64(), decoder); err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to measure event #%d %s.%s", i, module, variant.Name)
+			}
+		}
+		var topics []types.Hash
+		if err := decoder.Decode(&topics); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to decode the topics of event #%d", i)
+		}
+
+		event := raw[start : len(raw)-reader.Len()]
+		// a record of a single event is the compact encoded count of 1 followed by the event
+		single := append([]byte{0x04}, event...)
+		if err := types.EventRecordsRaw(single).DecodeEventRecords(meta, records); err != nil {
+			log.Warn().Err(err).Uint64("index", i).Str("event", fmt.Sprintf("%s.%s", module, variant.Name)).Msg("skipping event that can not be decoded")
+			undecodable = append(undecodable, pkg.MalformedEvent{
+				Type:   undecodableEventType,
+				Reason: fmt.Sprintf("event #%d %s.%s can not be decoded: %s", i, module, variant.Name, err),
+				Event:  hex.EncodeToString(event),
+			})
+		}
+	}
+
+	return records, undecodable, nil
+}
+
+// findEventVariant returns the pallet name and the variant of the event with id
+func findEventVariant(meta *types.MetadataV14, id types.EventID) (types.Text, *types.Si1Variant, error) {
+	for _, pallet := range meta.Pallets {
+		if !pallet.HasEvents || uint8(pallet.Index) != id[0] {
+			continue
+		}
+		typ, ok := meta.EfficientLookup[pallet.Events.Type.Int64()]
+		if !ok {
+			return "", nil, fmt.Errorf("event type of pallet %s is not in the type registry", pallet.Name)
+		}
+		for i := range typ.Def.Variant.Variants {
+			if uint8(typ.Def.Variant.Variants[i].Index) == id[1] {
+				return pallet.Name, &typ.Def.Variant.Variants[i], nil
+			}
+		}
+		return "", nil, fmt.Errorf("pallet %s has no event with index %d", pallet.Name, id[1])
+	}
+	return "", nil, fmt.Errorf("no pallet with index %d has events", id[0])
+}
+
+// primitiveSizes are the encoded sizes of the fixed size primitive types
+var primitiveSizes = map[types.Si0TypeDefPrimitive]int{
+	types.IsBool: 1,
+	types.IsChar: 4,
+	types.IsU8:   1,
+	types.IsU16:  2,
+	types.IsU32:  4,
+	types.IsU64:  8,
+	types.IsU128: 16,
+	types.IsU256: 32,
+	types.IsI8:   1,
+	types.IsI16:  2,
+	types.IsI32:  4,
+	types.IsI64:  8,
+	types.IsI128: 16,
+	types.IsI256: 32,
+}
+
+// skipType reads a value of the type with id from the decoder without decoding it
+func skipType(meta *types.MetadataV14, id int64, decoder *scale.Decoder) error {
+	typ, ok := meta.EfficientLookup[id]
+	if !ok {
+		return fmt.Errorf("type %d is not in the type registry", id)
+	}
+
+	def := typ.Def
+	switch {
+	case def.IsComposite:
+		for _, field := range def.Composite.Fields {
+			if err := skipType(meta, field.Type.Int64(), decoder); err != nil {
+				return err
+			}
+		}
+	case def.IsVariant:
+		index, err := decoder.ReadOneByte()
+		if err != nil {
+			return err
+		}
+		for _, variant := range def.Variant.Variants {
+			if uint8(variant.Index) != index {
+				continue
+			}
+			for _, field := range variant.Fields {
+				if err := skipType(meta, field.Type.Int64(), decoder); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return fmt.Errorf("type %d has no variant with index %d", id, index)
+	case def.IsSequence:
+		n, err := decoder.DecodeUintCompact()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < n.Uint64(); i++ {
+			if err := skipType(meta, def.Sequence.Type.Int64(), decoder); err != nil {
+				return err
+			}
+		}
+	case def.IsArray:
+		for i := uint32(0); i < uint32(def.Array.Len); i++ {
+			if err := skipType(meta, def.Array.Type.Int64(), decoder); err != nil {
+				return err
+			}
+		}
+	case def.IsTuple:
+		for _, element := range def.Tuple {
+			if err := skipType(meta, element.Int64(), decoder); err != nil {
+				return err
+			}
+		}
+	case def.IsPrimitive:
+		if def.Primitive.Si0TypeDefPrimitive == types.IsStr {
+			n, err := decoder.DecodeUintCompact()
+			if err != nil {
+				return err
+			}
+			return skipBytes(decoder, int(n.Uint64()))
+		}
+		size, ok := primitiveSizes[def.Primitive.Si0TypeDefPrimitive]
+		if !ok {
+			return fmt.Errorf("type %d is an unknown primitive %d", id, def.Primitive.Si0TypeDefPrimitive)
+		}
+		return skipBytes(decoder, size)
+	case def.IsCompact:
+		_, err := decoder.DecodeUintCompact()
+		return err
+	case def.IsBitSequence:
+		bits, err := decoder.DecodeUintCompact()
+		if err != nil {
+			return err
+		}
+		store, ok := meta.EfficientLookup[def.BitSequence.BitStoreType.Int64()]
+		if !ok || !store.Def.IsPrimitive {
+			return fmt.Errorf("type %d has an unknown bit store type", id)
+		}
+		size := primitiveSizes[store.Def.Primitive.Si0TypeDefPrimitive]
+		if size == 0 {
+			return fmt.Errorf("type %d has an unknown bit store type", id)
+		}
+		words := (int(bits.Uint64()) + size*8 - 1) / (size * 8)
+		return skipBytes(decoder, words*size)
+	default:
+		return fmt.Errorf("type %d can not be measured", id)
+	}
+	return nil
+}
+
+func skipBytes(decoder *scale.Decoder, n int) error {
+	if n == 0 {
+		return nil
+	}
+	return decoder.Read(make([]byte, n))
+}
@@ -0,0 +1,100 @@
+package substrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// testMetadata describes a bridge pallet with index 5 emitting BurnTransactionReady(u64) and an event the event
+// records have no field for, Unknown(u32, Vec<u8>)
+func testMetadata() *types.Metadata {
+	primitive := func(p types.Si0TypeDefPrimitive) *types.Si1Type {
+		return &types.Si1Type{Def: types.Si1TypeDef{IsPrimitive: true, Primitive: types.Si1TypeDefPrimitive{Si0TypeDefPrimitive: p}}}
+	}
+	field := func(id uint64) types.Si1Field {
+		return types.Si1Field{Type: types.NewSi1LookupTypeIDFromUInt(id)}
+	}
+
+	return &types.Metadata{
+		Version: 14,
+		AsMetadataV14: types.MetadataV14{
+			Pallets: []types.PalletMetadataV14{{
+				Name:      "TFTBridgeModule",
+				HasEvents: true,
+				Events:    types.EventMetadataV14{Type: types.NewSi1LookupTypeIDFromUInt(10)},
+				Index:     types.NewU8(5),
+			}},
+			EfficientLookup: map[int64]*types.Si1Type{
+				1: primitive(types.IsU64),
+				2: primitive(types.IsU32),
+				3: {Def: types.Si1TypeDef{IsSequence: true, Sequence: types.Si1TypeDefSequence{Type: types.NewSi1LookupTypeIDFromUInt(4)}}},
+				4: primitive(types.IsU8),
+				10: {Def: types.Si1TypeDef{IsVariant: true, Variant: types.Si1TypeDefVariant{Variants: []types.Si1Variant{
+					{Name: "BurnTransactionReady", Index: 3, Fields: []types.Si1Field{field(1)}},
+					{Name: "Unknown", Index: 9, Fields: []types.Si1Field{field(2), field(3)}},
+				}}}},
+			},
+		},
+	}
+}
+
+func encodeEvent(t *testing.T, id types.EventID, fields ...interface{}) []byte {
+	t.Helper()
+
+	values := append([]interface{}{types.Phase{IsApplyExtrinsic: true, AsApplyExtrinsic: 1}, id}, fields...)
+	values = append(values, []types.Hash{})
+	var encoded []byte
+	for _, value := range values {
+		b, err := types.Encode(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded = append(encoded, b...)
+	}
+	return encoded
+}
+
+func TestDecodeEventRecordsSkipsUndecodableEvents(t *testing.T) {
+	count, err := types.Encode(types.NewUCompactFromUInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := append(count, encodeEvent(t, types.EventID{5, 3}, types.U64(7))...)
+	raw = append(raw, encodeEvent(t, types.EventID{5, 9}, types.U32(1), types.NewBytes([]byte{1, 2, 3}))...)
+	raw = append(raw, encodeEvent(t, types.EventID{5, 3}, types.U64(8))...)
+
+	records, undecodable, err := decodeEventRecords(testMetadata(), types.EventRecordsRaw(raw))
+	if err != nil {
+		t.Fatalf("block failed to decode: %s", err)
+	}
+
+	var ids []uint64
+	for _, event := range records.TFTBridgeModule_BurnTransactionReady {
+		ids = append(ids, uint64(event.BurnTransactionID))
+	}
+	if len(ids) != 2 || ids[0] != 7 || ids[1] != 8 {
+		t.Errorf("expected the burn transaction ready events 7 and 8, got %v", ids)
+	}
+
+	if len(undecodable) != 1 {
+		t.Fatalf("expected 1 undecodable event, got %d", len(undecodable))
+	}
+	if undecodable[0].Type != undecodableEventType || !strings.Contains(undecodable[0].Reason, "TFTBridgeModule.Unknown") {
+		t.Errorf("unexpected undecodable event %+v", undecodable[0])
+	}
+}
+
+func TestDecodeEventRecordsFailsOnUnknownEvent(t *testing.T) {
+	count, err := types.Encode(types.NewUCompactFromUInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without a variant the length of the event is unknown, so the events after it can not be found
+	raw := append(count, encodeEvent(t, types.EventID{5, 4}, types.U64(7))...)
+
+	if _, _, err := decodeEventRecords(testMetadata(), types.EventRecordsRaw(raw)); err == nil {
+		t.Fatal("expected an event missing from the metadata to fail the block")
+	}
+}
@@ -5,13 +5,18 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/rpc/chain"
 	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
 )
 
 type EventSubscription struct {
 	Events Events
+	// Height and Hash identify the block the events belong to
+	Height uint32
+	Hash   types.Hash
 	Err    error
 }
 
@@ -22,6 +27,8 @@ type Events struct {
 	RefundCreatedEvents   []RefundTransactionCreatedEvent
 	RefundReadyEvents     []RefundTransactionReadyEvent
 	RefundExpiredEvents   []RefundTransactionExpiredEvent
+	// MalformedEvents are the events that were left out because their content is invalid
+	MalformedEvents []pkg.MalformedEvent
 }
 
 type WithdrawCreatedEvent struct {
@@ -58,7 +65,10 @@ type RefundTransactionExpiredEvent struct {
 	Amount uint64
 }
 
-func (client *SubstrateClient) SubscribeTfchainBridgeEvents(ctx context.Context, eventChannel chan<- EventSubscription) error {
+// SubscribeTfchainBridgeEvents sends the bridge events of every finalized block to eventChannel. The blocks
+// finalized after lastHeight, the last block the bridge processed, are replayed before the first head and after
+// every resubscription so the blocks finalized while the bridge or its endpoint was down are not missed.
+func (client *SubstrateClient) SubscribeTfchainBridgeEvents(ctx context.Context, eventChannel chan<- EventSubscription, lastHeight func() (uint32, error)) error {
 	cl, _, err := client.GetClient()
 	if err != nil {
 		log.Fatal().Msg("failed to get client")
@@ -69,26 +79,38 @@ func (client *SubstrateClient) SubscribeTfchainBridgeEvents(ctx context.Context,
 		log.Fatal().Msg("failed to subscribe to finalized heads")
 	}
 
+	heads := &headProcessor{
+		tracker: newBlockTracker(),
+		canonicalHash: func(height uint32) (types.Hash, error) {
+			cl, _, err := client.GetClient()
+			if err != nil {
+				return types.Hash{}, err
+			}
+			return cl.RPC.Chain.GetBlockHash(uint64(height))
+		},
+		fetch:   client.processEventsForHeight,
+		channel: eventChannel,
+	}
+	if err := heads.resume(lastHeight); err != nil {
+		return err
+	}
+
 	for {
 		select {
 		case head := <-chainHeadsSub.Chan():
-			events, err := client.processEventsForHeight(uint32(head.Number))
-			data := EventSubscription{
-				Events: events,
-				Err:    err,
-			}
-			eventChannel <- data
+			heads.process(uint32(head.Number), head.ParentHash)
 		case err := <-chainHeadsSub.Err():
 			log.Err(err).Msg("error with subscription")
 
-			bo := backoff.NewExponentialBackOff()
-			bo.MaxElapsedTime = time.Duration(time.Minute * 10) // 10 minutes
-			_ = backoff.RetryNotify(func() error {
-				chainHeadsSub, err = cl.RPC.Chain.SubscribeFinalizedHeads()
+			chainHeadsSub, err = client.resubscribe(ctx)
+			if err != nil {
+				return err
+			}
+			// the blocks sent before the subscription failed are not processed for sure, continue from the
+			// last block the bridge persisted
+			if err := heads.resume(lastHeight); err != nil {
 				return err
-			}, bo, func(err error, d time.Duration) {
-				log.Warn().Err(err).Msgf("connection to chain lost, reopening connection in %s", d.String())
-			})
+			}
 
 		case <-ctx.Done():
 			chainHeadsSub.Unsubscribe()
@@ -97,19 +119,126 @@ func (client *SubstrateClient) SubscribeTfchainBridgeEvents(ctx context.Context,
 	}
 }
 
+// headProcessor sends the events of the blocks of the finalized heads, the blocks between the last block sent
+// and a head are replayed first and the blocks of a reorg are replayed from the fork point
+type headProcessor struct {
+	tracker       *blockTracker
+	canonicalHash func(height uint32) (types.Hash, error)
+	fetch         func(height uint32) (Events, error)
+	channel       chan<- EventSubscription
+	// last is the height of the last block sent, 0 if none is known
+	last uint32
+}
+
+// process sends the events of the head at height with parentHash, the bridge stops on the first error it sends
+func (p *headProcessor) process(height uint32, parentHash types.Hash) {
+	if p.last != 0 && height > p.last+1 {
+		log.Info().Uint32("from", p.last+1).Uint32("to", height-1).Msg("replaying blocks finalized since the last processed block")
+		for h := p.last + 1; h < height; h++ {
+			if err := p.emit(h); err != nil {
+				return
+			}
+		}
+	}
+
+	if p.tracker.isReorg(height, parentHash) {
+		fork, err := p.tracker.forkPoint(height-1, p.canonicalHash)
+		if err != nil {
+			p.channel <- EventSubscription{Height: height, Err: err}
+			return
+		}
+		log.Warn().Uint32("height", height).Uint32("fork", fork).Msg("chain reorg detected, reprocessing blocks from fork point")
+
+		// replay the blocks between the fork point and the new head from the canonical chain
+		for h := fork + 1; h < height; h++ {
+			if err := p.emit(h); err != nil {
+				return
+			}
+		}
+	}
+
+	_ = p.emit(height)
+	p.last = height
+}
+
+// emit sends the events of a block to the event channel and tracks its hash for reorg detection
+func (p *headProcessor) emit(height uint32) error {
+	var hash types.Hash
+	events, err := p.fetch(height)
+	if err == nil {
+		hash, err = p.canonicalHash(height)
+		if err == nil {
+			p.tracker.track(height, hash)
+		}
+	}
+
+	p.channel <- EventSubscription{
+		Events: events,
+		Height: height,
+		Hash:   hash,
+		Err:    err,
+	}
+	return err
+}
+
+// resume continues sending blocks after the last block the bridge processed
+func (p *headProcessor) resume(lastHeight func() (uint32, error)) error {
+	last, err := lastHeight()
+	if err != nil {
+		return err
+	}
+	p.last = last
+	return nil
+}
+
+// resubscribe opens the subscription to the finalized heads again, the active endpoint gets a minute to come back
+// before the client fails over to the next endpoint. With a single endpoint the client connects to it again.
+func (client *SubstrateClient) resubscribe(ctx context.Context) (*chain.FinalizedHeadsSubscription, error) {
+	for {
+		var sub *chain.FinalizedHeadsSubscription
+		bo := backoff.NewExponentialBackOff()
+		bo.MaxElapsedTime = time.Minute
+		err := backoff.RetryNotify(func() error {
+			cl, _, err := client.GetClient()
+			if err != nil {
+				return err
+			}
+			sub, err = cl.RPC.Chain.SubscribeFinalizedHeads()
+			return err
+		}, backoff.WithContext(bo, ctx), func(err error, d time.Duration) {
+			log.Warn().Err(err).Msgf("connection to chain lost, reopening connection in %s", d.String())
+		})
+		if err == nil {
+			return sub, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err := client.Failover(); err != nil {
+			log.Err(err).Msg("failed to fail over to another tfchain endpoint")
+		}
+	}
+}
+
 func (client *SubstrateClient) processEventsForHeight(height uint32) (Events, error) {
 	log.Info().Uint32("ID", height).Msg("fetching events for blockheight")
 	if height == 0 {
 		return Events{}, nil
 	}
 
-	records, err := client.GetEventsForBlock(height)
+	records, undecodable, err := client.getEventsForBlock(height)
 	if err != nil {
 		log.Err(err).Uint32("ID", height).Msg("failed to decode block for height")
 		return Events{}, err
 	}
 
-	return client.processEventRecords(records), nil
+	events := client.processEventRecords(records)
+	events.MalformedEvents = append(events.MalformedEvents, undecodable...)
+	for i := range events.MalformedEvents {
+		events.MalformedEvents[i].Height = height
+	}
+	return events, nil
 }
 
 func (client *SubstrateClient) processEventRecords(events *substrate.EventRecords) Events {
@@ -118,31 +247,47 @@ func (client *SubstrateClient) processEventRecords(events *substrate.EventRecord
 	var withdrawCreatedEvents []WithdrawCreatedEvent
 	var withdrawReadyEvents []WithdrawReadyEvent
 	var withdrawExpiredEvents []WithdrawExpiredEvent
+	var malformedEvents []pkg.MalformedEvent
 
 	for _, e := range events.TFTBridgeModule_RefundTransactionReady {
 		log.Info().Str("hash", string(e.RefundTransactionHash)).Msg("found refund transaction ready event")
-		refundTransactionReadyEvents = append(refundTransactionReadyEvents, RefundTransactionReadyEvent{
+		event := RefundTransactionReadyEvent{
 			Hash: string(e.RefundTransactionHash),
-		})
+		}
+		if reason := validateRefundReady(event); reason != "" {
+			malformedEvents = append(malformedEvents, malformed("RefundTransactionReady", reason, event))
+			continue
+		}
+		refundTransactionReadyEvents = append(refundTransactionReadyEvents, event)
 	}
 
 	for _, e := range events.TFTBridgeModule_RefundTransactionExpired {
 		log.Info().Str("hash", string(e.RefundTransactionHash)).Msgf("found expired refund transaction")
-		refundTransactionExpiredEvents = append(refundTransactionExpiredEvents, RefundTransactionExpiredEvent{
+		event := RefundTransactionExpiredEvent{
 			Hash:   string(e.RefundTransactionHash),
 			Target: string(e.Target),
 			Amount: uint64(e.Amount),
-		})
+		}
+		if reason := validateRefundExpired(event); reason != "" {
+			malformedEvents = append(malformedEvents, malformed("RefundTransactionExpired", reason, event))
+			continue
+		}
+		refundTransactionExpiredEvents = append(refundTransactionExpiredEvents, event)
 	}
 
 	for _, e := range events.TFTBridgeModule_BurnTransactionCreated {
 		log.Info().Uint64("ID", uint64(e.BurnTransactionID)).Msg("found burn transaction created event")
-		withdrawCreatedEvents = append(withdrawCreatedEvents, WithdrawCreatedEvent{
+		event := WithdrawCreatedEvent{
 			ID:     uint64(e.BurnTransactionID),
 			Source: e.Source,
 			Target: string(e.Target),
 			Amount: uint64(e.Amount),
-		})
+		}
+		if reason := validateWithdrawCreated(event); reason != "" {
+			malformedEvents = append(malformedEvents, malformed("BurnTransactionCreated", reason, event))
+			continue
+		}
+		withdrawCreatedEvents = append(withdrawCreatedEvents, event)
 	}
 
 	for _, e := range events.TFTBridgeModule_BurnTransactionReady {
@@ -154,11 +299,16 @@ func (client *SubstrateClient) processEventRecords(events *substrate.EventRecord
 
 	for _, e := range events.TFTBridgeModule_BurnTransactionExpired {
 		log.Info().Uint64("ID", uint64(e.BurnTransactionID)).Msg("found burn transaction expired event")
-		withdrawExpiredEvents = append(withdrawExpiredEvents, WithdrawExpiredEvent{
+		event := WithdrawExpiredEvent{
 			ID:     uint64(e.BurnTransactionID),
 			Target: string(e.Target),
 			Amount: uint64(e.Amount),
-		})
+		}
+		if reason := validateWithdrawExpired(event); reason != "" {
+			malformedEvents = append(malformedEvents, malformed("BurnTransactionExpired", reason, event))
+			continue
+		}
+		withdrawExpiredEvents = append(withdrawExpiredEvents, event)
 	}
 
 	return Events{
@@ -167,5 +317,6 @@ func (client *SubstrateClient) processEventRecords(events *substrate.EventRecord
 		WithdrawExpiredEvents: withdrawExpiredEvents,
 		RefundReadyEvents:     refundTransactionReadyEvents,
 		RefundExpiredEvents:   refundTransactionExpiredEvents,
+		MalformedEvents:       malformedEvents,
 	}
 }
@@ -0,0 +1,298 @@
+package substrate
+
+import (
+	"fmt"
+	"math/bits"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// MaxExtrinsicTip is the highest tip the bridge pays for an extrinsic (1 TFT)
+const MaxExtrinsicTip = 10_000_000
+
+// maximum mortality of an extrinsic in blocks
+const maxMortality = 65536
+
+// extrinsicTimeout is how long the bridge waits for a submitted extrinsic to be included in a block
+const extrinsicTimeout = 30 * time.Second
+
+// ExtrinsicOptions are the options the bridge extrinsics are submitted with
+type ExtrinsicOptions struct {
+	// Tip is paid to the block author on top of the fee to prioritize the extrinsic during congestion
+	Tip uint64
+	// Mortality is the amount of blocks the extrinsic is valid for, 0 submits immortal extrinsics
+	Mortality uint64
+	// LocalNonces tracks the account nonce locally instead of fetching it from chain for every extrinsic
+	LocalNonces bool
+	// DryRun logs extrinsics instead of submitting them, the account does not have to be a validator
+	DryRun bool
+	// Proxy signs the extrinsics and pays their fees, the calls are dispatched as the bridge account through
+	// the proxy pallet. Nil signs the extrinsics with the bridge key.
+	Proxy substrate.Identity
+	// FeeRetryInterval is how long a submission waits before it is retried once the account can not pay
+	// the fees, 0 waits 5 minutes
+	FeeRetryInterval time.Duration
+	// OnInsufficientFunds is called with the account paying the fees once it runs out of funds
+	OnInsufficientFunds func(account string)
+}
+
+// Validate checks the options are within sane bounds
+func (o ExtrinsicOptions) Validate() error {
+	if o.Tip > MaxExtrinsicTip {
+		return fmt.Errorf("extrinsic tip %d is above the maximum of %d", o.Tip, MaxExtrinsicTip)
+	}
+	if o.Mortality != 0 && (o.Mortality < 4 || o.Mortality > maxMortality) {
+		return fmt.Errorf("extrinsic mortality %d must be 0 or between 4 and %d blocks", o.Mortality, maxMortality)
+	}
+	return nil
+}
+
+// callExtrinsic signs call with the bridge key, or the proxy key when set, and the configured extrinsic
+// options, submits it and waits for it to be included in a block. Usurped extrinsics are submitted again,
+// extrinsics the account can not pay the fees of are submitted again once the fee retry interval passed.
+func (s *SubstrateClient) callExtrinsic(call types.Call) error {
+	if s.options.DryRun {
+		log.Info().Uint8("section", call.CallIndex.SectionIndex).Uint8("method", call.CallIndex.MethodIndex).Msg("dry run, not submitting extrinsic")
+		return nil
+	}
+
+	s.gate.wait()
+	return s.resubmit(func() error {
+		return s.callExtrinsicOnce(call)
+	})
+}
+
+// resubmit calls submit until the extrinsic it submits is not usurped and the account can pay its fees
+func (s *SubstrateClient) resubmit(submit func() error) error {
+	for {
+		err := submit()
+		if errors.Is(err, substrate.ErrIsUsurped) {
+			continue
+		}
+		if errors.Is(err, ErrInsufficientFunds) {
+			s.waitForFunds(err)
+			continue
+		}
+		if err == nil {
+			s.funded()
+		}
+		return err
+	}
+}
+
+func (s *SubstrateClient) callExtrinsicOnce(call types.Call) error {
+	cl, meta, rv, err := s.metadata()
+	if err != nil {
+		return err
+	}
+
+	if s.options.Proxy != nil {
+		if call, err = s.proxyCall(meta, call); err != nil {
+			return err
+		}
+	}
+
+	genesisHash, err := cl.RPC.Chain.GetBlockHash(0)
+	if err != nil {
+		return errors.Wrap(err, "failed to get genesis hash")
+	}
+
+	nonce, err := s.nonces.Next()
+	if err != nil {
+		return err
+	}
+
+	err = s.submitExtrinsic(cl, meta, call, genesisHash, rv, nonce)
+	if err != nil && !errors.As(err, new(*extrinsicFailedError)) {
+		// the extrinsic was not included, its nonce may be unused or used by another extrinsic
+		s.nonces.Resync()
+	}
+	return err
+}
+
+// submitExtrinsic signs call with nonce, submits it and waits for it to be included in a block
+func (s *SubstrateClient) submitExtrinsic(cl substrate.Conn, meta substrate.Meta, call types.Call, genesisHash types.Hash, rv *types.RuntimeVersion, nonce uint64) error {
+	o := types.SignatureOptions{
+		BlockHash:          genesisHash,
+		Era:                types.ExtrinsicEra{IsImmortalEra: true},
+		GenesisHash:        genesisHash,
+		Nonce:              types.NewUCompactFromUInt(nonce),
+		SpecVersion:        rv.SpecVersion,
+		TransactionVersion: rv.TransactionVersion,
+	}
+
+	if s.options.Mortality > 0 {
+		header, err := cl.RPC.Chain.GetHeaderLatest()
+		if err != nil {
+			return errors.Wrap(err, "failed to get latest header")
+		}
+		blockHash, err := cl.RPC.Chain.GetBlockHash(uint64(header.Number))
+		if err != nil {
+			return errors.Wrap(err, "failed to get latest block hash")
+		}
+		o.BlockHash = blockHash
+		o.Era = types.ExtrinsicEra{IsMortalEra: true, AsMortalEra: mortalEra(s.options.Mortality, uint64(header.Number))}
+	}
+
+	ext, err := s.signExtrinsic(call, o)
+	if err != nil {
+		return err
+	}
+
+	sub, err := cl.RPC.Author.SubmitAndWatchExtrinsic(ext)
+	if isInsufficientFundsError(err) {
+		return errors.Wrap(ErrInsufficientFunds, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to submit extrinsic")
+	}
+	defer sub.Unsubscribe()
+
+	var blockHash types.Hash
+loop:
+	for {
+		select {
+		case err := <-sub.Err():
+			return errors.Wrap(err, "failed to watch extrinsic")
+		case <-time.After(extrinsicTimeout):
+			return fmt.Errorf("extrinsic timeout waiting for block")
+		case status := <-sub.Chan():
+			switch {
+			case status.IsInBlock:
+				blockHash = status.AsInBlock
+				break loop
+			case status.IsFinalized:
+				blockHash = status.AsFinalized
+				break loop
+			case status.IsDropped, status.IsInvalid:
+				return fmt.Errorf("extrinsic is dropped or invalid")
+			case status.IsUsurped:
+				return substrate.ErrIsUsurped
+			}
+		}
+	}
+
+	return s.checkExtrinsicFailed(cl, meta, blockHash)
+}
+
+// signExtrinsic signs call with the bridge key, paying the configured tip
+func (s *SubstrateClient) signExtrinsic(call types.Call, o types.SignatureOptions) (types.Extrinsic, error) {
+	o.Tip = types.NewUCompactFromUInt(s.options.Tip)
+
+	ext := types.NewExtrinsic(call)
+	if err := signWithIdentity(&ext, s.submitter(), o); err != nil {
+		return types.Extrinsic{}, errors.Wrap(err, "failed to sign extrinsic")
+	}
+	return ext, nil
+}
+
+// extrinsicFailedError is returned for an extrinsic that was included in a block but failed, its nonce is used
+type extrinsicFailedError struct {
+	msg string
+	// name is the name of the module error the extrinsic failed with, if it could be resolved
+	name string
+}
+
+func (e *extrinsicFailedError) Error() string {
+	return e.msg
+}
+
+// Is matches the module errors the bridge handles to their sentinel error
+func (e *extrinsicFailedError) Is(target error) bool {
+	err, ok := moduleErrors[e.name]
+	return ok && err == target
+}
+
+// checkExtrinsicFailed returns an error if an extrinsic of the bridge key, or its call through the proxy, failed in the block
+func (s *SubstrateClient) checkExtrinsicFailed(cl substrate.Conn, meta substrate.Meta, blockHash types.Hash) error {
+	key, err := types.CreateStorageKey(meta, "System", "Events", nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create storage key")
+	}
+
+	raw, err := cl.RPC.State.GetStorageRaw(key, blockHash)
+	if err != nil {
+		return errors.Wrap(err, "failed to get block events")
+	}
+
+	block, err := cl.RPC.Chain.GetBlock(blockHash)
+	if err != nil {
+		return errors.Wrap(err, "failed to get block")
+	}
+
+	var events substrate.EventRecords
+	if err := types.EventRecordsRaw(*raw).DecodeEventRecords(meta, &events); err != nil {
+		return errors.Wrap(err, "failed to decode block events")
+	}
+
+	signer := types.NewAccountID(s.submitter().PublicKey())
+	for _, e := range events.System_ExtrinsicFailed {
+		index := int(e.Phase.AsApplyExtrinsic)
+		if index >= len(block.Block.Extrinsics) || block.Block.Extrinsics[index].Signature.Signer.AsID != signer {
+			continue
+		}
+		if e.DispatchError.IsModule {
+			return moduleError(meta, e.DispatchError.ModuleError)
+		}
+		return &extrinsicFailedError{msg: "extrinsic failed"}
+	}
+
+	if s.options.Proxy == nil {
+		return nil
+	}
+	for index, ext := range block.Block.Extrinsics {
+		if ext.IsSigned() && ext.Signature.Signer.AsID == signer {
+			if err := checkProxyExecuted(meta, &events, index); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// moduleError resolves the name of the module error from the metadata, the indexes are kept in the
+// message so the error can still be looked up if the name is not found
+func moduleError(meta substrate.Meta, moduleErr types.ModuleError) error {
+	msg := fmt.Sprintf("extrinsic failed with module %d error %d", moduleErr.Index, moduleErr.Error)
+	metaErr, err := meta.FindError(moduleErr.Index, types.U8(moduleErr.Error))
+	if err != nil {
+		return &extrinsicFailedError{msg: msg}
+	}
+	return &extrinsicFailedError{msg: fmt.Sprintf("%s: %s", msg, metaErr.Name), name: metaErr.Name}
+}
+
+// mortalEra encodes the era of an extrinsic valid for period blocks starting at current
+func mortalEra(period uint64, current uint64) types.MortalEra {
+	// the period is rounded to a power of two between 4 and 65536
+	if period&(period-1) != 0 {
+		period = 1 << bits.Len64(period)
+	}
+	if period < 4 {
+		period = 4
+	}
+	if period > maxMortality {
+		period = maxMortality
+	}
+
+	phase := current % period
+	quantizeFactor := period >> 12
+	if quantizeFactor < 1 {
+		quantizeFactor = 1
+	}
+	quantizedPhase := phase / quantizeFactor * quantizeFactor
+
+	low := uint64(bits.TrailingZeros64(period)) - 1
+	if low < 1 {
+		low = 1
+	}
+	if low > 15 {
+		low = 15
+	}
+	encoded := low | (quantizedPhase/quantizeFactor)<<4
+	return types.MortalEra{First: byte(encoded), Second: byte(encoded >> 8)}
+}
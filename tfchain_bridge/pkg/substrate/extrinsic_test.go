@@ -0,0 +1,109 @@
+package substrate
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+func TestExtrinsicOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options ExtrinsicOptions
+		valid   bool
+	}{
+		{name: "defaults", valid: true},
+		{name: "maximum tip", options: ExtrinsicOptions{Tip: MaxExtrinsicTip}, valid: true},
+		{name: "tip too high", options: ExtrinsicOptions{Tip: MaxExtrinsicTip + 1}},
+		{name: "mortal", options: ExtrinsicOptions{Mortality: 64}, valid: true},
+		{name: "mortality too short", options: ExtrinsicOptions{Mortality: 3}},
+		{name: "mortality too long", options: ExtrinsicOptions{Mortality: maxMortality + 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.options.Validate()
+			if test.valid && err != nil {
+				t.Errorf("expected the options to be valid, got %s", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected the options to be rejected")
+			}
+		})
+	}
+}
+
+func TestSignExtrinsicEncodesTip(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase(signature.TestKeyringPairAlice.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SubstrateClient{
+		identity: identity,
+		options:  ExtrinsicOptions{Tip: 5000},
+	}
+	call := types.Call{CallIndex: types.CallIndex{SectionIndex: 35, MethodIndex: 1}, Args: types.Args{0x01}}
+
+	ext, err := s.signExtrinsic(call, types.SignatureOptions{
+		Era:         types.ExtrinsicEra{IsImmortalEra: true},
+		Nonce:       types.NewUCompactFromUInt(7),
+		SpecVersion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decode the submitted bytes to check the tip is part of the signed payload
+	encoded, err := types.EncodeToHex(ext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var submitted types.Extrinsic
+	if err := types.DecodeFromHex(encoded, &submitted); err != nil {
+		t.Fatal(err)
+	}
+
+	if !submitted.IsSigned() {
+		t.Fatal("expected the extrinsic to be signed")
+	}
+	if tip := big.Int(submitted.Signature.Tip); tip.Uint64() != 5000 {
+		t.Errorf("expected a tip of 5000, got %s", tip.String())
+	}
+	if nonce := big.Int(submitted.Signature.Nonce); nonce.Uint64() != 7 {
+		t.Errorf("expected nonce 7, got %s", nonce.String())
+	}
+}
+
+func TestDryRunSubmitsNothing(t *testing.T) {
+	// the client has no connection, submitting anything would panic
+	s := &SubstrateClient{options: ExtrinsicOptions{DryRun: true}}
+
+	call := types.Call{CallIndex: types.CallIndex{SectionIndex: 35, MethodIndex: 1}, Args: types.Args{0x01}}
+	if err := s.callExtrinsic(call); err != nil {
+		t.Errorf("expected the dry run to succeed, got %s", err)
+	}
+}
+
+func TestExtrinsicFailedAlreadyExecuted(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		executed bool
+	}{
+		{name: "already executed", err: &extrinsicFailedError{msg: "extrinsic failed with module 35 error 4: MintTransactionAlreadyExecuted", name: "MintTransactionAlreadyExecuted"}, executed: true},
+		{name: "wrapped", err: errors.Wrap(&extrinsicFailedError{msg: "failed", name: "MintTransactionAlreadyExecuted"}, "failed to propose mint"), executed: true},
+		{name: "other module error", err: &extrinsicFailedError{msg: "extrinsic failed with module 35 error 1: ValidatorExists", name: "ValidatorExists"}},
+		{name: "unresolved module error", err: &extrinsicFailedError{msg: "extrinsic failed with module 35 error 4"}},
+		{name: "not an extrinsic failure", err: errors.New("connection lost")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if executed := errors.Is(test.err, ErrMintAlreadyExecuted); executed != test.executed {
+				t.Errorf("expected already executed to be %t, got %t", test.executed, executed)
+			}
+		})
+	}
+}
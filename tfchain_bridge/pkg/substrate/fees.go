@@ -0,0 +1,60 @@
+package substrate
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultFeeRetryInterval is how long submissions wait for the account to be funded when no interval is configured
+const defaultFeeRetryInterval = 5 * time.Minute
+
+// isInsufficientFundsError checks if the node rejected an extrinsic because its signer can not pay the fees
+func isInsufficientFundsError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Inability to pay some fees")
+}
+
+// fundsState tracks whether the account submitting the extrinsics ran out of funds, so operators are
+// alerted once when it happens and not on every retried submission
+type fundsState struct {
+	mu       sync.Mutex
+	unfunded bool
+}
+
+// waitForFunds reports the account is out of funds and blocks for the fee retry interval, submissions are
+// retried after it so they resume by themselves once the account is funded
+func (s *SubstrateClient) waitForFunds(err error) {
+	account := s.submitter().Address()
+
+	s.funds.mu.Lock()
+	first := !s.funds.unfunded
+	s.funds.unfunded = true
+	s.funds.mu.Unlock()
+
+	interval := s.options.FeeRetryInterval
+	if interval <= 0 {
+		interval = defaultFeeRetryInterval
+	}
+
+	tfchainUnfunded.Set(1)
+	log.Error().Err(err).Str("account", account).Dur("retry_in", interval).Msg("account can not pay the extrinsic fees, waiting for it to be funded")
+	if first && s.options.OnInsufficientFunds != nil {
+		s.options.OnInsufficientFunds(account)
+	}
+
+	time.Sleep(interval)
+}
+
+// funded marks the account as able to pay the fees again after a successful submission
+func (s *SubstrateClient) funded() {
+	s.funds.mu.Lock()
+	defer s.funds.mu.Unlock()
+
+	if s.funds.unfunded {
+		s.funds.unfunded = false
+		tfchainUnfunded.Set(0)
+		log.Info().Str("account", s.submitter().Address()).Msg("account is funded again, extrinsic submissions resumed")
+	}
+}
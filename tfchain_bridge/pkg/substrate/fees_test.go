@@ -0,0 +1,98 @@
+package substrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+func TestIsInsufficientFundsError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		is   bool
+	}{
+		{name: "no error"},
+		{name: "fee error", err: errors.New("1010: Invalid Transaction: Inability to pay some fees , e.g. account balance too low"), is: true},
+		{name: "other error", err: errors.New("1014: Priority is too low")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if is := isInsufficientFundsError(test.err); is != test.is {
+				t.Errorf("expected %v, got %v", test.is, is)
+			}
+		})
+	}
+}
+
+func TestResubmitWaitsForFunds(t *testing.T) {
+	identity, err := substrate.NewIdentityFromSr25519Phrase(signature.TestKeyringPairAlice.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alerted []string
+	s := &SubstrateClient{
+		identity: identity,
+		options: ExtrinsicOptions{
+			FeeRetryInterval:    time.Millisecond,
+			OnInsufficientFunds: func(account string) { alerted = append(alerted, account) },
+		},
+	}
+
+	// the account is funded after the third submission
+	submissions := 0
+	err = s.resubmit(func() error {
+		submissions++
+		if submissions <= 3 {
+			return errors.Wrap(ErrInsufficientFunds, "Inability to pay some fees")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submissions != 4 {
+		t.Errorf("expected the extrinsic to be submitted 4 times, got %d", submissions)
+	}
+	if len(alerted) != 1 || alerted[0] != identity.Address() {
+		t.Errorf("expected one alert for %s, got %v", identity.Address(), alerted)
+	}
+	if s.funds.unfunded {
+		t.Error("expected the account to be funded again")
+	}
+
+	// running out of funds again alerts again
+	submissions = 0
+	if err := s.resubmit(func() error {
+		submissions++
+		if submissions == 1 {
+			return ErrInsufficientFunds
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerted) != 2 {
+		t.Errorf("expected a second alert, got %v", alerted)
+	}
+}
+
+func TestResubmitReturnsOtherErrors(t *testing.T) {
+	s := &SubstrateClient{options: ExtrinsicOptions{FeeRetryInterval: time.Millisecond}}
+	failure := errors.New("extrinsic failed")
+
+	submissions := 0
+	err := s.resubmit(func() error {
+		submissions++
+		return failure
+	})
+	if err != failure {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+	if submissions != 1 {
+		t.Errorf("expected the extrinsic to be submitted once, got %d", submissions)
+	}
+}
@@ -0,0 +1,60 @@
+package substrate
+
+import (
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// runtimeMetadata caches the metadata of the runtime for its spec version, a runtime upgrade
+// can move the pallets and calls so the metadata is fetched again once the spec version changes
+type runtimeMetadata struct {
+	mu          sync.Mutex
+	specVersion types.U32
+	meta        *types.Metadata
+}
+
+// get returns the metadata of the runtime with specVersion, fetch is only called when the spec version changed
+func (r *runtimeMetadata) get(specVersion types.U32, fetch func() (*types.Metadata, error)) (*types.Metadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.meta != nil && r.specVersion == specVersion {
+		return r.meta, nil
+	}
+
+	meta, err := fetch()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get runtime metadata")
+	}
+
+	if r.meta != nil {
+		log.Info().Uint32("from", uint32(r.specVersion)).Uint32("to", uint32(specVersion)).Msg("runtime upgraded, metadata refreshed")
+	}
+	r.specVersion, r.meta = specVersion, meta
+	return meta, nil
+}
+
+// metadata returns the connection and the metadata of the latest runtime with its version, the call indices
+// of the bridge calls must be resolved against it as the metadata of the connection predates runtime upgrades
+func (s *SubstrateClient) metadata() (substrate.Conn, *types.Metadata, *types.RuntimeVersion, error) {
+	cl, _, err := s.GetClient()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rv, err := cl.RPC.State.GetRuntimeVersionLatest()
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to get runtime version")
+	}
+
+	meta, err := s.runtime.get(rv.SpecVersion, cl.RPC.State.GetMetadataLatest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return cl, meta, rv, nil
+}
@@ -0,0 +1,45 @@
+package substrate
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+)
+
+func TestRuntimeMetadataRefresh(t *testing.T) {
+	var runtime runtimeMetadata
+	fetches := 0
+	fetch := func(version uint8) func() (*types.Metadata, error) {
+		return func() (*types.Metadata, error) {
+			fetches++
+			return &types.Metadata{Version: version}, nil
+		}
+	}
+
+	meta, err := runtime.get(100, fetch(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Version != 1 || fetches != 1 {
+		t.Fatalf("expected the metadata to be fetched, got version %d after %d fetches", meta.Version, fetches)
+	}
+
+	// the same runtime keeps its metadata
+	if meta, _ = runtime.get(100, fetch(2)); meta.Version != 1 || fetches != 1 {
+		t.Errorf("expected the cached metadata, got version %d after %d fetches", meta.Version, fetches)
+	}
+
+	// a failed fetch after a runtime upgrade is not cached
+	if _, err := runtime.get(101, func() (*types.Metadata, error) { return nil, errors.New("connection lost") }); err == nil {
+		t.Error("expected the failed fetch to be returned")
+	}
+
+	// the upgraded runtime gets its own metadata
+	if meta, _ = runtime.get(101, fetch(2)); meta.Version != 2 || fetches != 2 {
+		t.Errorf("expected the metadata to be fetched again after the upgrade, got version %d after %d fetches", meta.Version, fetches)
+	}
+	if meta, _ = runtime.get(101, fetch(3)); meta.Version != 2 || fetches != 2 {
+		t.Errorf("expected the refreshed metadata to be cached, got version %d after %d fetches", meta.Version, fetches)
+	}
+}
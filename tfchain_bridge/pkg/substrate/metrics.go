@@ -0,0 +1,11 @@
+package substrate
+
+import (
+	"github.com/threefoldtech/tfchain_bridge/pkg/metrics"
+)
+
+var (
+	tfchainActive    = metrics.NewGauge("bridge_tfchain_active", "1 for the tfchain endpoint the bridge uses, 0 for the standby endpoints", "url")
+	tfchainFailovers = metrics.NewCounter("bridge_tfchain_failovers_total", "Switches to another tfchain endpoint after the active one failed")
+	tfchainUnfunded  = metrics.NewGauge("bridge_tfchain_insufficient_funds", "1 while the account submitting the bridge extrinsics can not pay their fees")
+)
@@ -0,0 +1,55 @@
+package substrate
+
+import (
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// MintTransaction is a stellar to tfchain mint transaction as stored by the bridge pallet
+type MintTransaction struct {
+	Amount types.U64
+	Target substrate.AccountID
+	// Block is the block the mint was proposed in
+	Block types.U32
+	Votes types.U32
+}
+
+// GetProposedMintTransaction gets a mint transaction that is proposed but does not have enough votes yet
+func (s *SubstrateClient) GetProposedMintTransaction(txHash string) (*MintTransaction, error) {
+	return s.getMintTransaction("MintTransactions", txHash)
+}
+
+// GetExecutedMintTransaction gets a mint transaction that was already executed
+func (s *SubstrateClient) GetExecutedMintTransaction(txHash string) (*MintTransaction, error) {
+	return s.getMintTransaction("ExecutedMintTransactions", txHash)
+}
+
+func (s *SubstrateClient) getMintTransaction(storage string, txHash string) (*MintTransaction, error) {
+	cl, meta, err := s.GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := types.Encode(txHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode mint transaction hash")
+	}
+
+	key, err := types.CreateStorageKey(meta, "TFTBridgeModule", storage, bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create storage key")
+	}
+
+	var mintTx MintTransaction
+	ok, err := cl.RPC.State.GetStorageLatest(key, &mintTx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lookup mint transaction")
+	}
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &mintTx, nil
+}
@@ -0,0 +1,59 @@
+package substrate
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// NonceManager hands out the nonces of the bridge extrinsics. With local tracking enabled the next nonce is
+// kept in memory so extrinsics submitted back to back or concurrently get sequential nonces, without it every
+// extrinsic uses the nonce of the account on chain. The nonce is fetched from chain again after a resync.
+type NonceManager struct {
+	local bool
+	fetch func() (uint64, error)
+
+	mu     sync.Mutex
+	synced bool
+	next   uint64
+}
+
+func newNonceManager(local bool, fetch func() (uint64, error)) *NonceManager {
+	return &NonceManager{
+		local: local,
+		fetch: fetch,
+	}
+}
+
+// Next returns the nonce of the next extrinsic
+func (n *NonceManager) Next() (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.local || !n.synced {
+		nonce, err := n.fetch()
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get account nonce")
+		}
+		n.next = nonce
+		n.synced = true
+	}
+
+	nonce := n.next
+	n.next++
+	return nonce, nil
+}
+
+// Resync makes the next nonce be fetched from chain, it is called when an extrinsic
+// was not included so the nonce it was given may be unused
+func (n *NonceManager) Resync() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.synced = false
+}
+
+// Nonces returns the nonce manager of the client
+func (s *SubstrateClient) Nonces() *NonceManager {
+	return s.nonces
+}
@@ -0,0 +1,114 @@
+package substrate
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// chainNonce is the nonce of the bridge account on chain, it counts how often it is fetched
+type chainNonce struct {
+	nonce   uint64
+	fetches int
+	err     error
+}
+
+func (c *chainNonce) fetch() (uint64, error) {
+	c.fetches++
+	return c.nonce, c.err
+}
+
+func TestNonceManagerSequential(t *testing.T) {
+	chain := &chainNonce{nonce: 40}
+	nonces := newNonceManager(true, chain.fetch)
+
+	for want := uint64(40); want < 45; want++ {
+		got, err := nonces.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("expected nonce %d, got %d", want, got)
+		}
+	}
+	if chain.fetches != 1 {
+		t.Errorf("expected the nonce to be fetched once, got %d", chain.fetches)
+	}
+}
+
+func TestNonceManagerConcurrent(t *testing.T) {
+	chain := &chainNonce{nonce: 7}
+	nonces := newNonceManager(true, chain.fetch)
+
+	var (
+		mu  sync.Mutex
+		got []uint64
+		wg  sync.WaitGroup
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := nonces.Next()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			got = append(got, nonce)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	for i, nonce := range got {
+		if nonce != uint64(7+i) {
+			t.Fatalf("expected sequential nonces from 7, got %v", got)
+		}
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	chain := &chainNonce{nonce: 3}
+	nonces := newNonceManager(true, chain.fetch)
+
+	if _, err := nonces.Next(); err != nil {
+		t.Fatal(err)
+	}
+	// the extrinsic with nonce 3 was dropped, the chain is still at 3
+	nonces.Resync()
+	got, err := nonces.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Errorf("expected nonce 3 after resync, got %d", got)
+	}
+
+	chain.err = errors.New("node unavailable")
+	nonces.Resync()
+	if _, err := nonces.Next(); err == nil {
+		t.Error("expected the fetch error")
+	}
+}
+
+func TestNonceManagerChainNonces(t *testing.T) {
+	chain := &chainNonce{nonce: 12}
+	nonces := newNonceManager(false, chain.fetch)
+
+	for i := 0; i < 3; i++ {
+		got, err := nonces.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 12 {
+			t.Errorf("expected the chain nonce 12 without local tracking, got %d", got)
+		}
+	}
+	if chain.fetches != 3 {
+		t.Errorf("expected the nonce to be fetched for every extrinsic, got %d", chain.fetches)
+	}
+}
@@ -0,0 +1,27 @@
+package substrate
+
+import "sort"
+
+// Sort puts the events of every type in a stable order, withdraws by id and refunds by hash. The order of the
+// event types is fixed by the bridge handling them one type after the other, together this makes a block processed
+// the same way whatever order its events were delivered in, also when the block is delivered again after a restart.
+func (e *Events) Sort() {
+	sort.SliceStable(e.WithdrawCreatedEvents, func(i, j int) bool {
+		return e.WithdrawCreatedEvents[i].ID < e.WithdrawCreatedEvents[j].ID
+	})
+	sort.SliceStable(e.WithdrawExpiredEvents, func(i, j int) bool {
+		return e.WithdrawExpiredEvents[i].ID < e.WithdrawExpiredEvents[j].ID
+	})
+	sort.SliceStable(e.WithdrawReadyEvents, func(i, j int) bool {
+		return e.WithdrawReadyEvents[i].ID < e.WithdrawReadyEvents[j].ID
+	})
+	sort.SliceStable(e.RefundCreatedEvents, func(i, j int) bool {
+		return e.RefundCreatedEvents[i].Hash < e.RefundCreatedEvents[j].Hash
+	})
+	sort.SliceStable(e.RefundExpiredEvents, func(i, j int) bool {
+		return e.RefundExpiredEvents[i].Hash < e.RefundExpiredEvents[j].Hash
+	})
+	sort.SliceStable(e.RefundReadyEvents, func(i, j int) bool {
+		return e.RefundReadyEvents[i].Hash < e.RefundReadyEvents[j].Hash
+	})
+}
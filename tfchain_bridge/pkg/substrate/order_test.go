@@ -0,0 +1,59 @@
+package substrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventsSort(t *testing.T) {
+	// every delivery order of the events of a block is processed in the same order
+	orders := [][]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	ids := []uint64{3, 7, 12}
+	hashes := []string{"0a", "5f", "c3"}
+
+	for _, order := range orders {
+		var events Events
+		for _, i := range order {
+			events.WithdrawCreatedEvents = append(events.WithdrawCreatedEvents, WithdrawCreatedEvent{ID: ids[i]})
+			events.WithdrawExpiredEvents = append(events.WithdrawExpiredEvents, WithdrawExpiredEvent{ID: ids[i]})
+			events.WithdrawReadyEvents = append(events.WithdrawReadyEvents, WithdrawReadyEvent{ID: ids[i]})
+			events.RefundCreatedEvents = append(events.RefundCreatedEvents, RefundTransactionCreatedEvent{Hash: hashes[i]})
+			events.RefundExpiredEvents = append(events.RefundExpiredEvents, RefundTransactionExpiredEvent{Hash: hashes[i]})
+			events.RefundReadyEvents = append(events.RefundReadyEvents, RefundTransactionReadyEvent{Hash: hashes[i]})
+		}
+
+		events.Sort()
+
+		var withdraws [3][]uint64
+		for _, e := range events.WithdrawCreatedEvents {
+			withdraws[0] = append(withdraws[0], e.ID)
+		}
+		for _, e := range events.WithdrawExpiredEvents {
+			withdraws[1] = append(withdraws[1], e.ID)
+		}
+		for _, e := range events.WithdrawReadyEvents {
+			withdraws[2] = append(withdraws[2], e.ID)
+		}
+		for _, got := range withdraws {
+			if !reflect.DeepEqual(got, ids) {
+				t.Errorf("delivered in order %v: expected withdraws %v, got %v", order, ids, got)
+			}
+		}
+
+		var refunds [3][]string
+		for _, e := range events.RefundCreatedEvents {
+			refunds[0] = append(refunds[0], e.Hash)
+		}
+		for _, e := range events.RefundExpiredEvents {
+			refunds[1] = append(refunds[1], e.Hash)
+		}
+		for _, e := range events.RefundReadyEvents {
+			refunds[2] = append(refunds[2], e.Hash)
+		}
+		for _, got := range refunds {
+			if !reflect.DeepEqual(got, hashes) {
+				t.Errorf("delivered in order %v: expected refunds %v, got %v", order, hashes, got)
+			}
+		}
+	}
+}
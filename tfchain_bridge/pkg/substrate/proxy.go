@@ -0,0 +1,57 @@
+package substrate
+
+import (
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// submitter returns the identity the bridge extrinsics are signed with, it pays their fees
+func (s *SubstrateClient) submitter() substrate.Identity {
+	if s.options.Proxy != nil {
+		return s.options.Proxy
+	}
+	return s.identity
+}
+
+// proxyCall wraps call in a call of the proxy pallet dispatching it as the bridge account, the extrinsic is
+// signed by the proxy account so it pays the fees. The bridge account must have added the proxy account as
+// its proxy. ErrCallNotSupported is returned if the runtime has no proxy pallet.
+func (s *SubstrateClient) proxyCall(meta *types.Metadata, call types.Call) (types.Call, error) {
+	if _, err := meta.FindCallIndex("Proxy.proxy"); err != nil {
+		return types.Call{}, ErrCallNotSupported
+	}
+
+	c, err := types.NewCall(meta, "Proxy.proxy", types.NewMultiAddressFromAccountID(s.identity.PublicKey()), types.NewOptionU8Empty(), call)
+	if err != nil {
+		return types.Call{}, errors.Wrap(err, "failed to create proxy call")
+	}
+	return c, nil
+}
+
+// checkProxyExecuted returns an error if the call of a proxied extrinsic failed, the extrinsic itself
+// succeeds and the result of the call is only reported in the event of the proxy pallet
+func checkProxyExecuted(meta substrate.Meta, events *substrate.EventRecords, index int) error {
+	for _, e := range events.Proxy_ProxyExecuted {
+		if !e.Phase.IsApplyExtrinsic || int(e.Phase.AsApplyExtrinsic) != index || e.Result.Ok {
+			continue
+		}
+		if e.Result.Error.IsModule {
+			return moduleError(meta, e.Result.Error.ModuleError)
+		}
+		return &extrinsicFailedError{msg: "proxied call failed"}
+	}
+	return nil
+}
+
+// checkProxySupported fails if the runtime of cl has no proxy pallet to submit the bridge extrinsics through
+func checkProxySupported(cl *substrate.Substrate) error {
+	_, meta, err := cl.GetClient()
+	if err != nil {
+		return err
+	}
+	if _, err := meta.FindCallIndex("Proxy.proxy"); err != nil {
+		return errors.Wrap(ErrCallNotSupported, "tfchain runtime has no proxy pallet, extrinsics can not be submitted through a proxy account")
+	}
+	return nil
+}
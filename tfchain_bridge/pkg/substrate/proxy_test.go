@@ -0,0 +1,82 @@
+package substrate
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+func TestProxyCall(t *testing.T) {
+	bridge, err := substrate.NewIdentityFromSr25519Phrase(signature.TestKeyringPairAlice.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy, err := substrate.NewIdentityFromSr25519Phrase("//Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta types.Metadata
+	if err := types.DecodeFromHex(types.MetadataV14Data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	call := types.Call{CallIndex: types.CallIndex{SectionIndex: 35, MethodIndex: 1}, Args: types.Args{0x01}}
+
+	t.Run("wraps the call", func(t *testing.T) {
+		s := &SubstrateClient{identity: bridge, options: ExtrinsicOptions{Proxy: proxy}}
+		wrapped, err := s.proxyCall(&meta, call)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := meta.FindCallIndex("Proxy.proxy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wrapped.CallIndex != index {
+			t.Errorf("expected the call index of Proxy.proxy %v, got %v", index, wrapped.CallIndex)
+		}
+
+		// the bridge account is the real account the call is dispatched as
+		var real types.MultiAddress
+		if err := types.Decode(wrapped.Args, &real); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(real.AsID[:], bridge.PublicKey()) {
+			t.Errorf("expected the call to be dispatched as the bridge account, got %x", real.AsID)
+		}
+	})
+
+	t.Run("signed by the proxy", func(t *testing.T) {
+		for _, test := range []struct {
+			name   string
+			proxy  substrate.Identity
+			signer substrate.Identity
+		}{
+			{name: "proxy", proxy: proxy, signer: proxy},
+			{name: "no proxy", signer: bridge},
+		} {
+			s := &SubstrateClient{identity: bridge, options: ExtrinsicOptions{Proxy: test.proxy}}
+			ext, err := s.signExtrinsic(call, types.SignatureOptions{
+				Era:         types.ExtrinsicEra{IsImmortalEra: true},
+				Nonce:       types.NewUCompactFromUInt(1),
+				SpecVersion: 1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ext.Signature.Signer.AsID[:], test.signer.PublicKey()) {
+				t.Errorf("%s: expected the extrinsic to be signed by %x, got %x", test.name, test.signer.PublicKey(), ext.Signature.Signer.AsID)
+			}
+		}
+	})
+
+	t.Run("no proxy pallet", func(t *testing.T) {
+		s := &SubstrateClient{identity: bridge, options: ExtrinsicOptions{Proxy: proxy}}
+		if _, err := s.proxyCall(types.NewMetadataV14(), call); !errors.Is(err, ErrCallNotSupported) {
+			t.Errorf("expected ErrCallNotSupported, got %v", err)
+		}
+	})
+}
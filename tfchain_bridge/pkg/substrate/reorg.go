@@ -0,0 +1,79 @@
+package substrate
+
+import (
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// maxTrackedBlocks is the amount of recent block hashes kept to detect reorgs
+const maxTrackedBlocks = 64
+
+// blockTracker keeps the hashes of the most recently processed blocks so
+// a head whose parent does not match the stored hash can be detected as a reorg
+type blockTracker struct {
+	hashes map[uint32]types.Hash
+	lowest uint32
+}
+
+func newBlockTracker() *blockTracker {
+	return &blockTracker{
+		hashes: make(map[uint32]types.Hash),
+	}
+}
+
+// track records the hash of a processed block
+func (t *blockTracker) track(height uint32, hash types.Hash) {
+	t.hashes[height] = hash
+	if t.lowest == 0 || height < t.lowest {
+		t.lowest = height
+	}
+
+	for len(t.hashes) > maxTrackedBlocks {
+		delete(t.hashes, t.lowest)
+		t.lowest++
+	}
+}
+
+// isReorg returns true if the parent hash of the block at height does not
+// match the hash we processed for its parent
+func (t *blockTracker) isReorg(height uint32, parentHash types.Hash) bool {
+	if height == 0 {
+		return false
+	}
+
+	known, ok := t.hashes[height-1]
+	if !ok {
+		return false
+	}
+
+	return known != parentHash
+}
+
+// forkPoint walks back from height until the stored hash matches the canonical hash
+// returned by canonical, it returns the last common height and drops every tracked block after it
+func (t *blockTracker) forkPoint(height uint32, canonical func(height uint32) (types.Hash, error)) (uint32, error) {
+	fork := height
+	for fork > 0 {
+		known, ok := t.hashes[fork]
+		if !ok {
+			break
+		}
+
+		hash, err := canonical(fork)
+		if err != nil {
+			return 0, err
+		}
+
+		if hash == known {
+			break
+		}
+		fork--
+	}
+
+	for h := range t.hashes {
+		if h > fork {
+			delete(t.hashes, h)
+		}
+	}
+
+	return fork, nil
+}
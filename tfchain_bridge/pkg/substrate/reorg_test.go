@@ -0,0 +1,173 @@
+package substrate
+
+import (
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+)
+
+// testHash returns a block hash that differs per height and fork
+func testHash(height uint32, fork byte) types.Hash {
+	var hash types.Hash
+	hash[0] = byte(height)
+	hash[1] = byte(height >> 8)
+	hash[31] = fork
+	return hash
+}
+
+func TestBlockTrackerDetectsReorgs(t *testing.T) {
+	tracker := newBlockTracker()
+	for h := uint32(1); h <= 5; h++ {
+		tracker.track(h, testHash(h, 0))
+	}
+
+	tests := []struct {
+		name   string
+		height uint32
+		parent types.Hash
+		reorg  bool
+	}{
+		{name: "head on the tracked chain", height: 6, parent: testHash(5, 0)},
+		{name: "head on another fork", height: 6, parent: testHash(5, 1), reorg: true},
+		{name: "head replacing a tracked block", height: 4, parent: testHash(3, 1), reorg: true},
+		{name: "parent that is not tracked", height: 8, parent: testHash(7, 1)},
+		{name: "genesis", height: 0, parent: testHash(0, 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if reorg := tracker.isReorg(test.height, test.parent); reorg != test.reorg {
+				t.Errorf("expected reorg %t, got %t", test.reorg, reorg)
+			}
+		})
+	}
+}
+
+func TestBlockTrackerForkPoint(t *testing.T) {
+	tracker := newBlockTracker()
+	for h := uint32(1); h <= 5; h++ {
+		tracker.track(h, testHash(h, 0))
+	}
+
+	// the canonical chain forked off after block 3
+	canonical := func(height uint32) (types.Hash, error) {
+		if height > 3 {
+			return testHash(height, 1), nil
+		}
+		return testHash(height, 0), nil
+	}
+
+	fork, err := tracker.forkPoint(5, canonical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != 3 {
+		t.Errorf("expected fork point 3, got %d", fork)
+	}
+	for h := uint32(4); h <= 5; h++ {
+		if _, ok := tracker.hashes[h]; ok {
+			t.Errorf("block %d of the abandoned fork is still tracked", h)
+		}
+	}
+	if tracker.isReorg(4, testHash(3, 0)) {
+		t.Error("the canonical block after the fork point is detected as a reorg")
+	}
+}
+
+func TestBlockTrackerKeepsRecentBlocks(t *testing.T) {
+	tracker := newBlockTracker()
+	for h := uint32(1); h <= maxTrackedBlocks+10; h++ {
+		tracker.track(h, testHash(h, 0))
+	}
+
+	if len(tracker.hashes) != maxTrackedBlocks {
+		t.Errorf("expected %d tracked blocks, got %d", maxTrackedBlocks, len(tracker.hashes))
+	}
+	if _, ok := tracker.hashes[10]; ok {
+		t.Error("the oldest blocks are still tracked")
+	}
+}
+
+// testHeads creates a head processor on a chain whose canonical blocks are on fork, the heights of the blocks it
+// sends are returned by sent
+func testHeads(last uint32, fork func(height uint32) byte) (heads *headProcessor, sent func() []uint32) {
+	channel := make(chan EventSubscription, 100)
+	heads = &headProcessor{
+		tracker: newBlockTracker(),
+		canonicalHash: func(height uint32) (types.Hash, error) {
+			return testHash(height, fork(height)), nil
+		},
+		fetch:   func(height uint32) (Events, error) { return Events{}, nil },
+		channel: channel,
+		last:    last,
+	}
+	return heads, func() []uint32 {
+		var heights []uint32
+		for len(channel) > 0 {
+			heights = append(heights, (<-channel).Height)
+		}
+		return heights
+	}
+}
+
+func assertHeights(t *testing.T, expected, heights []uint32) {
+	t.Helper()
+
+	if len(heights) != len(expected) {
+		t.Fatalf("expected blocks %v, got %v", expected, heights)
+	}
+	for i := range expected {
+		if heights[i] != expected[i] {
+			t.Fatalf("expected blocks %v, got %v", expected, heights)
+		}
+	}
+}
+
+func TestHeadProcessorReplaysBlocksAfterLastProcessed(t *testing.T) {
+	heads, sent := testHeads(10, func(uint32) byte { return 0 })
+
+	heads.process(14, testHash(13, 0))
+	assertHeights(t, []uint32{11, 12, 13, 14}, sent())
+
+	heads.process(15, testHash(14, 0))
+	assertHeights(t, []uint32{15}, sent())
+}
+
+func TestHeadProcessorStartsAtHeadWithoutProcessedBlocks(t *testing.T) {
+	heads, sent := testHeads(0, func(uint32) byte { return 0 })
+
+	heads.process(14, testHash(13, 0))
+	assertHeights(t, []uint32{14}, sent())
+}
+
+func TestHeadProcessorReplaysReorgFromForkPoint(t *testing.T) {
+	forked := false
+	heads, sent := testHeads(0, func(height uint32) byte {
+		if forked && height > 3 {
+			return 1
+		}
+		return 0
+	})
+
+	for h := uint32(1); h <= 5; h++ {
+		heads.process(h, testHash(h-1, 0))
+	}
+	sent()
+
+	forked = true
+	heads.process(6, testHash(5, 1))
+	assertHeights(t, []uint32{4, 5, 6}, sent())
+}
+
+func TestHeadProcessorResumesFromPersistedHeight(t *testing.T) {
+	heads, sent := testHeads(0, func(uint32) byte { return 0 })
+
+	heads.process(15, testHash(14, 0))
+	sent()
+
+	// the subscription failed after block 15 was sent while the bridge only persisted block 12
+	if err := heads.resume(func() (uint32, error) { return 12, nil }); err != nil {
+		t.Fatal(err)
+	}
+	heads.process(16, testHash(15, 0))
+	assertHeights(t, []uint32{13, 14, 15, 16}, sent())
+}
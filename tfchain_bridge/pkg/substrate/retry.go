@@ -0,0 +1,73 @@
+package substrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// maxQueryElapsedTime is how long a tfchain query is retried on transient errors
+const maxQueryElapsedTime = 2 * time.Minute
+
+// IsTransientError returns true if a tfchain rpc call failed because of the connection
+// to the node, retrying such a call can succeed while other errors are definitive
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// CheckMinted returns whether the mint transaction with txID is executed already. A mint
+// transaction that is not found is not minted yet, transient errors are retried with backoff
+// and any other error is returned as is.
+func (s *SubstrateClient) CheckMinted(ctx context.Context, txID string) (bool, error) {
+	return checkMinted(ctx, txID, s.IsMintedAlready)
+}
+
+// checkMinted asks isMinted whether the mint transaction with txID is executed until it answers
+// without a transient error
+func checkMinted(ctx context.Context, txID string, isMinted func(txID string) (bool, error)) (bool, error) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = maxQueryElapsedTime
+
+	var minted bool
+	err := backoff.RetryNotify(func() error {
+		var err error
+		minted, err = isMinted(txID)
+		if errors.Is(err, substrate.ErrMintTransactionNotFound) {
+			minted = false
+			return nil
+		}
+		if err != nil && !IsTransientError(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(bo, ctx), func(err error, d time.Duration) {
+		log.Warn().Err(err).Str("tx_id", txID).Dur("retry_in", d).Msg("transient error while checking mint transaction")
+	})
+	if IsTransientError(err) {
+		return false, pkg.Transient(err)
+	}
+
+	return minted, err
+}
@@ -0,0 +1,88 @@
+package substrate
+
+import (
+	"context"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+)
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "connection closed", err: io.EOF, transient: true},
+		{name: "connection reset", err: errors.Wrap(syscall.ECONNRESET, "read"), transient: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, transient: true},
+		{name: "timeout", err: context.DeadlineExceeded, transient: true},
+		{name: "not found", err: substrate.ErrMintTransactionNotFound},
+		{name: "other", err: errors.New("module error")},
+		{name: "nil"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if transient := IsTransientError(test.err); transient != test.transient {
+				t.Errorf("expected transient %t, got %t", test.transient, transient)
+			}
+		})
+	}
+}
+
+func TestCheckMinted(t *testing.T) {
+	failure := errors.New("module error")
+
+	tests := []struct {
+		name    string
+		results []error
+		minted  bool
+		err     error
+		calls   int
+	}{
+		{name: "minted", results: []error{nil}, minted: true, calls: 1},
+		{name: "not minted yet", results: []error{substrate.ErrMintTransactionNotFound}, calls: 1},
+		{name: "transient error", results: []error{io.EOF, nil}, minted: true, calls: 2},
+		{name: "transient error before not found", results: []error{syscall.ECONNRESET, substrate.ErrMintTransactionNotFound}, calls: 2},
+		{name: "definitive error", results: []error{failure, nil}, err: failure, calls: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			minted, err := checkMinted(context.Background(), "tx", func(txID string) (bool, error) {
+				err := test.results[calls]
+				calls++
+				return err == nil, err
+			})
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if minted != test.minted {
+				t.Errorf("expected minted %t, got %t", test.minted, minted)
+			}
+			if calls != test.calls {
+				t.Errorf("expected %d calls, got %d", test.calls, calls)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		_, err := checkMinted(ctx, "tx", func(txID string) (bool, error) {
+			calls++
+			cancel()
+			return false, io.EOF
+		})
+		if err == nil {
+			t.Fatal("expected the check to stop when the context is cancelled")
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+}
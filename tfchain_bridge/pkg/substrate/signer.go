@@ -0,0 +1,177 @@
+package substrate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/substrate-client"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"github.com/vedhavyas/go-subkey"
+	"golang.org/x/crypto/blake2b"
+)
+
+// remoteSignerTimeout bounds a single request to a remote signer
+const remoteSignerTimeout = 10 * time.Second
+
+// ErrRemoteKey is returned when the private key of a remote signer is requested
+var ErrRemoteKey = errors.New("key is held by a remote signer")
+
+// NewSigner creates the identity the bridge extrinsics are signed with, a remote signer
+// if a signer url is set and the sr25519 key of the tfchain seed otherwise
+func NewSigner(config *pkg.BridgeConfig) (substrate.Identity, error) {
+	if config.TfchainSignerURL == "" {
+		return substrate.NewIdentityFromSr25519Phrase(config.TfchainSeed)
+	}
+
+	account, err := substrate.FromAddress(config.TfchainSignerAddress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid tfchain signer address %q", config.TfchainSignerAddress)
+	}
+
+	return &remoteSigner{
+		url:     config.TfchainSignerURL,
+		account: account,
+		client:  &http.Client{Timeout: remoteSignerTimeout},
+	}, nil
+}
+
+// NewProxySigner creates the identity of the proxy account paying the fees of the bridge extrinsics,
+// nil is returned when no proxy is configured
+func NewProxySigner(config *pkg.BridgeConfig) (substrate.Identity, error) {
+	if config.TfchainProxySeed == "" {
+		return nil, nil
+	}
+	identity, err := substrate.NewIdentityFromSr25519Phrase(config.TfchainProxySeed)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tfchain proxy seed")
+	}
+	return identity, nil
+}
+
+// remoteSigner is an sr25519 identity whose key is held by a remote signing service or vault,
+// the service receives the address and the hex encoded payload and returns the hex encoded signature
+type remoteSigner struct {
+	url     string
+	account substrate.AccountID
+	client  *http.Client
+}
+
+type remoteSignRequest struct {
+	Address string `json:"address"`
+	Payload string `json:"payload"`
+}
+
+type remoteSignResponse struct {
+	Signature string `json:"signature"`
+}
+
+func (s *remoteSigner) KeyPair() (subkey.KeyPair, error) {
+	return nil, ErrRemoteKey
+}
+
+// Sign signs data like the in process sr25519 identity, payloads longer than 256 bytes are hashed
+// first so the remote signer signs exactly what the chain verifies
+func (s *remoteSigner) Sign(data []byte) ([]byte, error) {
+	if len(data) > 256 {
+		h := blake2b.Sum256(data)
+		data = h[:]
+	}
+
+	body, err := json.Marshal(remoteSignRequest{Address: s.Address(), Payload: hex.EncodeToString(data)})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode sign request")
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, pkg.Transient(errors.Wrap(err, "failed to reach remote signer"))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer responded with status %d", resp.StatusCode)
+	}
+
+	var signed remoteSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return nil, errors.Wrap(err, "failed to decode sign response")
+	}
+
+	signature, err := hex.DecodeString(signed.Signature)
+	if err != nil || len(signature) != 64 {
+		return nil, fmt.Errorf("remote signer returned an invalid signature")
+	}
+
+	return signature, nil
+}
+
+func (s *remoteSigner) Type() string {
+	return "sr25519"
+}
+
+func (s *remoteSigner) MultiSignature(sig []byte) types.MultiSignature {
+	return types.MultiSignature{IsSr25519: true, AsSr25519: types.NewSignature(sig)}
+}
+
+func (s *remoteSigner) Address() string {
+	return s.account.String()
+}
+
+func (s *remoteSigner) PublicKey() []byte {
+	return s.account.PublicKey()
+}
+
+func (s *remoteSigner) URI() string {
+	return ""
+}
+
+// signWithIdentity signs ext with identity, it does the same as types.Extrinsic.Sign which only
+// accepts a keyring with the private key
+func signWithIdentity(ext *types.Extrinsic, identity substrate.Identity, o types.SignatureOptions) error {
+	if ext.Type() != types.ExtrinsicVersion4 {
+		return fmt.Errorf("unsupported extrinsic version: %v", ext.Version)
+	}
+
+	method, err := types.Encode(ext.Method)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode extrinsic method")
+	}
+
+	payload, err := types.Encode(types.ExtrinsicPayloadV4{
+		ExtrinsicPayloadV3: types.ExtrinsicPayloadV3{
+			Method:      method,
+			Era:         o.Era,
+			Nonce:       o.Nonce,
+			Tip:         o.Tip,
+			SpecVersion: o.SpecVersion,
+			GenesisHash: o.GenesisHash,
+			BlockHash:   o.BlockHash,
+		},
+		TransactionVersion: o.TransactionVersion,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode extrinsic payload")
+	}
+
+	sig, err := identity.Sign(payload)
+	if err != nil {
+		return err
+	}
+
+	ext.Signature = types.ExtrinsicSignatureV4{
+		Signer:    types.NewMultiAddressFromAccountID(identity.PublicKey()),
+		Signature: identity.MultiSignature(sig),
+		Era:       o.Era,
+		Nonce:     o.Nonce,
+		Tip:       o.Tip,
+	}
+	ext.Version |= types.ExtrinsicBitSigned
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package substrate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/signature"
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+	"golang.org/x/crypto/blake2b"
+)
+
+// aliceAddress is the address of the well known development account
+const aliceAddress = "5GrwvaEF5zXb26Fz9rcQpDWS57CtERHpNehXCPcNoHGKutQY"
+
+// fakeRemoteSigner answers with a deterministic signature, the blake2b hash of the payload repeated twice
+type fakeRemoteSigner struct {
+	*httptest.Server
+	payloads [][]byte
+	response string
+}
+
+func newFakeRemoteSigner(t *testing.T) *fakeRemoteSigner {
+	signer := &fakeRemoteSigner{}
+	signer.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		if request.Address != aliceAddress {
+			t.Errorf("expected a request for %s, got %s", aliceAddress, request.Address)
+		}
+		payload, err := hex.DecodeString(request.Payload)
+		if err != nil {
+			t.Error(err)
+		}
+		signer.payloads = append(signer.payloads, payload)
+
+		response := signer.response
+		if response == "" {
+			response = hex.EncodeToString(fakeSignature(payload))
+		}
+		if err := json.NewEncoder(w).Encode(remoteSignResponse{Signature: response}); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(signer.Close)
+	return signer
+}
+
+func fakeSignature(payload []byte) []byte {
+	h := blake2b.Sum256(payload)
+	return append(h[:], h[:]...)
+}
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		name   string
+		config pkg.BridgeConfig
+		remote bool
+		err    bool
+	}{
+		{name: "seed", config: pkg.BridgeConfig{TfchainSeed: signature.TestKeyringPairAlice.URI}},
+		{name: "remote", config: pkg.BridgeConfig{TfchainSignerURL: "http://signer", TfchainSignerAddress: aliceAddress}, remote: true},
+		{name: "remote without address", config: pkg.BridgeConfig{TfchainSignerURL: "http://signer"}, err: true},
+		{name: "remote with invalid address", config: pkg.BridgeConfig{TfchainSignerURL: "http://signer", TfchainSignerAddress: "5GrwvaEF"}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			identity, err := NewSigner(&test.config)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if identity.Address() != aliceAddress {
+				t.Errorf("expected address %s, got %s", aliceAddress, identity.Address())
+			}
+			if _, err := identity.KeyPair(); errors.Is(err, ErrRemoteKey) != test.remote {
+				t.Errorf("expected remote key %t, got %v", test.remote, err)
+			}
+		})
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	short := []byte("payload")
+	long := bytes.Repeat([]byte{0x01}, 300)
+	longHash := blake2b.Sum256(long)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		response string
+		closed   bool
+		sent     []byte
+		err      bool
+	}{
+		{name: "short payload", data: short, sent: short},
+		{name: "long payload is hashed", data: long, sent: longHash[:]},
+		{name: "invalid signature", data: short, response: "abcd", sent: short, err: true},
+		{name: "not hex", data: short, response: "signature", sent: short, err: true},
+		{name: "unreachable", data: short, closed: true, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remote := newFakeRemoteSigner(t)
+			remote.response = test.response
+			if test.closed {
+				remote.Close()
+			}
+			identity, err := NewSigner(&pkg.BridgeConfig{TfchainSignerURL: remote.URL, TfchainSignerAddress: aliceAddress})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sig, err := identity.Sign(test.data)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.closed && !pkg.IsTransient(err) {
+				t.Errorf("expected an unreachable signer to be transient, got %v", err)
+			}
+			if test.sent != nil && (len(remote.payloads) != 1 || !bytes.Equal(remote.payloads[0], test.sent)) {
+				t.Errorf("expected the signer to receive %x, got %x", test.sent, remote.payloads)
+			}
+			if err == nil && !bytes.Equal(sig, fakeSignature(test.sent)) {
+				t.Errorf("expected the signature of the remote signer, got %x", sig)
+			}
+		})
+	}
+}
+
+func TestSignExtrinsicWithRemoteSigner(t *testing.T) {
+	remote := newFakeRemoteSigner(t)
+	identity, err := NewSigner(&pkg.BridgeConfig{TfchainSignerURL: remote.URL, TfchainSignerAddress: aliceAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SubstrateClient{identity: identity, options: ExtrinsicOptions{Tip: 5000}}
+
+	call := types.Call{CallIndex: types.CallIndex{SectionIndex: 35, MethodIndex: 1}, Args: types.Args{0x01}}
+	ext, err := s.signExtrinsic(call, types.SignatureOptions{
+		Era:         types.ExtrinsicEra{IsImmortalEra: true},
+		Nonce:       types.NewUCompactFromUInt(7),
+		SpecVersion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(remote.payloads) != 1 {
+		t.Fatalf("expected the payload to be signed remotely once, got %d requests", len(remote.payloads))
+	}
+	if !ext.IsSigned() || ext.Signature.Signer.AsID != types.NewAccountID(identity.PublicKey()) {
+		t.Fatal("expected the extrinsic to be signed by the remote account")
+	}
+	if !ext.Signature.Signature.IsSr25519 || !bytes.Equal(ext.Signature.Signature.AsSr25519[:], fakeSignature(remote.payloads[0])) {
+		t.Error("expected the extrinsic to carry the remote signature")
+	}
+
+	// signing the same extrinsic again gives the same deterministic signature
+	again, err := s.signExtrinsic(call, types.SignatureOptions{
+		Era:         types.ExtrinsicEra{IsImmortalEra: true},
+		Nonce:       types.NewUCompactFromUInt(7),
+		SpecVersion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Signature.Signature.AsSr25519 != ext.Signature.Signature.AsSr25519 {
+		t.Error("expected the same payload to be signed")
+	}
+}
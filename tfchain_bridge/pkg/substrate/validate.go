@@ -0,0 +1,66 @@
+package substrate
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/threefoldtech/tfchain_bridge/pkg"
+)
+
+// stellarTxHashLength is the length of a hex encoded stellar transaction hash
+const stellarTxHashLength = 64
+
+func malformed(eventType string, reason string, event interface{}) pkg.MalformedEvent {
+	return pkg.MalformedEvent{
+		Type:   eventType,
+		Reason: reason,
+		Event:  fmt.Sprintf("%+v", event),
+	}
+}
+
+func validateStellarTxHash(hash string) string {
+	if len(hash) != stellarTxHashLength {
+		return "invalid transaction hash length"
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "transaction hash is not hex encoded"
+	}
+	return ""
+}
+
+func validateWithdrawCreated(e WithdrawCreatedEvent) string {
+	if e.Target == "" {
+		return "empty target"
+	}
+	if e.Amount == 0 {
+		return "zero amount"
+	}
+	return ""
+}
+
+func validateWithdrawExpired(e WithdrawExpiredEvent) string {
+	if e.Target == "" {
+		return "empty target"
+	}
+	if e.Amount == 0 {
+		return "zero amount"
+	}
+	return ""
+}
+
+func validateRefundReady(e RefundTransactionReadyEvent) string {
+	return validateStellarTxHash(e.Hash)
+}
+
+func validateRefundExpired(e RefundTransactionExpiredEvent) string {
+	if reason := validateStellarTxHash(e.Hash); reason != "" {
+		return reason
+	}
+	if e.Target == "" {
+		return "empty target"
+	}
+	if e.Amount == 0 {
+		return "zero amount"
+	}
+	return ""
+}
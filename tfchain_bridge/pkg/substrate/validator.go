@@ -0,0 +1,87 @@
+package substrate
+
+import (
+	"sync"
+
+	"github.com/centrifuge/go-substrate-rpc-client/v4/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/substrate-client"
+)
+
+// submissionGate blocks extrinsic submissions while the bridge account is not a validator
+type submissionGate struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{}
+}
+
+func newSubmissionGate() *submissionGate {
+	return &submissionGate{resume: make(chan struct{})}
+}
+
+func (g *submissionGate) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.paused {
+		g.paused = true
+		g.resume = make(chan struct{})
+	}
+}
+
+func (g *submissionGate) unpause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		g.paused = false
+		close(g.resume)
+	}
+}
+
+// wait blocks until submissions are resumed
+func (g *submissionGate) wait() {
+	g.mu.Lock()
+	paused, resume := g.paused, g.resume
+	g.mu.Unlock()
+
+	if paused {
+		log.Warn().Msg("extrinsic submissions are paused until the account is a bridge validator again")
+		<-resume
+	}
+}
+
+// IsBridgeValidator checks whether the account of the client is a validator of the bridge
+func (s *SubstrateClient) IsBridgeValidator() (bool, error) {
+	return s.IsValidator(s.identity)
+}
+
+// PauseSubmissions holds back all extrinsic submissions until ResumeSubmissions is called
+func (s *SubstrateClient) PauseSubmissions() {
+	s.gate.pause()
+}
+
+// ResumeSubmissions lets extrinsic submissions held back by PauseSubmissions through
+func (s *SubstrateClient) ResumeSubmissions() {
+	s.gate.unpause()
+}
+
+// GetBridgeValidators returns the accounts that are validators of the bridge on chain
+func (s *SubstrateClient) GetBridgeValidators() ([]substrate.AccountID, error) {
+	cl, meta, err := s.GetClient()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := types.CreateStorageKey(meta, "TFTBridgeModule", "Validators")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create storage key")
+	}
+
+	var validators []substrate.AccountID
+	if _, err := cl.RPC.State.GetStorageLatest(key, &validators); err != nil {
+		return nil, errors.Wrap(err, "failed to lookup bridge validators")
+	}
+	return validators, nil
+}
@@ -0,0 +1,38 @@
+package substrate
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubmissionGate(t *testing.T) {
+	gate := newSubmissionGate()
+	gate.wait()
+
+	gate.pause()
+	passed := make(chan struct{})
+	go func() {
+		gate.wait()
+		close(passed)
+	}()
+
+	select {
+	case <-passed:
+		t.Fatal("a submission passed the paused gate")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	gate.unpause()
+	select {
+	case <-passed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the submission was not let through once resumed")
+	}
+
+	// pausing and resuming twice is harmless
+	gate.pause()
+	gate.pause()
+	gate.unpause()
+	gate.unpause()
+	gate.wait()
+}
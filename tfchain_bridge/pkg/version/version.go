@@ -0,0 +1,19 @@
+// Package version holds the build information of the bridge, set at build time with
+//
+//	go build -ldflags "-X github.com/threefoldtech/tfchain_bridge/pkg/version.Version=v1.0.0 ..."
+package version
+
+import "fmt"
+
+var (
+	// Version is the release version of the bridge
+	Version = "dev"
+	// Commit is the git commit the bridge is built from
+	Commit = "unknown"
+	// BuildDate is the date the bridge is built on
+	BuildDate = "unknown"
+)
+
+func String() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", Version, Commit, BuildDate)
+}